@@ -0,0 +1,212 @@
+package chaincfg
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// Params defines a NovaChain network: its genesis block, consensus rules,
+// address encoding and how nodes find each other. Nodes on different
+// networks refuse each other in the handshake.
+type Params struct {
+	Name string
+	// ChainID is sent in the handshake.
+	ChainID string
+	// DataDir is the directory below .chain holding the data of the
+	// network. Mainnet keeps using .chain itself.
+	DataDir string
+
+	GenesisTimestamp int64
+	GenesisNBits     uint32
+	// GenesisPubKey signs the genesis coinbase input, GenesisPubKeyHash
+	// receives GenesisReward base units. Both are hex encoded.
+	GenesisPubKey     string
+	GenesisPubKeyHash string
+	GenesisReward     int64
+
+	// PowLimitBits is the easiest target a block may have.
+	PowLimitBits uint32
+	// PowNoRetargeting keeps every block at the genesis target.
+	PowNoRetargeting   bool
+	AdjustmentInterval int64
+	TargetBlockTime    int64 // seconds
+	HalvingInterval    int64
+	// MaxTimestampDrift is how many seconds a block may be ahead of the
+	// local clock.
+	MaxTimestampDrift int64
+
+	// MineOnDemand lets blocks be generated over RPC.
+	MineOnDemand bool
+
+	// IntegerAmountHeight is the first height whose transactions encode
+	// output values as integer base units instead of float64 coins.
+	IntegerAmountHeight int64
+	// HeaderActivationHeight is the first height whose proof of work
+	// commits to the serialized block header.
+	HeaderActivationHeight int64
+	// ScriptHeight is the first height whose outputs may be locked by
+	// scripts. Script signatures use the binary sighash, so it does not
+	// take effect before IntegerAmountHeight.
+	ScriptHeight int64
+
+	AddressVersion byte
+	// ScriptAddressVersion prefixes pay-to-script-hash addresses. It must
+	// differ from the AddressVersion of every network, see
+	// IsScriptAddressVersion.
+	ScriptAddressVersion byte
+	// HDCoinType is the coin type level of HD wallet paths. Test networks
+	// share 1.
+	HDCoinType uint32
+
+	// NoDiscovery turns off bootstrap peers and the DHT, peers only
+	// connect when told to.
+	NoDiscovery    bool
+	Rendezvous     string
+	BootstrapPeers []string
+}
+
+const (
+	genesisPubKey     = "5d5807642aea55229a534a596b0b98c76346abccf85c83d17e7e80cfc9eef4682c85ff581cc4ca8d26244e9d3d9ed0695241c76ac288bb3a9b3b7802da0db4b7"
+	genesisPubKeyHash = "d6c5f23076469b1ee99661d91e76c0c1aaabe8e3"
+	genesisReward     = 11_111_111_111_111_110
+)
+
+var MainNetParams = Params{
+	Name:    "mainnet",
+	ChainID: "novachain",
+	DataDir: "",
+
+	GenesisTimestamp:  1758441999,
+	GenesisNBits:      0x1f00ffff,
+	GenesisPubKey:     genesisPubKey,
+	GenesisPubKeyHash: genesisPubKeyHash,
+	GenesisReward:     genesisReward,
+
+	PowLimitBits:       0x1f00ffff,
+	AdjustmentInterval: 100,
+	TargetBlockTime:    60,
+	HalvingInterval:    210000,
+	MaxTimestampDrift:  60,
+
+	IntegerAmountHeight:    50_000,
+	HeaderActivationHeight: 50_000,
+	ScriptHeight:           60_000,
+
+	AddressVersion:       0x00,
+	ScriptAddressVersion: 0x05,
+	HDCoinType:           7777,
+
+	Rendezvous: "room-chain",
+	BootstrapPeers: []string{
+		"/ip4/103.139.154.23/tcp/9000/p2p/Qmb51pbTY5Nu7ERPJQLyK7kMQ96JQTSyPdxyWLcW4Zoq58",
+		"/ip4/103.139.154.23/tcp/9001/p2p/12D3KooWDuuLTYMT9jy6RukawRj4ZaNd1wzEtq3kTXTevVwP5Lhq",
+	},
+}
+
+// TestNetParams is a public test network with mainnet rules, active from
+// genesis on, and its own genesis, addresses and rendezvous.
+var TestNetParams = Params{
+	Name:    "testnet",
+	ChainID: "novachain-testnet",
+	DataDir: "testnet",
+
+	GenesisTimestamp:  1760000000,
+	GenesisNBits:      0x1f00ffff,
+	GenesisPubKey:     genesisPubKey,
+	GenesisPubKeyHash: genesisPubKeyHash,
+	GenesisReward:     genesisReward,
+
+	PowLimitBits:       0x1f00ffff,
+	AdjustmentInterval: 100,
+	TargetBlockTime:    60,
+	HalvingInterval:    210000,
+	MaxTimestampDrift:  60,
+
+	IntegerAmountHeight:    0,
+	HeaderActivationHeight: 0,
+	ScriptHeight:           0,
+
+	AddressVersion:       0x6f,
+	ScriptAddressVersion: 0x3a,
+	HDCoinType:           1,
+
+	Rendezvous:     "room-chain-testnet",
+	BootstrapPeers: []string{},
+}
+
+// RegTestParams is a local test network whose blocks are found with a
+// handful of hashes and whose difficulty never changes. Blocks are generated
+// over RPC, so their timestamps may run ahead of the clock by two hours.
+var RegTestParams = Params{
+	Name:    "regtest",
+	ChainID: "novachain-regtest",
+	DataDir: "regtest",
+
+	GenesisTimestamp:  1760000000,
+	GenesisNBits:      0x207fffff,
+	GenesisPubKey:     genesisPubKey,
+	GenesisPubKeyHash: genesisPubKeyHash,
+	GenesisReward:     genesisReward,
+
+	PowLimitBits:       0x207fffff,
+	PowNoRetargeting:   true,
+	AdjustmentInterval: 100,
+	TargetBlockTime:    60,
+	HalvingInterval:    150,
+	MaxTimestampDrift:  2 * 60 * 60,
+
+	MineOnDemand: true,
+
+	IntegerAmountHeight:    0,
+	HeaderActivationHeight: 0,
+	ScriptHeight:           0,
+
+	AddressVersion:       0x6f,
+	ScriptAddressVersion: 0x3a,
+	HDCoinType:           1,
+
+	NoDiscovery:    true,
+	Rendezvous:     "room-chain-regtest",
+	BootstrapPeers: []string{},
+}
+
+var networks = []*Params{&MainNetParams, &TestNetParams, &RegTestParams}
+
+// ByName returns the params of the network called name.
+func ByName(name string) (*Params, error) {
+	for _, params := range networks {
+		if strings.EqualFold(params.Name, name) {
+			return params, nil
+		}
+	}
+
+	return nil, fmt.Errorf("unknown network %q, want one of %s", name, strings.Join(Names(), ", "))
+}
+
+func Names() []string {
+	names := make([]string, 0, len(networks))
+	for _, params := range networks {
+		names = append(names, params.Name)
+	}
+
+	return names
+}
+
+// IsScriptAddressVersion reports whether version prefixes pay-to-script-hash
+// addresses on some network. Outputs are built from addresses alone, so the
+// two kinds must be told apart without knowing the network.
+func IsScriptAddressVersion(version byte) bool {
+	for _, params := range networks {
+		if params.ScriptAddressVersion == version {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ChainDir returns the .chain directory of the network below root.
+func (p *Params) ChainDir(root string) string {
+	return filepath.Join(root, ".chain", p.DataDir)
+}
@@ -81,10 +81,14 @@ func Start(cli *utilCmd.CommandLine, configPath string) {
 			startNodeProcess(cfg.InstanceID, cfg.Port, cfg.MinerAddress, true, cfg.SeedPeer, cfg.ChainData, cfg.LogFile)
 
 		case 3:
-			cli.CreateWallet()
+			if _, err := cli.CreateWallet(""); err != nil {
+				fmt.Println("❌ Create wallet failed:", err)
+			}
 
 		case 4:
-			cli.ListWallet()
+			if err := cli.ListWallet(""); err != nil {
+				fmt.Println("❌ List wallets failed:", err)
+			}
 
 		case 5:
 			fmt.Println("👋 Exiting...")
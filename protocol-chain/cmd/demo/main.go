@@ -1,6 +1,7 @@
 package main
 
 import (
+	"core-blockchain/chaincfg"
 	cui "core-blockchain/cmd/demo/CUI"
 	utilCmd "core-blockchain/cmd/utils"
 	"core-blockchain/common/env"
@@ -30,14 +31,17 @@ func main() {
 		isSeedPeer bool
 		chainData  string
 		LogFile    string
+		netName    string
 	)
 
 	cli := utilCmd.CommandLine{
 		Blockchain: &blockchain.Blockchain{
 			Database:   nil,
 			InstanceId: instanceID,
+			Params:     &chaincfg.MainNetParams,
 		},
-		P2P: nil,
+		P2P:    nil,
+		Params: &chaincfg.MainNetParams,
 	}
 
 	// -----------------------
@@ -74,22 +78,229 @@ Example:
 	walletCmd := &cobra.Command{
 		Use:   "wallet",
 		Short: "Manage wallets",
-		Long:  `Create, list, or check wallet balances`,
+		Long:  `Create, list, or check wallet balances, and encrypt the wallet file`,
 	}
 
+	var unlockTimeout int64
+
+	unlockCmd := &cobra.Command{
+		Use:   "unlock",
+		Short: "Unlock the wallet of a running node",
+		Long: `Unlock the encrypted wallet file of a running node so its wallet RPCs
+can sign, until the timeout passes or wallet lock is run. The node must run
+with --RPC over http.
+
+Example:
+  novachain wallet unlock --Timeout 300 --RPC-Port 9000`,
+		Run: func(cmd *cobra.Command, args []string) {
+			passphrase, err := readPassphrase("Wallet passphrase: ")
+			if err != nil {
+				log.Fatal(err)
+			}
+
+			var res utilCmd.WalletUnlockResponse
+			params := []any{map[string]any{"passphrase": passphrase, "timeout": unlockTimeout}}
+			if err := jsonrpc.Call(rpcAddress+":"+rpcPort, "API.WalletUnlock", params, &res); err != nil {
+				log.Fatal(err)
+			}
+			if res.Error != nil {
+				log.Fatal(res.Error.Message)
+			}
+
+			log.Infof("Wallet unlocked until %s", time.Unix(res.Until, 0).Format(time.RFC3339))
+		},
+	}
+	unlockCmd.Flags().Int64Var(&unlockTimeout, "Timeout", 60, "Seconds to stay unlocked")
+
+	var withMnemonic bool
+
+	newCmd := &cobra.Command{
+		Use:     "new",
+		Aliases: []string{"create"},
+		Short:   "Create a new wallet",
+		Long: `Create a new wallet. With --Mnemonic the wallet file becomes an HD wallet:
+a 12 word mnemonic is shown once and every later wallet is derived from it.
+Write the mnemonic down, it restores all of them with wallet restore.
+
+Example:
+  novachain wallet create --Mnemonic`,
+		Run: func(cmd *cobra.Command, args []string) {
+			err := withPassphrase(func(passphrase string) error {
+				if !withMnemonic {
+					_, err := cli.CreateWallet(passphrase)
+					return err
+				}
+
+				mnemonic, _, err := cli.CreateHDWallet(passphrase)
+				if err != nil {
+					return err
+				}
+				fmt.Printf("Mnemonic: %s\n", mnemonic)
+				return nil
+			})
+			if err != nil {
+				log.Fatal(err)
+			}
+		},
+	}
+	newCmd.Flags().BoolVar(&withMnemonic, "Mnemonic", false, "Create an HD wallet backed by a mnemonic")
+
+	restoreCmd := &cobra.Command{
+		Use:   "restore",
+		Short: "Restore HD wallets from a mnemonic",
+		Long: `Restore the HD wallets of a mnemonic into the wallet file. With
+--InstanceId the UTXO set of that instance is scanned for the addresses in
+use, stopping after 20 unused ones in a row. The node must not be running.
+
+Example:
+  novachain wallet restore --InstanceId 1001`,
+		Run: func(cmd *cobra.Command, args []string) {
+			mnemonic, err := readPassphrase("Mnemonic: ")
+			if err != nil {
+				log.Fatal(err)
+			}
+
+			cli.Blockchain.InstanceId = instanceID
+			err = withPassphrase(func(passphrase string) error {
+				found, err := cli.RestoreWallet(mnemonic, passphrase, chainData)
+				if err != nil {
+					return err
+				}
+				log.Infof("Wallet restored, found %d used addresses", found)
+				return nil
+			})
+			if err != nil {
+				log.Fatal(err)
+			}
+		},
+	}
+
+	var pubKey string
+
+	importPubKeyCmd := &cobra.Command{
+		Use:   "importpubkey",
+		Short: "Watch the address of a public key",
+		Long: `Add the address of a hex encoded public key to the wallet file as
+watch-only. Its balance and history can be queried, but it cannot sign.
+
+Example:
+  novachain wallet importpubkey --PubKey <hex_public_key>`,
+		Run: func(cmd *cobra.Command, args []string) {
+			if pubKey == "" {
+				log.Fatal("--PubKey flag is required")
+			}
+			res := cli.ImportPubKey(pubKey)
+			if res.Error != nil {
+				log.Fatal(res.Error.Message)
+			}
+			log.Infof("Watching address %s", res.Address)
+		},
+	}
+	importPubKeyCmd.Flags().StringVar(&pubKey, "PubKey", "", "Hex encoded public key")
+
+	var required int
+	var pubKeys []string
+
+	createMultiSigCmd := &cobra.Command{
+		Use:   "createmultisig",
+		Short: "Create a multisig pay-to-script-hash address",
+		Long: `Print the address of a script requiring --Required signatures of the
+hex encoded --PubKeys, in that order, along with the redeem script that
+spends its outputs. Script addresses are valid from the script activation
+height on. To spend them, pass the redeem script to the UpdatePSBT RPC and
+sign the PSBT with the wallets of the keys.
+
+Example:
+  novachain wallet createmultisig --Required 2 --PubKeys <key1>,<key2>,<key3>`,
+		Run: func(cmd *cobra.Command, args []string) {
+			res := cli.CreateMultiSig(required, pubKeys)
+			if res.Error != nil {
+				log.Fatal(res.Error.Message)
+			}
+			fmt.Printf("Address: %s\nRedeem script: %s\n", res.Address, res.RedeemScript)
+		},
+	}
+	createMultiSigCmd.Flags().IntVar(&required, "Required", 1, "Signatures required")
+	createMultiSigCmd.Flags().StringSliceVar(&pubKeys, "PubKeys", nil, "Hex encoded public keys")
+
 	walletCmd.AddCommand(
+		newCmd,
+		restoreCmd,
 		&cobra.Command{
-			Use:   "new",
-			Short: "Create a new wallet",
+			Use:   "importaddress",
+			Short: "Watch an address without its private key",
+			Long: `Add an address to the wallet file as watch-only. Its balance and history
+can be queried, but it cannot sign.
+
+Example:
+  novachain wallet importaddress --Address <wallet_address>`,
 			Run: func(cmd *cobra.Command, args []string) {
-				cli.CreateWallet()
+				if address == "" {
+					log.Fatal("--Address flag is required")
+				}
+				res := cli.ImportAddress(address)
+				if res.Error != nil {
+					log.Fatal(res.Error.Message)
+				}
+				log.Infof("Watching address %s", res.Address)
 			},
 		},
+		importPubKeyCmd,
+		createMultiSigCmd,
 		&cobra.Command{
 			Use:   "list",
 			Short: "List all wallet addresses",
 			Run: func(cmd *cobra.Command, args []string) {
-				cli.ListWallet()
+				if err := withPassphrase(cli.ListWallet); err != nil {
+					log.Fatal(err)
+				}
+			},
+		},
+		&cobra.Command{
+			Use:   "encrypt",
+			Short: "Encrypt the wallet file with a passphrase",
+			Run: func(cmd *cobra.Command, args []string) {
+				passphrase, err := readNewPassphrase()
+				if err != nil {
+					log.Fatal(err)
+				}
+				if err := cli.EncryptWallet(passphrase); err != nil {
+					log.Fatal(err)
+				}
+				log.Info("Wallet encrypted, unlock it to create wallets or sign")
+			},
+		},
+		&cobra.Command{
+			Use:   "changepassphrase",
+			Short: "Change the passphrase of the wallet file",
+			Run: func(cmd *cobra.Command, args []string) {
+				oldPassphrase, err := readPassphrase("Current passphrase: ")
+				if err != nil {
+					log.Fatal(err)
+				}
+				newPassphrase, err := readNewPassphrase()
+				if err != nil {
+					log.Fatal(err)
+				}
+				if err := cli.ChangeWalletPassphrase(oldPassphrase, newPassphrase); err != nil {
+					log.Fatal(err)
+				}
+				log.Info("Wallet passphrase changed")
+			},
+		},
+		unlockCmd,
+		&cobra.Command{
+			Use:   "lock",
+			Short: "Lock the wallet of a running node",
+			Run: func(cmd *cobra.Command, args []string) {
+				var res utilCmd.WalletLockResponse
+				if err := jsonrpc.Call(rpcAddress+":"+rpcPort, "API.WalletLock", []any{}, &res); err != nil {
+					log.Fatal(err)
+				}
+				if res.Error != nil {
+					log.Fatal(res.Error.Message)
+				}
+				log.Info(res.Message)
 			},
 		},
 		&cobra.Command{
@@ -111,6 +322,68 @@ Example:
 		},
 	)
 
+	// -----------------------
+	// PSBT
+	// -----------------------
+	var psbtData string
+
+	psbtCmd := &cobra.Command{
+		Use:   "psbt",
+		Short: "Sign partially signed transactions offline",
+		Long: `Work with base64 encoded partially signed transactions (PSBTs) without a
+running node. A node holding the public keys creates them with the CreatePSBT
+RPC, an offline wallet file signs them here, and the SendPSBT RPC submits the
+result.`,
+	}
+	psbtCmd.PersistentFlags().StringVar(&psbtData, "PSBT", "", "Base64 encoded PSBT")
+
+	psbtCmd.AddCommand(
+		&cobra.Command{
+			Use:   "sign",
+			Short: "Sign a PSBT with the wallet file",
+			Long: `Sign every input of a PSBT the wallet file holds the key for and print
+the signed PSBT.
+
+Example:
+  novachain psbt sign --PSBT <base64_psbt>`,
+			Run: func(cmd *cobra.Command, args []string) {
+				if psbtData == "" {
+					log.Fatal("--PSBT flag is required")
+				}
+				err := withPassphrase(func(passphrase string) error {
+					signed, count, err := cli.SignPSBTOffline(psbtData, passphrase)
+					if err != nil {
+						return err
+					}
+					log.Infof("Signed %d inputs", count)
+					fmt.Println(signed)
+					return nil
+				})
+				if err != nil {
+					log.Fatal(err)
+				}
+			},
+		},
+		&cobra.Command{
+			Use:   "decode",
+			Short: "Show the content of a PSBT",
+			Run: func(cmd *cobra.Command, args []string) {
+				if psbtData == "" {
+					log.Fatal("--PSBT flag is required")
+				}
+				res := cli.DecodePSBT(psbtData)
+				if res.Error != nil {
+					log.Fatal(res.Error.Message)
+				}
+				out, err := jsonrpc.SafeMarshalJSON(res)
+				if err != nil {
+					log.Fatal(err)
+				}
+				fmt.Println(string(out))
+			},
+		},
+	)
+
 	// -----------------------
 	// NODE
 	// -----------------------
@@ -179,6 +452,40 @@ If no flags are provided, node runs as full node by default.`,
 	nodeCmd.Flags().BoolVar(&fullNode, "Fullnode", conf.FullNode, "Run as full node")
 	nodeCmd.Flags().BoolVar(&isSeedPeer, "SeedPeer", false, "Enable seed peer discovery")
 
+	// -----------------------
+	// REINDEX
+	// -----------------------
+	var addrIndex bool
+
+	reindexCmd := &cobra.Command{
+		Use:   "reindex",
+		Short: "Build the transaction index for an existing chain",
+		Long: `Rebuild the txid → block index from the main chain. Once built, new
+blocks keep it up to date and transaction lookups use it.
+
+Required:
+  --InstanceId  Blockchain instance ID
+
+Optional:
+  --AddrIndex   Also build the address index used by API.GetAddressHistory
+                and API.GetAddressUTXOs
+
+Example:
+  novachain reindex --InstanceId 1001 --AddrIndex`,
+		Run: func(cmd *cobra.Command, args []string) {
+			if instanceID == "" {
+				log.Fatal("--InstanceId flag is required")
+			}
+			cli, err := cli.UpdateInstance(instanceID, true, LogFile)
+			if err != nil {
+				log.Fatal(err)
+			}
+			cli.Reindex(addrIndex)
+		},
+	}
+
+	reindexCmd.Flags().BoolVar(&addrIndex, "AddrIndex", false, "Also build the address index")
+
 	// -----------------------
 	// ROOT COMMAND
 	// -----------------------
@@ -199,9 +506,35 @@ Examples:
 
   4. Wallet management:
      novachain wallet new
+     novachain wallet create --Mnemonic
+     novachain wallet restore --InstanceId 1001
      novachain wallet list
+     novachain wallet importaddress --Address <wallet_address>
      novachain wallet balance --Address <wallet_address> --InstanceId 1001
+     novachain wallet encrypt
+     novachain wallet unlock --Timeout 300
+
+  5. Sign a PSBT offline:
+     novachain psbt sign --PSBT <base64_psbt>
+
+  6. Build the transaction index:
+     novachain reindex --InstanceId 1001
+
+  7. Run on a local regtest network:
+     novachain init --InstanceId 1001 --Network regtest
+     novachain startNode --Port 3000 --InstanceId 1001 --Network regtest
 `,
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			params, err := chaincfg.ByName(netName)
+			if err != nil {
+				return err
+			}
+			cli.Params = params
+			cli.Blockchain.Params = params
+			blockchain.UseParams(params)
+			log.Infof("Network: %s", params.Name)
+			return nil
+		},
 	}
 
 	rootCmd.PersistentFlags().StringVar(&address, "Address", "", "Wallet address")
@@ -212,8 +545,9 @@ Examples:
 	rootCmd.PersistentFlags().StringVar(&rpcMode, "RPC-Mode", "http", "RPC mode: http, tcp, both")
 	rootCmd.PersistentFlags().StringVar(&chainData, "ChainData", "", "Chain data")
 	rootCmd.PersistentFlags().StringVar(&LogFile, "LogFile", "", "Log data")
+	rootCmd.PersistentFlags().StringVar(&netName, "Network", chaincfg.MainNetParams.Name, "Network: mainnet, testnet, regtest")
 
-	rootCmd.AddCommand(initCmd, walletCmd, nodeCmd)
+	rootCmd.AddCommand(initCmd, walletCmd, psbtCmd, nodeCmd, reindexCmd)
 
 	if len(os.Args) == 1 {
 		cui.Start(&cli, "config.json")
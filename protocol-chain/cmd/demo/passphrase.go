@@ -0,0 +1,69 @@
+package main
+
+import (
+	"bufio"
+	"core-blockchain/wallet"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+var stdin = bufio.NewReader(os.Stdin)
+
+// readPassphrase prompts on stderr and reads a passphrase without echo from
+// a terminal, or one line from piped stdin.
+func readPassphrase(prompt string) (string, error) {
+	fmt.Fprint(os.Stderr, prompt)
+
+	fd := int(os.Stdin.Fd())
+	if term.IsTerminal(fd) {
+		passphrase, err := term.ReadPassword(fd)
+		fmt.Fprintln(os.Stderr)
+		return string(passphrase), err
+	}
+
+	line, err := stdin.ReadString('\n')
+	if err != nil && line == "" {
+		return "", err
+	}
+
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// readNewPassphrase asks for a passphrase twice.
+func readNewPassphrase() (string, error) {
+	passphrase, err := readPassphrase("New passphrase: ")
+	if err != nil {
+		return "", err
+	}
+
+	confirm, err := readPassphrase("Repeat new passphrase: ")
+	if err != nil {
+		return "", err
+	}
+
+	if passphrase != confirm {
+		return "", errors.New("passphrases do not match")
+	}
+
+	return passphrase, nil
+}
+
+// withPassphrase runs run without a passphrase first and asks for one when
+// the wallet file turns out to be encrypted.
+func withPassphrase(run func(passphrase string) error) error {
+	err := run("")
+	if !errors.Is(err, wallet.ErrWalletLocked) {
+		return err
+	}
+
+	passphrase, err := readPassphrase("Wallet passphrase: ")
+	if err != nil {
+		return err
+	}
+
+	return run(passphrase)
+}
@@ -6,11 +6,14 @@ import (
 	"core-blockchain/common/helpers"
 	"core-blockchain/common/utils"
 	blockchain "core-blockchain/core"
+	"core-blockchain/memopool"
 	"core-blockchain/p2p"
 	"core-blockchain/wallet"
 	"encoding/hex"
 	"errors"
 	"fmt"
+	"maps"
+	"sort"
 	"strconv"
 	"time"
 
@@ -24,15 +27,19 @@ var (
 	checkSumlength = conf.WalletAddressCheckSum
 )
 
+// walletSession keeps the encrypted wallet file of a running node unlocked
+// between API.WalletUnlock and API.WalletLock.
+var walletSession = &wallet.UnlockSession{}
+
 func (cli *CommandLine) CreateBlockchain(chainData string) {
 	defer helpers.RecoverAndLog()
-	if blockchain.Exists(cli.Blockchain.InstanceId) {
+	if blockchain.Exists(cli.Params, cli.Blockchain.InstanceId) {
 		log.Infof("Blockchain already exists for instance ID: %s", cli.Blockchain.InstanceId)
-		log.Info("Path: ", blockchain.GetDatabasePath(cli.Blockchain.InstanceId))
+		log.Info("Path: ", blockchain.GetDatabasePath(cli.Params, cli.Blockchain.InstanceId))
 		return
 	}
 
-	chain, err := blockchain.InitBlockchain(chainData, cli.Blockchain.InstanceId)
+	chain, err := blockchain.InitBlockchain(cli.Params, chainData, cli.Blockchain.InstanceId)
 	if err != nil {
 		log.Error(err)
 		return
@@ -73,6 +80,18 @@ func (cli *CommandLine) StartNode(logFile, chainData, listenPort, minerAddress s
 		defer chain.Database.Close()
 	}
 
+	utxos := blockchain.UTXOSet{Blockchain: chain}
+	if err := utxos.EnsureIndexed(); err != nil {
+		log.Errorf("Rebuild UTXO set with error: %v", err)
+		return
+	}
+
+	headerIndex := blockchain.HeaderIndex{Blockchain: chain}
+	if err := headerIndex.EnsureIndexed(); err != nil {
+		log.Errorf("Build header index with error: %v", err)
+		return
+	}
+
 	p2p.StartNode(logFile, chain, listenPort, minerAddress, miner, fullNode, isSeedPeer, callback)
 }
 
@@ -82,12 +101,11 @@ func (cli *CommandLine) UpdateInstance(InstanceId string, closeDbAlways bool, lo
 
 	if cli.Blockchain != nil && cli.Blockchain.Database != nil {
 		_ = cli.Blockchain.Database.Close()
-		cli.Blockchain = nil
 	}
 
-	cli.Blockchain.InstanceId = InstanceId
+	cli.Blockchain = &blockchain.Blockchain{InstanceId: InstanceId, Params: cli.Params}
 
-	if blockchain.Exists(InstanceId) {
+	if blockchain.Exists(cli.Params, InstanceId) {
 		chain, err := cli.Blockchain.ContinueBlockchain()
 		if err != nil {
 			log.Error(err)
@@ -115,10 +133,18 @@ func (cli *CommandLine) SendTx(txs []*blockchain.Transaction) SendResponse {
 		}
 	}
 
+	// A transaction may spend outputs of pool transactions and of the ones
+	// before it in txs.
+	batch := make(map[string]blockchain.Transaction, len(txs))
+
 	for _, tx := range txs {
 		txID := hex.EncodeToString(tx.ID)
 
-		if !cli.Blockchain.VerifyTransaction(tx) {
+		parents := p2p.MemoryPool.Parents(tx)
+		maps.Copy(parents, batch)
+		batch[txID] = *tx
+
+		if !cli.Blockchain.VerifyTransactionWithParents(tx, parents) {
 			log.Warnf("Verify failed: %s", txID)
 			return SendResponse{
 				Error: err.ErrInvalidArgument("Transaction invalid", txID),
@@ -131,29 +157,62 @@ func (cli *CommandLine) SendTx(txs []*blockchain.Transaction) SendResponse {
 		listTxsStr = append(listTxsStr, txID)
 	}
 
-	if cli.P2P != nil {
-		if len(listTxs) > 0 {
-			cli.P2P.Transactions <- listTxs
+	if cli.P2P == nil {
+		return SendResponse{
+			Error: err.ErrInternal("Internal error"),
+		}
+	}
+
+	// Admit to the mempool here so that policy rejections reach the caller,
+	// HandleEvents only relays what got in.
+	accepted := make([]*blockchain.Transaction, 0, len(listTxs))
+	acceptedStr := make([]string, 0, len(listTxs))
+	rejected := make([]RejectedTx, 0)
+	replaced := make([]ReplacedTx, 0)
 
-		} else {
+	for i, tx := range listTxs {
+		replacedIDs, e := cli.P2P.AcceptTx(tx)
+		if e != nil {
+			log.Infof("Transaction %s rejected: %v", listTxsStr[i], e)
+			rejected = append(rejected, RejectedTx{TxID: listTxsStr[i], Reason: e.Error()})
+			continue
+		}
+		accepted = append(accepted, tx)
+		acceptedStr = append(acceptedStr, listTxsStr[i])
+
+		for _, id := range replacedIDs {
+			replaced = append(replaced, ReplacedTx{TxID: id, ReplacedBy: listTxsStr[i]})
+		}
+	}
+
+	if len(accepted) == 0 {
+		if len(rejected) > 0 {
 			return SendResponse{
-				Message: "Empty",
-				Count:   0,
-				ListTxs: []string{},
-				Error:   nil,
+				Message:  "Rejected",
+				Count:    0,
+				ListTxs:  []string{},
+				Rejected: rejected,
+				Error:    err.ErrInvalidArgument("Transaction rejected", rejected[0].Reason),
 			}
 		}
-	} else {
+
 		return SendResponse{
-			Error: err.ErrInternal("Internal error"),
+			Message: "Empty",
+			Count:   0,
+			ListTxs: []string{},
+			Error:   nil,
 		}
 	}
 
+	cli.P2P.Transactions <- accepted
+
 	return SendResponse{
-		Message: "Send transaction successfully",
-		Count:   int64(len(listTxsStr)),
-		ListTxs: listTxsStr,
-		Error:   nil,
+		Message:  "Send transaction successfully",
+		Count:    int64(len(acceptedStr)),
+		ListTxs:  acceptedStr,
+		Rejected: rejected,
+		Replaced: replaced,
+		Error:    nil,
 	}
 }
 
@@ -162,11 +221,11 @@ func (cli *CommandLine) GetMiningTxs(verbose bool) GetMiningTxsResponse {
 	listTxs := make([]any, 0)
 
 	if verbose {
-		for _, txInfo := range p2p.MemoryPool.Queued {
+		for _, txInfo := range p2p.MemoryPool.QueuedTxs() {
 			listTxs = append(listTxs, txInfo.Transaction)
 		}
 	} else {
-		for _, txInfo := range p2p.MemoryPool.Queued {
+		for _, txInfo := range p2p.MemoryPool.QueuedTxs() {
 			listTxs = append(listTxs, hex.EncodeToString(txInfo.Transaction.ID))
 		}
 	}
@@ -202,6 +261,42 @@ func (cli *CommandLine) ComputeUTXOs() {
 	log.Infof("Rebuild DONE!!!, there are %d transactions in the UTXOs set", count)
 }
 
+func (cli *CommandLine) Reindex(withAddrIndex bool) {
+	chain, err := cli.Blockchain.ContinueBlockchain()
+	if err != nil {
+		log.Error(err)
+		return
+	}
+
+	if cli.CloseDbAlways {
+		defer chain.Database.Close()
+	}
+
+	txIndex := blockchain.TxIndex{
+		Blockchain: chain,
+	}
+	count, err := txIndex.Reindex()
+	if err != nil {
+		log.Errorf("Reindex with error: %v", err)
+		return
+	}
+	log.Infof("Reindex DONE!!!, %d transactions indexed", count)
+
+	if !withAddrIndex {
+		return
+	}
+
+	addrIndex := blockchain.AddrIndex{
+		Blockchain: chain,
+	}
+	count, err = addrIndex.Reindex()
+	if err != nil {
+		log.Errorf("Address reindex with error: %v", err)
+		return
+	}
+	log.Infof("Address reindex DONE!!!, %d transactions indexed", count)
+}
+
 func (cli *CommandLine) PrintUtxos() {
 	chain, err := cli.Blockchain.ContinueBlockchain()
 	if err != nil {
@@ -223,7 +318,10 @@ func (cli *CommandLine) PrintUtxos() {
 	for _, u := range utxos {
 		for _, out := range u.Outputs {
 			fmt.Printf("Pub_key_Hash: %x\n", out.PubKeyHash)
-			fmt.Printf("Value: %f\n", out.Value)
+			if len(out.LockingScript) > 0 {
+				fmt.Printf("Locking_Script: %s\n", blockchain.DisassembleScript(out.LockingScript))
+			}
+			fmt.Printf("Value: %s\n", blockchain.NewCoinAmountFromUnits(out.Value))
 
 			fmt.Printf("-------------------------------------------\n")
 		}
@@ -263,7 +361,7 @@ func (cli *CommandLine) PrintBestChain() {
 }
 
 func (cli *CommandLine) GetBalance(address string) BalanceResponse {
-	if !wallet.ValidateAddress(address) {
+	if !wallet.ValidateAddress(address, cli.Params) {
 		return BalanceResponse{
 			Address:   address,
 			Timestamp: time.Now().Unix(),
@@ -283,7 +381,7 @@ func (cli *CommandLine) GetBalance(address string) BalanceResponse {
 		defer chain.Database.Close()
 	}
 
-	balance := float64(0)
+	var balance int64
 	publicKeyHash := wallet.Base58Decode([]byte(address))
 
 	publicKeyHash = publicKeyHash[1 : int64(len(publicKeyHash))-checkSumlength]
@@ -312,28 +410,375 @@ func (cli *CommandLine) GetBalance(address string) BalanceResponse {
 	}
 }
 
-func (cli *CommandLine) CreateWallet() (string, error) {
+// loadWallets reads the wallet file. An encrypted one is unlocked with
+// passphrase when given, otherwise with the unlock session of the running
+// node, and stays locked when neither works.
+func (cli *CommandLine) loadWallets(cwd bool, passphrase string) (*wallet.WalletPool, error) {
+	wallets, err := wallet.InitializeWallets(cwd, cli.Params)
+	if err != nil || !wallets.IsEncrypted() {
+		return wallets, err
+	}
+
+	if passphrase != "" {
+		return wallets, wallets.Unlock(passphrase)
+	}
+
+	if err := walletSession.Open(wallets); err != nil && !errors.Is(err, wallet.ErrWalletLocked) {
+		return nil, err
+	}
+
+	return wallets, nil
+}
+
+// CreateWallet adds a wallet to the wallet file. An encrypted file needs
+// passphrase, or an unlocked node when called over RPC.
+func (cli *CommandLine) CreateWallet(passphrase string) (string, error) {
+	cli.walletMu.Lock()
+	defer cli.walletMu.Unlock()
+
 	cwd := false
-	wallets, err := wallet.InitializeWallets(cwd)
+	wallets, err := cli.loadWallets(cwd, passphrase)
 	if err != nil {
 		return "", err
 	}
-	address := wallets.AddWallet()
 
-	wallets.SaveFile(cwd)
+	address, err := wallets.AddWallet()
+	if err != nil {
+		return "", err
+	}
+
+	if err := wallets.SaveFile(cwd); err != nil {
+		return "", err
+	}
 
 	log.Infof("NEW WALLET WITH ADDRESS: %s", address)
 
 	return address, nil
 }
 
-func (cli *CommandLine) ListWallet() error {
+// CreateHDWallet gives the wallet file a new mnemonic and derives its first
+// receive wallet. The mnemonic is the only backup of the HD wallets and is
+// not stored.
+func (cli *CommandLine) CreateHDWallet(passphrase string) (string, string, error) {
+	cli.walletMu.Lock()
+	defer cli.walletMu.Unlock()
+
+	cwd := false
+	wallets, err := cli.loadWallets(cwd, passphrase)
+	if err != nil {
+		return "", "", err
+	}
+
+	mnemonic, err := wallet.NewMnemonic()
+	if err != nil {
+		return "", "", err
+	}
+
+	if err := wallets.SetMnemonic(mnemonic); err != nil {
+		return "", "", err
+	}
+
+	address, err := wallets.AddWallet()
+	if err != nil {
+		return "", "", err
+	}
+
+	if err := wallets.SaveFile(cwd); err != nil {
+		return "", "", err
+	}
+
+	log.Infof("NEW HD WALLET WITH ADDRESS: %s", address)
+
+	return mnemonic, address, nil
+}
+
+// RestoreWallet gives the wallet file the seed of mnemonic and, when the
+// chain instance exists, rescans the UTXO set for the wallets in use. It
+// derives a receive wallet when the rescan found none and returns how many
+// used wallets were found.
+func (cli *CommandLine) RestoreWallet(mnemonic, passphrase, chainData string) (int, error) {
+	cli.walletMu.Lock()
+	defer cli.walletMu.Unlock()
+
+	cwd := false
+	wallets, err := cli.loadWallets(cwd, passphrase)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := wallets.SetMnemonic(mnemonic); err != nil {
+		return 0, err
+	}
+
+	found := 0
+
+	if cli.Blockchain.InstanceId != "" && blockchain.Exists(cli.Params, cli.Blockchain.InstanceId) {
+		chain, err := cli.Blockchain.ContinueBlockchain(chainData)
+		if err != nil {
+			return 0, err
+		}
+		defer chain.Database.Close()
+
+		utxos := blockchain.UTXOSet{Blockchain: chain}
+		used, err := utxos.PubKeyHashes()
+		if err != nil {
+			return 0, err
+		}
+
+		found, err = wallets.Rescan(func(pubKeyHash []byte) bool {
+			return used[hex.EncodeToString(pubKeyHash)]
+		})
+		if err != nil {
+			return 0, err
+		}
+	} else {
+		log.Warn("No blockchain instance, skipped the rescan")
+	}
+
+	if wallets.HD.Next[wallet.ExternalChain] == 0 {
+		if _, err := wallets.AddWallet(); err != nil {
+			return 0, err
+		}
+	}
+
+	if err := wallets.SaveFile(cwd); err != nil {
+		return 0, err
+	}
+
+	return found, nil
+}
+
+// EncryptWallet encrypts the wallet file under passphrase.
+func (cli *CommandLine) EncryptWallet(passphrase string) error {
+	cli.walletMu.Lock()
+	defer cli.walletMu.Unlock()
+
+	cwd := false
+	wallets, err := wallet.InitializeWallets(cwd, cli.Params)
+	if err != nil {
+		return err
+	}
+
+	if err := wallets.Encrypt(passphrase); err != nil {
+		return err
+	}
+
+	return wallets.SaveFile(cwd)
+}
+
+// ChangeWalletPassphrase re-encrypts the wallet file under newPassphrase.
+func (cli *CommandLine) ChangeWalletPassphrase(oldPassphrase, newPassphrase string) error {
+	cli.walletMu.Lock()
+	defer cli.walletMu.Unlock()
+
 	cwd := false
-	wallets, err := wallet.InitializeWallets(cwd)
+	wallets, err := wallet.InitializeWallets(cwd, cli.Params)
 	if err != nil {
 		return err
 	}
 
+	if err := wallets.ChangePassphrase(oldPassphrase, newPassphrase); err != nil {
+		return err
+	}
+
+	// A running node holding the old key is locked from now on.
+	walletSession.Lock()
+
+	return wallets.SaveFile(cwd)
+}
+
+// WalletUnlock keeps the encrypted wallet file unlocked for timeout seconds
+// so the wallet RPCs can sign.
+func (cli *CommandLine) WalletUnlock(passphrase string, timeout int64) WalletUnlockResponse {
+	if timeout < 1 || timeout > MaxWalletUnlockTimeout {
+		return WalletUnlockResponse{Error: err.ErrInvalidArgument(fmt.Sprintf("Timeout must be between 1 and %d seconds", MaxWalletUnlockTimeout))}
+	}
+
+	wallets, e := wallet.InitializeWallets(false, cli.Params)
+	if e != nil {
+		log.Errorf("Load wallets with error: %v", e)
+		return WalletUnlockResponse{Error: err.ErrInternal("Internal error")}
+	}
+
+	until, e := walletSession.Unlock(wallets, passphrase, time.Duration(timeout)*time.Second)
+	switch {
+	case errors.Is(e, wallet.ErrWalletNotEncrypted):
+		return WalletUnlockResponse{Error: err.ErrInvalidArgument("Wallet is not encrypted")}
+	case errors.Is(e, wallet.ErrWrongPassphrase), errors.Is(e, wallet.ErrEmptyPassphrase):
+		return WalletUnlockResponse{Error: err.ErrInvalidArgument("The wallet passphrase entered was incorrect")}
+	case e != nil:
+		log.Errorf("Unlock wallet with error: %v", e)
+		return WalletUnlockResponse{Error: err.ErrInternal("Internal error")}
+	}
+
+	return WalletUnlockResponse{Until: until.Unix(), Error: nil}
+}
+
+func (cli *CommandLine) WalletLock() WalletLockResponse {
+	wallets, e := wallet.InitializeWallets(false, cli.Params)
+	if e != nil {
+		log.Errorf("Load wallets with error: %v", e)
+		return WalletLockResponse{Error: err.ErrInternal("Internal error")}
+	}
+
+	if !wallets.IsEncrypted() {
+		return WalletLockResponse{Error: err.ErrInvalidArgument("Wallet is not encrypted")}
+	}
+
+	walletSession.Lock()
+
+	return WalletLockResponse{Message: "Wallet locked", Error: nil}
+}
+
+func importResponse(address string, e error) ImportResponse {
+	switch {
+	case errors.Is(e, wallet.ErrInvalidAddress):
+		return ImportResponse{Error: err.ErrInvalidArgument("Address is invalid")}
+	case errors.Is(e, wallet.ErrInvalidPublicKey):
+		return ImportResponse{Error: err.ErrInvalidArgument("Public key is invalid")}
+	case errors.Is(e, wallet.ErrAddressInWallet):
+		return ImportResponse{Error: err.ErrInvalidArgument("Address is already in the wallet")}
+	case e != nil:
+		log.Errorf("Import watch-only address with error: %v", e)
+		return ImportResponse{Error: err.ErrInternal("Internal error")}
+	}
+
+	return ImportResponse{Address: address, Error: nil}
+}
+
+// ImportAddress adds address to the wallet file as watch-only.
+func (cli *CommandLine) ImportAddress(address string) ImportResponse {
+	cli.walletMu.Lock()
+	defer cli.walletMu.Unlock()
+
+	cwd := false
+	wallets, e := wallet.InitializeWallets(cwd, cli.Params)
+	if e == nil {
+		e = wallets.ImportAddress(address)
+	}
+	if e == nil {
+		e = wallets.SaveFile(cwd)
+	}
+
+	return importResponse(address, e)
+}
+
+// ImportPubKey adds the address of the hex encoded pubKey to the wallet
+// file as watch-only.
+func (cli *CommandLine) ImportPubKey(pubKey string) ImportResponse {
+	key, e := hex.DecodeString(pubKey)
+	if e != nil {
+		return ImportResponse{Error: err.ErrInvalidArgument("Public key is invalid")}
+	}
+
+	cli.walletMu.Lock()
+	defer cli.walletMu.Unlock()
+
+	cwd := false
+	wallets, e := wallet.InitializeWallets(cwd, cli.Params)
+	if e != nil {
+		return importResponse("", e)
+	}
+
+	address, e := wallets.ImportPubKey(key)
+	if e == nil {
+		e = wallets.SaveFile(cwd)
+	}
+
+	return importResponse(address, e)
+}
+
+// CreateMultiSig returns the pay-to-script-hash address of a required of
+// pubKeys multisig script along with that redeem script, which spending its
+// outputs needs.
+func (cli *CommandLine) CreateMultiSig(required int, pubKeys []string) MultiSigResponse {
+	keys := make([][]byte, 0, len(pubKeys))
+	for _, pubKey := range pubKeys {
+		key, e := hex.DecodeString(pubKey)
+		if e != nil || !wallet.ValidatePublicKey(key) {
+			return MultiSigResponse{Error: err.ErrInvalidArgument("Public key is invalid", pubKey)}
+		}
+		keys = append(keys, key)
+	}
+
+	script, e := blockchain.NewMultiSigScript(required, keys)
+	if e != nil {
+		return MultiSigResponse{Error: err.ErrInvalidArgument(e.Error())}
+	}
+
+	return MultiSigResponse{
+		Address:      string(wallet.ScriptHashToAddr(blockchain.ScriptHash(script), cli.Params)),
+		RedeemScript: hex.EncodeToString(script),
+		Error:        nil,
+	}
+}
+
+// GetWalletBalance sums the unspent outputs of every address of the wallet
+// file, the watch-only ones when includeWatchOnly is set.
+func (cli *CommandLine) GetWalletBalance(includeWatchOnly bool) WalletBalanceResponse {
+	wallets, e := wallet.InitializeWallets(false, cli.Params)
+	if e != nil {
+		log.Errorf("Load wallets with error: %v", e)
+		return WalletBalanceResponse{Error: err.ErrInternal("Internal error")}
+	}
+
+	chain, e := cli.Blockchain.ContinueBlockchain()
+	if e != nil {
+		log.Error(e)
+		return WalletBalanceResponse{Error: err.ErrInternal("Internal error")}
+	}
+	if cli.CloseDbAlways {
+		defer chain.Database.Close()
+	}
+
+	hashes := wallets.PubKeyHashes(includeWatchOnly)
+	utxos := blockchain.UTXOSet{Blockchain: chain}
+
+	balances, e := utxos.Balances(func(pubKeyHash []byte) bool {
+		_, ok := hashes[hex.EncodeToString(pubKeyHash)]
+		return ok
+	})
+	if e != nil {
+		log.Errorf("Get wallet balance with error: %v", e)
+		return WalletBalanceResponse{Error: err.ErrInternal("Internal error")}
+	}
+
+	res := WalletBalanceResponse{Addresses: make([]WalletAddressBalance, 0, len(hashes))}
+
+	for hash, address := range hashes {
+		entry := WalletAddressBalance{
+			Address:   address,
+			Balance:   balances[hash],
+			WatchOnly: wallets.IsWatchOnly(address),
+		}
+
+		if entry.WatchOnly {
+			res.WatchOnlyBalance += entry.Balance
+		} else {
+			res.Balance += entry.Balance
+		}
+		res.Addresses = append(res.Addresses, entry)
+	}
+
+	sort.Slice(res.Addresses, func(i, j int) bool {
+		return res.Addresses[i].Address < res.Addresses[j].Address
+	})
+
+	return res
+}
+
+// ListWallet prints every wallet with its exported private key. An encrypted
+// file needs passphrase.
+func (cli *CommandLine) ListWallet(passphrase string) error {
+	cwd := false
+	wallets, err := cli.loadWallets(cwd, passphrase)
+	if err != nil {
+		return err
+	}
+	if wallets.IsLocked() {
+		return wallet.ErrWalletLocked
+	}
+
 	addresses := wallets.GetAllAddress()
 
 	for _, address := range addresses {
@@ -342,6 +787,9 @@ func (cli *CommandLine) ListWallet() error {
 		if err != nil {
 			log.Panic("Get wallet with error: ", err)
 		}
+		if w.Path != "" {
+			fmt.Printf("Path: %s\n", w.Path)
+		}
 		privBytes := w.PrivateKey.D.Bytes()
 		privHex := hex.EncodeToString(privBytes)
 		privWebApp, _ := wallet.EncryptPrivateKeyForExport(privHex)
@@ -352,6 +800,15 @@ func (cli *CommandLine) ListWallet() error {
 
 	}
 
+	for _, address := range wallets.GetWatchOnlyAddresses() {
+		fmt.Printf("------------------------------ %s ------------------------------\n", address)
+		fmt.Println("Watch-only")
+		if pubKey := wallets.Watched[address].PublicKey; len(pubKey) > 0 {
+			fmt.Printf("PublicKey: %s\n", hex.EncodeToString(pubKey))
+		}
+		fmt.Println()
+	}
+
 	return nil
 }
 
@@ -582,10 +1039,7 @@ func (cli *CommandLine) GetAllUTXOs() *GetAllUTXOsResponse {
 	if e != nil {
 		return &GetAllUTXOsResponse{
 			Message: "Successfully",
-			Error: &err.RPCError{
-				Code:    blockchain.HALVING_INTERVAL,
-				Message: "Please try again.",
-			},
+			Error:   err.ErrInternal("Please try again."),
 		}
 	}
 
@@ -598,6 +1052,95 @@ func (cli *CommandLine) GetAllUTXOs() *GetAllUTXOsResponse {
 
 }
 
+func (cli *CommandLine) addressIndex(address string) (*blockchain.AddrIndex, []byte, func(), *err.RPCError) {
+	if !wallet.ValidateAddress(address, cli.Params) {
+		return nil, nil, nil, err.ErrInvalidArgument("Address is invalid")
+	}
+
+	chain, e := cli.Blockchain.ContinueBlockchain()
+	if e != nil {
+		log.Error(e)
+		return nil, nil, nil, err.ErrInternal("Internal error")
+	}
+
+	release := func() {
+		if cli.CloseDbAlways {
+			chain.Database.Close()
+		}
+	}
+
+	addrIndex := &blockchain.AddrIndex{Blockchain: chain}
+	if !addrIndex.Enabled() {
+		release()
+		return nil, nil, nil, err.ErrNotFound("Address index is not built, run reindex with --AddrIndex")
+	}
+
+	publicKeyHash := wallet.Base58Decode([]byte(address))
+	publicKeyHash = publicKeyHash[1 : int64(len(publicKeyHash))-checkSumlength]
+
+	return addrIndex, publicKeyHash, release, nil
+}
+
+func normalizePage(offset, limit int64) (int, int) {
+	if offset < 0 {
+		offset = 0
+	}
+	if limit <= 0 || limit > MaxAddressPageSize {
+		limit = MaxAddressPageSize
+	}
+
+	return int(offset), int(limit)
+}
+
+func (cli *CommandLine) GetAddressHistory(address string, offset, limit int64) AddressHistoryResponse {
+	addrIndex, publicKeyHash, release, rpcErr := cli.addressIndex(address)
+	if rpcErr != nil {
+		return AddressHistoryResponse{Address: address, Error: rpcErr}
+	}
+	defer release()
+
+	from, size := normalizePage(offset, limit)
+	txs, total, e := addrIndex.History(publicKeyHash, from, size)
+	if e != nil {
+		log.Errorf("Get address history with error: %v", e)
+		return AddressHistoryResponse{Address: address, Error: err.ErrInternal("Internal error")}
+	}
+
+	return AddressHistoryResponse{
+		Address: address,
+		Txs:     txs,
+		Total:   int64(total),
+		Offset:  int64(from),
+		Limit:   int64(size),
+		Error:   nil,
+	}
+}
+
+func (cli *CommandLine) GetAddressUTXOs(address string, offset, limit int64) AddressUTXOsResponse {
+	addrIndex, publicKeyHash, release, rpcErr := cli.addressIndex(address)
+	if rpcErr != nil {
+		return AddressUTXOsResponse{Address: address, Error: rpcErr}
+	}
+	defer release()
+
+	from, size := normalizePage(offset, limit)
+	utxos, total, balance, e := addrIndex.UTXOs(publicKeyHash, from, size)
+	if e != nil {
+		log.Errorf("Get address utxos with error: %v", e)
+		return AddressUTXOsResponse{Address: address, Error: err.ErrInternal("Internal error")}
+	}
+
+	return AddressUTXOsResponse{
+		Address: address,
+		UTXOs:   utxos,
+		Balance: balance,
+		Total:   int64(total),
+		Offset:  int64(from),
+		Limit:   int64(size),
+		Error:   nil,
+	}
+}
+
 func (cli *CommandLine) GetLastHeight() (int64, error) {
 	chain, e := cli.Blockchain.ContinueBlockchain()
 	if e != nil {
@@ -615,3 +1158,209 @@ func (cli *CommandLine) GetLastHeight() (int64, error) {
 
 	return lastHeight, nil
 }
+
+func (cli *CommandLine) ListBanned() ListBannedResponse {
+	if cli.P2P == nil {
+		return ListBannedResponse{Error: err.ErrInternal("Node is not running")}
+	}
+
+	banned := cli.P2P.Bans.ListBanned()
+
+	return ListBannedResponse{
+		Banned: banned,
+		Count:  int64(len(banned)),
+		Error:  nil,
+	}
+}
+
+// SetBan adds ("add") or removes ("remove") a ban on peerID. banTime is in
+// seconds, zero falls back to p2p.DefaultBanTime.
+func (cli *CommandLine) SetBan(peerID, command string, banTime int64, reason string) SetBanResponse {
+	res := SetBanResponse{PeerID: peerID, Command: command}
+
+	if cli.P2P == nil {
+		res.Error = err.ErrInternal("Node is not running")
+		return res
+	}
+
+	switch command {
+	case "add":
+		if banTime < 0 {
+			res.Error = err.ErrInvalidArgument("Ban time must not be negative")
+			return res
+		}
+
+		duration := p2p.DefaultBanTime
+		if banTime > 0 {
+			duration = time.Duration(banTime) * time.Second
+		}
+		if reason == "" {
+			reason = "manually banned"
+		}
+
+		if e := cli.P2P.Bans.Ban(peerID, duration, reason); e != nil {
+			if errors.Is(e, p2p.ErrInvalidPeerID) {
+				res.Error = err.ErrInvalidArgument("Peer ID is invalid")
+				return res
+			}
+			log.Errorf("Ban peer %s with error: %v", peerID, e)
+			res.Error = err.ErrInternal("Internal error")
+			return res
+		}
+
+		res.BanUntil = time.Now().Add(duration).Unix()
+		res.Message = "Peer banned"
+	case "remove":
+		removed, e := cli.P2P.Bans.Unban(peerID)
+		if e != nil {
+			log.Errorf("Unban peer %s with error: %v", peerID, e)
+			res.Error = err.ErrInternal("Internal error")
+			return res
+		}
+		if !removed {
+			res.Error = err.ErrNotFound("Peer is not banned")
+			return res
+		}
+
+		res.Message = "Peer unbanned"
+	default:
+		res.Error = err.ErrInvalidArgument("Command must be add or remove")
+	}
+
+	return res
+}
+
+func (cli *CommandLine) ClearBanned() ClearBannedResponse {
+	if cli.P2P == nil {
+		return ClearBannedResponse{Error: err.ErrInternal("Node is not running")}
+	}
+
+	count, e := cli.P2P.Bans.ClearBanned()
+	if e != nil {
+		log.Errorf("Clear ban list with error: %v", e)
+		return ClearBannedResponse{Error: err.ErrInternal("Internal error")}
+	}
+
+	return ClearBannedResponse{Count: int64(count), Error: nil}
+}
+
+// SaveMempool writes the mempool of the running node to its chain data dir,
+// where the next start loads it from.
+func (cli *CommandLine) SaveMempool() SaveMempoolResponse {
+	if cli.P2P == nil {
+		return SaveMempoolResponse{Error: err.ErrInternal("Node is not running")}
+	}
+
+	count, e := cli.P2P.SaveMempool()
+	if e != nil {
+		log.Errorf("Save mempool with error: %v", e)
+		return SaveMempoolResponse{Error: err.ErrInternal("Internal error")}
+	}
+
+	return SaveMempoolResponse{Count: int64(count), Error: nil}
+}
+
+// LoadMempool re-admits the saved mempool, dropping the transactions no
+// longer valid.
+func (cli *CommandLine) LoadMempool() LoadMempoolResponse {
+	if cli.P2P == nil {
+		return LoadMempoolResponse{Error: err.ErrInternal("Node is not running")}
+	}
+
+	loaded, dropped, e := cli.P2P.LoadMempool()
+	if e != nil {
+		log.Errorf("Load mempool with error: %v", e)
+		return LoadMempoolResponse{Error: err.ErrInternal("Internal error")}
+	}
+
+	return LoadMempoolResponse{Count: int64(loaded), Dropped: int64(dropped), Error: nil}
+}
+
+func (cli *CommandLine) EstimateFee(targetBlocks int64) EstimateFeeResponse {
+	if cli.P2P == nil {
+		return EstimateFeeResponse{Error: err.ErrInternal("Node is not running")}
+	}
+	if targetBlocks < 1 || targetBlocks > memopool.MaxEstimateTarget {
+		return EstimateFeeResponse{Error: err.ErrInvalidArgument(fmt.Sprintf("Target blocks must be between 1 and %d", memopool.MaxEstimateTarget))}
+	}
+
+	estimate, e := p2p.MemoryPool.EstimateFee(int(targetBlocks))
+	if errors.Is(e, memopool.ErrNoEstimate) {
+		return EstimateFeeResponse{Error: err.ErrNotFound("Insufficient data to estimate fee")}
+	}
+	if e != nil {
+		log.Errorf("Estimate fee with error: %v", e)
+		return EstimateFeeResponse{Error: err.ErrInternal("Internal error")}
+	}
+
+	return EstimateFeeResponse{FeeRate: estimate.FeeRate, Blocks: int64(estimate.Blocks), Error: nil}
+}
+
+// GenerateToAddress mines nBlocks blocks paying address on networks that
+// generate blocks on demand.
+func (cli *CommandLine) GenerateToAddress(nBlocks int64, address string) GenerateResponse {
+	if e := cli.checkGenerate(address); e != nil {
+		return GenerateResponse{Error: e}
+	}
+	if nBlocks < 1 || nBlocks > MaxGenerateBlocks {
+		return GenerateResponse{Error: err.ErrInvalidArgument(fmt.Sprintf("Number of blocks must be between 1 and %d", MaxGenerateBlocks))}
+	}
+
+	blocks, e := cli.P2P.GenerateToAddress(int(nBlocks), address)
+
+	res := GenerateResponse{Hashes: make([]string, 0, len(blocks))}
+	for _, block := range blocks {
+		res.Hashes = append(res.Hashes, hex.EncodeToString(block.Hash))
+		res.Height = block.Height
+	}
+
+	if e != nil {
+		log.Errorf("Generate blocks with error: %v", e)
+		res.Error = err.ErrInternal("Internal error")
+	}
+
+	return res
+}
+
+// GenerateBlock mines one block with the mempool transactions txIDs. An
+// empty address pays the miner address of the node.
+func (cli *CommandLine) GenerateBlock(txIDs []string, address string) GenerateResponse {
+	if address == "" {
+		address = p2p.MinerAddress
+	}
+	if e := cli.checkGenerate(address); e != nil {
+		return GenerateResponse{Error: e}
+	}
+
+	block, e := cli.P2P.GenerateBlock(txIDs, address)
+	if e != nil {
+		if errors.Is(e, p2p.ErrTxNotInMempool) {
+			return GenerateResponse{Error: err.ErrNotFound(e.Error())}
+		}
+		if errors.Is(e, p2p.ErrInvalidBlockTxs) {
+			return GenerateResponse{Error: err.ErrInvalidArgument(e.Error())}
+		}
+		log.Errorf("Generate block with error: %v", e)
+		return GenerateResponse{Error: err.ErrInternal("Internal error")}
+	}
+
+	return GenerateResponse{
+		Hashes: []string{hex.EncodeToString(block.Hash)},
+		Height: block.Height,
+		Error:  nil,
+	}
+}
+
+func (cli *CommandLine) checkGenerate(address string) *err.RPCError {
+	if cli.P2P == nil {
+		return err.ErrInternal("Node is not running")
+	}
+	if !cli.Params.MineOnDemand {
+		return err.ErrInvalidArgument(fmt.Sprintf("Block generation is not available on %s", cli.Params.Name))
+	}
+	if !wallet.ValidateAddress(address, cli.Params) {
+		return err.ErrInvalidArgument("Address is invalid")
+	}
+
+	return nil
+}
@@ -0,0 +1,394 @@
+package utils
+
+import (
+	"core-blockchain/common/err"
+	blockchain "core-blockchain/core"
+	"core-blockchain/p2p"
+	"core-blockchain/wallet"
+	"encoding/hex"
+	"errors"
+	"maps"
+	"slices"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// CreatePSBT builds an unsigned transaction spending inputs to outputs for
+// the block at height, the next one when 0, and fills in what the node
+// knows, see UpdatePSBT. The SubtractFee flag of outputs is ignored, the fee
+// is what the inputs leave over.
+func (cli *CommandLine) CreatePSBT(inputs []blockchain.TxInput, outputs []blockchain.Recipient, height int64) PSBTResponse {
+	if len(inputs) == 0 || len(outputs) == 0 {
+		return PSBTResponse{Error: err.ErrInvalidArgument("Inputs and outputs are required")}
+	}
+	if height < 0 {
+		return PSBTResponse{Error: err.ErrInvalidArgument("Height must not be negative")}
+	}
+
+	tx := &blockchain.Transaction{}
+
+	spent := make(map[string]bool, len(inputs))
+	for _, in := range inputs {
+		key := outpoint(in.ID, in.Out)
+		if in.Out < 0 || spent[key] {
+			return PSBTResponse{Error: err.ErrInvalidArgument("Invalid or duplicate input", key)}
+		}
+		spent[key] = true
+		tx.Inputs = append(tx.Inputs, blockchain.TxInput{ID: in.ID, Out: in.Out})
+	}
+
+	for _, out := range outputs {
+		if !wallet.ValidateAddress(out.Address, cli.Params) {
+			return PSBTResponse{Error: err.ErrInvalidArgument("Address is invalid", out.Address)}
+		}
+		if out.Amount <= 0 {
+			return PSBTResponse{Error: err.ErrInvalidArgument("Amount must be positive", out.Address)}
+		}
+		tx.Outputs = append(tx.Outputs, *blockchain.NewTxOutput(out.Amount, out.Address))
+	}
+
+	chain, bestHeight, release, rpcErr := cli.walletChain()
+	if rpcErr != nil {
+		return PSBTResponse{Error: rpcErr}
+	}
+	defer release()
+
+	if height == 0 {
+		height = bestHeight + 1
+	}
+
+	p, e := blockchain.NewPSBT(tx, height)
+	if e != nil {
+		return PSBTResponse{Error: err.ErrInvalidArgument(e.Error())}
+	}
+
+	if rpcErr := cli.updatePSBT(chain, p, nil); rpcErr != nil {
+		return PSBTResponse{Error: rpcErr}
+	}
+
+	return PSBTResponse{PSBT: p.Base64(), Complete: p.IsSigned(), Error: nil}
+}
+
+// updatePSBT adds the previous outputs found in the UTXO set or the mempool,
+// and the public keys and derivation paths of the wallet file, watched
+// public keys included. Inputs spending a script hash get the one of
+// redeemScripts it commits to.
+func (cli *CommandLine) updatePSBT(chain *blockchain.Blockchain, p *blockchain.PSBT, redeemScripts [][]byte) *err.RPCError {
+	wallets, e := wallet.InitializeWallets(false, cli.Params)
+	if e != nil {
+		log.Errorf("Load wallets with error: %v", e)
+		return err.ErrInternal("Internal error")
+	}
+
+	utxos := blockchain.UTXOSet{Blockchain: chain}
+	owners := wallets.PubKeyHashes(true)
+
+	publicKey := func(pubKeyHash []byte) ([]byte, string) {
+		address := owners[hex.EncodeToString(pubKeyHash)]
+		if w, ok := wallets.Wallets[address]; ok {
+			return w.PublicKey, w.Path
+		}
+		if watched, ok := wallets.Watched[address]; ok {
+			return watched.PublicKey, ""
+		}
+		return nil, ""
+	}
+
+	for i, in := range p.Tx.Inputs {
+		if p.Inputs[i].PrevOutput == nil {
+			out, ok, e := utxos.FindOutput(in.ID, in.Out)
+			if e != nil {
+				log.Errorf("Find output with error: %v", e)
+				return err.ErrInternal("Internal error")
+			}
+			if parent := p2p.MemoryPool.GetTxByID(hex.EncodeToString(in.ID)); !ok && parent != nil && in.Out < int64(len(parent.Outputs)) {
+				out, ok = parent.Outputs[in.Out], true
+			}
+			if !ok {
+				continue
+			}
+
+			if e := p.SetPrevOutput(i, out); e != nil {
+				return err.ErrInvalidArgument(e.Error())
+			}
+		}
+
+		if p.Inputs[i].SpendsScript() {
+			for _, script := range redeemScripts {
+				if len(p.Inputs[i].RedeemScript) > 0 {
+					break
+				}
+				if e := p.SetRedeemScript(i, script); e != nil && !errors.Is(e, blockchain.ErrPrevOutputMismatch) {
+					return err.ErrInvalidArgument(e.Error())
+				}
+			}
+			continue
+		}
+
+		if len(in.PubKey) > 0 && p.Inputs[i].Path != "" {
+			continue
+		}
+
+		if pubKey, path := publicKey(p.Inputs[i].PrevOutput.PubKeyHash); len(pubKey) > 0 {
+			if e := p.SetInputKey(i, pubKey, path); e != nil && !errors.Is(e, blockchain.ErrPSBTSigned) {
+				return err.ErrInvalidArgument(e.Error())
+			}
+		}
+	}
+
+	for i, out := range p.Tx.Outputs {
+		if len(p.Outputs[i].PubKey) > 0 {
+			continue
+		}
+		if pubKey, path := publicKey(out.PubKeyHash); len(pubKey) > 0 {
+			if e := p.SetOutputKey(i, pubKey, path); e != nil {
+				return err.ErrInvalidArgument(e.Error())
+			}
+		}
+	}
+
+	return nil
+}
+
+// UpdatePSBT fills in the previous outputs and keys the node knows, and the
+// hex encoded redeemScripts of the script hashes spent, see updatePSBT.
+func (cli *CommandLine) UpdatePSBT(psbt string, redeemScripts []string) PSBTResponse {
+	p, e := blockchain.DecodePSBT(psbt)
+	if e != nil {
+		return PSBTResponse{Error: err.ErrInvalidArgument(e.Error())}
+	}
+
+	scripts := make([][]byte, 0, len(redeemScripts))
+	for _, redeemScript := range redeemScripts {
+		script, e := hex.DecodeString(redeemScript)
+		if e != nil || len(script) == 0 {
+			return PSBTResponse{Error: err.ErrInvalidArgument("Redeem script is invalid", redeemScript)}
+		}
+		scripts = append(scripts, script)
+	}
+
+	chain, _, release, rpcErr := cli.walletChain()
+	if rpcErr != nil {
+		return PSBTResponse{Error: rpcErr}
+	}
+	defer release()
+
+	if rpcErr := cli.updatePSBT(chain, p, scripts); rpcErr != nil {
+		return PSBTResponse{Error: rpcErr}
+	}
+
+	return PSBTResponse{PSBT: p.Base64(), Complete: p.IsSigned(), Error: nil}
+}
+
+func (cli *CommandLine) DecodePSBT(psbt string) DecodePSBTResponse {
+	p, e := blockchain.DecodePSBT(psbt)
+	if e != nil {
+		return DecodePSBTResponse{Error: err.ErrInvalidArgument(e.Error())}
+	}
+
+	fee, ok := p.Fee()
+	if !ok {
+		fee = -1
+	}
+
+	return DecodePSBTResponse{PSBT: p, Fee: fee, Complete: p.IsSigned(), Finalized: p.IsFinalized(), Error: nil}
+}
+
+// signPSBT signs p with every wallet of the pool that unlocks one of its
+// inputs, or holds a key of its redeem script. hashType, when not 0, is set
+// on those inputs that ask for none.
+func signPSBT(p *blockchain.PSBT, wallets *wallet.WalletPool, hashType blockchain.SigHashType) (int, error) {
+	owners := wallets.PubKeyHashes(false)
+	signers := make(map[string]bool)
+
+	// Keys are filled in before anything is signed, each of them changes the
+	// transaction ID.
+	for i, in := range p.Inputs {
+		if in.PrevOutput == nil || in.IsFinal() {
+			continue
+		}
+
+		if in.SpendsScript() {
+			for _, pubKey := range in.RedeemKeys() {
+				address, ok := owners[hex.EncodeToString(wallet.PublicKeyHash(pubKey))]
+				if !ok {
+					continue
+				}
+				if _, ok := wallets.Wallets[address]; !ok {
+					continue
+				}
+
+				if hashType != 0 && in.SigHashType == 0 {
+					p.Inputs[i].SigHashType = hashType
+				}
+				signers[address] = true
+			}
+			continue
+		}
+
+		address, ok := owners[hex.EncodeToString(in.PrevOutput.PubKeyHash)]
+		if !ok {
+			continue
+		}
+
+		w, ok := wallets.Wallets[address]
+		if !ok {
+			continue
+		}
+		if e := p.SetInputKey(i, w.PublicKey, w.Path); e != nil {
+			return 0, e
+		}
+
+		if hashType != 0 && in.SigHashType == 0 {
+			p.Inputs[i].SigHashType = hashType
+		}
+		signers[address] = true
+	}
+
+	signed := 0
+	for _, address := range slices.Sorted(maps.Keys(signers)) {
+		w, e := wallets.GetWallet(address)
+		if e != nil {
+			return 0, e
+		}
+
+		n, e := p.Sign(w)
+		if e != nil {
+			return 0, e
+		}
+		signed += n
+	}
+
+	return signed, nil
+}
+
+// SignPSBT signs the inputs of psbt the wallet file holds keys for. An
+// encrypted file has to be unlocked.
+func (cli *CommandLine) SignPSBT(psbt string, sigHashType string) SignPSBTResponse {
+	p, e := blockchain.DecodePSBT(psbt)
+	if e != nil {
+		return SignPSBTResponse{Error: err.ErrInvalidArgument(e.Error())}
+	}
+
+	var hashType blockchain.SigHashType
+	if sigHashType != "" {
+		if hashType, e = blockchain.ParseSigHashType(sigHashType); e != nil {
+			return SignPSBTResponse{Error: err.ErrInvalidArgument(e.Error())}
+		}
+	}
+
+	wallets, e := cli.loadWallets(false, "")
+	if e != nil {
+		log.Errorf("Load wallets with error: %v", e)
+		return SignPSBTResponse{Error: err.ErrInternal("Internal error")}
+	}
+	if wallets.IsLocked() {
+		return SignPSBTResponse{Error: err.ErrWalletLocked()}
+	}
+
+	signed, e := signPSBT(p, wallets, hashType)
+	if e != nil {
+		return SignPSBTResponse{Error: err.ErrInvalidArgument(e.Error())}
+	}
+
+	return SignPSBTResponse{PSBT: p.Base64(), Signed: signed, Complete: p.IsSigned(), Error: nil}
+}
+
+// SignPSBTOffline signs psbt with the wallet file alone, no chain or node is
+// needed, which is what air-gapped signers run.
+func (cli *CommandLine) SignPSBTOffline(psbt string, passphrase string) (string, int, error) {
+	p, err := blockchain.DecodePSBT(psbt)
+	if err != nil {
+		return "", 0, err
+	}
+
+	wallets, err := cli.loadWallets(false, passphrase)
+	if err != nil {
+		return "", 0, err
+	}
+	if wallets.IsLocked() {
+		return "", 0, wallet.ErrWalletLocked
+	}
+
+	signed, err := signPSBT(p, wallets, 0)
+	if err != nil {
+		return "", 0, err
+	}
+
+	return p.Base64(), signed, nil
+}
+
+// CombinePSBT merges the signatures and data of PSBTs of the same
+// transaction.
+func (cli *CommandLine) CombinePSBT(psbts []string) PSBTResponse {
+	if len(psbts) == 0 {
+		return PSBTResponse{Error: err.ErrInvalidArgument("No PSBTs to combine")}
+	}
+
+	var combined *blockchain.PSBT
+	for _, psbt := range psbts {
+		p, e := blockchain.DecodePSBT(psbt)
+		if e != nil {
+			return PSBTResponse{Error: err.ErrInvalidArgument(e.Error())}
+		}
+
+		if combined == nil {
+			combined = p
+			continue
+		}
+		if e := combined.Combine(p); e != nil {
+			return PSBTResponse{Error: err.ErrInvalidArgument(e.Error())}
+		}
+	}
+
+	return PSBTResponse{PSBT: combined.Base64(), Complete: combined.IsSigned(), Error: nil}
+}
+
+// FinalizePSBT checks the collected signatures and, with extract, returns
+// the signed transaction once every input is final.
+func (cli *CommandLine) FinalizePSBT(psbt string, extract bool) FinalizePSBTResponse {
+	p, e := blockchain.DecodePSBT(psbt)
+	if e != nil {
+		return FinalizePSBTResponse{Error: err.ErrInvalidArgument(e.Error())}
+	}
+
+	complete, e := p.Finalize()
+	if e != nil {
+		return FinalizePSBTResponse{Error: err.ErrInvalidArgument(e.Error())}
+	}
+
+	res := FinalizePSBTResponse{PSBT: p.Base64(), Complete: complete, Error: nil}
+
+	if extract && complete {
+		tx, e := p.Extract()
+		if e != nil {
+			return FinalizePSBTResponse{Error: err.ErrInvalidArgument(e.Error())}
+		}
+		res.Transaction = tx
+	}
+
+	return res
+}
+
+// SendPSBT finalizes psbt and submits its transaction through SendTx.
+func (cli *CommandLine) SendPSBT(psbt string) SendResponse {
+	p, e := blockchain.DecodePSBT(psbt)
+	if e != nil {
+		return SendResponse{Error: err.ErrInvalidArgument(e.Error())}
+	}
+
+	complete, e := p.Finalize()
+	if e != nil {
+		return SendResponse{Error: err.ErrInvalidArgument(e.Error())}
+	}
+	if !complete {
+		return SendResponse{Error: err.ErrInvalidArgument("PSBT is not fully signed")}
+	}
+
+	tx, e := p.Extract()
+	if e != nil {
+		return SendResponse{Error: err.ErrInvalidArgument(e.Error())}
+	}
+
+	return cli.SendTx([]*blockchain.Transaction{tx})
+}
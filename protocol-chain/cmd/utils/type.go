@@ -1,29 +1,68 @@
 package utils
 
 import (
+	"core-blockchain/chaincfg"
 	"core-blockchain/common/err"
 	blockchain "core-blockchain/core"
 	"core-blockchain/p2p"
+	"sync"
+)
+
+const (
+	// MaxAddressPageSize caps the page size of the address index RPCs.
+	MaxAddressPageSize = 500
+	// MaxGenerateBlocks caps how many blocks one API.GenerateToAddress
+	// call mines.
+	MaxGenerateBlocks = 1000
+
+	// MaxWalletUnlockTimeout caps API.WalletUnlock, in seconds.
+	MaxWalletUnlockTimeout = 100_000_000
+
+	// DefaultListTransactions is the page size of API.ListTransactions
+	// when none is asked.
+	DefaultListTransactions = 10
 )
 
 type CommandLine struct {
 	Blockchain    *blockchain.Blockchain
 	P2P           *p2p.Network
+	Params        *chaincfg.Params
 	CloseDbAlways bool
+
+	// walletMu serializes the RPCs that write the wallet file, each holds
+	// it from loading the file to saving it so none of them overwrites
+	// what another one added.
+	walletMu sync.Mutex
 }
 
+// BalanceResponse.Balance is expressed in base units.
 type BalanceResponse struct {
-	Balance   float64
+	Balance   int64
 	Address   string
 	Timestamp int64
 	Error     *err.RPCError
 }
 
+// SendResponse.Rejected lists the transactions the mempool turned down and
+// why, ListTxs the ones it accepted and Replaced the pool transactions they
+// replaced by fee, which only happens on nodes running full RBF.
 type SendResponse struct {
-	Message string
-	ListTxs []string
-	Count   int64
-	Error   *err.RPCError
+	Message  string
+	ListTxs  []string
+	Count    int64
+	Rejected []RejectedTx
+	Replaced []ReplacedTx
+	Error    *err.RPCError
+}
+
+type RejectedTx struct {
+	TxID   string
+	Reason string
+}
+
+type ReplacedTx struct {
+	TxID       string
+	ReplacedBy string
 }
 
 type GetMiningTxsResponse struct {
@@ -38,9 +77,226 @@ type GetBlockResponse struct {
 	Error *err.RPCError
 }
 
+type AddressHistoryResponse struct {
+	Address string
+	Txs     []blockchain.AddressTx
+	Total   int64
+	Offset  int64
+	Limit   int64
+	Error   *err.RPCError
+}
+
+// AddressUTXOsResponse.Balance is the sum of every unspent output of the
+// address in base units, not only the ones on this page.
+type AddressUTXOsResponse struct {
+	Address string
+	UTXOs   []blockchain.AddressUTXO
+	Balance int64
+	Total   int64
+	Offset  int64
+	Limit   int64
+	Error   *err.RPCError
+}
+
 type GetAllUTXOsResponse struct {
 	Message string
 	Data    map[string]blockchain.TxOutputs
 	Count   int64
 	Error   *err.RPCError
 }
+
+type ListBannedResponse struct {
+	Banned []p2p.BanEntry
+	Count  int64
+	Error  *err.RPCError
+}
+
+// SetBanResponse.BanUntil is a unix timestamp, zero when the ban was lifted.
+type SetBanResponse struct {
+	PeerID   string
+	Command  string
+	BanUntil int64
+	Message  string
+	Error    *err.RPCError
+}
+
+type ClearBannedResponse struct {
+	Count int64
+	Error *err.RPCError
+}
+
+type SaveMempoolResponse struct {
+	Count int64
+	Error *err.RPCError
+}
+
+// LoadMempoolResponse.Count is how many saved transactions got back into
+// the mempool, Dropped how many failed validation or policy.
+type LoadMempoolResponse struct {
+	Count   int64
+	Dropped int64
+	Error   *err.RPCError
+}
+
+// WalletUnlockResponse.Until is the unix time the wallet locks again.
+type WalletUnlockResponse struct {
+	Until int64
+	Error *err.RPCError
+}
+
+// ImportResponse.Address is the address now watched.
+type ImportResponse struct {
+	Address string
+	Error   *err.RPCError
+}
+
+// MultiSigResponse.RedeemScript is hex encoded.
+type MultiSigResponse struct {
+	Address      string
+	RedeemScript string
+	Error        *err.RPCError
+}
+
+// WalletBalanceResponse amounts are in base units. Balance covers the
+// wallets with a private key, WatchOnlyBalance the watched addresses.
+type WalletBalanceResponse struct {
+	Balance          int64
+	WatchOnlyBalance int64
+	Addresses        []WalletAddressBalance
+	Error            *err.RPCError
+}
+
+type WalletAddressBalance struct {
+	Address   string
+	Balance   int64
+	WatchOnly bool
+}
+
+type NewAddressResponse struct {
+	Address string
+	Error   *err.RPCError
+}
+
+// WalletAddress.Path is the derivation path of an HD wallet.
+type WalletAddress struct {
+	Address   string
+	Path      string
+	WatchOnly bool
+}
+
+type ListAddressesResponse struct {
+	Addresses []WalletAddress
+	Count     int64
+	Error     *err.RPCError
+}
+
+// WalletUTXO.Value is in base units. Spendable is false for watch-only
+// addresses.
+type WalletUTXO struct {
+	TxID          string
+	Out           int64
+	Address       string
+	Value         int64
+	Height        int64
+	Confirmations int64
+	Spendable     bool
+}
+
+type ListUnspentResponse struct {
+	UTXOs []WalletUTXO
+	Count int64
+	Error *err.RPCError
+}
+
+// WalletSendResponse.Fee is what the transaction pays in base units, dust
+// change included.
+type WalletSendResponse struct {
+	TxID  string
+	Fee   int64
+	Error *err.RPCError
+}
+
+// WalletTx is a transaction seen from the wallet. Category is generate,
+// send or receive. Amount is the change of the wallet balance in base
+// units, the fee included for sends, and Fee is only set when the wallet
+// paid it. Addresses are the wallet addresses the transaction touches.
+// Transactions in the mempool have Height -1 and no confirmations.
+type WalletTx struct {
+	TxID          string
+	Category      string
+	Amount        int64
+	Fee           int64
+	Addresses     []string
+	Height        int64
+	Confirmations int64
+	BlockHash     string
+	Time          int64
+}
+
+type ListTransactionsResponse struct {
+	Txs   []WalletTx
+	Count int64
+	Error *err.RPCError
+}
+
+type GetTransactionResponse struct {
+	WalletTx
+	Transaction *blockchain.Transaction
+	Error       *err.RPCError
+}
+
+// PSBTResponse.PSBT is base64 encoded. Complete reports that every input
+// carries the signature of its key, see blockchain.PSBT.IsSigned.
+type PSBTResponse struct {
+	PSBT     string
+	Complete bool
+	Error    *err.RPCError
+}
+
+// SignPSBTResponse.Signed is how many inputs the wallet signed.
+type SignPSBTResponse struct {
+	PSBT     string
+	Signed   int
+	Complete bool
+	Error    *err.RPCError
+}
+
+// DecodePSBTResponse.Fee is -1 while a previous output is unknown.
+type DecodePSBTResponse struct {
+	PSBT      *blockchain.PSBT
+	Fee       int64
+	Complete  bool
+	Finalized bool
+	Error     *err.RPCError
+}
+
+// FinalizePSBTResponse.Transaction is set when extraction was asked for and
+// every input is final.
+type FinalizePSBTResponse struct {
+	PSBT        string
+	Complete    bool
+	Transaction *blockchain.Transaction
+	Error       *err.RPCError
+}
+
+type WalletLockResponse struct {
+	Message string
+	Error   *err.RPCError
+}
+
+// EstimateFeeResponse.FeeRate is in base units per serialized byte of
+// transaction, Blocks is the confirmation target it was estimated for, which
+// can be later than the one asked when recent blocks do not cover it.
+type EstimateFeeResponse struct {
+	FeeRate float64
+	Blocks  int64
+	Error   *err.RPCError
+}
+
+// GenerateResponse lists the hashes of the generated blocks, Height is the
+// height of the last one.
+type GenerateResponse struct {
+	Hashes []string
+	Height int64
+	Error  *err.RPCError
+}
@@ -0,0 +1,646 @@
+package utils
+
+import (
+	"core-blockchain/coinselect"
+	"core-blockchain/common/err"
+	blockchain "core-blockchain/core"
+	"core-blockchain/p2p"
+	"core-blockchain/wallet"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"sort"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// The wallet RPCs work on the wallet file of the node. Signing ones use the
+// unlock session when the file is encrypted, the others only need the
+// public keys.
+
+func outpoint(txID []byte, out int64) string {
+	return fmt.Sprintf("%x:%d", txID, out)
+}
+
+// walletChain opens the chain and returns the function that releases it
+// when the database is closed after every call.
+func (cli *CommandLine) walletChain() (*blockchain.Blockchain, int64, func(), *err.RPCError) {
+	chain, e := cli.Blockchain.ContinueBlockchain()
+	if e != nil {
+		log.Error(e)
+		return nil, 0, nil, err.ErrInternal("Internal error")
+	}
+
+	release := func() {
+		if cli.CloseDbAlways {
+			chain.Database.Close()
+		}
+	}
+
+	bestHeight, e := chain.GetBestHeight()
+	if e != nil {
+		release()
+		log.Errorf("Get best height with error: %v", e)
+		return nil, 0, nil, err.ErrInternal("Internal error")
+	}
+
+	return chain, bestHeight, release, nil
+}
+
+// GetNewAddress adds a wallet, the next receive or change wallet of an HD
+// file.
+func (cli *CommandLine) GetNewAddress(change bool) NewAddressResponse {
+	cli.walletMu.Lock()
+	defer cli.walletMu.Unlock()
+
+	cwd := false
+	wallets, e := cli.loadWallets(cwd, "")
+	if e != nil {
+		log.Errorf("Load wallets with error: %v", e)
+		return NewAddressResponse{Error: err.ErrInternal("Internal error")}
+	}
+
+	var address string
+	switch {
+	case wallets.IsHD() && change:
+		address, e = wallets.NewAddress(wallet.InternalChain)
+	default:
+		address, e = wallets.AddWallet()
+	}
+
+	if errors.Is(e, wallet.ErrWalletLocked) {
+		return NewAddressResponse{Error: err.ErrWalletLocked()}
+	}
+	if e == nil {
+		e = wallets.SaveFile(cwd)
+	}
+	if e != nil {
+		log.Errorf("Create wallet with error: %v", e)
+		return NewAddressResponse{Error: err.ErrInternal("Internal error")}
+	}
+
+	return NewAddressResponse{Address: address, Error: nil}
+}
+
+// ListAddresses lists the wallets of the file, HD ones in derivation order.
+func (cli *CommandLine) ListAddresses(includeWatchOnly bool) ListAddressesResponse {
+	wallets, e := wallet.InitializeWallets(false, cli.Params)
+	if e != nil {
+		log.Errorf("Load wallets with error: %v", e)
+		return ListAddressesResponse{Error: err.ErrInternal("Internal error")}
+	}
+
+	addresses := make([]WalletAddress, 0, len(wallets.Wallets))
+	for address, w := range wallets.Wallets {
+		addresses = append(addresses, WalletAddress{Address: address, Path: w.Path})
+	}
+
+	if includeWatchOnly {
+		for _, address := range wallets.GetWatchOnlyAddresses() {
+			addresses = append(addresses, WalletAddress{Address: address, WatchOnly: true})
+		}
+	}
+
+	sort.Slice(addresses, func(i, j int) bool {
+		a, b := addresses[i], addresses[j]
+		if a.WatchOnly != b.WatchOnly {
+			return !a.WatchOnly
+		}
+		if len(a.Path) != len(b.Path) {
+			return len(a.Path) < len(b.Path)
+		}
+		if a.Path != b.Path {
+			return a.Path < b.Path
+		}
+		return a.Address < b.Address
+	})
+
+	return ListAddressesResponse{Addresses: addresses, Count: int64(len(addresses)), Error: nil}
+}
+
+// walletUnspent returns the outputs locked to hashes with between minConf
+// and maxConf confirmations, maxConf 0 setting no bound. Outputs spent by a
+// mempool transaction are left out.
+func walletUnspent(chain *blockchain.Blockchain, bestHeight int64, hashes map[string]string, minConf, maxConf int64) ([]blockchain.UnspentOutput, error) {
+	utxos := blockchain.UTXOSet{Blockchain: chain}
+
+	unspent, e := utxos.Unspent(func(pubKeyHash []byte) bool {
+		_, ok := hashes[hex.EncodeToString(pubKeyHash)]
+		return ok
+	})
+	if e != nil {
+		return nil, e
+	}
+
+	coins := make([]blockchain.UnspentOutput, 0, len(unspent))
+	for _, u := range unspent {
+		confirmations := bestHeight - u.Height + 1
+		if confirmations < minConf || (maxConf > 0 && confirmations > maxConf) {
+			continue
+		}
+		if _, spent := p2p.MemoryPool.Spender(u.TxID, u.Index); spent {
+			continue
+		}
+		coins = append(coins, u)
+	}
+
+	sort.Slice(coins, func(i, j int) bool {
+		if coins[i].Height != coins[j].Height {
+			return coins[i].Height < coins[j].Height
+		}
+		return outpoint(coins[i].TxID, coins[i].Index) < outpoint(coins[j].TxID, coins[j].Index)
+	})
+
+	return coins, nil
+}
+
+// ListUnspent lists the confirmed unspent outputs of the wallet, oldest
+// first. minConf below 1 counts as 1, outputs of mempool transactions are
+// not listed.
+func (cli *CommandLine) ListUnspent(minConf, maxConf int64, addresses []string, includeWatchOnly bool) ListUnspentResponse {
+	if minConf < 1 {
+		minConf = 1
+	}
+	if maxConf < 0 || (maxConf > 0 && maxConf < minConf) {
+		return ListUnspentResponse{Error: err.ErrInvalidArgument("maxConf must not be below minConf")}
+	}
+
+	for _, address := range addresses {
+		if !wallet.ValidateAddress(address, cli.Params) {
+			return ListUnspentResponse{Error: err.ErrInvalidArgument("Address is invalid", address)}
+		}
+	}
+
+	wallets, e := wallet.InitializeWallets(false, cli.Params)
+	if e != nil {
+		log.Errorf("Load wallets with error: %v", e)
+		return ListUnspentResponse{Error: err.ErrInternal("Internal error")}
+	}
+
+	hashes := wallets.PubKeyHashes(includeWatchOnly)
+	if len(addresses) > 0 {
+		maps.DeleteFunc(hashes, func(_ string, address string) bool {
+			return !slices.Contains(addresses, address)
+		})
+	}
+
+	chain, bestHeight, release, rpcErr := cli.walletChain()
+	if rpcErr != nil {
+		return ListUnspentResponse{Error: rpcErr}
+	}
+	defer release()
+
+	unspent, e := walletUnspent(chain, bestHeight, hashes, minConf, maxConf)
+	if e != nil {
+		log.Errorf("List unspent with error: %v", e)
+		return ListUnspentResponse{Error: err.ErrInternal("Internal error")}
+	}
+
+	utxos := make([]WalletUTXO, 0, len(unspent))
+	for _, u := range unspent {
+		address := hashes[hex.EncodeToString(u.Output.AddressHash())]
+		utxos = append(utxos, WalletUTXO{
+			TxID:          hex.EncodeToString(u.TxID),
+			Out:           u.Index,
+			Address:       address,
+			Value:         u.Output.Value,
+			Height:        u.Height,
+			Confirmations: bestHeight - u.Height + 1,
+			Spendable:     !wallets.IsWatchOnly(address),
+		})
+	}
+
+	return ListUnspentResponse{UTXOs: utxos, Count: int64(len(utxos)), Error: nil}
+}
+
+// SendToAddress pays amount base units to address, see SendMany.
+func (cli *CommandLine) SendToAddress(address string, amount, fee int64, subtractFee bool, minConf int64, coinSelection string) WalletSendResponse {
+	recipients := []blockchain.Recipient{{Address: address, Amount: amount, SubtractFee: subtractFee}}
+
+	return cli.send(recipients, fee, minConf, coinSelection)
+}
+
+// SendMany pays every address its amount in base units from the spendable
+// outputs of the wallet with at least minConf confirmations, and submits
+// the transaction through SendTx. The addresses in subtractFeeFrom pay the
+// fee out of their amount. An encrypted wallet file has to be unlocked.
+func (cli *CommandLine) SendMany(amounts map[string]int64, fee int64, subtractFeeFrom []string, minConf int64, coinSelection string) WalletSendResponse {
+	recipients := make([]blockchain.Recipient, 0, len(amounts))
+	for _, address := range slices.Sorted(maps.Keys(amounts)) {
+		recipients = append(recipients, blockchain.Recipient{
+			Address:     address,
+			Amount:      amounts[address],
+			SubtractFee: slices.Contains(subtractFeeFrom, address),
+		})
+	}
+
+	for _, address := range subtractFeeFrom {
+		if _, ok := amounts[address]; !ok {
+			return WalletSendResponse{Error: err.ErrInvalidArgument("Fee subtracted from an address that is not paid", address)}
+		}
+	}
+
+	return cli.send(recipients, fee, minConf, coinSelection)
+}
+
+func (cli *CommandLine) send(recipients []blockchain.Recipient, fee, minConf int64, coinSelection string) WalletSendResponse {
+	if len(recipients) == 0 {
+		return WalletSendResponse{Error: err.ErrInvalidArgument("No recipients")}
+	}
+	for _, r := range recipients {
+		if !wallet.ValidateAddress(r.Address, cli.Params) {
+			return WalletSendResponse{Error: err.ErrInvalidArgument("Address is invalid", r.Address)}
+		}
+	}
+	if minConf < 1 {
+		minConf = 1
+	}
+
+	strategy, e := coinselect.ParseStrategy(coinSelection)
+	if e != nil {
+		return WalletSendResponse{Error: err.ErrInvalidArgument(e.Error())}
+	}
+
+	// Held until the file is saved, so two sends neither pick the same
+	// coins nor derive the same change address.
+	cli.walletMu.Lock()
+	defer cli.walletMu.Unlock()
+
+	cwd := false
+	wallets, e := cli.loadWallets(cwd, "")
+	if e != nil {
+		log.Errorf("Load wallets with error: %v", e)
+		return WalletSendResponse{Error: err.ErrInternal("Internal error")}
+	}
+	if wallets.IsLocked() {
+		return WalletSendResponse{Error: err.ErrWalletLocked()}
+	}
+
+	chain, bestHeight, release, rpcErr := cli.walletChain()
+	if rpcErr != nil {
+		return WalletSendResponse{Error: rpcErr}
+	}
+	defer release()
+
+	coins, e := walletUnspent(chain, bestHeight, wallets.PubKeyHashes(false), minConf, 0)
+	if e != nil {
+		log.Errorf("List unspent with error: %v", e)
+		return WalletSendResponse{Error: err.ErrInternal("Internal error")}
+	}
+
+	tx, e := blockchain.NewWalletTransaction(wallets, coins, recipients, fee, strategy, bestHeight+1)
+	switch {
+	case errors.Is(e, wallet.ErrWalletLocked):
+		return WalletSendResponse{Error: err.ErrWalletLocked()}
+	case errors.Is(e, coinselect.ErrInsufficientFunds):
+		return WalletSendResponse{Error: err.ErrInvalidArgument("Insufficient funds")}
+	case e != nil:
+		return WalletSendResponse{Error: err.ErrInvalidArgument(e.Error())}
+	}
+
+	res := cli.SendTx([]*blockchain.Transaction{tx})
+	if res.Error != nil {
+		return WalletSendResponse{Error: res.Error}
+	}
+
+	// An HD file derived a change address.
+	if e := wallets.SaveFile(cwd); e != nil {
+		log.Errorf("Save wallets with error: %v", e)
+	}
+
+	var in, out int64
+	for _, coin := range coins {
+		for _, input := range tx.Inputs {
+			if input.Out == coin.Index && hex.EncodeToString(input.ID) == hex.EncodeToString(coin.TxID) {
+				in += coin.Output.Value
+			}
+		}
+	}
+	for _, output := range tx.Outputs {
+		out += output.Value
+	}
+
+	return WalletSendResponse{TxID: hex.EncodeToString(tx.ID), Fee: in - out, Error: nil}
+}
+
+// describeWalletTx returns tx as seen by the wallet owning hashes, false
+// when it touches none of them. prevOut looks up the output an input spends.
+func describeWalletTx(tx *blockchain.Transaction, hashes map[string]string, prevOut func(in blockchain.TxInput) (blockchain.TxOutput, bool)) (WalletTx, bool) {
+	var received, sent, inputTotal, outputTotal int64
+	allInputsKnown := true
+	touched := make(map[string]bool)
+
+	for _, out := range tx.Outputs {
+		outputTotal += out.Value
+		if address, ok := hashes[hex.EncodeToString(out.AddressHash())]; ok {
+			received += out.Value
+			touched[address] = true
+		}
+	}
+
+	if !tx.IsMinerTx() {
+		for _, in := range tx.Inputs {
+			out, ok := prevOut(in)
+			if !ok {
+				allInputsKnown = false
+				continue
+			}
+
+			inputTotal += out.Value
+			if address, ok := hashes[hex.EncodeToString(out.AddressHash())]; ok {
+				sent += out.Value
+				touched[address] = true
+			}
+		}
+	}
+
+	if len(touched) == 0 {
+		return WalletTx{}, false
+	}
+
+	wtx := WalletTx{
+		TxID:      hex.EncodeToString(tx.ID),
+		Category:  "receive",
+		Amount:    received - sent,
+		Addresses: slices.Sorted(maps.Keys(touched)),
+		Height:    -1,
+	}
+
+	switch {
+	case tx.IsMinerTx():
+		wtx.Category = "generate"
+	case sent > 0:
+		wtx.Category = "send"
+		if allInputsKnown {
+			wtx.Fee = inputTotal - outputTotal
+		}
+	}
+
+	return wtx, true
+}
+
+// poolPrevOut looks up the outputs spent by a mempool transaction in its
+// pool parents and the UTXO set.
+func poolPrevOut(chain *blockchain.Blockchain, tx *blockchain.Transaction) func(in blockchain.TxInput) (blockchain.TxOutput, bool) {
+	parents := p2p.MemoryPool.Parents(tx)
+	utxos := blockchain.UTXOSet{Blockchain: chain}
+
+	return func(in blockchain.TxInput) (blockchain.TxOutput, bool) {
+		if parent, ok := parents[hex.EncodeToString(in.ID)]; ok {
+			if in.Out < 0 || in.Out >= int64(len(parent.Outputs)) {
+				return blockchain.TxOutput{}, false
+			}
+			return parent.Outputs[in.Out], true
+		}
+
+		out, ok, e := utxos.FindOutput(in.ID, in.Out)
+		return out, ok && e == nil
+	}
+}
+
+// blockPrevOut looks up the outputs spent by a transaction of block in the
+// undo data of the block, read on first use, and in the chain when the
+// block has none.
+func blockPrevOut(chain *blockchain.Blockchain, block *blockchain.Block) func(in blockchain.TxInput) (blockchain.TxOutput, bool) {
+	var spent map[string]blockchain.TxOutput
+	var undoErr error
+
+	return func(in blockchain.TxInput) (blockchain.TxOutput, bool) {
+		if spent == nil && undoErr == nil {
+			utxos := blockchain.UTXOSet{Blockchain: chain}
+
+			var outs []blockchain.SpentOutput
+			outs, undoErr = utxos.SpentOutputs(block.Hash)
+			spent = make(map[string]blockchain.TxOutput, len(outs))
+			for _, s := range outs {
+				spent[outpoint(s.TxID, s.Index)] = s.Output
+			}
+		}
+
+		if out, ok := spent[outpoint(in.ID, in.Out)]; ok {
+			return out, true
+		}
+		if undoErr == nil {
+			return blockchain.TxOutput{}, false
+		}
+
+		prev, e := chain.FindTransaction(in.ID)
+		if e != nil || in.Out < 0 || in.Out >= int64(len(prev.Outputs)) {
+			return blockchain.TxOutput{}, false
+		}
+
+		return prev.Outputs[in.Out], true
+	}
+}
+
+func confirmedWalletTx(chain *blockchain.Blockchain, bestHeight int64, block *blockchain.Block, pos int, hashes map[string]string, prevOut func(in blockchain.TxInput) (blockchain.TxOutput, bool)) (WalletTx, bool) {
+	wtx, ok := describeWalletTx(block.Transactions[pos], hashes, prevOut)
+	if !ok {
+		return wtx, false
+	}
+
+	wtx.Height = block.Height
+	wtx.Confirmations = bestHeight - block.Height + 1
+	wtx.BlockHash = hex.EncodeToString(block.Hash)
+	wtx.Time = block.Timestamp
+
+	return wtx, true
+}
+
+// ListTransactions pages through the transactions of the wallet, newest
+// first, starting with the mempool. It reads the address index when it has
+// been built and walks back from the tip otherwise.
+func (cli *CommandLine) ListTransactions(count, skip int64, includeWatchOnly bool) ListTransactionsResponse {
+	if count <= 0 {
+		count = DefaultListTransactions
+	}
+	if count > MaxAddressPageSize {
+		count = MaxAddressPageSize
+	}
+	if skip < 0 {
+		skip = 0
+	}
+	want := int(skip + count)
+
+	wallets, e := wallet.InitializeWallets(false, cli.Params)
+	if e != nil {
+		log.Errorf("Load wallets with error: %v", e)
+		return ListTransactionsResponse{Error: err.ErrInternal("Internal error")}
+	}
+
+	hashes := wallets.PubKeyHashes(includeWatchOnly)
+
+	chain, bestHeight, release, rpcErr := cli.walletChain()
+	if rpcErr != nil {
+		return ListTransactionsResponse{Error: rpcErr}
+	}
+	defer release()
+
+	var txs []WalletTx
+
+	pool := p2p.MemoryPool.All()
+	sort.Slice(pool, func(i, j int) bool {
+		return pool[i].Time.After(pool[j].Time)
+	})
+
+	for _, info := range pool {
+		tx := info.Transaction
+		if wtx, ok := describeWalletTx(&tx, hashes, poolPrevOut(chain, &tx)); ok {
+			wtx.Time = info.Time.Unix()
+			txs = append(txs, wtx)
+		}
+	}
+
+	addrIndex := &blockchain.AddrIndex{Blockchain: chain}
+	if addrIndex.Enabled() {
+		txs, e = indexedWalletTxs(chain, addrIndex, bestHeight, hashes, txs, want)
+	} else {
+		txs, e = scannedWalletTxs(chain, bestHeight, hashes, txs, want)
+	}
+	if e != nil {
+		log.Errorf("List transactions with error: %v", e)
+		return ListTransactionsResponse{Error: err.ErrInternal("Internal error")}
+	}
+
+	from := min(int(skip), len(txs))
+	to := min(want, len(txs))
+	page := txs[from:to]
+
+	return ListTransactionsResponse{Txs: page, Count: int64(len(page)), Error: nil}
+}
+
+// indexedWalletTxs appends the newest main chain transactions of hashes
+// from the address index until txs holds want entries.
+func indexedWalletTxs(chain *blockchain.Blockchain, addrIndex *blockchain.AddrIndex, bestHeight int64, hashes map[string]string, txs []WalletTx, want int) ([]WalletTx, error) {
+	entries := make(map[string]blockchain.AddressTx)
+
+	for hash := range hashes {
+		pubKeyHash, e := hex.DecodeString(hash)
+		if e != nil {
+			return nil, e
+		}
+
+		history, _, e := addrIndex.History(pubKeyHash, 0, want)
+		if e != nil {
+			return nil, e
+		}
+		for _, entry := range history {
+			entries[hex.EncodeToString(entry.TxID)] = entry
+		}
+	}
+
+	sorted := slices.Collect(maps.Values(entries))
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].Height != sorted[j].Height {
+			return sorted[i].Height > sorted[j].Height
+		}
+		return sorted[i].Position > sorted[j].Position
+	})
+
+	blocks := make(map[string]*blockchain.Block)
+
+	for _, entry := range sorted {
+		if len(txs) >= want {
+			break
+		}
+
+		key := hex.EncodeToString(entry.BlockHash)
+		block, ok := blocks[key]
+		if !ok {
+			b, e := chain.GetBlock(entry.BlockHash)
+			if e != nil {
+				return nil, e
+			}
+			block = &b
+			blocks[key] = block
+		}
+
+		if int(entry.Position) >= len(block.Transactions) {
+			return nil, fmt.Errorf("address index entry for %x is stale, run reindex", entry.TxID)
+		}
+
+		if wtx, ok := confirmedWalletTx(chain, bestHeight, block, int(entry.Position), hashes, blockPrevOut(chain, block)); ok {
+			txs = append(txs, wtx)
+		}
+	}
+
+	return txs, nil
+}
+
+// scannedWalletTxs walks back from the tip and appends the transactions of
+// hashes until txs holds want entries.
+func scannedWalletTxs(chain *blockchain.Blockchain, bestHeight int64, hashes map[string]string, txs []WalletTx, want int) ([]WalletTx, error) {
+	iter, e := chain.Iterator()
+	if e != nil {
+		return nil, e
+	}
+
+	for len(txs) < want {
+		block, e := iter.Next()
+		if e != nil {
+			return nil, e
+		}
+
+		prevOut := blockPrevOut(chain, block)
+		for pos := len(block.Transactions) - 1; pos >= 0 && len(txs) < want; pos-- {
+			if wtx, ok := confirmedWalletTx(chain, bestHeight, block, pos, hashes, prevOut); ok {
+				txs = append(txs, wtx)
+			}
+		}
+
+		if len(block.PrevHash) == 0 {
+			break
+		}
+	}
+
+	return txs, nil
+}
+
+// GetTransaction returns a mempool or main chain transaction of the wallet.
+func (cli *CommandLine) GetTransaction(txID string, includeWatchOnly bool) GetTransactionResponse {
+	id, e := hex.DecodeString(txID)
+	if e != nil || len(id) == 0 {
+		return GetTransactionResponse{Error: err.ErrInvalidArgument("Transaction id is invalid")}
+	}
+
+	wallets, e := wallet.InitializeWallets(false, cli.Params)
+	if e != nil {
+		log.Errorf("Load wallets with error: %v", e)
+		return GetTransactionResponse{Error: err.ErrInternal("Internal error")}
+	}
+
+	hashes := wallets.PubKeyHashes(includeWatchOnly)
+
+	chain, bestHeight, release, rpcErr := cli.walletChain()
+	if rpcErr != nil {
+		return GetTransactionResponse{Error: rpcErr}
+	}
+	defer release()
+
+	var (
+		wtx WalletTx
+		tx  *blockchain.Transaction
+		ok  bool
+	)
+
+	if tx = p2p.MemoryPool.GetTxByID(hex.EncodeToString(id)); tx != nil {
+		wtx, ok = describeWalletTx(tx, hashes, poolPrevOut(chain, tx))
+	} else {
+		block, pos, e := chain.FindTransactionBlock(id)
+		if e != nil {
+			log.Debugf("Find transaction %s: %v", txID, e)
+			return GetTransactionResponse{Error: err.ErrNotFound("Transaction not found")}
+		}
+
+		tx = block.Transactions[pos]
+		wtx, ok = confirmedWalletTx(chain, bestHeight, block, pos, hashes, blockPrevOut(chain, block))
+	}
+
+	if !ok {
+		return GetTransactionResponse{Error: err.ErrNotFound("Not a wallet transaction")}
+	}
+
+	return GetTransactionResponse{WalletTx: wtx, Transaction: tx, Error: nil}
+}
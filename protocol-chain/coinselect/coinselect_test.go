@@ -0,0 +1,177 @@
+package coinselect
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+)
+
+func coins(values ...int64) []Coin {
+	var cs []Coin
+	for i, v := range values {
+		cs = append(cs, Coin{TxID: fmt.Sprintf("tx%d", i), Out: int64(i), Value: v})
+	}
+	return cs
+}
+
+func TestSelect(t *testing.T) {
+	tests := []struct {
+		name     string
+		strategy Strategy
+		coins    []Coin
+		amount   int64
+		fee      int64
+
+		// want lists the values of the picked coins in ascending order.
+		want       []int64
+		wantChange int64
+		wantFee    int64
+		wantErr    error
+	}{
+		{
+			name:     "bnb exact match",
+			strategy: BranchAndBound,
+			coins:    coins(5000, 3000, 2000, 7000),
+			amount:   9000,
+			fee:      1000,
+			want:     []int64{3000, 7000},
+			wantFee:  1000,
+		},
+		{
+			name:     "bnb leaves waste below dust to the fee",
+			strategy: BranchAndBound,
+			coins:    coins(6000, 4500, 20000),
+			amount:   10000,
+			want:     []int64{4500, 6000},
+			wantFee:  500,
+		},
+		{
+			name:       "bnb falls back to largest first",
+			strategy:   BranchAndBound,
+			coins:      coins(20000, 3000),
+			amount:     5000,
+			fee:        100,
+			want:       []int64{20000},
+			wantChange: 14900,
+			wantFee:    100,
+		},
+		{
+			name:       "largest first",
+			strategy:   LargestFirst,
+			coins:      coins(1000, 5000, 3000),
+			amount:     4000,
+			want:       []int64{5000},
+			wantChange: 1000,
+		},
+		{
+			name:       "largest first takes another coin over dust change",
+			strategy:   LargestFirst,
+			coins:      coins(5000, 4500, 2000),
+			amount:     4400,
+			fee:        100,
+			want:       []int64{4500, 5000},
+			wantChange: 5000,
+			wantFee:    100,
+		},
+		{
+			name:       "smallest first",
+			strategy:   SmallestFirst,
+			coins:      coins(1000, 5000, 3000),
+			amount:     3500,
+			want:       []int64{1000, 3000, 5000},
+			wantChange: 5500,
+		},
+		{
+			name:     "smallest first settles for dust when coins run out",
+			strategy: SmallestFirst,
+			coins:    coins(1000, 3000),
+			amount:   3800,
+			want:     []int64{1000, 3000},
+			wantFee:  200,
+		},
+		{
+			name:       "spend all",
+			strategy:   SpendAll,
+			coins:      coins(1000, 2000),
+			amount:     500,
+			fee:        100,
+			want:       []int64{1000, 2000},
+			wantChange: 2400,
+			wantFee:    100,
+		},
+		{
+			name:     "unknown strategy",
+			strategy: "random",
+			coins:    coins(1000),
+			amount:   500,
+			wantErr:  ErrUnknownStrategy,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sel, err := Select(tt.strategy, tt.coins, tt.amount, tt.fee)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Select error %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			var got []int64
+			var total int64
+			for _, c := range sel.Coins {
+				got = append(got, c.Value)
+				total += c.Value
+			}
+			slices.Sort(got)
+
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("picked %v, want %v", got, tt.want)
+			}
+			if sel.Total != total {
+				t.Errorf("total %d, coins add up to %d", sel.Total, total)
+			}
+			if sel.Change != tt.wantChange {
+				t.Errorf("change %d, want %d", sel.Change, tt.wantChange)
+			}
+			if sel.Fee != tt.wantFee {
+				t.Errorf("fee %d, want %d", sel.Fee, tt.wantFee)
+			}
+			if sel.Total != tt.amount+sel.Fee+sel.Change {
+				t.Errorf("total %d does not cover amount %d, fee %d and change %d", sel.Total, tt.amount, sel.Fee, sel.Change)
+			}
+		})
+	}
+}
+
+func TestSelectInsufficientFunds(t *testing.T) {
+	for _, strategy := range []Strategy{BranchAndBound, LargestFirst, SmallestFirst, SpendAll} {
+		if _, err := Select(strategy, coins(1000, 2000), 3000, 1); !errors.Is(err, ErrInsufficientFunds) {
+			t.Errorf("%s: Select error %v, want %v", strategy, err, ErrInsufficientFunds)
+		}
+	}
+}
+
+func TestParseStrategy(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    Strategy
+		wantErr error
+	}{
+		{"", DefaultStrategy, nil},
+		{"bnb", BranchAndBound, nil},
+		{"largest", LargestFirst, nil},
+		{"smallest", SmallestFirst, nil},
+		{"all", SpendAll, nil},
+		{"fifo", "", ErrUnknownStrategy},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseStrategy(tt.name)
+		if got != tt.want || !errors.Is(err, tt.wantErr) {
+			t.Errorf("ParseStrategy(%q) = %q, %v, want %q, %v", tt.name, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
@@ -26,6 +26,10 @@ type Config struct {
 	Wallet_Padding        string
 	Peer_TTL_Minute       int64
 	Seed_Url              string
+	MempoolMaxMB          int64
+	MempoolExpiryHours    int64
+	MinRelayFeeRate       int64
+	MempoolFullRBF        bool
 }
 
 func New() *Config {
@@ -39,6 +43,10 @@ func New() *Config {
 		Wallet_Padding:        GetEnvAsStr("WALLET_PADDING", ""),
 		Peer_TTL_Minute:       GetEnvAsInt("PEER_TTL_MINUTE", 6),
 		Seed_Url:              GetEnvAsStr("SEED_URL", "localhost:3001"),
+		MempoolMaxMB:          GetEnvAsInt("MEMPOOL_MAX_MB", 64),
+		MempoolExpiryHours:    GetEnvAsInt("MEMPOOL_EXPIRY_HOURS", 336),
+		MinRelayFeeRate:       GetEnvAsInt("MIN_RELAY_FEE_RATE", 1),
+		MempoolFullRBF:        GetEnvAsBool("MEMPOOL_FULL_RBF", false),
 	}
 }
 
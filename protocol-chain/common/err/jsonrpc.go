@@ -8,6 +8,7 @@ const (
 	CodeNotFound        = -32001
 	CodeInvalidArgument = -32002
 	CodeDatabase        = -32010
+	CodeWalletLocked    = -32020
 )
 
 type RPCError struct {
@@ -36,6 +37,13 @@ func ErrDatabase(msg ...string) *RPCError {
 	return &RPCError{Code: CodeDatabase, Message: "Database error"}
 }
 
+func ErrWalletLocked(msg ...string) *RPCError {
+	if len(msg) > 0 {
+		return &RPCError{Code: CodeWalletLocked, Message: strings.Join(msg, " ")}
+	}
+	return &RPCError{Code: CodeWalletLocked, Message: "Wallet is locked, unlock it with wallet unlock first"}
+}
+
 func ErrInternal(msg ...string) *RPCError {
 	if len(msg) > 0 {
 		return &RPCError{Code: CodeInternal, Message: strings.Join(msg, " ")}
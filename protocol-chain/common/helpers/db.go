@@ -3,13 +3,42 @@ package helpers
 import (
 	blockchain "core-blockchain/core"
 	"os"
+	"sync"
 	"syscall"
 
 	log "github.com/sirupsen/logrus"
 	"github.com/vrecan/death"
 )
 
+var (
+	closeHooksMu sync.Mutex
+	closeHooks   []func()
+)
+
+// OnCloseDB registers fn to run once before SafeCloseDB closes the
+// database, so state kept in memory can be written out on shutdown.
+func OnCloseDB(fn func()) {
+	closeHooksMu.Lock()
+	defer closeHooksMu.Unlock()
+
+	closeHooks = append(closeHooks, fn)
+}
+
+// runCloseHooks holds the lock while the hooks run, so a concurrent
+// SafeCloseDB waits for them before closing the database.
+func runCloseHooks() {
+	closeHooksMu.Lock()
+	defer closeHooksMu.Unlock()
+
+	for _, fn := range closeHooks {
+		fn()
+	}
+	closeHooks = nil
+}
+
 func SafeCloseDB(bc *blockchain.Blockchain) {
+	runCloseHooks()
+
 	if bc == nil || bc.Database == nil {
 		log.Warn("Blockchain or database is nil, nothing to close.")
 		return
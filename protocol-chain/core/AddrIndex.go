@@ -0,0 +1,472 @@
+package blockchain
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"github.com/dgraph-io/badger"
+	log "github.com/sirupsen/logrus"
+)
+
+var (
+	addrHistoryPrefix = []byte("addr-")
+	addrUTXOPrefix    = []byte("addru-")
+)
+
+const (
+	// AddrIndexKey marks that the address index has been built. Like the
+	// txindex it is only maintained once Reindex created it.
+	AddrIndexKey = "addrindex"
+)
+
+// AddressTx is a main chain transaction that spends from or pays to an address.
+type AddressTx struct {
+	TxID      []byte
+	BlockHash []byte
+	Height    int64
+	Position  uint32
+}
+
+type AddressUTXO struct {
+	TxID   []byte
+	Index  int64
+	Output TxOutput
+}
+
+type AddrIndex struct {
+	Blockchain *Blockchain
+}
+
+func addrPrefix(prefix, pubKeyHash []byte) []byte {
+	key := make([]byte, 0, len(prefix)+len(pubKeyHash))
+	key = append(key, prefix...)
+	return append(key, pubKeyHash...)
+}
+
+// addrHistoryKey sorts entries of one address by height then position.
+func addrHistoryKey(pubKeyHash []byte, height int64, pos uint32) []byte {
+	key := addrPrefix(addrHistoryPrefix, pubKeyHash)
+	key = binary.BigEndian.AppendUint64(key, uint64(height))
+	return binary.BigEndian.AppendUint32(key, pos)
+}
+
+func addrUTXOKey(pubKeyHash, txID []byte, index int64) []byte {
+	key := addrPrefix(addrUTXOPrefix, pubKeyHash)
+	key = append(key, txID...)
+	return binary.BigEndian.AppendUint64(key, uint64(index))
+}
+
+func addrIndexEnabled(txn *badger.Txn) (bool, error) {
+	_, err := txn.Get([]byte(AddrIndexKey))
+	if err != nil {
+		if errors.Is(err, badger.ErrKeyNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	return true, nil
+}
+
+func (ai *AddrIndex) Enabled() bool {
+	enabled := false
+
+	err := ai.Blockchain.Database.View(func(txn *badger.Txn) error {
+		var err error
+		enabled, err = addrIndexEnabled(txn)
+		return err
+	})
+	if err != nil {
+		log.Errorf("Read addrindex state with error: %v", err)
+		return false
+	}
+
+	return enabled
+}
+
+// touchedAddresses returns the address hashes, public key or script hashes,
+// a transaction spends from or pays to, each once.
+func touchedAddresses(tx *Transaction) [][]byte {
+	seen := make(map[string]bool)
+	var hashes [][]byte
+
+	add := func(pubKeyHash []byte) {
+		k := hex.EncodeToString(pubKeyHash)
+		if len(pubKeyHash) == 0 || seen[k] {
+			return
+		}
+		seen[k] = true
+		hashes = append(hashes, pubKeyHash)
+	}
+
+	if !tx.IsMinerTx() {
+		for _, in := range tx.Inputs {
+			add(in.AddressHash())
+		}
+	}
+	for _, out := range tx.Outputs {
+		add(out.AddressHash())
+	}
+
+	return hashes
+}
+
+func (ai *AddrIndex) putHistory(txn *badger.Txn, bl *Block) error {
+	for pos, tx := range bl.Transactions {
+		data, err := GobEncode(AddressTx{
+			TxID:      tx.ID,
+			BlockHash: bl.Hash,
+			Height:    bl.Height,
+			Position:  uint32(pos),
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, pubKeyHash := range touchedAddresses(tx) {
+			if err := txn.Set(addrHistoryKey(pubKeyHash, bl.Height, uint32(pos)), data); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// putOutputs indexes the outputs of tx under their address hash, outputs
+// without an address are left out.
+func (ai *AddrIndex) putOutputs(txn *badger.Txn, tx *Transaction) error {
+	for outIdx, out := range tx.Outputs {
+		hash := out.AddressHash()
+		if hash == nil {
+			continue
+		}
+
+		data, err := GobEncode(AddressUTXO{TxID: tx.ID, Index: int64(outIdx), Output: out})
+		if err != nil {
+			return err
+		}
+
+		if err := txn.Set(addrUTXOKey(hash, tx.ID, int64(outIdx)), data); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func readUndo(txn *badger.Txn, blockHash []byte) (*BlockUndo, error) {
+	item, err := txn.Get(undoKey(blockHash))
+	if err != nil {
+		if errors.Is(err, badger.ErrKeyNotFound) {
+			return nil, fmt.Errorf("block %x: %w", blockHash, ErrUndoNotFound)
+		}
+		return nil, err
+	}
+
+	v, err := item.ValueCopy(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	undo, err := GobDecode[BlockUndo](v)
+	if err != nil {
+		return nil, err
+	}
+
+	return &undo, nil
+}
+
+// ConnectBlock records the history of bl and moves the outputs it spends and
+// creates. It reads the undo data written by UTXOSet.ConnectBlock, so it runs
+// after it in the same transaction. It is a no-op while the index has not
+// been built.
+func (ai *AddrIndex) ConnectBlock(txn *badger.Txn, bl *Block) error {
+	enabled, err := addrIndexEnabled(txn)
+	if err != nil || !enabled {
+		return err
+	}
+
+	undo, err := readUndo(txn, bl.Hash)
+	if err != nil {
+		return err
+	}
+
+	if err := ai.putHistory(txn, bl); err != nil {
+		return err
+	}
+
+	// Outputs go in before the spent ones are deleted, so the ones spent by
+	// a child in the same block do not come back.
+	for _, tx := range bl.Transactions {
+		if err := ai.putOutputs(txn, tx); err != nil {
+			return err
+		}
+	}
+
+	for _, spent := range undo.Spent {
+		hash := spent.Output.AddressHash()
+		if hash == nil {
+			continue
+		}
+		if err := txn.Delete(addrUTXOKey(hash, spent.TxID, spent.Index)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// DisconnectBlock reverts ConnectBlock. Spent outputs are restored from the
+// block's undo data, so it must run before UTXOSet.DisconnectBlock drops it.
+func (ai *AddrIndex) DisconnectBlock(txn *badger.Txn, bl *Block) error {
+	enabled, err := addrIndexEnabled(txn)
+	if err != nil || !enabled {
+		return err
+	}
+
+	undo, err := readUndo(txn, bl.Hash)
+	if err != nil {
+		return err
+	}
+
+	// Spent outputs are restored first, the block's own outputs deleted
+	// after them include the ones its children spent.
+	for _, spent := range undo.Spent {
+		hash := spent.Output.AddressHash()
+		if hash == nil {
+			continue
+		}
+
+		data, err := GobEncode(AddressUTXO{TxID: spent.TxID, Index: spent.Index, Output: spent.Output})
+		if err != nil {
+			return err
+		}
+
+		if err := txn.Set(addrUTXOKey(hash, spent.TxID, spent.Index), data); err != nil {
+			return err
+		}
+	}
+
+	for pos, tx := range bl.Transactions {
+		for _, pubKeyHash := range touchedAddresses(tx) {
+			if err := txn.Delete(addrHistoryKey(pubKeyHash, bl.Height, uint32(pos))); err != nil {
+				return err
+			}
+		}
+
+		for outIdx, out := range tx.Outputs {
+			hash := out.AddressHash()
+			if hash == nil {
+				continue
+			}
+			if err := txn.Delete(addrUTXOKey(hash, tx.ID, int64(outIdx))); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// History pages through the transactions of pubKeyHash, newest first, and
+// returns the total number of entries.
+func (ai *AddrIndex) History(pubKeyHash []byte, offset, limit int) ([]AddressTx, int, error) {
+	var txs []AddressTx
+	total := 0
+
+	err := ai.Blockchain.Database.View(func(txn *badger.Txn) error {
+		prefix := addrPrefix(addrHistoryPrefix, pubKeyHash)
+
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = false
+		opts.Reverse = true
+		it := txn.NewIterator(opts)
+
+		defer it.Close()
+
+		seek := append(append([]byte{}, prefix...), 0xff)
+		for it.Seek(seek); it.ValidForPrefix(prefix); it.Next() {
+			// Keys are prefix || height || position, anything else belongs
+			// to a longer pubkey hash sharing the prefix.
+			if len(it.Item().Key()) != len(prefix)+12 {
+				continue
+			}
+
+			if total >= offset && len(txs) < limit {
+				v, err := it.Item().ValueCopy(nil)
+				if err != nil {
+					return err
+				}
+
+				entry, err := GobDecode[AddressTx](v)
+				if err != nil {
+					return err
+				}
+				txs = append(txs, entry)
+			}
+			total++
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return txs, total, nil
+}
+
+// UTXOs pages through the unspent outputs locked to pubKeyHash and returns
+// the total count along with their summed value.
+func (ai *AddrIndex) UTXOs(pubKeyHash []byte, offset, limit int) ([]AddressUTXO, int, int64, error) {
+	var utxos []AddressUTXO
+	total := 0
+	var balance int64
+
+	err := ai.Blockchain.Database.View(func(txn *badger.Txn) error {
+		prefix := addrPrefix(addrUTXOPrefix, pubKeyHash)
+
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = true
+		it := txn.NewIterator(opts)
+
+		defer it.Close()
+
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			v, err := it.Item().ValueCopy(nil)
+			if err != nil {
+				return err
+			}
+
+			entry, err := GobDecode[AddressUTXO](v)
+			if err != nil {
+				return err
+			}
+
+			if !bytes.Equal(entry.Output.AddressHash(), pubKeyHash) {
+				continue
+			}
+
+			if total >= offset && len(utxos) < limit {
+				utxos = append(utxos, entry)
+			}
+			total++
+			balance += entry.Output.Value
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, 0, 0, err
+	}
+
+	return utxos, total, balance, nil
+}
+
+// Reindex rebuilds the address index: history from the main chain and the
+// unspent outputs from the UTXO set, which has to be up to date.
+func (ai *AddrIndex) Reindex() (int, error) {
+	err := ai.Blockchain.Database.Update(func(txn *badger.Txn) error {
+		return txn.Delete([]byte(AddrIndexKey))
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	utxoSet := UTXOSet{Blockchain: ai.Blockchain}
+	utxoSet.DeteleByPrefix(addrHistoryPrefix)
+	utxoSet.DeteleByPrefix(addrUTXOPrefix)
+
+	iter, err := ai.Blockchain.Iterator()
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+
+	for {
+		block, err := iter.Next()
+		if err != nil {
+			return count, err
+		}
+
+		err = ai.Blockchain.Database.Update(func(txn *badger.Txn) error {
+			return ai.putHistory(txn, block)
+		})
+		if err != nil {
+			return count, fmt.Errorf("index block %x: %w", block.Hash, err)
+		}
+		count += len(block.Transactions)
+
+		if len(block.PrevHash) == 0 {
+			break
+		}
+	}
+
+	var entries []AddressUTXO
+
+	err = ai.Blockchain.Database.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = true
+		it := txn.NewIterator(opts)
+
+		defer it.Close()
+
+		for it.Seek(utxoPrefix); it.ValidForPrefix(utxoPrefix); it.Next() {
+			item := it.Item()
+
+			v, err := item.ValueCopy(nil)
+			if err != nil {
+				return err
+			}
+			outs, err := DeSerializeOuputs(v)
+			if err != nil {
+				return err
+			}
+
+			txID := bytes.TrimPrefix(item.KeyCopy(nil), utxoPrefix)
+			for i, out := range outs.Outputs {
+				if out.AddressHash() == nil {
+					continue
+				}
+				entries = append(entries, AddressUTXO{TxID: txID, Index: outs.IndexAt(i), Output: out})
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return count, err
+	}
+
+	// The UTXO set does not fit in one transaction, the write batch
+	// commits as it fills up. The index only counts as built once all of
+	// it is written.
+	wb := ai.Blockchain.Database.NewWriteBatch()
+	defer wb.Cancel()
+
+	for _, entry := range entries {
+		data, err := GobEncode(entry)
+		if err != nil {
+			return count, err
+		}
+
+		if err := wb.Set(addrUTXOKey(entry.Output.AddressHash(), entry.TxID, entry.Index), data); err != nil {
+			return count, err
+		}
+	}
+
+	if err := wb.Flush(); err != nil {
+		return count, err
+	}
+
+	err = ai.Blockchain.Database.Update(func(txn *badger.Txn) error {
+		return txn.Set([]byte(AddrIndexKey), []byte{1})
+	})
+
+	return count, err
+}
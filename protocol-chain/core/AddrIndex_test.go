@@ -0,0 +1,198 @@
+package blockchain
+
+import (
+	"core-blockchain/wallet"
+	"fmt"
+	"maps"
+	"slices"
+	"testing"
+
+	"github.com/dgraph-io/badger"
+)
+
+// addrTx builds a transaction of owner spending inputs, given as tx name and
+// output index, to outputs given as recipient name and value.
+func addrTx(name, owner string, inputs []string, outputs ...any) *Transaction {
+	tx := &Transaction{ID: testTxID(name)}
+
+	for _, in := range inputs {
+		var prev string
+		var out int64
+		fmt.Sscanf(in, "%s %d", &prev, &out)
+		tx.Inputs = append(tx.Inputs, TxInput{ID: testTxID(prev), Out: out, PubKey: []byte(owner)})
+	}
+
+	for i := 0; i < len(outputs); i += 2 {
+		tx.Outputs = append(tx.Outputs, TxOutput{
+			Value:      int64(outputs[i+1].(int)),
+			PubKeyHash: wallet.PublicKeyHash([]byte(outputs[i].(string))),
+		})
+	}
+
+	return tx
+}
+
+// addrState is what the index reports for one address: its unspent
+// outpoints and the height and position of its history, newest first.
+type addrState struct {
+	UTXOs   []string
+	History []string
+}
+
+func addrIndexState(t *testing.T, ai *AddrIndex, owners ...string) map[string]addrState {
+	t.Helper()
+
+	state := make(map[string]addrState)
+	for _, owner := range owners {
+		pubKeyHash := wallet.PublicKeyHash([]byte(owner))
+
+		utxos, _, _, err := ai.UTXOs(pubKeyHash, 0, 100)
+		if err != nil {
+			t.Fatal(err)
+		}
+		history, _, err := ai.History(pubKeyHash, 0, 100)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var s addrState
+		for _, u := range utxos {
+			s.UTXOs = append(s.UTXOs, fmt.Sprintf("%x:%d", u.TxID, u.Index))
+		}
+		slices.Sort(s.UTXOs)
+		for _, h := range history {
+			s.History = append(s.History, fmt.Sprintf("%d/%d", h.Height, h.Position))
+		}
+		state[owner] = s
+	}
+
+	return state
+}
+
+func equalAddrStates(a, b map[string]addrState) bool {
+	return maps.EqualFunc(a, b, func(x, y addrState) bool {
+		return slices.Equal(x.UTXOs, y.UTXOs) && slices.Equal(x.History, y.History)
+	})
+}
+
+func TestAddrIndexConnectDisconnect(t *testing.T) {
+	owners := []string{"alice", "bob", "carol"}
+	base := []*Transaction{addrTx("a", "", nil, "alice", 100)}
+
+	tests := []struct {
+		name  string
+		block []*Transaction
+		want  map[string]addrState
+	}{
+		{
+			name:  "pay with change",
+			block: []*Transaction{addrTx("b", "alice", []string{"a 0"}, "bob", 60, "alice", 30)},
+			want: map[string]addrState{
+				"alice": {UTXOs: []string{outpoint("b", 1)}, History: []string{"2/0", "1/0"}},
+				"bob":   {UTXOs: []string{outpoint("b", 0)}, History: []string{"2/0"}},
+				"carol": {},
+			},
+		},
+		{
+			name: "spend output created in the same block",
+			block: []*Transaction{
+				addrTx("b", "alice", []string{"a 0"}, "bob", 90),
+				addrTx("c", "bob", []string{"b 0"}, "carol", 80),
+			},
+			want: map[string]addrState{
+				"alice": {History: []string{"2/0", "1/0"}},
+				"bob":   {History: []string{"2/1", "2/0"}},
+				"carol": {UTXOs: []string{outpoint("c", 0)}, History: []string{"2/1"}},
+			},
+		},
+		{
+			name: "pay to self",
+			block: []*Transaction{
+				addrTx("b", "alice", []string{"a 0"}, "alice", 50, "alice", 40),
+			},
+			want: map[string]addrState{
+				"alice": {UTXOs: []string{outpoint("b", 0), outpoint("b", 1)}, History: []string{"2/0", "1/0"}},
+				"bob":   {},
+				"carol": {},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bc := newTestChain(t)
+			utxoSet := UTXOSet{Blockchain: bc}
+			ai := AddrIndex{Blockchain: bc}
+
+			err := bc.Database.Update(func(txn *badger.Txn) error {
+				return txn.Set([]byte(AddrIndexKey), []byte{1})
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			connect := func(block *Block) error {
+				return bc.Database.Update(func(txn *badger.Txn) error {
+					if err := utxoSet.ConnectBlock(txn, block); err != nil {
+						return err
+					}
+					return ai.ConnectBlock(txn, block)
+				})
+			}
+			disconnect := func(block *Block) error {
+				return bc.Database.Update(func(txn *badger.Txn) error {
+					if err := ai.DisconnectBlock(txn, block); err != nil {
+						return err
+					}
+					return utxoSet.DisconnectBlock(txn, block)
+				})
+			}
+
+			if err := connect(&Block{Hash: testTxID("base"), Height: 1, Transactions: base}); err != nil {
+				t.Fatal(err)
+			}
+			before := addrIndexState(t, &ai, owners...)
+
+			block := &Block{Hash: testTxID(tt.name), Height: 2, Transactions: tt.block}
+			if err := connect(block); err != nil {
+				t.Fatalf("connect: %v", err)
+			}
+			if got := addrIndexState(t, &ai, owners...); !equalAddrStates(got, tt.want) {
+				t.Errorf("after connect %v, want %v", got, tt.want)
+			}
+
+			if err := disconnect(block); err != nil {
+				t.Fatalf("disconnect: %v", err)
+			}
+			if got := addrIndexState(t, &ai, owners...); !equalAddrStates(got, before) {
+				t.Errorf("after disconnect %v, want %v", got, before)
+			}
+		})
+	}
+}
+
+func TestAddrIndexDisabled(t *testing.T) {
+	bc := newTestChain(t)
+	utxoSet := UTXOSet{Blockchain: bc}
+	ai := AddrIndex{Blockchain: bc}
+
+	block := &Block{Hash: testTxID("base"), Height: 1, Transactions: []*Transaction{addrTx("a", "", nil, "alice", 100)}}
+	err := bc.Database.Update(func(txn *badger.Txn) error {
+		if err := utxoSet.ConnectBlock(txn, block); err != nil {
+			return err
+		}
+		return ai.ConnectBlock(txn, block)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ai.Enabled() {
+		t.Error("index enabled before Reindex")
+	}
+
+	want := map[string]addrState{"alice": {}}
+	if got := addrIndexState(t, &ai, "alice"); !equalAddrStates(got, want) {
+		t.Errorf("disabled index wrote %v", got)
+	}
+}
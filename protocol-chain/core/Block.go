@@ -3,7 +3,9 @@ package blockchain
 import (
 	"bytes"
 	"context"
+	"core-blockchain/chaincfg"
 	"crypto/sha256"
+	"errors"
 	"fmt"
 	"math/big"
 	"time"
@@ -11,6 +13,9 @@ import (
 	log "github.com/sirupsen/logrus"
 )
 
+// ErrBadBlock marks a block that is invalid whatever chain it is added to.
+var ErrBadBlock = errors.New("bad block")
+
 type Block struct {
 	Timestamp    int64          `json:"Timestamp"`
 	Hash         []byte         `json:"Hash"`
@@ -25,16 +30,30 @@ type Block struct {
 	NChainWork *big.Int `json:"NChainWork"`
 }
 
-func CreateBlock(txs []*Transaction, prevHash []byte, newBlockHeight int64, NBits uint32, ctx context.Context) (*Block, error) {
+// CreateBlock mines a block on top of prev. Its timestamp is the current
+// time, or one second past prev when blocks are found faster than that.
+func CreateBlock(txs []*Transaction, prev *Block, NBits uint32, ctx context.Context) (*Block, error) {
+	timestamp := time.Now().Unix()
+	if timestamp <= prev.Timestamp {
+		timestamp = prev.Timestamp + 1
+	}
+
 	block := &Block{
-		Timestamp:    time.Now().Unix(),
-		PrevHash:     prevHash,
+		Timestamp:    timestamp,
+		PrevHash:     prev.Hash,
 		Transactions: txs,
 		NBits:        NBits,
-		Height:       newBlockHeight,
+		Height:       prev.Height + 1,
 		TxCount:      int64(len(txs)),
 	}
 
+	merkleRoot, err := block.HashTransactions()
+	if err != nil {
+		return nil, err
+	}
+
+	block.MerkleRoot = merkleRoot
+
 	pow := NewProof(block)
 	start := time.Now()
 	nonce, hash, err := pow.Run(ctx)
@@ -48,13 +67,6 @@ func CreateBlock(txs []*Transaction, prevHash []byte, newBlockHeight int64, NBit
 	block.Hash = hash
 	block.Nonce = nonce
 
-	merkleRoot, err := block.HashTransactions()
-	if err != nil {
-		return nil, err
-	}
-
-	block.MerkleRoot = merkleRoot
-
 	return block, nil
 }
 
@@ -65,16 +77,25 @@ func (b *Block) Size() (int, error) {
 	return len(dataBytes), nil
 }
 
-func Genesis(MinerTx *Transaction) (*Block, error) {
+func Genesis(params *chaincfg.Params, MinerTx *Transaction) (*Block, error) {
 	block := &Block{
-		Timestamp:    1758441999,
+		Timestamp:    params.GenesisTimestamp,
 		PrevHash:     nil,
 		Transactions: []*Transaction{MinerTx},
-		NBits:        0x1f00ffff,
+		NBits:        params.GenesisNBits,
 		Height:       1,
 		TxCount:      1,
 	}
 
+	// The header committed to from HeaderActivationHeight on holds the
+	// merkle root.
+	merkleRoot, err := block.HashTransactions()
+	if err != nil {
+		return nil, err
+	}
+
+	block.MerkleRoot = merkleRoot
+
 	pow := NewProof(block)
 	start := time.Now()
 	nonce := int64(1)
@@ -92,13 +113,6 @@ func Genesis(MinerTx *Transaction) (*Block, error) {
 	block.Hash = hash[:]
 	block.Nonce = nonce
 
-	merkleRoot, err := block.HashTransactions()
-	if err != nil {
-		return nil, err
-	}
-
-	block.MerkleRoot = merkleRoot
-
 	return block, nil
 }
 
@@ -107,7 +121,7 @@ func (b *Block) HashTransactions() ([]byte, error) {
 
 	for _, tx := range b.Transactions {
 		serialize := new(bytes.Buffer)
-		SerializeTransaction(tx, serialize)
+		SerializeTransactionAt(tx, serialize, b.Height)
 
 		txHashes = append(txHashes, serialize.Bytes())
 	}
@@ -120,6 +134,34 @@ func (b *Block) HashTransactions() ([]byte, error) {
 	return tree.RootNode.Data, nil
 }
 
+// CheckSanity runs the checks that do not depend on the chain the block
+// connects to: size, merkle root and proof of work. A block failing them was
+// relayed by a peer that did not check it, one passing them can still be
+// invalid on top of its parent.
+func (b *Block) CheckSanity() error {
+	size, err := b.Size()
+	if err != nil {
+		return err
+	}
+	if size > MaxBlockSize {
+		return fmt.Errorf("%w: size %d exceeds %d", ErrBadBlock, size, MaxBlockSize)
+	}
+
+	merkleRoot, err := b.HashTransactions()
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrBadBlock, err)
+	}
+	if !bytes.Equal(b.MerkleRoot, merkleRoot) {
+		return fmt.Errorf("%w: merkle root mismatch", ErrBadBlock)
+	}
+
+	if !NewProof(b).Validate() {
+		return fmt.Errorf("%w: proof of work invalid", ErrBadBlock)
+	}
+
+	return nil
+}
+
 func (b *Block) IsBlockValid(oldBlock Block) bool {
 	if b.Height != oldBlock.Height+1 {
 		log.Warning("Block is not valid")
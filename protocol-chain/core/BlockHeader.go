@@ -0,0 +1,118 @@
+package blockchain
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"math/big"
+)
+
+const (
+	// BlockHeaderSize is the encoded size of a BlockHeader:
+	// version(4) | prev hash(32) | merkle root(32) | timestamp(8) | nbits(4) | nonce(8).
+	BlockHeaderSize = 88
+
+	BlockVersion int32 = 1
+)
+
+// IsHeaderActive reports whether the proof of work at height commits to the
+// serialized BlockHeader, see chaincfg.Params.HeaderActivationHeight. Older
+// blocks hash the full transaction list, so their headers can only be
+// checked once the body arrives.
+func IsHeaderActive(height int64) bool {
+	return height >= ActiveParams().HeaderActivationHeight
+}
+
+type BlockHeader struct {
+	Version    int32
+	PrevHash   [32]byte
+	MerkleRoot [32]byte
+	Timestamp  int64
+	NBits      uint32
+	Nonce      int64
+}
+
+// Header returns the fixed-size header of b. Blocks below
+// HeaderActivationHeight report version 0.
+func (b *Block) Header() BlockHeader {
+	header := BlockHeader{
+		Timestamp: b.Timestamp,
+		NBits:     b.NBits,
+		Nonce:     b.Nonce,
+	}
+
+	if IsHeaderActive(b.Height) {
+		header.Version = BlockVersion
+	}
+
+	copy(header.PrevHash[:], b.PrevHash)
+	copy(header.MerkleRoot[:], b.MerkleRoot)
+
+	return header
+}
+
+func (h *BlockHeader) Serialize() []byte {
+	buf := bytes.NewBuffer(make([]byte, 0, BlockHeaderSize))
+
+	binary.Write(buf, binary.LittleEndian, h.Version)
+	buf.Write(h.PrevHash[:])
+	buf.Write(h.MerkleRoot[:])
+	binary.Write(buf, binary.LittleEndian, h.Timestamp)
+	binary.Write(buf, binary.LittleEndian, h.NBits)
+	binary.Write(buf, binary.LittleEndian, h.Nonce)
+
+	return buf.Bytes()
+}
+
+func DeserializeBlockHeader(data []byte) (*BlockHeader, error) {
+	if len(data) != BlockHeaderSize {
+		return nil, fmt.Errorf("invalid header size %d, want %d", len(data), BlockHeaderSize)
+	}
+
+	h := &BlockHeader{}
+	buf := bytes.NewReader(data)
+
+	binary.Read(buf, binary.LittleEndian, &h.Version)
+	buf.Read(h.PrevHash[:])
+	buf.Read(h.MerkleRoot[:])
+	binary.Read(buf, binary.LittleEndian, &h.Timestamp)
+	binary.Read(buf, binary.LittleEndian, &h.NBits)
+	binary.Read(buf, binary.LittleEndian, &h.Nonce)
+
+	return h, nil
+}
+
+// PrevBlockHash returns PrevHash the way blocks store it, nil for genesis.
+func (h *BlockHeader) PrevBlockHash() []byte {
+	if h.PrevHash == [32]byte{} {
+		return nil
+	}
+
+	return append([]byte{}, h.PrevHash[:]...)
+}
+
+func (h *BlockHeader) Hash() []byte {
+	hash := sha256.Sum256(h.Serialize())
+	return hash[:]
+}
+
+// LegacyHash is the hash of a block below HeaderActivationHeight with this
+// header. The legacy proof of work also commits to the height and the
+// number of transactions, which the header does not hold.
+func (h *BlockHeader) LegacyHash(height, txCount int64) []byte {
+	hash := sha256.Sum256(legacyPowData(h.MerkleRoot[:], h.PrevBlockHash(), h.Nonce, h.NBits, height, h.Timestamp, txCount))
+	return hash[:]
+}
+
+// CheckProofOfWork reports whether the header hash is below its target.
+func (h *BlockHeader) CheckProofOfWork() bool {
+	return meetsTarget(h.Hash(), h.NBits)
+}
+
+func meetsTarget(hash []byte, nbits uint32) bool {
+	var hashInt big.Int
+	hashInt.SetBytes(hash)
+
+	return hashInt.Cmp(CompactToBig(nbits)) == -1
+}
@@ -5,12 +5,11 @@ import "math/big"
 const (
 	PER_COIN             int64 = 100_000_000
 	INITIAL_BLOCK_REWARD int64 = 50 * PER_COIN
-	HALVING_INTERVAL     int64 = 210000
 	MAX_HALVING          int64 = 64
 )
 
 func (bc *Blockchain) GetReward(height int64) *CoinAmount {
-	numHalvings := height / HALVING_INTERVAL
+	numHalvings := height / bc.Params.HalvingInterval
 
 	if numHalvings >= MAX_HALVING {
 		return ZeroAmount()
@@ -30,19 +29,22 @@ func (bc *Blockchain) GetBlockReward(height int64, address string) (*Transaction
 	rewardBlock := bc.GetReward(height)
 
 	txIn := TxInput{
-		[]byte{},
-		-1,
-		[]byte{},
-		[]byte{},
+		ID:        []byte{},
+		Out:       -1,
+		Signature: []byte{},
+		PubKey:    []byte{},
 	}
 
-	txOut := NewTxOutput(rewardBlock.ToFloat(), address)
+	txOut := NewTxOutput(rewardBlock.Units(), address)
 
 	tx := Transaction{
 		ID:      nil,
 		Inputs:  []TxInput{txIn},
 		Outputs: []TxOutput{*txOut},
 	}
+	if err := tx.CheckScripts(height); err != nil {
+		return nil, err
+	}
 
 	txIdHash, err := tx.Hash(height)
 	if err != nil {
@@ -3,6 +3,7 @@ package blockchain
 import (
 	"bytes"
 	"context"
+	"core-blockchain/chaincfg"
 	"crypto/ecdsa"
 	"encoding/hex"
 	"errors"
@@ -12,6 +13,7 @@ import (
 	"path/filepath"
 	"runtime"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/dgraph-io/badger"
@@ -22,16 +24,14 @@ type Blockchain struct {
 	LastHash   []byte
 	Database   *badger.DB
 	InstanceId string
-}
+	Params     *chaincfg.Params
 
-var (
-	MaxTarget = CompactToBig(0x1f00ffff)
-)
+	// missingCursor is the lowest best header chain height that may lack
+	// its block, see HeaderIndex.MissingBlocks.
+	missingCursor atomic.Int64
+}
 
 const (
-	AdjustmentInterval = 100
-	TargetBlockTime    = 60 // 3 minute
-	MaxTimestampDrift  = 60 // 1 minute
 	CheckpointInterval = 10
 	MaxBlockSize       = 1 * 1024 * 1024 // 1mb
 	BestHeightPrefix   = "lh"
@@ -46,12 +46,34 @@ var (
 	Root = filepath.Join(filepath.Dir(file), "../")
 )
 
-func GetDatabasePath(port string) string {
+// ErrInvalidBlockTx reports a transaction MineBlock refused to include.
+var ErrInvalidBlockTx = errors.New("invalid transaction")
+
+var activeParams atomic.Pointer[chaincfg.Params]
+
+// UseParams selects the network whose activation heights encoding and
+// validation follow. A node runs a single network, opening its chain
+// selects it.
+func UseParams(params *chaincfg.Params) {
+	activeParams.Store(params)
+}
+
+// ActiveParams returns the network selected with UseParams, mainnet until
+// one is.
+func ActiveParams() *chaincfg.Params {
+	if params := activeParams.Load(); params != nil {
+		return params
+	}
+
+	return &chaincfg.MainNetParams
+}
+
+func GetDatabasePath(params *chaincfg.Params, port string) string {
 	if port != "" {
-		return filepath.Join(Root, fmt.Sprintf("./.chain/blocks_%s", port))
+		return filepath.Join(params.ChainDir(Root), fmt.Sprintf("blocks_%s", port))
 	}
 
-	return filepath.Join(Root, "./.chain/blocks")
+	return filepath.Join(params.ChainDir(Root), "blocks")
 }
 
 func DBExists(path string) bool {
@@ -62,15 +84,17 @@ func DBExists(path string) bool {
 	return true
 }
 
-func Exists(InstanceId string) bool {
-	return DBExists(GetDatabasePath(InstanceId))
+func Exists(params *chaincfg.Params, InstanceId string) bool {
+	return DBExists(GetDatabasePath(params, InstanceId))
 }
 
-func InitBlockchain(chainDatapath string, instanceId string) (*Blockchain, error) {
+func InitBlockchain(params *chaincfg.Params, chainDatapath string, instanceId string) (*Blockchain, error) {
+	UseParams(params)
+
 	var lastHash []byte
-	path := GetDatabasePath(instanceId)
+	path := GetDatabasePath(params, instanceId)
 	if chainDatapath != "" {
-		path = fmt.Sprintf("%s/.chain/blocks_%s", chainDatapath, instanceId)
+		path = filepath.Join(params.ChainDir(chainDatapath), fmt.Sprintf("blocks_%s", instanceId))
 	}
 
 	if DBExists(path) {
@@ -89,13 +113,13 @@ func InitBlockchain(chainDatapath string, instanceId string) (*Blockchain, error
 	}
 
 	err = db.Update(func(txn *badger.Txn) error {
-		cbtx, err := InitGenesisTx(1)
+		cbtx, err := InitGenesisTx(params, 1)
 		if err != nil {
 			return err
 		}
 		log.Info("No existing blockchain found")
 
-		genesis, err := Genesis(cbtx)
+		genesis, err := Genesis(params, cbtx)
 		if err != nil {
 			return err
 		}
@@ -129,15 +153,23 @@ func InitBlockchain(chainDatapath string, instanceId string) (*Blockchain, error
 
 		key := fmt.Sprintf("%s%d", CheckpointPrefix, genesis.Height)
 		err = txn.Set([]byte(key), genesis.Hash)
+		if err != nil {
+			return err
+		}
 
-		return err
+		headerIndex := HeaderIndex{}
+		if err := headerIndex.PutBlock(txn, genesis); err != nil {
+			return err
+		}
+
+		return txn.Set([]byte(HeaderIndexKey), []byte{1})
 	})
 
 	if err != nil {
 		return nil, err
 	}
 
-	chain := &Blockchain{lastHash, db, instanceId}
+	chain := &Blockchain{LastHash: lastHash, Database: db, InstanceId: instanceId, Params: params}
 
 	utxo := UTXOSet{
 		Blockchain: chain,
@@ -148,12 +180,12 @@ func InitBlockchain(chainDatapath string, instanceId string) (*Blockchain, error
 	return chain, nil
 }
 
-func OpenBadgerDB(instanceId string, chainDataPath ...string) (*badger.DB, error) {
+func OpenBadgerDB(params *chaincfg.Params, instanceId string, chainDataPath ...string) (*badger.DB, error) {
 
-	path := GetDatabasePath(instanceId)
+	path := GetDatabasePath(params, instanceId)
 
 	if len(chainDataPath) > 0 {
-		path = fmt.Sprintf("%s/.chain/blocks_%s", chainDataPath[0], instanceId)
+		path = filepath.Join(params.ChainDir(chainDataPath[0]), fmt.Sprintf("blocks_%s", instanceId))
 	}
 
 	log.Info("Path: ", path)
@@ -177,11 +209,15 @@ func OpenBadgerDB(instanceId string, chainDataPath ...string) (*badger.DB, error
 }
 
 func (bc *Blockchain) ContinueBlockchain(chainData ...string) (*Blockchain, error) {
+	if bc.Params != nil {
+		UseParams(bc.Params)
+	}
+
 	var lastHash []byte
 	var db *badger.DB
 
 	if bc.Database == nil {
-		database, err := OpenBadgerDB(bc.InstanceId, chainData[0])
+		database, err := OpenBadgerDB(bc.Params, bc.InstanceId, chainData[0])
 		if err != nil {
 			return nil, err
 		}
@@ -205,7 +241,7 @@ func (bc *Blockchain) ContinueBlockchain(chainData ...string) (*Blockchain, erro
 		lastHash = nil
 	}
 
-	return &Blockchain{LastHash: lastHash, Database: db, InstanceId: bc.InstanceId}, nil
+	return &Blockchain{LastHash: lastHash, Database: db, InstanceId: bc.InstanceId, Params: bc.Params}, nil
 
 }
 
@@ -227,20 +263,26 @@ func (bc *Blockchain) HasBlock(hash []byte) (bool, error) {
 }
 
 func (bc *Blockchain) AdjustDifficulty(lastBlock *Block) uint32 {
+	params := bc.Params
 
-	if lastBlock.Height%AdjustmentInterval != 0 {
+	if params.PowNoRetargeting || lastBlock.Height%params.AdjustmentInterval != 0 {
 		return lastBlock.NBits
 	}
 
-	firstHeight := lastBlock.Height - AdjustmentInterval
+	firstHeight := lastBlock.Height - params.AdjustmentInterval
 	firstBlock, err := bc.GetBlockByHeight(firstHeight)
 	if err != nil {
 		log.Error(err)
 		return lastBlock.NBits
 	}
 
-	actualTimespan := lastBlock.Timestamp - firstBlock.Timestamp
-	targetTimespan := int64(TargetBlockTime * AdjustmentInterval)
+	return retargetNBits(params, lastBlock.NBits, lastBlock.Timestamp-firstBlock.Timestamp)
+}
+
+// retargetNBits scales the target of lastNBits by how long the last
+// AdjustmentInterval blocks took compared to the expected timespan.
+func retargetNBits(params *chaincfg.Params, lastNBits uint32, actualTimespan int64) uint32 {
+	targetTimespan := params.TargetBlockTime * params.AdjustmentInterval
 
 	if actualTimespan < targetTimespan/4 {
 		actualTimespan = targetTimespan / 4
@@ -249,12 +291,12 @@ func (bc *Blockchain) AdjustDifficulty(lastBlock *Block) uint32 {
 		actualTimespan = targetTimespan * 4
 	}
 
-	oldTarget := CompactToBig(lastBlock.NBits)
+	oldTarget := CompactToBig(lastNBits)
 	newTarget := new(big.Int).Mul(oldTarget, big.NewInt(actualTimespan))
 	newTarget.Div(newTarget, big.NewInt(targetTimespan))
 
-	if newTarget.Cmp(MaxTarget) > 0 {
-		newTarget = MaxTarget
+	if maxTarget := CompactToBig(params.PowLimitBits); newTarget.Cmp(maxTarget) > 0 {
+		newTarget = maxTarget
 	}
 
 	return BigToCompact(newTarget)
@@ -389,8 +431,9 @@ func (bc *Blockchain) IsBlockValid(bl Block) bool {
 		return false
 	}
 
-	if bl.Timestamp >= currentTime+MaxTimestampDrift || bl.Timestamp <= prevBlock.Timestamp {
-		log.Warnf("Invalid timestamp: too far in future or past. Current timestamp of block %d, MaxTimestampDrift %d", bl.Timestamp, currentTime+MaxTimestampDrift)
+	maxTimestampDrift := bc.Params.MaxTimestampDrift
+	if bl.Timestamp >= currentTime+maxTimestampDrift || bl.Timestamp <= prevBlock.Timestamp {
+		log.Warnf("Invalid timestamp: too far in future or past. Current timestamp of block %d, MaxTimestampDrift %d", bl.Timestamp, currentTime+maxTimestampDrift)
 		return false
 	}
 
@@ -539,6 +582,11 @@ func (bc *Blockchain) GetBlockHashByHeight(height int64) ([]byte, error) {
 	return blockHash, nil
 }
 
+// GenesisHash returns the hash of the first block, which identifies the chain.
+func (bc *Blockchain) GenesisHash() ([]byte, error) {
+	return bc.GetBlockHashByHeight(1)
+}
+
 func (bc *Blockchain) GetBlockLocator() ([][]byte, error) {
 
 	var locator [][]byte
@@ -691,7 +739,10 @@ func (bc *Blockchain) FindUTXO() (map[string]TxOutputs, error) {
 			return nil, err
 		}
 
-		for _, tx := range block.Transactions {
+		// Children may spend their parent in the same block, walk it backwards
+		// so their spends are recorded before the parent's outputs are checked.
+		for i := len(block.Transactions) - 1; i >= 0; i-- {
+			tx := block.Transactions[i]
 			txID := hex.EncodeToString(tx.ID)
 
 		Outputs:
@@ -705,7 +756,9 @@ func (bc *Blockchain) FindUTXO() (map[string]TxOutputs, error) {
 				}
 
 				outs := UTXOs[txID]
+				outs.Height = block.Height
 				outs.Outputs = append(outs.Outputs, out)
+				outs.Indexes = append(outs.Indexes, int64(outIdx))
 				UTXOs[txID] = outs
 			}
 
@@ -725,7 +778,64 @@ func (bc *Blockchain) FindUTXO() (map[string]TxOutputs, error) {
 	return UTXOs, nil
 }
 
+// FindTransactionBlock returns the main chain block holding the transaction
+// ID and its position in Block.Transactions. It reads the txindex when it
+// has been built and walks back from the tip otherwise.
+func (bc *Blockchain) FindTransactionBlock(ID []byte) (*Block, int, error) {
+	txIndex := TxIndex{Blockchain: bc}
+	if txIndex.Enabled() {
+		loc, found, err := txIndex.Lookup(ID)
+		if err != nil {
+			return nil, 0, err
+		}
+		if !found {
+			return nil, 0, errors.New("No transaction with ID: " + hex.EncodeToString(ID))
+		}
+
+		block, err := bc.GetBlock(loc.BlockHash)
+		if err != nil {
+			return nil, 0, fmt.Errorf("txindex block %x: %w", loc.BlockHash, err)
+		}
+		if int(loc.Position) >= len(block.Transactions) || !bytes.Equal(block.Transactions[loc.Position].ID, ID) {
+			return nil, 0, fmt.Errorf("txindex entry for %x is stale, run reindex", ID)
+		}
+
+		return &block, int(loc.Position), nil
+	}
+
+	iter, err := bc.Iterator()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	for {
+		block, err := iter.Next()
+		if err != nil {
+			return nil, 0, err
+		}
+
+		for i, tx := range block.Transactions {
+			if bytes.Equal(tx.ID, ID) {
+				return block, i, nil
+			}
+		}
+
+		if len(block.PrevHash) == 0 {
+			break
+		}
+	}
+
+	return nil, 0, errors.New("No transaction with ID: " + hex.EncodeToString(ID))
+}
+
+// FindTransaction returns a main chain transaction by ID. It reads the
+// txindex when it has been built and walks back from the tip otherwise.
 func (bc *Blockchain) FindTransaction(ID []byte) (Transaction, error) {
+	txIndex := TxIndex{Blockchain: bc}
+	if txIndex.Enabled() {
+		return bc.findIndexedTransaction(&txIndex, ID)
+	}
+
 	iter, err := bc.Iterator()
 	if err != nil {
 		return Transaction{}, nil
@@ -752,6 +862,28 @@ func (bc *Blockchain) FindTransaction(ID []byte) (Transaction, error) {
 	return Transaction{}, errors.New("No transaction with ID: " + hex.EncodeToString(ID))
 }
 
+func (bc *Blockchain) findIndexedTransaction(txIndex *TxIndex, ID []byte) (Transaction, error) {
+	loc, found, err := txIndex.Lookup(ID)
+	if err != nil {
+		return Transaction{}, err
+	}
+
+	if !found {
+		return Transaction{}, errors.New("No transaction with ID: " + hex.EncodeToString(ID))
+	}
+
+	block, err := bc.GetBlock(loc.BlockHash)
+	if err != nil {
+		return Transaction{}, fmt.Errorf("txindex block %x: %w", loc.BlockHash, err)
+	}
+
+	if int(loc.Position) >= len(block.Transactions) || !bytes.Equal(block.Transactions[loc.Position].ID, ID) {
+		return Transaction{}, fmt.Errorf("txindex entry for %x is stale, run reindex", ID)
+	}
+
+	return *block.Transactions[loc.Position], nil
+}
+
 func (bc *Blockchain) GetTransaction(transaction *Transaction) map[string]Transaction {
 	txs := make(map[string]Transaction)
 
@@ -769,17 +901,16 @@ func (bc *Blockchain) GetTransaction(transaction *Transaction) map[string]Transa
 	return txs
 }
 
-func (bc *Blockchain) SignTransaction(privKey ecdsa.PrivateKey, tx *Transaction) {
+func (bc *Blockchain) SignTransaction(privKey ecdsa.PrivateKey, tx *Transaction, height int64) {
 	prevTxs := bc.GetTransaction(tx)
-	tx.Sign(privKey, prevTxs)
+	tx.Sign(privKey, prevTxs, height)
 }
 
 func (bc *Blockchain) ValidateBlockTransactions(bl *Block) bool {
-	utxos, err := bc.FindUTXO()
-	if err != nil {
-		log.Error("❌ Failed to retrieve UTXO set during block transaction validation")
-		return false
-	}
+	utxoSet := UTXOSet{Blockchain: bc}
+	spentInBlock := make(map[string]bool)
+	// Transactions may spend outputs created earlier in the same block.
+	createdInBlock := make(map[string]Transaction)
 
 	lastBlock, err := bc.GetLastBlock()
 	if err != nil {
@@ -787,8 +918,8 @@ func (bc *Blockchain) ValidateBlockTransactions(bl *Block) bool {
 		return false
 	}
 
-	currentRewardBlock := NewCoinAmountFromFloat(0.0)
-	fee := NewCoinAmountFromFloat(0.0)
+	currentRewardBlock := ZeroAmount()
+	fee := ZeroAmount()
 
 	for _, tx := range bl.Transactions {
 		if tx.IsMinerTx() {
@@ -797,45 +928,58 @@ func (bc *Blockchain) ValidateBlockTransactions(bl *Block) bool {
 				return false
 			}
 
-			currentRewardBlock = NewCoinAmountFromFloat(tx.Outputs[0].Value)
+			if err := tx.CheckScripts(bl.Height); err != nil {
+				log.Warnf("🚫 Miner transaction has invalid scripts: %v", err)
+				return false
+			}
+
+			currentRewardBlock = NewCoinAmountFromUnits(tx.Outputs[0].Value)
 			log.Debugf("💰 Detected miner transaction with reward: %.8f", currentRewardBlock)
 			continue
 		}
 
-		if !bc.VerifyTransaction(tx) {
+		if !bc.verifyTransaction(tx, createdInBlock, bl.Height) {
 			log.Errorf("🚫 Transaction verification failed for TxID: %x", tx.ID)
 			return false
 		}
 
-		totalInput := NewCoinAmountFromFloat(0.0)
+		totalInput := ZeroAmount()
 		for _, in := range tx.Inputs {
 			txID := hex.EncodeToString(in.ID)
-			if _, exists := utxos[txID]; !exists {
-				log.Warnf("🚫 Referenced input not found in UTXO set — TxID: %s", txID)
+			outpoint := fmt.Sprintf("%s:%d", txID, in.Out)
+			if spentInBlock[outpoint] {
+				log.Warnf("🚫 Output %s spent twice in the same block", outpoint)
 				return false
 			}
+			spentInBlock[outpoint] = true
+
+			if parent, ok := createdInBlock[txID]; ok {
+				totalInput = totalInput.Add(NewCoinAmountFromUnits(parent.Outputs[in.Out].Value))
+				continue
+			}
 
-			tx, err := bc.FindTransaction(in.ID)
+			out, exists, err := utxoSet.FindOutput(in.ID, in.Out)
 			if err != nil {
-				log.Errorf("❌ Failed to retrieve referenced transaction (%s): %v", txID, err)
+				log.Errorf("❌ Failed to look up referenced output (%s): %v", outpoint, err)
 				return false
 			}
 
-			if in.Out > int64(len(tx.Outputs)-1) {
-				log.Errorf("🚫 Invalid input index %d — transaction has only %d outputs", in.Out, len(tx.Outputs))
+			if !exists {
+				log.Warnf("🚫 Referenced input not found in UTXO set — Output: %s", outpoint)
 				return false
 			}
 
-			totalInput = totalInput.Add(NewCoinAmountFromFloat(tx.Outputs[in.Out].Value))
+			totalInput = totalInput.Add(NewCoinAmountFromUnits(out.Value))
 		}
 
-		totalOutput := NewCoinAmountFromFloat(0.0)
+		totalOutput := ZeroAmount()
 		for _, out := range tx.Outputs {
-			totalOutput = totalOutput.Add(NewCoinAmountFromFloat(out.Value))
+			totalOutput = totalOutput.Add(NewCoinAmountFromUnits(out.Value))
 		}
 
 		feeTx := SumFees(totalInput, totalOutput)
 		fee = fee.Add(feeTx)
+		createdInBlock[hex.EncodeToString(tx.ID)] = *tx
 		log.Debugf("🧮 Processed transaction %x — totalInput: %.8f, totalOutput: %.8f, feeAcc: %.8f", tx.ID, totalInput, totalOutput, fee)
 	}
 
@@ -850,66 +994,139 @@ func (bc *Blockchain) ValidateBlockTransactions(bl *Block) bool {
 	return true
 }
 
+// VerifyTransaction checks tx against the UTXO set as a candidate for the next block.
 func (bc *Blockchain) VerifyTransaction(tx *Transaction) bool {
+	bestHeight, err := bc.GetBestHeight()
+	if err != nil {
+		return false
+	}
+
+	return bc.VerifyTransactionAt(tx, bestHeight+1)
+}
+
+func (bc *Blockchain) VerifyTransactionAt(tx *Transaction, height int64) bool {
+	return bc.verifyTransaction(tx, nil, height)
+}
+
+// VerifyTransactionWithParents checks tx as a candidate for the next block
+// when its inputs may also spend outputs of the unconfirmed transactions in
+// parents, keyed by hex ID. Whether those outputs are still unspent is up to
+// the caller.
+func (bc *Blockchain) VerifyTransactionWithParents(tx *Transaction, parents map[string]Transaction) bool {
+	bestHeight, err := bc.GetBestHeight()
+	if err != nil {
+		return false
+	}
+
+	return bc.verifyTransaction(tx, parents, bestHeight+1)
+}
+
+func (bc *Blockchain) verifyTransaction(tx *Transaction, parents map[string]Transaction, height int64) bool {
 	if tx.IsMinerTx() {
-		return true
+		return tx.Verify(nil, height)
+	}
+
+	prevTxs, err := bc.inputTransactions(tx, parents)
+	if err != nil {
+		log.Debugf("Transaction %x: %v", tx.ID, err)
+		return false
 	}
 
+	return tx.Verify(prevTxs, height)
+}
+
+// inputTransactions returns the transactions tx spends from, keyed by hex
+// ID. Inputs are taken from parents first and must be unspent in the UTXO
+// set otherwise.
+func (bc *Blockchain) inputTransactions(tx *Transaction, parents map[string]Transaction) (map[string]Transaction, error) {
 	utxoSet := UTXOSet{
 		Blockchain: bc,
 	}
 
+	prevTxs := make(map[string]Transaction, len(tx.Inputs))
+
 	for _, in := range tx.Inputs {
-		outs, _, err := utxoSet.FindUTXOPrefix(in.ID)
+		txID := hex.EncodeToString(in.ID)
+
+		if parent, ok := parents[txID]; ok {
+			if in.Out < 0 || in.Out >= int64(len(parent.Outputs)) {
+				return nil, fmt.Errorf("input %s:%d out of range", txID, in.Out)
+			}
+			prevTxs[txID] = parent
+			continue
+		}
+
+		_, exists, err := utxoSet.FindOutput(in.ID, in.Out)
 		if err != nil {
-			return false
+			return nil, err
+		}
+		if !exists {
+			return nil, fmt.Errorf("input %s:%d not found in UTXO set", txID, in.Out)
 		}
 
-		if len(outs.Outputs) == 0 || in.Out >= int64(len(outs.Outputs)) || in.Out < 0 {
-			return false
+		if _, ok := prevTxs[txID]; ok {
+			continue
+		}
+
+		prevTx, err := bc.FindTransaction(in.ID)
+		if err != nil {
+			return nil, err
 		}
+		prevTxs[txID] = prevTx
 	}
 
-	prevTxs := bc.GetTransaction(tx)
+	return prevTxs, nil
+}
+
+// InputValue sums the outputs tx spends, looking them up in parents before
+// the chain.
+func (bc *Blockchain) InputValue(tx *Transaction, parents map[string]Transaction) (*CoinAmount, error) {
+	total := ZeroAmount()
 
-	return tx.Verify(prevTxs)
+	for _, in := range tx.Inputs {
+		prevTx, ok := parents[hex.EncodeToString(in.ID)]
+		if !ok {
+			var err error
+			prevTx, err = bc.FindTransaction(in.ID)
+			if err != nil {
+				return ZeroAmount(), err
+			}
+		}
+
+		if in.Out < 0 || in.Out >= int64(len(prevTx.Outputs)) {
+			return ZeroAmount(), fmt.Errorf("🚫 Invalid input index %d — transaction has only %d outputs", in.Out, len(prevTx.Outputs)-1)
+		}
+
+		total = total.Add(NewCoinAmountFromUnits(prevTx.Outputs[in.Out].Value))
+	}
+
+	return total, nil
 }
 
 func (bc *Blockchain) MineBlock(transactions []*Transaction, address string, callback func([]*Transaction), ctx context.Context) (*Block, error) {
-	fee := NewCoinAmountFromFloat(0.0)
+	fee := ZeroAmount()
+	// Children follow their parents in transactions.
+	created := make(map[string]Transaction, len(transactions))
 
 	for _, tx := range transactions {
-		totalInput := NewCoinAmountFromFloat(0.0)
-		totalOuput := NewCoinAmountFromFloat(0.0)
-		publicKey := tx.Inputs[0].PubKey
-		if !bc.VerifyTransaction(tx) {
-			log.Error("Invalid Transaction")
-			return nil, nil
+		totalOuput := ZeroAmount()
+		if !bc.VerifyTransactionWithParents(tx, created) {
+			return nil, fmt.Errorf("%w %x", ErrInvalidBlockTx, tx.ID)
 		}
 
-		for _, in := range tx.Inputs {
-			if !bytes.Equal(publicKey, in.PubKey) {
-				log.Error("Pubkey Does not math")
-				return nil, nil
-			}
-			tx, err := bc.FindTransaction(in.ID)
-			if err != nil {
-				return nil, err
-			}
-			if in.Out > int64(len(tx.Outputs)-1) {
-				return nil, fmt.Errorf("🚫 Invalid input index %d — transaction has only %d outputs", in.Out, len(tx.Outputs)-1)
-			}
-			value := NewCoinAmountFromFloat(tx.Outputs[in.Out].Value)
-			totalInput = totalInput.Add(value)
+		totalInput, err := bc.InputValue(tx, created)
+		if err != nil {
+			return nil, err
 		}
 
 		for _, out := range tx.Outputs {
-			value := NewCoinAmountFromFloat(out.Value)
+			value := NewCoinAmountFromUnits(out.Value)
 			totalOuput = totalOuput.Add(value)
 		}
 
 		feeTx := totalInput.Sub(totalOuput)
 		fee = fee.Add(feeTx)
+		created[hex.EncodeToString(tx.ID)] = *tx
 	}
 
 	lastestBlock, err := bc.GetLastBlock()
@@ -925,13 +1142,13 @@ func (bc *Blockchain) MineBlock(transactions []*Transaction, address string, cal
 		return nil, err
 	}
 
-	value := NewCoinAmountFromFloat(reward.Outputs[0].Value)
+	value := NewCoinAmountFromUnits(reward.Outputs[0].Value)
 
-	reward.Outputs[0].Value = value.Add(fee).ToFloat()
+	reward.Outputs[0].Value = value.Add(fee).Units()
 
 	transactions = append(transactions, reward)
 
-	block, err := CreateBlock(transactions, lastestBlock.Hash, lastestBlock.Height+1, nbits, ctx)
+	block, err := CreateBlock(transactions, lastestBlock, nbits, ctx)
 
 	if err != nil {
 		return nil, err
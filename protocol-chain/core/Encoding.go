@@ -6,21 +6,55 @@ import (
 	"encoding/binary"
 )
 
+// scriptMarker takes the place of the input count of transactions carrying
+// scripts, the count follows it. Transactions without scripts keep the
+// encoding, and so the IDs, they had before ScriptHeight.
+const scriptMarker uint32 = 0xffffffff
+
+// SerializeTransaction writes tx with output values as integer base units.
+// It is the encoding used when relaying loose transactions. Transactions
+// with scripts start their inputs with scriptMarker and append the
+// UnlockingScript of each input and the LockingScript of each output.
 func SerializeTransaction(tx *Transaction, buf *bytes.Buffer) {
+	serializeTransaction(tx, buf, false)
+}
+
+// SerializeTransactionAt writes tx the way a block at height commits to it:
+// below IntegerAmountHeight output values are encoded as legacy float64 coins.
+func SerializeTransactionAt(tx *Transaction, buf *bytes.Buffer, height int64) {
+	serializeTransaction(tx, buf, !IsIntegerAmountActive(height))
+}
+
+func serializeTransaction(tx *Transaction, buf *bytes.Buffer, legacy bool) {
 	utils.WriteBytes(buf, tx.ID)
 
+	scripts := tx.HasScripts()
+	if scripts {
+		binary.Write(buf, binary.LittleEndian, scriptMarker)
+	}
+
 	binary.Write(buf, binary.LittleEndian, uint32(len(tx.Inputs)))
 	for _, in := range tx.Inputs {
 		utils.WriteBytes(buf, in.ID)
 		binary.Write(buf, binary.LittleEndian, in.Out)
 		utils.WriteBytes(buf, in.Signature)
 		utils.WriteBytes(buf, in.PubKey)
+		if scripts {
+			utils.WriteBytes(buf, in.UnlockingScript)
+		}
 	}
 
 	binary.Write(buf, binary.LittleEndian, uint32(len(tx.Outputs)))
 	for _, out := range tx.Outputs {
-		binary.Write(buf, binary.LittleEndian, out.Value)
+		if legacy {
+			binary.Write(buf, binary.LittleEndian, UnitsToLegacyFloat(out.Value))
+		} else {
+			binary.Write(buf, binary.LittleEndian, out.Value)
+		}
 		utils.WriteBytes(buf, out.PubKeyHash)
+		if scripts {
+			utils.WriteBytes(buf, out.LockingScript)
+		}
 	}
 }
 
@@ -39,18 +73,30 @@ func SerializeBlock(b *Block) []byte {
 
 	binary.Write(buf, binary.LittleEndian, uint32(len(b.Transactions)))
 	for _, tx := range b.Transactions {
-		SerializeTransaction(tx, buf)
+		SerializeTransactionAt(tx, buf, b.Height)
 	}
 
 	return buf.Bytes()
 }
 
 func DeserializeTxData(buf *bytes.Buffer) *Transaction {
+	return deserializeTxData(buf, false)
+}
+
+func DeserializeTxDataAt(buf *bytes.Buffer, height int64) *Transaction {
+	return deserializeTxData(buf, !IsIntegerAmountActive(height))
+}
+
+func deserializeTxData(buf *bytes.Buffer, legacy bool) *Transaction {
 	tx := &Transaction{}
 	tx.ID = utils.ReadBytes(buf)
 
 	var inCount uint32
 	binary.Read(buf, binary.LittleEndian, &inCount)
+	scripts := inCount == scriptMarker
+	if scripts {
+		binary.Read(buf, binary.LittleEndian, &inCount)
+	}
 	for i := uint32(0); i < inCount; i++ {
 		in := TxInput{
 			ID:        utils.ReadBytes(buf),
@@ -61,6 +107,9 @@ func DeserializeTxData(buf *bytes.Buffer) *Transaction {
 		binary.Read(buf, binary.LittleEndian, &in.Out)
 		in.Signature = utils.ReadBytes(buf)
 		in.PubKey = utils.ReadBytes(buf)
+		if scripts {
+			in.UnlockingScript = utils.ReadBytes(buf)
+		}
 		tx.Inputs = append(tx.Inputs, in)
 	}
 
@@ -68,8 +117,17 @@ func DeserializeTxData(buf *bytes.Buffer) *Transaction {
 	binary.Read(buf, binary.LittleEndian, &outCount)
 	for i := uint32(0); i < outCount; i++ {
 		out := TxOutput{}
-		binary.Read(buf, binary.LittleEndian, &out.Value)
+		if legacy {
+			var value float64
+			binary.Read(buf, binary.LittleEndian, &value)
+			out.Value = LegacyFloatToUnits(value)
+		} else {
+			binary.Read(buf, binary.LittleEndian, &out.Value)
+		}
 		out.PubKeyHash = utils.ReadBytes(buf)
+		if scripts {
+			out.LockingScript = utils.ReadBytes(buf)
+		}
 		tx.Outputs = append(tx.Outputs, out)
 	}
 
@@ -93,7 +151,7 @@ func DeserializeBlockData(data []byte) *Block {
 	var txCount uint32
 	binary.Read(buf, binary.LittleEndian, &txCount)
 	for i := uint32(0); i < txCount; i++ {
-		tx := DeserializeTxData(buf)
+		tx := DeserializeTxDataAt(buf, b.Height)
 		b.Transactions = append(b.Transactions, tx)
 	}
 
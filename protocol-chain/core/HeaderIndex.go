@@ -0,0 +1,507 @@
+package blockchain
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/dgraph-io/badger"
+	log "github.com/sirupsen/logrus"
+)
+
+var (
+	headerPrefix       = []byte("hdr-")
+	headerHeightPrefix = []byte("hdrh-")
+)
+
+const (
+	// BestHeaderKey holds the hash of the header with the most cumulative work.
+	BestHeaderKey = "besthdr"
+	// HeaderIndexKey marks that every main chain block has a header entry.
+	HeaderIndexKey = "hdrindex"
+)
+
+var (
+	ErrOrphanHeader  = errors.New("header does not connect to a known header")
+	ErrInvalidHeader = errors.New("invalid header")
+)
+
+// HeaderNode is a header index entry. ChainWork is the cumulative work of the
+// chain ending at this header.
+type HeaderNode struct {
+	Hash      []byte
+	Header    BlockHeader
+	Height    int64
+	ChainWork *big.Int
+}
+
+// HeaderData is a header as it travels over the network: the serialized
+// BlockHeader and the hash the block is known by. TxCount is only needed to
+// check the proof of work below HeaderActivationHeight.
+type HeaderData struct {
+	Hash    []byte
+	Header  []byte
+	TxCount int64
+}
+
+type HeaderIndex struct {
+	Blockchain *Blockchain
+}
+
+func headerKey(hash []byte) []byte {
+	key := make([]byte, 0, len(headerPrefix)+len(hash))
+	key = append(key, headerPrefix...)
+	return append(key, hash...)
+}
+
+// headerHeightKey maps a height to the hash of the best header chain there.
+func headerHeightKey(height int64) []byte {
+	key := make([]byte, len(headerHeightPrefix)+8)
+	copy(key, headerHeightPrefix)
+	binary.BigEndian.PutUint64(key[len(headerHeightPrefix):], uint64(height))
+	return key
+}
+
+func getHeaderHash(txn *badger.Txn, height int64) ([]byte, error) {
+	item, err := txn.Get(headerHeightKey(height))
+	if err != nil {
+		return nil, err
+	}
+
+	return item.ValueCopy(nil)
+}
+
+func getHeaderNode(txn *badger.Txn, hash []byte) (*HeaderNode, error) {
+	item, err := txn.Get(headerKey(hash))
+	if err != nil {
+		return nil, err
+	}
+
+	v, err := item.ValueCopy(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	node, err := GobDecode[HeaderNode](v)
+	if err != nil {
+		return nil, err
+	}
+
+	return &node, nil
+}
+
+func getBestHeader(txn *badger.Txn) (*HeaderNode, error) {
+	item, err := txn.Get([]byte(BestHeaderKey))
+	if err != nil {
+		return nil, err
+	}
+
+	hash, err := item.ValueCopy(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return getHeaderNode(txn, hash)
+}
+
+// putHeaderNode stores node and moves the best header to it when it carries
+// more work.
+func putHeaderNode(txn *badger.Txn, node *HeaderNode) error {
+	data, err := GobEncode(node)
+	if err != nil {
+		return err
+	}
+
+	if err := txn.Set(headerKey(node.Hash), data); err != nil {
+		return err
+	}
+
+	best, err := getBestHeader(txn)
+	if err != nil && !errors.Is(err, badger.ErrKeyNotFound) {
+		return err
+	}
+
+	if best == nil || node.ChainWork.Cmp(best.ChainWork) > 0 {
+		if err := indexBestChain(txn, node); err != nil {
+			return err
+		}
+		return txn.Set([]byte(BestHeaderKey), node.Hash)
+	}
+
+	return nil
+}
+
+// indexBestChain points the height entries at node and its ancestors, down
+// to the first height that already does.
+func indexBestChain(txn *badger.Txn, node *HeaderNode) error {
+	for {
+		hash, err := getHeaderHash(txn, node.Height)
+		if err == nil && bytes.Equal(hash, node.Hash) {
+			return nil
+		} else if err != nil && !errors.Is(err, badger.ErrKeyNotFound) {
+			return err
+		}
+
+		if err := txn.Set(headerHeightKey(node.Height), node.Hash); err != nil {
+			return err
+		}
+
+		prevHash := node.Header.PrevBlockHash()
+		if prevHash == nil {
+			return nil
+		}
+
+		parent, err := getHeaderNode(txn, prevHash)
+		if errors.Is(err, badger.ErrKeyNotFound) {
+			// EnsureIndexed adds the tip first, it sets the heights
+			// of the ancestors itself.
+			return nil
+		} else if err != nil {
+			return err
+		}
+		node = parent
+	}
+}
+
+func headerNodeFromBlock(b *Block) *HeaderNode {
+	work := b.NChainWork
+	if work == nil {
+		work = big.NewInt(0)
+	}
+
+	return &HeaderNode{
+		Hash:      b.Hash,
+		Header:    b.Header(),
+		Height:    b.Height,
+		ChainWork: new(big.Int).Set(work),
+	}
+}
+
+// PutBlock indexes the header of a stored block. NChainWork must be set.
+func (hi *HeaderIndex) PutBlock(txn *badger.Txn, b *Block) error {
+	return putHeaderNode(txn, headerNodeFromBlock(b))
+}
+
+func (hi *HeaderIndex) Get(hash []byte) (*HeaderNode, error) {
+	var node *HeaderNode
+
+	err := hi.Blockchain.Database.View(func(txn *badger.Txn) error {
+		var err error
+		node, err = getHeaderNode(txn, hash)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return node, nil
+}
+
+func (hi *HeaderIndex) Best() (*HeaderNode, error) {
+	var node *HeaderNode
+
+	err := hi.Blockchain.Database.View(func(txn *badger.Txn) error {
+		var err error
+		node, err = getBestHeader(txn)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return node, nil
+}
+
+// EnsureIndexed writes header entries for the main chain of data dirs created
+// before the header index existed.
+func (hi *HeaderIndex) EnsureIndexed() error {
+	indexed := false
+
+	err := hi.Blockchain.Database.View(func(txn *badger.Txn) error {
+		_, err := txn.Get([]byte(HeaderIndexKey))
+		if err == nil {
+			indexed = true
+			return nil
+		}
+		if errors.Is(err, badger.ErrKeyNotFound) {
+			return nil
+		}
+		return err
+	})
+	if err != nil || indexed {
+		return err
+	}
+
+	log.Info("Building header index from the main chain...")
+
+	iter, err := hi.Blockchain.Iterator()
+	if err != nil {
+		return err
+	}
+
+	for {
+		block, err := iter.Next()
+		if err != nil {
+			return err
+		}
+
+		err = hi.Blockchain.Database.Update(func(txn *badger.Txn) error {
+			if err := hi.PutBlock(txn, block); err != nil {
+				return err
+			}
+			return txn.Set(headerHeightKey(block.Height), block.Hash)
+		})
+		if err != nil {
+			return err
+		}
+
+		if len(block.PrevHash) == 0 {
+			break
+		}
+	}
+
+	return hi.Blockchain.Database.Update(func(txn *badger.Txn) error {
+		return txn.Set([]byte(HeaderIndexKey), []byte{1})
+	})
+}
+
+// ancestor walks back from node to the header at height.
+func (hi *HeaderIndex) ancestor(txn *badger.Txn, node *HeaderNode, height int64) (*HeaderNode, error) {
+	for node.Height > height {
+		parent, err := getHeaderNode(txn, node.Header.PrevBlockHash())
+		if err != nil {
+			return nil, err
+		}
+		node = parent
+	}
+
+	return node, nil
+}
+
+// expectedNBits mirrors AdjustDifficulty on the header chain ending at parent.
+func (hi *HeaderIndex) expectedNBits(txn *badger.Txn, parent *HeaderNode) uint32 {
+	params := hi.Blockchain.Params
+
+	if params.PowNoRetargeting || parent.Height%params.AdjustmentInterval != 0 {
+		return parent.Header.NBits
+	}
+
+	first, err := hi.ancestor(txn, parent, parent.Height-params.AdjustmentInterval)
+	if err != nil || first.Height != parent.Height-params.AdjustmentInterval {
+		return parent.Header.NBits
+	}
+
+	return retargetNBits(params, parent.Header.NBits, parent.Header.Timestamp-first.Header.Timestamp)
+}
+
+func (hi *HeaderIndex) checkHeader(txn *badger.Txn, parent *HeaderNode, data HeaderData, header *BlockHeader) error {
+	height := parent.Height + 1
+
+	if header.Timestamp <= parent.Header.Timestamp || header.Timestamp >= time.Now().Unix()+hi.Blockchain.Params.MaxTimestampDrift {
+		return fmt.Errorf("%w: timestamp %d out of range", ErrInvalidHeader, header.Timestamp)
+	}
+
+	if expected := hi.expectedNBits(txn, parent); header.NBits != expected {
+		return fmt.Errorf("%w: nbits %08x, want %08x", ErrInvalidHeader, header.NBits, expected)
+	}
+
+	hash := header.LegacyHash(height, data.TxCount)
+	if IsHeaderActive(height) {
+		if header.Version != BlockVersion {
+			return fmt.Errorf("%w: version %d", ErrInvalidHeader, header.Version)
+		}
+		hash = header.Hash()
+	}
+
+	if !bytes.Equal(hash, data.Hash) {
+		return fmt.Errorf("%w: hash mismatch", ErrInvalidHeader)
+	}
+
+	if !meetsTarget(hash, header.NBits) {
+		return fmt.Errorf("%w: proof of work", ErrInvalidHeader)
+	}
+
+	return nil
+}
+
+// ProcessHeaders validates a run of headers ordered from lowest to highest
+// and adds them to the index. Headers that are already known are skipped.
+// It returns the node of the last header.
+func (hi *HeaderIndex) ProcessHeaders(headers []HeaderData) (*HeaderNode, error) {
+	var last *HeaderNode
+
+	err := hi.Blockchain.Database.Update(func(txn *badger.Txn) error {
+		for _, data := range headers {
+			header, err := DeserializeBlockHeader(data.Header)
+			if err != nil {
+				return fmt.Errorf("%w: %v", ErrInvalidHeader, err)
+			}
+
+			if known, err := getHeaderNode(txn, data.Hash); err == nil {
+				last = known
+				continue
+			} else if !errors.Is(err, badger.ErrKeyNotFound) {
+				return err
+			}
+
+			parent, err := getHeaderNode(txn, header.PrevBlockHash())
+			if err != nil {
+				if errors.Is(err, badger.ErrKeyNotFound) {
+					return fmt.Errorf("%w: %x", ErrOrphanHeader, data.Hash)
+				}
+				return err
+			}
+
+			if err := hi.checkHeader(txn, parent, data, header); err != nil {
+				return fmt.Errorf("header %x: %w", data.Hash, err)
+			}
+
+			node := &HeaderNode{
+				Hash:      data.Hash,
+				Header:    *header,
+				Height:    parent.Height + 1,
+				ChainWork: new(big.Int).Add(parent.ChainWork, hi.Blockchain.CalcWork(header.NBits)),
+			}
+
+			if err := putHeaderNode(txn, node); err != nil {
+				return err
+			}
+			last = node
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return last, nil
+}
+
+// Locator lists hashes back from the best header, dense near the tip and
+// sparse further down, ending at genesis.
+func (hi *HeaderIndex) Locator() ([][]byte, error) {
+	var locator [][]byte
+
+	err := hi.Blockchain.Database.View(func(txn *badger.Txn) error {
+		node, err := getBestHeader(txn)
+		if err != nil {
+			return err
+		}
+
+		step := int64(1)
+		for {
+			locator = append(locator, node.Hash)
+
+			if node.Height <= 1 {
+				return nil
+			}
+
+			next := node.Height - step
+			if next < 1 {
+				next = 1
+			}
+			if len(locator) >= 10 {
+				step *= 2
+			}
+
+			node, err = hi.ancestor(txn, node, next)
+			if err != nil {
+				return err
+			}
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return locator, nil
+}
+
+// MissingBlocks returns up to max headers on the best header chain whose
+// bodies are not stored yet, ordered from lowest to highest so that every
+// block's parent comes before it. It walks up from the height the previous
+// call stopped at instead of down from the best header.
+func (hi *HeaderIndex) MissingBlocks(max int) ([]*HeaderNode, error) {
+	var missing []*HeaderNode
+
+	height := hi.Blockchain.missingCursor.Load()
+	if height < 1 {
+		tip, err := hi.Blockchain.GetBestHeight()
+		if err != nil {
+			return nil, err
+		}
+		height = tip
+	}
+
+	err := hi.Blockchain.Database.View(func(txn *badger.Txn) error {
+		best, err := getBestHeader(txn)
+		if err != nil {
+			return err
+		}
+
+		hasBody := func(height int64) (bool, error) {
+			hash, err := getHeaderHash(txn, height)
+			if err != nil {
+				return false, err
+			}
+
+			if _, err := txn.Get(hash); err == nil {
+				return true, nil
+			} else if !errors.Is(err, badger.ErrKeyNotFound) {
+				return false, err
+			}
+			return false, nil
+		}
+
+		// A header reorg can replace the chain below the cursor, step
+		// back to the first height whose block is stored. Everything
+		// below a stored block is stored as well.
+		height = min(height, best.Height+1)
+		for height > 1 {
+			stored, err := hasBody(height - 1)
+			if err != nil {
+				return err
+			}
+			if stored {
+				break
+			}
+			height--
+		}
+
+		for ; height <= best.Height; height++ {
+			stored, err := hasBody(height)
+			if err != nil {
+				return err
+			}
+			if !stored {
+				break
+			}
+		}
+
+		for h := height; h <= best.Height && len(missing) < max; h++ {
+			hash, err := getHeaderHash(txn, h)
+			if err != nil {
+				return err
+			}
+
+			node, err := getHeaderNode(txn, hash)
+			if err != nil {
+				return err
+			}
+			missing = append(missing, node)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	hi.Blockchain.missingCursor.Store(height)
+
+	return missing, nil
+}
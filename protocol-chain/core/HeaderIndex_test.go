@@ -0,0 +1,365 @@
+package blockchain
+
+import (
+	"bytes"
+	"core-blockchain/chaincfg"
+	"crypto/sha256"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/dgraph-io/badger"
+)
+
+// testHeader is a header together with the height it is mined for.
+type testHeader struct {
+	BlockHeader
+	Height  int64
+	TxCount int64
+	Hash    []byte
+}
+
+func (h testHeader) data() HeaderData {
+	return HeaderData{Hash: h.Hash, Header: h.Serialize(), TxCount: h.TxCount}
+}
+
+// powHash is the hash the header is known by at its height.
+func (h *testHeader) powHash() []byte {
+	if IsHeaderActive(h.Height) {
+		return h.BlockHeader.Hash()
+	}
+	return h.LegacyHash(h.Height, h.TxCount)
+}
+
+// mine searches for a nonce whose hash meets the target when valid is set
+// and misses it otherwise.
+func (h *testHeader) mine(valid bool) {
+	for h.Nonce = 0; ; h.Nonce++ {
+		h.Hash = h.powHash()
+		if meetsTarget(h.Hash, h.NBits) == valid {
+			return
+		}
+	}
+}
+
+// nextHeader mines a child of parent, salt tells siblings apart.
+func nextHeader(parent testHeader, salt string) testHeader {
+	h := testHeader{
+		BlockHeader: BlockHeader{
+			MerkleRoot: sha256.Sum256([]byte(salt)),
+			Timestamp:  parent.Timestamp + 1,
+			NBits:      parent.NBits,
+		},
+		Height:  parent.Height + 1,
+		TxCount: 1,
+	}
+	copy(h.PrevHash[:], parent.Hash)
+	if IsHeaderActive(h.Height) {
+		h.Version = BlockVersion
+	}
+
+	h.mine(true)
+	return h
+}
+
+func headerRun(parent testHeader, salt string, n int) []testHeader {
+	var run []testHeader
+	for i := 0; i < n; i++ {
+		parent = nextHeader(parent, salt)
+		run = append(run, parent)
+	}
+	return run
+}
+
+func headerDatas(run ...testHeader) []HeaderData {
+	var datas []HeaderData
+	for _, h := range run {
+		datas = append(datas, h.data())
+	}
+	return datas
+}
+
+// newTestHeaderIndex selects params for the test and stores a genesis block
+// with its header.
+func newTestHeaderIndex(t *testing.T, params *chaincfg.Params) (*HeaderIndex, testHeader) {
+	t.Helper()
+
+	prev := ActiveParams()
+	UseParams(params)
+	t.Cleanup(func() { UseParams(prev) })
+
+	bc := newTestChain(t)
+	bc.Params = params
+
+	genesis := testHeader{
+		BlockHeader: BlockHeader{Timestamp: params.GenesisTimestamp, NBits: params.GenesisNBits},
+		Height:      1,
+		TxCount:     1,
+	}
+	if IsHeaderActive(1) {
+		genesis.Version = BlockVersion
+	}
+	genesis.mine(true)
+
+	block := &Block{
+		Hash:       genesis.Hash,
+		Timestamp:  genesis.Timestamp,
+		NBits:      genesis.NBits,
+		Nonce:      genesis.Nonce,
+		Height:     1,
+		TxCount:    1,
+		NChainWork: bc.CalcWork(genesis.NBits),
+	}
+
+	hi := &HeaderIndex{Blockchain: bc}
+	err := bc.Database.Update(func(txn *badger.Txn) error {
+		if err := txn.Set(block.Hash, SerializeBlock(block)); err != nil {
+			return err
+		}
+		if err := txn.Set([]byte(BestHeightPrefix), block.Hash); err != nil {
+			return err
+		}
+		return hi.PutBlock(txn, block)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return hi, genesis
+}
+
+func TestHeaderIndexProcessHeaders(t *testing.T) {
+	legacyParams := chaincfg.RegTestParams
+	legacyParams.HeaderActivationHeight = 4
+
+	tests := []struct {
+		name    string
+		params  *chaincfg.Params
+		headers func(genesis testHeader) []HeaderData
+		wantErr error
+		// wantHeight is the best header height after processing.
+		wantHeight int64
+	}{
+		{
+			name: "extend best chain",
+			headers: func(genesis testHeader) []HeaderData {
+				return headerDatas(headerRun(genesis, "a", 3)...)
+			},
+			wantHeight: 4,
+		},
+		{
+			name:   "across header activation",
+			params: &legacyParams,
+			headers: func(genesis testHeader) []HeaderData {
+				return headerDatas(headerRun(genesis, "a", 5)...)
+			},
+			wantHeight: 6,
+		},
+		{
+			name: "known headers are skipped",
+			headers: func(genesis testHeader) []HeaderData {
+				run := headerRun(genesis, "a", 2)
+				return headerDatas(run[0], run[0], run[1])
+			},
+			wantHeight: 3,
+		},
+		{
+			name: "orphan",
+			headers: func(genesis testHeader) []HeaderData {
+				return headerDatas(headerRun(genesis, "a", 2)[1])
+			},
+			wantErr:    ErrOrphanHeader,
+			wantHeight: 1,
+		},
+		{
+			name: "proof of work",
+			headers: func(genesis testHeader) []HeaderData {
+				h := nextHeader(genesis, "a")
+				h.mine(false)
+				return headerDatas(h)
+			},
+			wantErr:    ErrInvalidHeader,
+			wantHeight: 1,
+		},
+		{
+			name: "hash does not match header",
+			headers: func(genesis testHeader) []HeaderData {
+				h := nextHeader(genesis, "a")
+				h.Hash = bytes.Repeat([]byte{0}, 32)
+				return headerDatas(h)
+			},
+			wantErr:    ErrInvalidHeader,
+			wantHeight: 1,
+		},
+		{
+			name: "timestamp not after parent",
+			headers: func(genesis testHeader) []HeaderData {
+				h := nextHeader(genesis, "a")
+				h.Timestamp = genesis.Timestamp
+				h.mine(true)
+				return headerDatas(h)
+			},
+			wantErr:    ErrInvalidHeader,
+			wantHeight: 1,
+		},
+		{
+			name: "nbits",
+			headers: func(genesis testHeader) []HeaderData {
+				h := nextHeader(genesis, "a")
+				h.NBits = 0x2000ffff
+				h.mine(true)
+				return headerDatas(h)
+			},
+			wantErr:    ErrInvalidHeader,
+			wantHeight: 1,
+		},
+		{
+			name: "version",
+			headers: func(genesis testHeader) []HeaderData {
+				h := nextHeader(genesis, "a")
+				h.Version = 0
+				h.mine(true)
+				return headerDatas(h)
+			},
+			wantErr:    ErrInvalidHeader,
+			wantHeight: 1,
+		},
+		{
+			name:   "legacy tx count",
+			params: &legacyParams,
+			headers: func(genesis testHeader) []HeaderData {
+				h := nextHeader(genesis, "a")
+				h.TxCount = 2
+				return headerDatas(h)
+			},
+			wantErr:    ErrInvalidHeader,
+			wantHeight: 1,
+		},
+		{
+			name: "invalid header rolls back the run",
+			headers: func(genesis testHeader) []HeaderData {
+				run := headerRun(genesis, "a", 3)
+				run[2].mine(false)
+				return headerDatas(run...)
+			},
+			wantErr:    ErrInvalidHeader,
+			wantHeight: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params := tt.params
+			if params == nil {
+				params = &chaincfg.RegTestParams
+			}
+			hi, genesis := newTestHeaderIndex(t, params)
+
+			_, err := hi.ProcessHeaders(tt.headers(genesis))
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ProcessHeaders error %v, want %v", err, tt.wantErr)
+			}
+
+			best, err := hi.Best()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if best.Height != tt.wantHeight {
+				t.Errorf("best height %d, want %d", best.Height, tt.wantHeight)
+			}
+
+			wantWork := new(big.Int).Mul(hi.Blockchain.CalcWork(genesis.NBits), big.NewInt(tt.wantHeight))
+			if best.ChainWork.Cmp(wantWork) != 0 {
+				t.Errorf("best chain work %v, want %v", best.ChainWork, wantWork)
+			}
+		})
+	}
+}
+
+// missingHashes returns the hashes MissingBlocks reports, lowest first.
+func missingHashes(t *testing.T, hi *HeaderIndex, max int) [][]byte {
+	t.Helper()
+
+	missing, err := hi.MissingBlocks(max)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var hashes [][]byte
+	for _, node := range missing {
+		hashes = append(hashes, node.Hash)
+	}
+	return hashes
+}
+
+func wantHashes(t *testing.T, got [][]byte, want ...testHeader) {
+	t.Helper()
+
+	if len(got) != len(want) {
+		t.Fatalf("got %d hashes, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if !bytes.Equal(got[i], want[i].Hash) {
+			t.Errorf("hash %d is %x, want %x at height %d", i, got[i], want[i].Hash, want[i].Height)
+		}
+	}
+}
+
+func storeBodies(t *testing.T, hi *HeaderIndex, headers ...testHeader) {
+	t.Helper()
+
+	err := hi.Blockchain.Database.Update(func(txn *badger.Txn) error {
+		for _, h := range headers {
+			if err := txn.Set(h.Hash, []byte{1}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestHeaderIndexReorg(t *testing.T) {
+	hi, genesis := newTestHeaderIndex(t, &chaincfg.RegTestParams)
+
+	a := headerRun(genesis, "a", 2)
+	if _, err := hi.ProcessHeaders(headerDatas(a...)); err != nil {
+		t.Fatal(err)
+	}
+	wantHashes(t, missingHashes(t, hi, 10), a...)
+	storeBodies(t, hi, a[0])
+
+	// A fork of equal work does not move the best header.
+	b := headerRun(genesis, "b", 3)
+	if _, err := hi.ProcessHeaders(headerDatas(b[:2]...)); err != nil {
+		t.Fatal(err)
+	}
+	if best, _ := hi.Best(); !bytes.Equal(best.Hash, a[1].Hash) {
+		t.Fatalf("best header moved to a fork of equal work")
+	}
+	wantHashes(t, missingHashes(t, hi, 10), a[1])
+
+	// One more header gives the fork more work. The cursor stood above
+	// the stored a[0] and steps back to the fork point.
+	if _, err := hi.ProcessHeaders(headerDatas(b[2])); err != nil {
+		t.Fatal(err)
+	}
+	if best, _ := hi.Best(); !bytes.Equal(best.Hash, b[2].Hash) {
+		t.Fatalf("best header did not move to the fork with more work")
+	}
+	wantHashes(t, missingHashes(t, hi, 2), b[0], b[1])
+
+	storeBodies(t, hi, b[0], b[1])
+	wantHashes(t, missingHashes(t, hi, 2), b[2])
+
+	storeBodies(t, hi, b[2])
+	wantHashes(t, missingHashes(t, hi, 2))
+
+	locator, err := hi.Locator()
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantHashes(t, locator, b[2], b[1], b[0], genesis)
+}
@@ -28,23 +28,36 @@ func NewProof(b *Block) *ProofOfWork {
 	return pow
 }
 
+// InitData returns the bytes hashed for nonce. From HeaderActivationHeight on
+// it is the serialized header, which needs MerkleRoot to be set beforehand.
 func (pow *ProofOfWork) InitData(nonce int64) ([]byte, error) {
+	if IsHeaderActive(pow.Block.Height) {
+		header := pow.Block.Header()
+		header.Nonce = nonce
+
+		return header.Serialize(), nil
+	}
+
 	hashTx, err := pow.Block.HashTransactions()
 	if err != nil {
 		return nil, err
 	}
 
-	info := bytes.Join([][]byte{
+	return legacyPowData(hashTx, pow.Block.PrevHash, nonce, pow.Block.NBits, pow.Block.Height, pow.Block.Timestamp, pow.Block.TxCount), nil
+}
+
+// legacyPowData is what blocks below HeaderActivationHeight hash. hashTx is
+// the merkle root of the transactions.
+func legacyPowData(hashTx, prevHash []byte, nonce int64, nbits uint32, height, timestamp, txCount int64) []byte {
+	return bytes.Join([][]byte{
 		hashTx,
-		pow.Block.PrevHash,
-		ToByte(int64(nonce)),
-		ToByte(int64(pow.Block.NBits)),
-		ToByte(pow.Block.Height),
-		ToByte(pow.Block.Timestamp),
-		ToByte(pow.Block.TxCount),
+		prevHash,
+		ToByte(nonce),
+		ToByte(int64(nbits)),
+		ToByte(height),
+		ToByte(timestamp),
+		ToByte(txCount),
 	}, []byte{})
-
-	return info, nil
 }
 
 func (pow *ProofOfWork) Validate() bool {
@@ -57,6 +70,10 @@ func (pow *ProofOfWork) Validate() bool {
 	}
 	hash = sha256.Sum256(info)
 
+	if !bytes.Equal(hash[:], pow.Block.Hash) {
+		return false
+	}
+
 	initHash.SetBytes(hash[:])
 
 	return initHash.Cmp(pow.Target) == -1
@@ -0,0 +1,819 @@
+package blockchain
+
+import (
+	"bytes"
+	"core-blockchain/common/utils"
+	"core-blockchain/wallet"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// A PSBT carries an unsigned transaction between the parties that sign it:
+// the outputs its inputs spend, the derivation path of the keys involved and
+// the signatures collected so far. Signers need no chain access, everything
+// a signature commits to is in the container.
+//
+// Serialized layout, integers little endian, byte strings length prefixed
+// as in SerializeTransaction:
+//
+//	magic "psbt" 0xff | version uint32 | height int64 | unsigned transaction
+//	per input:  prev PubKeyHash (empty when unknown) | prev Value int64 |
+//	            sighash type uint32 | path | signature count uint32 |
+//	            (public key | signature)... | final signature |
+//	            prev LockingScript | redeem script | final script
+//	per output: public key | path
+//
+// Version 1 PSBTs end their inputs at the final signature, they are still
+// read.
+const PSBTVersion uint32 = 2
+
+var psbtMagic = []byte{'p', 's', 'b', 't', 0xff}
+
+var (
+	ErrInvalidPSBT        = errors.New("invalid psbt")
+	ErrPSBTMismatch       = errors.New("psbts spend different transactions")
+	ErrPSBTNotFinal       = errors.New("psbt is not finalized")
+	ErrPSBTSigned         = errors.New("psbt already carries signatures")
+	ErrMissingPrevOutput  = errors.New("previous output of input is unknown")
+	ErrMissingInputPubKey = errors.New("public key of input is unknown")
+	ErrPrevOutputMismatch = errors.New("previous output does not match")
+	ErrInvalidPartialSig  = errors.New("invalid partial signature")
+	ErrPSBTScriptInput    = errors.New("psbt inputs must spend a public key hash or a script hash")
+)
+
+type PSBT struct {
+	// Height is the block height the transaction is built for. It selects
+	// the sighash and the encoding its ID is hashed with.
+	Height  int64
+	Tx      Transaction
+	Inputs  []PSBTInput
+	Outputs []PSBTOutput
+}
+
+// PSBTInput.SigHashType 0 signs with SigHashAll. PartialSigs maps hex encoded
+// public keys to their signature. FinalSignature is the one Extract puts in
+// the transaction. An input spending a pay-to-script-hash output has no key,
+// it carries the multisig RedeemScript the output commits to instead, and
+// FinalScript, its unlocking script, once enough keys signed.
+type PSBTInput struct {
+	PrevOutput     *TxOutput
+	SigHashType    SigHashType
+	Path           string
+	RedeemScript   []byte
+	PartialSigs    map[string][]byte
+	FinalSignature []byte
+	FinalScript    []byte
+}
+
+// PSBTOutput describes the key of an output the creator owns, a change
+// output, so that signers can check it.
+type PSBTOutput struct {
+	PubKey []byte
+	Path   string
+}
+
+// NewPSBT wraps tx, whose inputs must not be signed, for signing at height.
+// The ID is computed when tx has none.
+func NewPSBT(tx *Transaction, height int64) (*PSBT, error) {
+	if tx.IsMinerTx() || len(tx.Inputs) == 0 || len(tx.Outputs) == 0 {
+		return nil, fmt.Errorf("%w: transaction needs inputs and outputs", ErrInvalidPSBT)
+	}
+
+	p := &PSBT{
+		Height:  height,
+		Tx:      Transaction{ID: bytes.Clone(tx.ID)},
+		Inputs:  make([]PSBTInput, len(tx.Inputs)),
+		Outputs: make([]PSBTOutput, len(tx.Outputs)),
+	}
+
+	for _, in := range tx.Inputs {
+		if len(in.Signature) > 0 || len(in.UnlockingScript) > 0 {
+			return nil, ErrPSBTSigned
+		}
+		p.Tx.Inputs = append(p.Tx.Inputs, TxInput{ID: bytes.Clone(in.ID), Out: in.Out, PubKey: bytes.Clone(in.PubKey)})
+	}
+	for _, out := range tx.Outputs {
+		p.Tx.Outputs = append(p.Tx.Outputs, TxOutput{Value: out.Value, PubKeyHash: bytes.Clone(out.PubKeyHash), LockingScript: bytes.Clone(out.LockingScript)})
+	}
+
+	if len(p.Tx.ID) == 0 {
+		if err := p.rehash(); err != nil {
+			return nil, err
+		}
+	}
+
+	return p, nil
+}
+
+func (p *PSBT) rehash() error {
+	p.Tx.ID = nil
+
+	id, err := p.Tx.Hash(p.Height)
+	if err != nil {
+		return err
+	}
+	p.Tx.ID = id
+
+	return nil
+}
+
+func (p *PSBT) hasSignatures() bool {
+	for _, in := range p.Inputs {
+		if len(in.PartialSigs) > 0 || in.IsFinal() {
+			return true
+		}
+	}
+
+	return false
+}
+
+// IsFinal reports whether the input carries what Extract puts in the
+// transaction.
+func (in *PSBTInput) IsFinal() bool {
+	return len(in.FinalSignature) > 0 || len(in.FinalScript) > 0
+}
+
+// SpendsScript reports whether the input spends an output locked by a
+// script, which it unlocks with its redeem script.
+func (in *PSBTInput) SpendsScript() bool {
+	return in.PrevOutput != nil && len(in.PrevOutput.LockingScript) > 0
+}
+
+// RedeemKeys returns the keys of the redeem script, in script order.
+func (in *PSBTInput) RedeemKeys() [][]byte {
+	_, pubKeys, err := parseMultiSig(in.RedeemScript)
+	if err != nil {
+		return nil
+	}
+
+	return pubKeys
+}
+
+// redeems reports whether script is the redeem script prev commits to.
+func redeems(script []byte, prev *TxOutput) bool {
+	return prev != nil && bytes.Equal(NewPayToScriptHashScript(ScriptHash(script)), prev.LockingScript)
+}
+
+// SetPrevOutput records the output input idx spends. It has to be locked by
+// a public key hash or, once scripts are active, by a script hash, see
+// SetRedeemScript.
+func (p *PSBT) SetPrevOutput(idx int, out TxOutput) error {
+	if idx < 0 || idx >= len(p.Inputs) {
+		return fmt.Errorf("input index %d out of range", idx)
+	}
+	if len(out.LockingScript) > 0 {
+		if class, _ := ClassifyScript(out.LockingScript); class != ClassScriptHash || !IsScriptActive(p.Height) {
+			return fmt.Errorf("input %d: %w", idx, ErrPSBTScriptInput)
+		}
+	}
+
+	pubKey := p.Tx.Inputs[idx].PubKey
+	if len(pubKey) > 0 && !bytes.Equal(wallet.PublicKeyHash(pubKey), out.PubKeyHash) {
+		return fmt.Errorf("input %d: %w", idx, ErrPrevOutputMismatch)
+	}
+
+	prev := &TxOutput{Value: out.Value, PubKeyHash: bytes.Clone(out.PubKeyHash), LockingScript: bytes.Clone(out.LockingScript)}
+	if redeem := p.Inputs[idx].RedeemScript; len(redeem) > 0 && !redeems(redeem, prev) {
+		return fmt.Errorf("input %d: %w", idx, ErrPrevOutputMismatch)
+	}
+
+	p.Inputs[idx].PrevOutput = prev
+
+	return nil
+}
+
+// SetRedeemScript records script as the redeem script of input idx, whose
+// previous output has to commit to it. Only multisig scripts can be signed.
+func (p *PSBT) SetRedeemScript(idx int, script []byte) error {
+	if idx < 0 || idx >= len(p.Inputs) {
+		return fmt.Errorf("input index %d out of range", idx)
+	}
+
+	in := &p.Inputs[idx]
+	if in.PrevOutput == nil {
+		return fmt.Errorf("input %d: %w", idx, ErrMissingPrevOutput)
+	}
+	if !redeems(script, in.PrevOutput) {
+		return fmt.Errorf("input %d: %w", idx, ErrPrevOutputMismatch)
+	}
+	if _, _, err := parseMultiSig(script); err != nil {
+		return fmt.Errorf("input %d: %w: redeem script is not multisig", idx, err)
+	}
+
+	in.RedeemScript = bytes.Clone(script)
+
+	return nil
+}
+
+// SetInputKey sets the public key that unlocks input idx. The key is part of
+// the transaction ID, so it can only change while nothing is signed.
+func (p *PSBT) SetInputKey(idx int, pubKey []byte, path string) error {
+	if idx < 0 || idx >= len(p.Inputs) {
+		return fmt.Errorf("input index %d out of range", idx)
+	}
+
+	in := &p.Inputs[idx]
+	if in.PrevOutput != nil && !bytes.Equal(wallet.PublicKeyHash(pubKey), in.PrevOutput.PubKeyHash) {
+		return fmt.Errorf("input %d: %w", idx, ErrPrevOutputMismatch)
+	}
+
+	if path != "" {
+		in.Path = path
+	}
+
+	if bytes.Equal(p.Tx.Inputs[idx].PubKey, pubKey) {
+		return nil
+	}
+	if p.hasSignatures() {
+		return ErrPSBTSigned
+	}
+
+	p.Tx.Inputs[idx].PubKey = bytes.Clone(pubKey)
+
+	return p.rehash()
+}
+
+// SetOutputKey describes output idx as locked to pubKey.
+func (p *PSBT) SetOutputKey(idx int, pubKey []byte, path string) error {
+	if idx < 0 || idx >= len(p.Outputs) {
+		return fmt.Errorf("output index %d out of range", idx)
+	}
+	if !bytes.Equal(wallet.PublicKeyHash(pubKey), p.Tx.Outputs[idx].PubKeyHash) {
+		return fmt.Errorf("output %d: public key does not match", idx)
+	}
+
+	p.Outputs[idx] = PSBTOutput{PubKey: bytes.Clone(pubKey), Path: path}
+
+	return nil
+}
+
+func (in *PSBTInput) hashType() SigHashType {
+	if in.SigHashType == 0 {
+		return SigHashAll
+	}
+	return in.SigHashType
+}
+
+// Sign adds the signature of w to every input locked to its key, or to a
+// redeem script holding it, and returns how many it signed. Inputs without a
+// public key get the one of w first, which is only possible while nothing is
+// signed.
+func (p *PSBT) Sign(w wallet.Wallet) (int, error) {
+	pubKeyHash := wallet.PublicKeyHash(w.PublicKey)
+
+	var mine, scripts []int
+	for i := range p.Inputs {
+		in := &p.Inputs[i]
+		if in.IsFinal() {
+			continue
+		}
+
+		if in.SpendsScript() {
+			if slices.ContainsFunc(in.RedeemKeys(), func(pubKey []byte) bool { return bytes.Equal(pubKey, w.PublicKey) }) {
+				scripts = append(scripts, i)
+			}
+			continue
+		}
+
+		switch {
+		case bytes.Equal(p.Tx.Inputs[i].PubKey, w.PublicKey):
+		case len(p.Tx.Inputs[i].PubKey) == 0 && in.PrevOutput != nil && bytes.Equal(in.PrevOutput.PubKeyHash, pubKeyHash):
+			if err := p.SetInputKey(i, w.PublicKey, w.Path); err != nil {
+				return 0, err
+			}
+		default:
+			continue
+		}
+
+		if in.PrevOutput == nil {
+			return 0, fmt.Errorf("input %d: %w", i, ErrMissingPrevOutput)
+		}
+		mine = append(mine, i)
+	}
+
+	// Every key has to be known before signing, it changes the ID.
+	for i, in := range p.Tx.Inputs {
+		if len(in.PubKey) == 0 && !p.Inputs[i].SpendsScript() && len(mine)+len(scripts) > 0 {
+			return 0, fmt.Errorf("input %d: %w", i, ErrMissingInputPubKey)
+		}
+	}
+
+	for _, i := range mine {
+		in := &p.Inputs[i]
+
+		signature, err := p.Tx.inputSignature(i, w.PrivateKey, in.PrevOutput.PubKeyHash, in.hashType(), p.Height)
+		if err != nil {
+			return 0, fmt.Errorf("input %d: %w", i, err)
+		}
+
+		if in.PartialSigs == nil {
+			in.PartialSigs = make(map[string][]byte)
+		}
+		in.PartialSigs[hex.EncodeToString(w.PublicKey)] = signature
+		if in.Path == "" {
+			in.Path = w.Path
+		}
+	}
+
+	for _, i := range scripts {
+		in := &p.Inputs[i]
+
+		signature, err := p.Tx.ScriptSignature(i, w.PrivateKey, in.RedeemScript, in.hashType())
+		if err != nil {
+			return 0, fmt.Errorf("input %d: %w", i, err)
+		}
+
+		if in.PartialSigs == nil {
+			in.PartialSigs = make(map[string][]byte)
+		}
+		in.PartialSigs[hex.EncodeToString(w.PublicKey)] = signature
+	}
+
+	return len(mine) + len(scripts), nil
+}
+
+// Combine merges the data other collected for the same transaction.
+func (p *PSBT) Combine(other *PSBT) error {
+	if p.Height != other.Height || !bytes.Equal(p.unsignedTx(), other.unsignedTx()) {
+		return ErrPSBTMismatch
+	}
+
+	for i := range p.Inputs {
+		in, o := &p.Inputs[i], &other.Inputs[i]
+
+		if o.PrevOutput != nil {
+			if in.PrevOutput == nil {
+				in.PrevOutput = &TxOutput{Value: o.PrevOutput.Value, PubKeyHash: bytes.Clone(o.PrevOutput.PubKeyHash), LockingScript: bytes.Clone(o.PrevOutput.LockingScript)}
+			} else if in.PrevOutput.Value != o.PrevOutput.Value || !bytes.Equal(in.PrevOutput.PubKeyHash, o.PrevOutput.PubKeyHash) ||
+				!bytes.Equal(in.PrevOutput.LockingScript, o.PrevOutput.LockingScript) {
+				return fmt.Errorf("input %d: %w", i, ErrPrevOutputMismatch)
+			}
+		}
+
+		if len(o.RedeemScript) > 0 {
+			if !redeems(o.RedeemScript, in.PrevOutput) {
+				return fmt.Errorf("input %d: %w", i, ErrPrevOutputMismatch)
+			}
+			in.RedeemScript = bytes.Clone(o.RedeemScript)
+		}
+
+		if o.SigHashType != 0 {
+			if in.SigHashType != 0 && in.SigHashType != o.SigHashType {
+				return fmt.Errorf("input %d: conflicting sighash types", i)
+			}
+			in.SigHashType = o.SigHashType
+		}
+
+		if in.Path == "" {
+			in.Path = o.Path
+		}
+
+		for key, sig := range o.PartialSigs {
+			if in.PartialSigs == nil {
+				in.PartialSigs = make(map[string][]byte)
+			}
+			if _, ok := in.PartialSigs[key]; !ok {
+				in.PartialSigs[key] = bytes.Clone(sig)
+			}
+		}
+
+		if !in.IsFinal() {
+			in.FinalSignature = bytes.Clone(o.FinalSignature)
+			in.FinalScript = bytes.Clone(o.FinalScript)
+		}
+	}
+
+	for i := range p.Outputs {
+		if len(p.Outputs[i].PubKey) == 0 {
+			p.Outputs[i] = PSBTOutput{PubKey: bytes.Clone(other.Outputs[i].PubKey), Path: other.Outputs[i].Path}
+		}
+	}
+
+	return nil
+}
+
+func (p *PSBT) unsignedTx() []byte {
+	buf := new(bytes.Buffer)
+	SerializeTransaction(&p.Tx, buf)
+	return buf.Bytes()
+}
+
+// IsSigned reports whether every input carries the signature of its key, or
+// as many signatures as its redeem script requires.
+func (p *PSBT) IsSigned() bool {
+	for i, in := range p.Inputs {
+		if in.IsFinal() {
+			continue
+		}
+		if in.SpendsScript() {
+			if sigs, m := in.multiSigs(); m == 0 || len(sigs) < m {
+				return false
+			}
+			continue
+		}
+		if _, ok := in.PartialSigs[hex.EncodeToString(p.Tx.Inputs[i].PubKey)]; !ok || len(p.Tx.Inputs[i].PubKey) == 0 {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (p *PSBT) IsFinalized() bool {
+	for _, in := range p.Inputs {
+		if !in.IsFinal() {
+			return false
+		}
+	}
+
+	return true
+}
+
+// multiSigs returns the partial signatures of the redeem script keys, in
+// script order, and how many of them the script requires.
+func (in *PSBTInput) multiSigs() ([][]byte, int) {
+	m, pubKeys, err := parseMultiSig(in.RedeemScript)
+	if err != nil {
+		return nil, 0
+	}
+
+	var sigs [][]byte
+	for _, pubKey := range pubKeys {
+		if sig, ok := in.PartialSigs[hex.EncodeToString(pubKey)]; ok {
+			sigs = append(sigs, sig)
+		}
+	}
+
+	return sigs, m
+}
+
+// finalizeScript builds the unlocking script of input idx, which spends a
+// script hash, from the first signatures its redeem script requires and
+// checks it. Inputs still missing signatures are left alone.
+func (p *PSBT) finalizeScript(idx int) error {
+	in := &p.Inputs[idx]
+
+	sigs, m := in.multiSigs()
+	if m == 0 || len(sigs) < m {
+		return nil
+	}
+
+	tx := p.Tx
+	tx.Inputs = slices.Clone(p.Tx.Inputs)
+	tx.Inputs[idx].UnlockingScript = NewPushScript(append(sigs[:m:m], in.RedeemScript)...)
+
+	if err := tx.VerifyInputScript(idx, *in.PrevOutput); err != nil {
+		return fmt.Errorf("input %d: %w: %v", idx, ErrInvalidPartialSig, err)
+	}
+
+	in.FinalScript = tx.Inputs[idx].UnlockingScript
+	in.PartialSigs = nil
+
+	return nil
+}
+
+// Finalize checks the signature of the key of every input and moves it to
+// FinalSignature, dropping the partial ones. Inputs spending a script hash
+// get their FinalScript instead. Inputs that are not signed yet are left
+// alone. It reports whether every input is final.
+func (p *PSBT) Finalize() (bool, error) {
+	for i := range p.Inputs {
+		in := &p.Inputs[i]
+		if in.IsFinal() {
+			continue
+		}
+
+		if in.SpendsScript() {
+			if err := p.finalizeScript(i); err != nil {
+				return false, err
+			}
+			continue
+		}
+
+		pubKey := p.Tx.Inputs[i].PubKey
+		signature, ok := in.PartialSigs[hex.EncodeToString(pubKey)]
+		if !ok || len(pubKey) == 0 {
+			continue
+		}
+		if in.PrevOutput == nil {
+			return false, fmt.Errorf("input %d: %w", i, ErrMissingPrevOutput)
+		}
+
+		tx := p.Tx
+		tx.Inputs = slices.Clone(p.Tx.Inputs)
+		tx.Inputs[i].Signature = signature
+
+		if !tx.verifyInputAt(i, in.PrevOutput.PubKeyHash, p.Height) {
+			return false, fmt.Errorf("input %d: %w", i, ErrInvalidPartialSig)
+		}
+
+		in.FinalSignature = signature
+		in.PartialSigs = nil
+	}
+
+	return p.IsFinalized(), nil
+}
+
+// Fee returns what the inputs leave over the outputs, false while a
+// previous output is unknown.
+func (p *PSBT) Fee() (int64, bool) {
+	var fee int64
+
+	for _, in := range p.Inputs {
+		if in.PrevOutput == nil {
+			return 0, false
+		}
+		fee += in.PrevOutput.Value
+	}
+	for _, out := range p.Tx.Outputs {
+		fee -= out.Value
+	}
+
+	return fee, true
+}
+
+// Extract returns the signed transaction of a finalized PSBT.
+func (p *PSBT) Extract() (*Transaction, error) {
+	if !p.IsFinalized() {
+		return nil, ErrPSBTNotFinal
+	}
+
+	if fee, ok := p.Fee(); !ok || fee < 0 {
+		return nil, fmt.Errorf("%w: outputs exceed inputs", ErrInvalidPSBT)
+	}
+
+	tx := &Transaction{ID: bytes.Clone(p.Tx.ID)}
+	for i, in := range p.Tx.Inputs {
+		tx.Inputs = append(tx.Inputs, TxInput{
+			ID:              bytes.Clone(in.ID),
+			Out:             in.Out,
+			Signature:       bytes.Clone(p.Inputs[i].FinalSignature),
+			PubKey:          bytes.Clone(in.PubKey),
+			UnlockingScript: bytes.Clone(p.Inputs[i].FinalScript),
+		})
+	}
+	for _, out := range p.Tx.Outputs {
+		tx.Outputs = append(tx.Outputs, TxOutput{Value: out.Value, PubKeyHash: bytes.Clone(out.PubKeyHash), LockingScript: bytes.Clone(out.LockingScript)})
+	}
+
+	return tx, nil
+}
+
+func (p *PSBT) Serialize() []byte {
+	buf := new(bytes.Buffer)
+
+	buf.Write(psbtMagic)
+	binary.Write(buf, binary.LittleEndian, PSBTVersion)
+	binary.Write(buf, binary.LittleEndian, p.Height)
+	SerializeTransaction(&p.Tx, buf)
+
+	for _, in := range p.Inputs {
+		var prev TxOutput
+		if in.PrevOutput != nil {
+			prev = *in.PrevOutput
+		}
+		utils.WriteBytes(buf, prev.PubKeyHash)
+		binary.Write(buf, binary.LittleEndian, prev.Value)
+		binary.Write(buf, binary.LittleEndian, uint32(in.SigHashType))
+		utils.WriteBytes(buf, []byte(in.Path))
+
+		keys := slices.Sorted(maps.Keys(in.PartialSigs))
+		binary.Write(buf, binary.LittleEndian, uint32(len(keys)))
+		for _, key := range keys {
+			pubKey, _ := hex.DecodeString(key)
+			utils.WriteBytes(buf, pubKey)
+			utils.WriteBytes(buf, in.PartialSigs[key])
+		}
+
+		utils.WriteBytes(buf, in.FinalSignature)
+		utils.WriteBytes(buf, prev.LockingScript)
+		utils.WriteBytes(buf, in.RedeemScript)
+		utils.WriteBytes(buf, in.FinalScript)
+	}
+
+	for _, out := range p.Outputs {
+		utils.WriteBytes(buf, out.PubKey)
+		utils.WriteBytes(buf, []byte(out.Path))
+	}
+
+	return buf.Bytes()
+}
+
+// Base64 is the form PSBTs are exchanged in.
+func (p *PSBT) Base64() string {
+	return base64.StdEncoding.EncodeToString(p.Serialize())
+}
+
+// psbtReader reads untrusted PSBT data, every length is checked against what
+// is left. The first error sticks.
+type psbtReader struct {
+	buf *bytes.Buffer
+	err error
+}
+
+func (r *psbtReader) read(v any) {
+	if r.err == nil && binary.Read(r.buf, binary.LittleEndian, v) != nil {
+		r.err = fmt.Errorf("%w: truncated", ErrInvalidPSBT)
+	}
+}
+
+func (r *psbtReader) bytes() []byte {
+	var length uint32
+	r.read(&length)
+	if r.err != nil || length == 0 {
+		return nil
+	}
+	if int(length) > r.buf.Len() {
+		r.err = fmt.Errorf("%w: truncated", ErrInvalidPSBT)
+		return nil
+	}
+
+	return bytes.Clone(r.buf.Next(int(length)))
+}
+
+// scriptMarker consumes the marker of a transaction with scripts, see
+// SerializeTransaction, and reports whether it was there.
+func (r *psbtReader) scriptMarker() bool {
+	if r.err != nil || r.buf.Len() < 4 || binary.LittleEndian.Uint32(r.buf.Bytes()) != scriptMarker {
+		return false
+	}
+	r.buf.Next(4)
+
+	return true
+}
+
+// count reads an item count, each item taking at least min bytes.
+func (r *psbtReader) count(min int) int {
+	var n uint32
+	r.read(&n)
+	if r.err == nil && int(n) > r.buf.Len()/min {
+		r.err = fmt.Errorf("%w: count %d", ErrInvalidPSBT, n)
+	}
+	if r.err != nil {
+		return 0
+	}
+
+	return int(n)
+}
+
+func DeserializePSBT(data []byte) (*PSBT, error) {
+	if !bytes.HasPrefix(data, psbtMagic) {
+		return nil, fmt.Errorf("%w: bad magic", ErrInvalidPSBT)
+	}
+
+	r := &psbtReader{buf: bytes.NewBuffer(data[len(psbtMagic):])}
+
+	var version uint32
+	r.read(&version)
+	if r.err == nil && (version == 0 || version > PSBTVersion) {
+		return nil, fmt.Errorf("%w: version %d", ErrInvalidPSBT, version)
+	}
+
+	p := &PSBT{}
+	r.read(&p.Height)
+
+	p.Tx.ID = r.bytes()
+	scripts := r.scriptMarker()
+	for range r.count(16) {
+		in := TxInput{ID: r.bytes()}
+		r.read(&in.Out)
+		in.Signature = r.bytes()
+		in.PubKey = r.bytes()
+		if scripts {
+			in.UnlockingScript = r.bytes()
+		}
+		p.Tx.Inputs = append(p.Tx.Inputs, in)
+	}
+	for range r.count(12) {
+		out := TxOutput{}
+		r.read(&out.Value)
+		out.PubKeyHash = r.bytes()
+		if scripts {
+			out.LockingScript = r.bytes()
+		}
+		p.Tx.Outputs = append(p.Tx.Outputs, out)
+	}
+
+	for range p.Tx.Inputs {
+		in := PSBTInput{}
+
+		prev := TxOutput{PubKeyHash: r.bytes()}
+		r.read(&prev.Value)
+
+		var hashType uint32
+		r.read(&hashType)
+		in.SigHashType = SigHashType(hashType)
+		in.Path = string(r.bytes())
+
+		for range r.count(8) {
+			pubKey, signature := r.bytes(), r.bytes()
+			if in.PartialSigs == nil {
+				in.PartialSigs = make(map[string][]byte)
+			}
+			in.PartialSigs[hex.EncodeToString(pubKey)] = signature
+		}
+
+		in.FinalSignature = r.bytes()
+		if version > 1 {
+			prev.LockingScript = r.bytes()
+			in.RedeemScript = r.bytes()
+			in.FinalScript = r.bytes()
+		}
+
+		if len(prev.PubKeyHash) > 0 || len(prev.LockingScript) > 0 {
+			in.PrevOutput = &prev
+		}
+		p.Inputs = append(p.Inputs, in)
+	}
+
+	for range p.Tx.Outputs {
+		out := PSBTOutput{PubKey: r.bytes()}
+		out.Path = string(r.bytes())
+		p.Outputs = append(p.Outputs, out)
+	}
+
+	if r.err != nil {
+		return nil, r.err
+	}
+	if r.buf.Len() > 0 {
+		return nil, fmt.Errorf("%w: %d trailing bytes", ErrInvalidPSBT, r.buf.Len())
+	}
+
+	if err := p.check(); err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+func (p *PSBT) check() error {
+	if len(p.Tx.Inputs) == 0 || len(p.Tx.Outputs) == 0 || p.Tx.IsMinerTx() {
+		return fmt.Errorf("%w: transaction needs inputs and outputs", ErrInvalidPSBT)
+	}
+
+	for i, in := range p.Tx.Inputs {
+		if len(in.Signature) > 0 || len(in.UnlockingScript) > 0 {
+			return fmt.Errorf("%w: input %d of the unsigned transaction is signed", ErrInvalidPSBT, i)
+		}
+		if t := p.Inputs[i].SigHashType; t != 0 && !t.IsValid() {
+			return fmt.Errorf("%w: input %d: %v", ErrInvalidPSBT, i, ErrInvalidSigHashType)
+		}
+		if prev := p.Inputs[i].PrevOutput; prev != nil && len(in.PubKey) > 0 && !bytes.Equal(wallet.PublicKeyHash(in.PubKey), prev.PubKeyHash) {
+			return fmt.Errorf("%w: input %d: %v", ErrInvalidPSBT, i, ErrPrevOutputMismatch)
+		}
+		if p.Inputs[i].SpendsScript() {
+			if class, _ := ClassifyScript(p.Inputs[i].PrevOutput.LockingScript); class != ClassScriptHash {
+				return fmt.Errorf("%w: input %d: %v", ErrInvalidPSBT, i, ErrPSBTScriptInput)
+			}
+		}
+		if redeem := p.Inputs[i].RedeemScript; len(redeem) > 0 && !redeems(redeem, p.Inputs[i].PrevOutput) {
+			return fmt.Errorf("%w: input %d: %v", ErrInvalidPSBT, i, ErrPrevOutputMismatch)
+		}
+	}
+
+	return nil
+}
+
+// DecodePSBT parses the base64 form of a PSBT.
+func DecodePSBT(s string) (*PSBT, error) {
+	data, err := base64.StdEncoding.DecodeString(strings.TrimSpace(s))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPSBT, err)
+	}
+
+	return DeserializePSBT(data)
+}
+
+// ParseSigHashType reads ALL, NONE or SINGLE, optionally followed by
+// |ANYONECANPAY. An empty string is SigHashAll.
+func ParseSigHashType(s string) (SigHashType, error) {
+	base, anyoneCanPay, _ := strings.Cut(strings.ToUpper(strings.TrimSpace(s)), "|")
+
+	var t SigHashType
+	switch base {
+	case "", "ALL":
+		t = SigHashAll
+	case "NONE":
+		t = SigHashNone
+	case "SINGLE":
+		t = SigHashSingle
+	default:
+		return 0, fmt.Errorf("%w: %s", ErrInvalidSigHashType, s)
+	}
+
+	switch anyoneCanPay {
+	case "":
+	case "ANYONECANPAY":
+		t |= SigHashAnyoneCanPay
+	default:
+		return 0, fmt.Errorf("%w: %s", ErrInvalidSigHashType, s)
+	}
+
+	return t, nil
+}
@@ -0,0 +1,353 @@
+package blockchain
+
+import (
+	"bytes"
+	"core-blockchain/chaincfg"
+	"core-blockchain/wallet"
+	"errors"
+	"testing"
+)
+
+// psbtHeight is a height at which regtest has scripts and the binary
+// sighash active.
+const psbtHeight = 10
+
+// useTestParams selects params for the test and restores the previous
+// network afterwards.
+func useTestParams(t *testing.T, params *chaincfg.Params) {
+	t.Helper()
+
+	prev := ActiveParams()
+	UseParams(params)
+	t.Cleanup(func() { UseParams(prev) })
+}
+
+// roundTrip serializes p and decodes it again, checking nothing is lost.
+func roundTrip(t *testing.T, p *PSBT) *PSBT {
+	t.Helper()
+
+	data := p.Serialize()
+	decoded, err := DecodePSBT(p.Base64())
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if !bytes.Equal(decoded.Serialize(), data) {
+		t.Fatal("psbt changed in a serialize round trip")
+	}
+
+	return decoded
+}
+
+// multiSigOutput locks value to an m of n multisig redeem script of keys.
+func multiSigOutput(t *testing.T, value int64, m int, keys ...*wallet.Wallet) (TxOutput, []byte) {
+	t.Helper()
+
+	var pubKeys [][]byte
+	for _, w := range keys {
+		pubKeys = append(pubKeys, w.PublicKey)
+	}
+
+	redeem, err := NewMultiSigScript(m, pubKeys)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return TxOutput{Value: value, LockingScript: NewPayToScriptHashScript(ScriptHash(redeem))}, redeem
+}
+
+func TestPSBTSignCombineFinalize(t *testing.T) {
+	useTestParams(t, &chaincfg.RegTestParams)
+
+	alice, bob, carol := wallet.NewWallet(), wallet.NewWallet(), wallet.NewWallet()
+	payee := wallet.PublicKeyHash(wallet.NewWallet().PublicKey)
+
+	tests := []struct {
+		name string
+		// build returns an unsigned PSBT with its previous outputs set and
+		// the wallets that sign it, each on its own copy.
+		build   func(t *testing.T) (*PSBT, []*wallet.Wallet)
+		wantFee int64
+	}{
+		{
+			name: "pay to public key hash inputs of two signers",
+			build: func(t *testing.T) (*PSBT, []*wallet.Wallet) {
+				tx := &Transaction{
+					Inputs:  []TxInput{{ID: testTxID("a"), Out: 0}, {ID: testTxID("b"), Out: 1}},
+					Outputs: []TxOutput{{Value: 1000, PubKeyHash: payee}},
+				}
+				p, err := NewPSBT(tx, psbtHeight)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				for i, w := range []*wallet.Wallet{alice, bob} {
+					if err := p.SetPrevOutput(i, TxOutput{Value: 600, PubKeyHash: wallet.PublicKeyHash(w.PublicKey)}); err != nil {
+						t.Fatal(err)
+					}
+					// Keys change the ID, they have to be set before the
+					// copies part ways.
+					if err := p.SetInputKey(i, w.PublicKey, ""); err != nil {
+						t.Fatal(err)
+					}
+				}
+				return p, []*wallet.Wallet{alice, bob}
+			},
+			wantFee: 200,
+		},
+		{
+			name: "two of three multisig",
+			build: func(t *testing.T) (*PSBT, []*wallet.Wallet) {
+				prev, redeem := multiSigOutput(t, 1500, 2, alice, bob, carol)
+
+				tx := &Transaction{
+					Inputs:  []TxInput{{ID: testTxID("a"), Out: 0}},
+					Outputs: []TxOutput{{Value: 1400, PubKeyHash: payee}},
+				}
+				p, err := NewPSBT(tx, psbtHeight)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if err := p.SetPrevOutput(0, prev); err != nil {
+					t.Fatal(err)
+				}
+				if err := p.SetRedeemScript(0, redeem); err != nil {
+					t.Fatal(err)
+				}
+				return p, []*wallet.Wallet{carol, alice}
+			},
+			wantFee: 100,
+		},
+		{
+			name: "multisig and public key hash inputs",
+			build: func(t *testing.T) (*PSBT, []*wallet.Wallet) {
+				prev, redeem := multiSigOutput(t, 1000, 1, bob, carol)
+
+				tx := &Transaction{
+					Inputs:  []TxInput{{ID: testTxID("a"), Out: 0}, {ID: testTxID("b"), Out: 0}},
+					Outputs: []TxOutput{{Value: 1500, PubKeyHash: payee}},
+				}
+				p, err := NewPSBT(tx, psbtHeight)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if err := p.SetPrevOutput(0, prev); err != nil {
+					t.Fatal(err)
+				}
+				if err := p.SetRedeemScript(0, redeem); err != nil {
+					t.Fatal(err)
+				}
+				if err := p.SetPrevOutput(1, TxOutput{Value: 1000, PubKeyHash: wallet.PublicKeyHash(alice.PublicKey)}); err != nil {
+					t.Fatal(err)
+				}
+				if err := p.SetInputKey(1, alice.PublicKey, ""); err != nil {
+					t.Fatal(err)
+				}
+				return p, []*wallet.Wallet{alice, carol}
+			},
+			wantFee: 500,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, signers := tt.build(t)
+
+			var copies []*PSBT
+			for _, w := range signers {
+				c := roundTrip(t, p)
+				if n, err := c.Sign(*w); err != nil || n == 0 {
+					t.Fatalf("sign: %d inputs, %v", n, err)
+				}
+				copies = append(copies, roundTrip(t, c))
+			}
+
+			combined := copies[0]
+			for _, c := range copies[1:] {
+				if combined.IsSigned() {
+					t.Fatal("signed before every signature was combined")
+				}
+				if err := combined.Combine(c); err != nil {
+					t.Fatalf("combine: %v", err)
+				}
+			}
+			if !combined.IsSigned() {
+				t.Fatal("not signed after combining")
+			}
+
+			if _, err := combined.Extract(); !errors.Is(err, ErrPSBTNotFinal) {
+				t.Errorf("extract before finalize: %v, want %v", err, ErrPSBTNotFinal)
+			}
+
+			final, err := combined.Finalize()
+			if err != nil || !final {
+				t.Fatalf("finalize: %v, %v", final, err)
+			}
+			combined = roundTrip(t, combined)
+
+			if fee, ok := combined.Fee(); !ok || fee != tt.wantFee {
+				t.Errorf("fee %d, %v, want %d", fee, ok, tt.wantFee)
+			}
+
+			tx, err := combined.Extract()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(tx.ID, p.Tx.ID) {
+				t.Errorf("extracted ID %x, want %x", tx.ID, p.Tx.ID)
+			}
+			for i, in := range combined.Inputs {
+				if err := tx.VerifyInputScript(i, *in.PrevOutput); err != nil {
+					t.Errorf("input %d: %v", i, err)
+				}
+			}
+		})
+	}
+}
+
+func TestPSBTCombineAndFinalizeErrors(t *testing.T) {
+	useTestParams(t, &chaincfg.RegTestParams)
+
+	alice := wallet.NewWallet()
+	prevOut := TxOutput{Value: 1000, PubKeyHash: wallet.PublicKeyHash(alice.PublicKey)}
+
+	newPSBT := func(t *testing.T, value int64) *PSBT {
+		t.Helper()
+
+		tx := &Transaction{
+			Inputs:  []TxInput{{ID: testTxID("a"), Out: 0, PubKey: alice.PublicKey}},
+			Outputs: []TxOutput{{Value: value, PubKeyHash: prevOut.PubKeyHash}},
+		}
+		p, err := NewPSBT(tx, psbtHeight)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := p.SetPrevOutput(0, prevOut); err != nil {
+			t.Fatal(err)
+		}
+		return p
+	}
+
+	tests := []struct {
+		name    string
+		run     func(t *testing.T) error
+		wantErr error
+	}{
+		{
+			name: "combine different transactions",
+			run: func(t *testing.T) error {
+				return newPSBT(t, 900).Combine(newPSBT(t, 800))
+			},
+			wantErr: ErrPSBTMismatch,
+		},
+		{
+			name: "combine conflicting previous outputs",
+			run: func(t *testing.T) error {
+				p, other := newPSBT(t, 900), newPSBT(t, 900)
+				other.Inputs[0].PrevOutput.Value = 2000
+				return p.Combine(other)
+			},
+			wantErr: ErrPrevOutputMismatch,
+		},
+		{
+			name: "finalize signature of another transaction",
+			run: func(t *testing.T) error {
+				p, other := newPSBT(t, 900), newPSBT(t, 800)
+				if _, err := other.Sign(*alice); err != nil {
+					t.Fatal(err)
+				}
+				p.Inputs[0].PartialSigs = other.Inputs[0].PartialSigs
+				_, err := p.Finalize()
+				return err
+			},
+			wantErr: ErrInvalidPartialSig,
+		},
+		{
+			name: "previous output of another key",
+			run: func(t *testing.T) error {
+				return newPSBT(t, 900).SetPrevOutput(0, TxOutput{Value: 1000, PubKeyHash: bytes.Repeat([]byte{1}, 20)})
+			},
+			wantErr: ErrPrevOutputMismatch,
+		},
+		{
+			name: "extract unsigned",
+			run: func(t *testing.T) error {
+				_, err := newPSBT(t, 900).Extract()
+				return err
+			},
+			wantErr: ErrPSBTNotFinal,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.run(t); !errors.Is(err, tt.wantErr) {
+				t.Errorf("error %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestDeserializePSBTTruncated(t *testing.T) {
+	useTestParams(t, &chaincfg.RegTestParams)
+
+	alice, bob := wallet.NewWallet(), wallet.NewWallet()
+	prev, redeem := multiSigOutput(t, 1000, 1, alice, bob)
+
+	tx := &Transaction{
+		Inputs:  []TxInput{{ID: testTxID("a"), Out: 0}, {ID: testTxID("b"), Out: 0, PubKey: alice.PublicKey}},
+		Outputs: []TxOutput{{Value: 1500, PubKeyHash: wallet.PublicKeyHash(bob.PublicKey)}},
+	}
+	p, err := NewPSBT(tx, psbtHeight)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.SetPrevOutput(0, prev); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.SetRedeemScript(0, redeem); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.SetPrevOutput(1, TxOutput{Value: 1000, PubKeyHash: wallet.PublicKeyHash(alice.PublicKey)}); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.SetOutputKey(0, bob.PublicKey, "m/0/1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.Sign(*alice); err != nil {
+		t.Fatal(err)
+	}
+
+	data := p.Serialize()
+	if _, err := DeserializePSBT(data); err != nil {
+		t.Fatalf("full psbt: %v", err)
+	}
+
+	for n := range len(data) {
+		if _, err := DeserializePSBT(data[:n]); !errors.Is(err, ErrInvalidPSBT) {
+			t.Fatalf("%d of %d bytes: error %v, want %v", n, len(data), err, ErrInvalidPSBT)
+		}
+	}
+
+	if _, err := DeserializePSBT(append(data, 0)); !errors.Is(err, ErrInvalidPSBT) {
+		t.Errorf("trailing byte: error %v, want %v", err, ErrInvalidPSBT)
+	}
+
+	// A count larger than the data left must fail before allocating. The
+	// input count follows the magic, version, height and transaction ID.
+	huge := append([]byte(nil), data...)
+	inputCount := len(psbtMagic) + 4 + 8 + 4 + len(p.Tx.ID)
+	copy(huge[inputCount:], []byte{0xff, 0xff, 0xff, 0x7f})
+	if _, err := DeserializePSBT(huge); !errors.Is(err, ErrInvalidPSBT) {
+		t.Errorf("huge count: error %v, want %v", err, ErrInvalidPSBT)
+	}
+
+	for _, bad := range []string{"", "70736274", "70736274ff00000000", "70736274ff03000000"} {
+		if _, err := DeserializePSBT(decodeHex(t, bad)); !errors.Is(err, ErrInvalidPSBT) {
+			t.Errorf("%q: error %v, want %v", bad, err, ErrInvalidPSBT)
+		}
+	}
+
+	if _, err := DecodePSBT("not base64!"); !errors.Is(err, ErrInvalidPSBT) {
+		t.Errorf("bad base64: error %v, want %v", err, ErrInvalidPSBT)
+	}
+}
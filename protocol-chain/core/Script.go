@@ -0,0 +1,673 @@
+package blockchain
+
+import (
+	"bytes"
+	"core-blockchain/wallet"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// IsScriptActive reports whether transactions at height may lock outputs
+// with a LockingScript and unlock inputs with an UnlockingScript, see
+// chaincfg.Params.ScriptHeight. From there on every input is checked by the
+// script engine, outputs locked by a bare PubKeyHash run the
+// pay-to-pubkey-hash template. Script signatures use the binary sighash, so
+// IntegerAmountHeight has to be reached as well.
+func IsScriptActive(height int64) bool {
+	return IsIntegerAmountActive(height) && height >= ActiveParams().ScriptHeight
+}
+
+// Opcodes of the script engine. Bytes 0x01 to 0x4b push that many bytes.
+const (
+	Op0                   byte = 0x00
+	OpPushData1           byte = 0x4c
+	OpPushData2           byte = 0x4d
+	Op1                   byte = 0x51
+	Op16                  byte = 0x60
+	OpVerify              byte = 0x69
+	OpReturn              byte = 0x6a
+	OpDrop                byte = 0x75
+	OpDup                 byte = 0x76
+	OpEqual               byte = 0x87
+	OpEqualVerify         byte = 0x88
+	OpSHA256              byte = 0xa8
+	OpHash160             byte = 0xa9
+	OpCheckSig            byte = 0xac
+	OpCheckSigVerify      byte = 0xad
+	OpCheckMultiSig       byte = 0xae
+	OpCheckMultiSigVerify byte = 0xaf
+)
+
+const (
+	MaxScriptSize        = 10_000
+	MaxScriptElementSize = 520
+	MaxStackSize         = 1000
+	MaxMultiSigKeys      = 16
+)
+
+var (
+	ErrScriptNotActive = errors.New("scripts are not active")
+	ErrInvalidScript   = errors.New("invalid script")
+	ErrScriptFailed    = errors.New("script failed")
+)
+
+// ScriptClass names the standard templates a locking script may follow.
+type ScriptClass int
+
+const (
+	ClassNonStandard ScriptClass = iota
+	ClassPubKeyHash
+	ClassScriptHash
+	ClassMultiSig
+)
+
+func (c ScriptClass) String() string {
+	switch c {
+	case ClassPubKeyHash:
+		return "pubkeyhash"
+	case ClassScriptHash:
+		return "scripthash"
+	case ClassMultiSig:
+		return "multisig"
+	default:
+		return "nonstandard"
+	}
+}
+
+// NewPayToPubKeyHashScript returns DUP HASH160 <hash> EQUALVERIFY CHECKSIG,
+// the script an output locked by a bare PubKeyHash runs.
+func NewPayToPubKeyHashScript(pubKeyHash []byte) []byte {
+	script := []byte{OpDup, OpHash160}
+	script = appendPush(script, pubKeyHash)
+
+	return append(script, OpEqualVerify, OpCheckSig)
+}
+
+// NewPayToScriptHashScript returns HASH160 <hash> EQUAL. The input spending
+// it pushes the redeem script hashing to scriptHash last, the redeem script
+// then runs on the rest of its pushes.
+func NewPayToScriptHashScript(scriptHash []byte) []byte {
+	script := []byte{OpHash160}
+	script = appendPush(script, scriptHash)
+
+	return append(script, OpEqual)
+}
+
+// NewMultiSigScript returns <m> <pubkey>... <n> CHECKMULTISIG, satisfied by
+// m signatures of the keys in the order of pubKeys.
+func NewMultiSigScript(m int, pubKeys [][]byte) ([]byte, error) {
+	if len(pubKeys) == 0 || len(pubKeys) > MaxMultiSigKeys {
+		return nil, fmt.Errorf("%w: %d keys, 1 to %d allowed", ErrInvalidScript, len(pubKeys), MaxMultiSigKeys)
+	}
+	if m < 1 || m > len(pubKeys) {
+		return nil, fmt.Errorf("%w: %d of %d signatures", ErrInvalidScript, m, len(pubKeys))
+	}
+
+	script := []byte{Op1 + byte(m-1)}
+	for _, pubKey := range pubKeys {
+		if !wallet.ValidatePublicKey(pubKey) {
+			return nil, fmt.Errorf("%w: public key %x", ErrInvalidScript, pubKey)
+		}
+		script = appendPush(script, pubKey)
+	}
+
+	return append(script, Op1+byte(len(pubKeys)-1), OpCheckMultiSig), nil
+}
+
+// NewPushScript returns a script pushing items in order, the form unlocking
+// scripts take.
+func NewPushScript(items ...[]byte) []byte {
+	var script []byte
+	for _, item := range items {
+		script = appendPush(script, item)
+	}
+
+	return script
+}
+
+func appendPush(script, data []byte) []byte {
+	switch {
+	case len(data) == 0:
+		return append(script, Op0)
+	case len(data) < int(OpPushData1):
+		script = append(script, byte(len(data)))
+	case len(data) <= 0xff:
+		script = append(script, OpPushData1, byte(len(data)))
+	default:
+		script = append(script, OpPushData2)
+		script = binary.LittleEndian.AppendUint16(script, uint16(len(data)))
+	}
+
+	return append(script, data...)
+}
+
+// ScriptHash is the HASH160 of script, what a pay-to-script-hash output
+// commits to.
+func ScriptHash(script []byte) []byte {
+	return wallet.PublicKeyHash(script)
+}
+
+// ClassifyScript returns the template script follows and the hash it locks
+// to, the public key or script hash.
+func ClassifyScript(script []byte) (ScriptClass, []byte) {
+	switch {
+	case len(script) == 25 && script[0] == OpDup && script[1] == OpHash160 && script[2] == 20 &&
+		script[23] == OpEqualVerify && script[24] == OpCheckSig:
+		return ClassPubKeyHash, script[3:23]
+	case len(script) == 23 && script[0] == OpHash160 && script[1] == 20 && script[22] == OpEqual:
+		return ClassScriptHash, script[2:22]
+	}
+
+	if _, _, err := parseMultiSig(script); err == nil {
+		return ClassMultiSig, nil
+	}
+
+	return ClassNonStandard, nil
+}
+
+// parseMultiSig returns the signatures required and the keys of a script
+// built by NewMultiSigScript.
+func parseMultiSig(script []byte) (int, [][]byte, error) {
+	ops, err := parseScript(script)
+	if err != nil {
+		return 0, nil, err
+	}
+	if len(ops) < 4 || ops[len(ops)-1].code != OpCheckMultiSig {
+		return 0, nil, ErrInvalidScript
+	}
+
+	m, n := smallInt(ops[0].code), smallInt(ops[len(ops)-2].code)
+	keys := ops[1 : len(ops)-2]
+	if m < 1 || n != len(keys) || m > n {
+		return 0, nil, ErrInvalidScript
+	}
+
+	pubKeys := make([][]byte, 0, n)
+	for _, op := range keys {
+		if !op.isPush() || !wallet.ValidatePublicKey(op.data) {
+			return 0, nil, ErrInvalidScript
+		}
+		pubKeys = append(pubKeys, op.data)
+	}
+
+	return m, pubKeys, nil
+}
+
+// smallInt returns the value of Op1 to Op16, -1 for any other opcode.
+func smallInt(code byte) int {
+	if code < Op1 || code > Op16 {
+		return -1
+	}
+
+	return int(code-Op1) + 1
+}
+
+type scriptOp struct {
+	code byte
+	data []byte
+}
+
+func (op scriptOp) isPush() bool {
+	return op.code <= OpPushData2 || smallInt(op.code) > 0
+}
+
+// parseScript splits script into its opcodes, failing on truncated pushes.
+func parseScript(script []byte) ([]scriptOp, error) {
+	if len(script) > MaxScriptSize {
+		return nil, fmt.Errorf("%w: %d bytes exceeds %d", ErrInvalidScript, len(script), MaxScriptSize)
+	}
+
+	var ops []scriptOp
+	for i := 0; i < len(script); {
+		code := script[i]
+		i++
+
+		size := 0
+		switch {
+		case code > Op0 && code < OpPushData1:
+			size = int(code)
+		case code == OpPushData1:
+			if i+1 > len(script) {
+				return nil, fmt.Errorf("%w: truncated push", ErrInvalidScript)
+			}
+			size = int(script[i])
+			i++
+		case code == OpPushData2:
+			if i+2 > len(script) {
+				return nil, fmt.Errorf("%w: truncated push", ErrInvalidScript)
+			}
+			size = int(binary.LittleEndian.Uint16(script[i:]))
+			i += 2
+		}
+
+		if i+size > len(script) {
+			return nil, fmt.Errorf("%w: truncated push", ErrInvalidScript)
+		}
+		if size > MaxScriptElementSize {
+			return nil, fmt.Errorf("%w: push of %d bytes exceeds %d", ErrInvalidScript, size, MaxScriptElementSize)
+		}
+
+		ops = append(ops, scriptOp{code: code, data: script[i : i+size]})
+		i += size
+	}
+
+	return ops, nil
+}
+
+func isPushOnly(script []byte) bool {
+	ops, err := parseScript(script)
+	if err != nil {
+		return false
+	}
+
+	for _, op := range ops {
+		if !op.isPush() {
+			return false
+		}
+	}
+
+	return true
+}
+
+// DisassembleScript renders script as opcode names and hex encoded pushes.
+func DisassembleScript(script []byte) string {
+	ops, err := parseScript(script)
+	if err != nil {
+		return fmt.Sprintf("[error] %x", script)
+	}
+
+	names := map[byte]string{
+		OpVerify: "OP_VERIFY", OpReturn: "OP_RETURN", OpDrop: "OP_DROP", OpDup: "OP_DUP",
+		OpEqual: "OP_EQUAL", OpEqualVerify: "OP_EQUALVERIFY", OpSHA256: "OP_SHA256",
+		OpHash160: "OP_HASH160", OpCheckSig: "OP_CHECKSIG", OpCheckSigVerify: "OP_CHECKSIGVERIFY",
+		OpCheckMultiSig: "OP_CHECKMULTISIG", OpCheckMultiSigVerify: "OP_CHECKMULTISIGVERIFY",
+	}
+
+	parts := make([]string, 0, len(ops))
+	for _, op := range ops {
+		switch {
+		case op.code == Op0:
+			parts = append(parts, "0")
+		case smallInt(op.code) > 0:
+			parts = append(parts, fmt.Sprint(smallInt(op.code)))
+		case op.isPush():
+			parts = append(parts, fmt.Sprintf("%x", op.data))
+		case names[op.code] != "":
+			parts = append(parts, names[op.code])
+		default:
+			parts = append(parts, fmt.Sprintf("OP_UNKNOWN_%x", op.code))
+		}
+	}
+
+	return strings.Join(parts, " ")
+}
+
+func castToBool(v []byte) bool {
+	for i, b := range v {
+		if b != 0 {
+			// Negative zero is false.
+			return i != len(v)-1 || b != 0x80
+		}
+	}
+
+	return false
+}
+
+// scriptVM runs scripts for input idx of tx on a shared stack.
+type scriptVM struct {
+	tx    *Transaction
+	idx   int
+	stack [][]byte
+}
+
+func (vm *scriptVM) push(v []byte) error {
+	if len(vm.stack) >= MaxStackSize {
+		return fmt.Errorf("%w: stack exceeds %d items", ErrScriptFailed, MaxStackSize)
+	}
+	vm.stack = append(vm.stack, v)
+
+	return nil
+}
+
+func (vm *scriptVM) pop() ([]byte, error) {
+	if len(vm.stack) == 0 {
+		return nil, fmt.Errorf("%w: stack is empty", ErrScriptFailed)
+	}
+	v := vm.stack[len(vm.stack)-1]
+	vm.stack = vm.stack[:len(vm.stack)-1]
+
+	return v, nil
+}
+
+// popN removes the n topmost items and returns them bottom first.
+func (vm *scriptVM) popN(n int) ([][]byte, error) {
+	if n < 0 || n > len(vm.stack) {
+		return nil, fmt.Errorf("%w: stack is too short", ErrScriptFailed)
+	}
+	items := append([][]byte(nil), vm.stack[len(vm.stack)-n:]...)
+	vm.stack = vm.stack[:len(vm.stack)-n]
+
+	return items, nil
+}
+
+func (vm *scriptVM) popInt() (int, error) {
+	v, err := vm.pop()
+	if err != nil {
+		return 0, err
+	}
+	if len(v) > 1 || (len(v) == 1 && v[0] > MaxMultiSigKeys) {
+		return 0, fmt.Errorf("%w: number out of range", ErrScriptFailed)
+	}
+	if len(v) == 0 {
+		return 0, nil
+	}
+
+	return int(v[0]), nil
+}
+
+func (vm *scriptVM) verify() error {
+	v, err := vm.pop()
+	if err != nil {
+		return err
+	}
+	if !castToBool(v) {
+		return fmt.Errorf("%w: verify", ErrScriptFailed)
+	}
+
+	return nil
+}
+
+// execute runs script on the stack of vm.
+func (vm *scriptVM) execute(script []byte) error {
+	ops, err := parseScript(script)
+	if err != nil {
+		return err
+	}
+
+	subScript := scriptCode(script)
+
+	for _, op := range ops {
+		if op.isPush() {
+			data := op.data
+			if n := smallInt(op.code); n > 0 {
+				data = []byte{byte(n)}
+			}
+			if err := vm.push(data); err != nil {
+				return err
+			}
+			continue
+		}
+
+		switch op.code {
+		case OpVerify:
+			err = vm.verify()
+		case OpReturn:
+			err = fmt.Errorf("%w: return", ErrScriptFailed)
+		case OpDrop:
+			_, err = vm.pop()
+		case OpDup:
+			var v []byte
+			if v, err = vm.pop(); err == nil {
+				if err = vm.push(v); err == nil {
+					err = vm.push(v)
+				}
+			}
+		case OpEqual, OpEqualVerify:
+			var items [][]byte
+			if items, err = vm.popN(2); err == nil {
+				err = vm.pushBool(bytes.Equal(items[0], items[1]))
+			}
+			if err == nil && op.code == OpEqualVerify {
+				err = vm.verify()
+			}
+		case OpSHA256:
+			var v []byte
+			if v, err = vm.pop(); err == nil {
+				hash := sha256.Sum256(v)
+				err = vm.push(hash[:])
+			}
+		case OpHash160:
+			var v []byte
+			if v, err = vm.pop(); err == nil {
+				err = vm.push(wallet.PublicKeyHash(v))
+			}
+		case OpCheckSig, OpCheckSigVerify:
+			var items [][]byte
+			if items, err = vm.popN(2); err == nil {
+				err = vm.pushBool(vm.checkSig(items[0], items[1], subScript))
+			}
+			if err == nil && op.code == OpCheckSigVerify {
+				err = vm.verify()
+			}
+		case OpCheckMultiSig, OpCheckMultiSigVerify:
+			err = vm.checkMultiSig(subScript)
+			if err == nil && op.code == OpCheckMultiSigVerify {
+				err = vm.verify()
+			}
+		default:
+			err = fmt.Errorf("%w: unknown opcode 0x%x", ErrInvalidScript, op.code)
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (vm *scriptVM) pushBool(v bool) error {
+	if v {
+		return vm.push([]byte{1})
+	}
+
+	return vm.push(nil)
+}
+
+// checkMultiSig pops <sig>... <m> <pubkey>... <n> and pushes whether the m
+// signatures match keys in the same order.
+func (vm *scriptVM) checkMultiSig(subScript []byte) error {
+	n, err := vm.popInt()
+	if err != nil {
+		return err
+	}
+	pubKeys, err := vm.popN(n)
+	if err != nil {
+		return err
+	}
+	m, err := vm.popInt()
+	if err != nil {
+		return err
+	}
+	if m > n {
+		return fmt.Errorf("%w: %d of %d signatures", ErrScriptFailed, m, n)
+	}
+	sigs, err := vm.popN(m)
+	if err != nil {
+		return err
+	}
+
+	k := 0
+	for _, sig := range sigs {
+		for k < len(pubKeys) && !vm.checkSig(sig, pubKeys[k], subScript) {
+			k++
+		}
+		if k == len(pubKeys) {
+			return vm.pushBool(false)
+		}
+		k++
+	}
+
+	return vm.pushBool(true)
+}
+
+// scriptCode is what a signature inside script commits to in place of the
+// previous PubKeyHash: the hash itself for the pay-to-pubkey-hash template,
+// which keeps those signatures what they were before scripts, the script
+// otherwise.
+func scriptCode(script []byte) []byte {
+	if class, hash := ClassifyScript(script); class == ClassPubKeyHash {
+		return hash
+	}
+
+	return script
+}
+
+func (vm *scriptVM) checkSig(sig, pubKey, subScript []byte) bool {
+	r, s, hashType, err := DecodeSignature(sig)
+	if err != nil || !wallet.ValidatePublicKey(pubKey) {
+		return false
+	}
+
+	digest, err := vm.tx.SigHash(vm.idx, subScript, hashType)
+	if err != nil {
+		return false
+	}
+
+	half := len(pubKey) / 2
+	rawPubKey := ecdsa.PublicKey{
+		Curve: elliptic.P256(),
+		X:     new(big.Int).SetBytes(pubKey[:half]),
+		Y:     new(big.Int).SetBytes(pubKey[half:]),
+	}
+
+	return ecdsa.Verify(&rawPubKey, digest, r, s)
+}
+
+// VerifyInputScript runs the unlocking script of input idx followed by the
+// locking script of prevOut, the output it spends. A pay-to-script-hash
+// output then runs the redeem script, the last push of the unlocking script,
+// on the pushes before it.
+func (tx *Transaction) VerifyInputScript(idx int, prevOut TxOutput) error {
+	if idx < 0 || idx >= len(tx.Inputs) {
+		return fmt.Errorf("input index %d out of range", idx)
+	}
+	in := tx.Inputs[idx]
+
+	if len(prevOut.LockingScript) > 0 && (len(in.Signature) > 0 || len(in.PubKey) > 0) {
+		return fmt.Errorf("%w: input spending a script carries a signature and key", ErrInvalidScript)
+	}
+	if len(prevOut.LockingScript) == 0 && len(in.UnlockingScript) > 0 {
+		return fmt.Errorf("%w: input spending a key hash carries an unlocking script", ErrInvalidScript)
+	}
+
+	unlock := in.Script()
+	if !isPushOnly(unlock) {
+		return fmt.Errorf("%w: unlocking script is not push only", ErrInvalidScript)
+	}
+
+	vm := &scriptVM{tx: tx, idx: idx}
+	if err := vm.execute(unlock); err != nil {
+		return err
+	}
+	pushes := append([][]byte(nil), vm.stack...)
+
+	lock := prevOut.Script()
+	if err := vm.execute(lock); err != nil {
+		return err
+	}
+	if err := vm.verify(); err != nil {
+		return err
+	}
+
+	if class, _ := ClassifyScript(lock); class != ClassScriptHash {
+		return nil
+	}
+
+	vm.stack = pushes
+	redeem, err := vm.pop()
+	if err != nil {
+		return err
+	}
+	if err := vm.execute(redeem); err != nil {
+		return err
+	}
+
+	return vm.verify()
+}
+
+// ScriptSignature signs input idx for script, the locking script it spends
+// or the redeem script of a pay-to-script-hash output, and returns the
+// signature to push in its unlocking script.
+func (tx *Transaction) ScriptSignature(idx int, privKey ecdsa.PrivateKey, script []byte, hashType SigHashType) ([]byte, error) {
+	digest, err := tx.SigHash(idx, scriptCode(script), hashType)
+	if err != nil {
+		return nil, err
+	}
+
+	r, s, err := ecdsa.Sign(rand.Reader, &privKey, digest)
+	if err != nil {
+		return nil, err
+	}
+
+	return EncodeSignature(r, s, hashType), nil
+}
+
+// HasScripts reports whether an input or output of tx carries a script,
+// which changes its encoding, see SerializeTransaction.
+func (tx *Transaction) HasScripts() bool {
+	for _, in := range tx.Inputs {
+		if len(in.UnlockingScript) > 0 {
+			return true
+		}
+	}
+	for _, out := range tx.Outputs {
+		if len(out.LockingScript) > 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
+// CheckScripts checks the scripts of tx for a block at height, without the
+// outputs it spends: none below ScriptHeight, then well formed scripts that
+// replace the PubKeyHash of an output or the Signature and PubKey of an
+// input. Outputs paying to a public key hash keep using PubKeyHash.
+func (tx *Transaction) CheckScripts(height int64) error {
+	if !IsScriptActive(height) {
+		if tx.HasScripts() {
+			return fmt.Errorf("%w below height %d", ErrScriptNotActive, ActiveParams().ScriptHeight)
+		}
+		return nil
+	}
+
+	for i, in := range tx.Inputs {
+		if len(in.UnlockingScript) == 0 {
+			continue
+		}
+		if tx.IsMinerTx() {
+			return fmt.Errorf("%w: miner input with unlocking script", ErrInvalidScript)
+		}
+		if len(in.Signature) > 0 || len(in.PubKey) > 0 {
+			return fmt.Errorf("%w: input %d has both an unlocking script and a signature", ErrInvalidScript, i)
+		}
+		if !isPushOnly(in.UnlockingScript) {
+			return fmt.Errorf("%w: unlocking script of input %d is not push only", ErrInvalidScript, i)
+		}
+	}
+
+	for i, out := range tx.Outputs {
+		if len(out.LockingScript) == 0 {
+			continue
+		}
+		if len(out.PubKeyHash) > 0 {
+			return fmt.Errorf("%w: output %d has both a locking script and a public key hash", ErrInvalidScript, i)
+		}
+		if _, err := parseScript(out.LockingScript); err != nil {
+			return fmt.Errorf("output %d: %w", i, err)
+		}
+		if class, _ := ClassifyScript(out.LockingScript); class == ClassPubKeyHash {
+			return fmt.Errorf("%w: output %d pays to a public key hash by script", ErrInvalidScript, i)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,217 @@
+package blockchain
+
+import (
+	"core-blockchain/chaincfg"
+	"core-blockchain/wallet"
+	"errors"
+	"testing"
+)
+
+func TestVerifyInputScript(t *testing.T) {
+	useTestParams(t, &chaincfg.RegTestParams)
+
+	alice, bob, carol := wallet.NewWallet(), wallet.NewWallet(), wallet.NewWallet()
+	alicePKH := wallet.PublicKeyHash(alice.PublicKey)
+
+	multiSig, err := NewMultiSigScript(2, [][]byte{alice.PublicKey, bob.PublicKey, carol.PublicKey})
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherMultiSig, err := NewMultiSigScript(2, [][]byte{alice.PublicKey, bob.PublicKey})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p2pkh := TxOutput{Value: 1000, PubKeyHash: alicePKH}
+	p2sh := TxOutput{Value: 1000, LockingScript: NewPayToScriptHashScript(ScriptHash(multiSig))}
+	bare := TxOutput{Value: 1000, LockingScript: multiSig}
+
+	// sign signs input 0 of tx with w for script, the locking script it
+	// spends or the redeem script.
+	sign := func(tx *Transaction, w *wallet.Wallet, script []byte) []byte {
+		t.Helper()
+
+		sig, err := tx.ScriptSignature(0, w.PrivateKey, script, SigHashAll)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return sig
+	}
+
+	tests := []struct {
+		name string
+		prev TxOutput
+		// unlock fills in input 0 of tx, which spends prev.
+		unlock  func(tx *Transaction)
+		wantErr error
+	}{
+		{
+			name: "pay to public key hash",
+			prev: p2pkh,
+			unlock: func(tx *Transaction) {
+				tx.Inputs[0].Signature = sign(tx, alice, p2pkh.Script())
+				tx.Inputs[0].PubKey = alice.PublicKey
+			},
+		},
+		{
+			name: "pay to public key hash signed like wallet transactions",
+			prev: p2pkh,
+			unlock: func(tx *Transaction) {
+				sig, err := tx.inputSignature(0, alice.PrivateKey, alicePKH, SigHashAll, psbtHeight)
+				if err != nil {
+					t.Fatal(err)
+				}
+				tx.Inputs[0].Signature = sig
+				tx.Inputs[0].PubKey = alice.PublicKey
+			},
+		},
+		{
+			name: "pay to public key hash with another key",
+			prev: p2pkh,
+			unlock: func(tx *Transaction) {
+				tx.Inputs[0].Signature = sign(tx, bob, p2pkh.Script())
+				tx.Inputs[0].PubKey = bob.PublicKey
+			},
+			wantErr: ErrScriptFailed,
+		},
+		{
+			name: "pay to public key hash signed by another key",
+			prev: p2pkh,
+			unlock: func(tx *Transaction) {
+				tx.Inputs[0].Signature = sign(tx, bob, p2pkh.Script())
+				tx.Inputs[0].PubKey = alice.PublicKey
+			},
+			wantErr: ErrScriptFailed,
+		},
+		{
+			name: "pay to public key hash with unlocking script",
+			prev: p2pkh,
+			unlock: func(tx *Transaction) {
+				tx.Inputs[0].UnlockingScript = NewPushScript(sign(tx, alice, p2pkh.Script()), alice.PublicKey)
+			},
+			wantErr: ErrInvalidScript,
+		},
+		{
+			name: "two of three multisig script hash",
+			prev: p2sh,
+			unlock: func(tx *Transaction) {
+				tx.Inputs[0].UnlockingScript = NewPushScript(sign(tx, alice, multiSig), sign(tx, carol, multiSig), multiSig)
+			},
+		},
+		{
+			name: "multisig signatures out of key order",
+			prev: p2sh,
+			unlock: func(tx *Transaction) {
+				tx.Inputs[0].UnlockingScript = NewPushScript(sign(tx, carol, multiSig), sign(tx, alice, multiSig), multiSig)
+			},
+			wantErr: ErrScriptFailed,
+		},
+		{
+			name: "multisig missing a signature",
+			prev: p2sh,
+			unlock: func(tx *Transaction) {
+				tx.Inputs[0].UnlockingScript = NewPushScript(sign(tx, bob, multiSig), multiSig)
+			},
+			wantErr: ErrScriptFailed,
+		},
+		{
+			name: "multisig signature by a key outside the script",
+			prev: p2sh,
+			unlock: func(tx *Transaction) {
+				tx.Inputs[0].UnlockingScript = NewPushScript(sign(tx, alice, multiSig), sign(tx, wallet.NewWallet(), multiSig), multiSig)
+			},
+			wantErr: ErrScriptFailed,
+		},
+		{
+			name: "redeem script of another hash",
+			prev: p2sh,
+			unlock: func(tx *Transaction) {
+				tx.Inputs[0].UnlockingScript = NewPushScript(sign(tx, alice, otherMultiSig), sign(tx, bob, otherMultiSig), otherMultiSig)
+			},
+			wantErr: ErrScriptFailed,
+		},
+		{
+			name: "script hash with signature and key",
+			prev: p2sh,
+			unlock: func(tx *Transaction) {
+				tx.Inputs[0].UnlockingScript = NewPushScript(sign(tx, alice, multiSig), sign(tx, carol, multiSig), multiSig)
+				tx.Inputs[0].PubKey = alice.PublicKey
+			},
+			wantErr: ErrInvalidScript,
+		},
+		{
+			name: "unlocking script that is not push only",
+			prev: p2sh,
+			unlock: func(tx *Transaction) {
+				tx.Inputs[0].UnlockingScript = append(NewPushScript(sign(tx, alice, multiSig), sign(tx, carol, multiSig), multiSig), OpDup, OpDrop)
+			},
+			wantErr: ErrInvalidScript,
+		},
+		{
+			name: "bare multisig",
+			prev: bare,
+			unlock: func(tx *Transaction) {
+				tx.Inputs[0].UnlockingScript = NewPushScript(sign(tx, bob, multiSig), sign(tx, carol, multiSig))
+			},
+		},
+		{
+			name: "bare multisig signed for another output",
+			prev: bare,
+			unlock: func(tx *Transaction) {
+				tx.Inputs[0].UnlockingScript = NewPushScript(sign(tx, alice, otherMultiSig), sign(tx, bob, otherMultiSig))
+			},
+			wantErr: ErrScriptFailed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tx := &Transaction{
+				Inputs:  []TxInput{{ID: testTxID("a"), Out: 0}},
+				Outputs: []TxOutput{{Value: 900, PubKeyHash: wallet.PublicKeyHash(carol.PublicKey)}},
+			}
+			tt.unlock(tx)
+
+			err := tx.VerifyInputScript(0, tt.prev)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("error %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestClassifyScript(t *testing.T) {
+	key := wallet.NewWallet().PublicKey
+	pubKeyHash := wallet.PublicKeyHash(key)
+
+	multiSig, err := NewMultiSigScript(1, [][]byte{key})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		script []byte
+		want   ScriptClass
+	}{
+		{"pay to public key hash", NewPayToPubKeyHashScript(pubKeyHash), ClassPubKeyHash},
+		{"pay to script hash", NewPayToScriptHashScript(ScriptHash(multiSig)), ClassScriptHash},
+		{"multisig", multiSig, ClassMultiSig},
+		{"truncated push", []byte{OpHash160, 20, 1, 2}, ClassNonStandard},
+		{"return", []byte{OpReturn}, ClassNonStandard},
+		{"empty", nil, ClassNonStandard},
+	}
+
+	for _, tt := range tests {
+		if got, _ := ClassifyScript(tt.script); got != tt.want {
+			t.Errorf("%s: class %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	if _, err := NewMultiSigScript(2, [][]byte{key}); !errors.Is(err, ErrInvalidScript) {
+		t.Errorf("2 of 1 multisig: error %v, want %v", err, ErrInvalidScript)
+	}
+	if _, err := NewMultiSigScript(1, [][]byte{key[:32]}); !errors.Is(err, ErrInvalidScript) {
+		t.Errorf("multisig of an invalid key: error %v, want %v", err, ErrInvalidScript)
+	}
+}
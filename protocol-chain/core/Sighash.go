@@ -0,0 +1,215 @@
+package blockchain
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// SigHashType selects which parts of a transaction an input signature commits
+// to. The binary sighash replaces the JSON based digest from
+// IntegerAmountHeight on; blocks below it keep verifying with the legacy one.
+type SigHashType uint32
+
+const (
+	// SigHashAll commits to every input and every output.
+	SigHashAll SigHashType = 0x01
+	// SigHashNone commits to the inputs only, outputs may change freely.
+	SigHashNone SigHashType = 0x02
+	// SigHashSingle commits to the output with the same index as the input.
+	SigHashSingle SigHashType = 0x03
+	// SigHashAnyoneCanPay may be combined with the types above so the
+	// signature only commits to its own input.
+	SigHashAnyoneCanPay SigHashType = 0x80
+
+	// SignatureLength is the size of r||s, both padded to 32 bytes.
+	SignatureLength = 64
+)
+
+var (
+	ErrInvalidSigHashType = errors.New("invalid sighash type")
+	ErrSigHashSingleIndex = errors.New("sighash single without matching output")
+	ErrInvalidSignature   = errors.New("invalid signature encoding")
+)
+
+func (t SigHashType) Base() SigHashType {
+	return t &^ SigHashAnyoneCanPay
+}
+
+func (t SigHashType) IsValid() bool {
+	base := t.Base()
+	return t <= 0xff && base >= SigHashAll && base <= SigHashSingle
+}
+
+// SigHashPreimage returns the bytes committed to by the signature of input idx:
+//
+//  1. Copy the transaction, clear its ID and every input's Signature, PubKey
+//     and UnlockingScript.
+//  2. Set the PubKey of input idx to prevPubKeyHash, the hash locking the output it spends,
+//     or the script a script signature commits to, see ScriptSignature.
+//  3. NONE drops every output. SINGLE keeps outputs 0..idx and blanks the ones
+//     before idx (Value -1, empty PubKeyHash).
+//  4. ANYONECANPAY keeps input idx only.
+//  5. Encode the copy with SerializeTransaction and append hashType as a
+//     little endian uint32.
+func (tx *Transaction) SigHashPreimage(idx int, prevPubKeyHash []byte, hashType SigHashType) ([]byte, error) {
+	if !hashType.IsValid() {
+		return nil, fmt.Errorf("%w: 0x%x", ErrInvalidSigHashType, uint32(hashType))
+	}
+
+	if idx < 0 || idx >= len(tx.Inputs) {
+		return nil, fmt.Errorf("input index %d out of range", idx)
+	}
+
+	txCopy := Transaction{}
+
+	for i, in := range tx.Inputs {
+		if hashType&SigHashAnyoneCanPay != 0 && i != idx {
+			continue
+		}
+
+		input := TxInput{ID: in.ID, Out: in.Out}
+		if i == idx {
+			input.PubKey = prevPubKeyHash
+		}
+
+		txCopy.Inputs = append(txCopy.Inputs, input)
+	}
+
+	switch hashType.Base() {
+	case SigHashAll:
+		txCopy.Outputs = append(txCopy.Outputs, tx.Outputs...)
+	case SigHashSingle:
+		if idx >= len(tx.Outputs) {
+			return nil, ErrSigHashSingleIndex
+		}
+
+		for i := 0; i < idx; i++ {
+			txCopy.Outputs = append(txCopy.Outputs, TxOutput{Value: -1})
+		}
+		txCopy.Outputs = append(txCopy.Outputs, tx.Outputs[idx])
+	}
+
+	buf := new(bytes.Buffer)
+	SerializeTransaction(&txCopy, buf)
+	binary.Write(buf, binary.LittleEndian, uint32(hashType))
+
+	return buf.Bytes(), nil
+}
+
+// SigHash is the DoubleSHA256 of SigHashPreimage, the digest passed to ECDSA.
+func (tx *Transaction) SigHash(idx int, prevPubKeyHash []byte, hashType SigHashType) ([]byte, error) {
+	preimage, err := tx.SigHashPreimage(idx, prevPubKeyHash, hashType)
+	if err != nil {
+		return nil, err
+	}
+
+	return DoubleSHA256(preimage), nil
+}
+
+// SignInput signs input idx with the binary sighash and stores the signature.
+func (tx *Transaction) SignInput(idx int, privKey ecdsa.PrivateKey, prevPubKeyHash []byte, hashType SigHashType) error {
+	digest, err := tx.SigHash(idx, prevPubKeyHash, hashType)
+	if err != nil {
+		return err
+	}
+
+	r, s, err := ecdsa.Sign(rand.Reader, &privKey, digest)
+	if err != nil {
+		return err
+	}
+
+	tx.Inputs[idx].Signature = EncodeSignature(r, s, hashType)
+
+	return nil
+}
+
+// EncodeSignature lays out r||s padded to 32 bytes each. Any type other than
+// SigHashAll is appended as a trailing byte.
+func EncodeSignature(r, s *big.Int, hashType SigHashType) []byte {
+	sig := make([]byte, SignatureLength)
+	r.FillBytes(sig[:SignatureLength/2])
+	s.FillBytes(sig[SignatureLength/2:])
+
+	if hashType != SigHashAll {
+		sig = append(sig, byte(hashType))
+	}
+
+	return sig
+}
+
+func DecodeSignature(sig []byte) (*big.Int, *big.Int, SigHashType, error) {
+	hashType := SigHashAll
+
+	switch len(sig) {
+	case SignatureLength:
+	case SignatureLength + 1:
+		hashType = SigHashType(sig[SignatureLength])
+	default:
+		return nil, nil, 0, ErrInvalidSignature
+	}
+
+	if !hashType.IsValid() {
+		return nil, nil, 0, ErrInvalidSigHashType
+	}
+
+	r := new(big.Int).SetBytes(sig[:SignatureLength/2])
+	s := new(big.Int).SetBytes(sig[SignatureLength/2 : SignatureLength])
+
+	return r, s, hashType, nil
+}
+
+// legacyTransaction mirrors the JSON layout signatures committed to before
+// IntegerAmountHeight, when output values were float64 coins.
+type legacyTransaction struct {
+	ID      []byte
+	Inputs  []legacyTxInput
+	Outputs []legacyTxOutput
+}
+
+type legacyTxInput struct {
+	ID        []byte
+	Out       int64
+	Signature []byte
+	PubKey    []byte
+}
+
+type legacyTxOutput struct {
+	Value      float64
+	PubKeyHash []byte
+}
+
+// legacySigHash reproduces the pre-activation digest: the JSON encoded
+// trimmed copy, hex formatted and hashed with DoubleSHA256.
+func (tx *Transaction) legacySigHash(idx int, prevPubKeyHash []byte) ([]byte, error) {
+	txCopy := tx.TrimmedCopy()
+	txCopy.Inputs[idx].PubKey = prevPubKeyHash
+
+	legacy := legacyTransaction{ID: txCopy.ID}
+	for _, in := range txCopy.Inputs {
+		legacy.Inputs = append(legacy.Inputs, legacyTxInput{
+			ID:        in.ID,
+			Out:       in.Out,
+			Signature: in.Signature,
+			PubKey:    in.PubKey,
+		})
+	}
+	for _, out := range txCopy.Outputs {
+		legacy.Outputs = append(legacy.Outputs, legacyTxOutput{
+			Value:      UnitsToLegacyFloat(out.Value),
+			PubKeyHash: out.PubKeyHash,
+		})
+	}
+
+	dataBytes, err := json.Marshal(legacy)
+	if err != nil {
+		return nil, err
+	}
+
+	return DoubleSHA256([]byte(fmt.Sprintf("%x", dataBytes))), nil
+}
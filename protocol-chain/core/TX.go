@@ -2,6 +2,7 @@ package blockchain
 
 import (
 	"bytes"
+	"core-blockchain/chaincfg"
 	"core-blockchain/common/env"
 	"core-blockchain/wallet"
 	"encoding/gob"
@@ -14,38 +15,188 @@ var (
 	// version        = byte(0x00)
 )
 
+// TxInput.UnlockingScript replaces Signature and PubKey when the output it
+// spends has a LockingScript, see VerifyInputScript.
 type TxInput struct {
-	ID        []byte
-	Out       int64
-	Signature []byte
-	PubKey    []byte
+	ID              []byte
+	Out             int64
+	Signature       []byte
+	PubKey          []byte
+	UnlockingScript []byte
 }
 
+// TxOutput.Value is expressed in base units (1 coin = PER_COIN units). An
+// output is locked either by PubKeyHash, paying to a public key hash, or by
+// LockingScript from ScriptHeight on.
 type TxOutput struct {
-	Value      float64
-	PubKeyHash []byte
+	Value         int64
+	PubKeyHash    []byte
+	LockingScript []byte
 }
 
+// TxOutputs are the unspent outputs of one transaction, Height the height of
+// the block holding it.
 type TxOutputs struct {
 	Outputs []TxOutput
+	Indexes []int64
+	Height  int64
 }
 
-func NewTxOutput(value float64, address string) *TxOutput {
-	txo := &TxOutput{value, nil}
+func NewTxOutput(value int64, address string) *TxOutput {
+	txo := &TxOutput{Value: value}
 	txo.Lock([]byte(address))
 
 	return txo
 }
 
+// Lock locks out to address, a pay-to-script-hash script for script
+// addresses.
 func (out *TxOutput) Lock(address []byte) {
 	pubKeyHash := wallet.Base58Decode(address)
 	pubKeyHash = pubKeyHash[1 : int64(len(pubKeyHash))-checkSumlength]
 
+	if wallet.IsScriptAddress(string(address)) {
+		out.PubKeyHash = nil
+		out.LockingScript = NewPayToScriptHashScript(pubKeyHash)
+		return
+	}
+
 	out.PubKeyHash = pubKeyHash
+	out.LockingScript = nil
 }
 
+// IsLockWithKey reports whether the key hashing to pubKeyHash alone unlocks
+// out, script outputs never are.
 func (out *TxOutput) IsLockWithKey(pubKeyHash []byte) bool {
-	return bytes.Equal(out.PubKeyHash, pubKeyHash)
+	return len(out.LockingScript) == 0 && bytes.Equal(out.PubKeyHash, pubKeyHash)
+}
+
+// Script returns the locking script of out, the pay-to-pubkey-hash template
+// for outputs locked by PubKeyHash.
+func (out *TxOutput) Script() []byte {
+	if len(out.LockingScript) > 0 {
+		return out.LockingScript
+	}
+
+	return NewPayToPubKeyHashScript(out.PubKeyHash)
+}
+
+// AddressHash returns the hash the address of out encodes, the public key
+// or the script hash. Outputs without an address return nil.
+func (out *TxOutput) AddressHash() []byte {
+	if len(out.LockingScript) == 0 {
+		return out.PubKeyHash
+	}
+	if class, hash := ClassifyScript(out.LockingScript); class == ClassScriptHash {
+		return hash
+	}
+
+	return nil
+}
+
+// Address returns the address out pays to, empty when it has none.
+func (out *TxOutput) Address(params *chaincfg.Params) string {
+	hash := out.AddressHash()
+	if hash == nil {
+		return ""
+	}
+	if len(out.LockingScript) > 0 {
+		return string(wallet.ScriptHashToAddr(hash, params))
+	}
+
+	return string(wallet.PubKeyHashToAddr(hash, params))
+}
+
+// Script returns the unlocking script of in, pushing Signature and PubKey
+// for inputs spending a public key hash.
+func (in *TxInput) Script() []byte {
+	if len(in.UnlockingScript) > 0 {
+		return in.UnlockingScript
+	}
+
+	return NewPushScript(in.Signature, in.PubKey)
+}
+
+// AddressHash returns the address hash of the output in spends as far as
+// in tells: the hash of PubKey, or of the redeem script an unlocking script
+// pushes last.
+func (in *TxInput) AddressHash() []byte {
+	if len(in.UnlockingScript) == 0 {
+		return wallet.PublicKeyHash(in.PubKey)
+	}
+
+	ops, err := parseScript(in.UnlockingScript)
+	if err != nil || len(ops) == 0 {
+		return nil
+	}
+
+	return ScriptHash(ops[len(ops)-1].data)
+}
+
+// IndexAt returns the output index (inside its transaction) of the i-th entry.
+// Sets written before indexes were stored fall back to the slice position.
+func (outs *TxOutputs) IndexAt(i int) int64 {
+	if len(outs.Indexes) != len(outs.Outputs) {
+		return int64(i)
+	}
+
+	return outs.Indexes[i]
+}
+
+func (outs *TxOutputs) Find(index int64) (TxOutput, bool) {
+	for i, out := range outs.Outputs {
+		if outs.IndexAt(i) == index {
+			return out, true
+		}
+	}
+
+	return TxOutput{}, false
+}
+
+// ensureIndexes fills Indexes from the slice positions of a legacy entry,
+// which stops holding once an output is added or removed.
+func (outs *TxOutputs) ensureIndexes() {
+	if len(outs.Indexes) != len(outs.Outputs) {
+		outs.Indexes = make([]int64, len(outs.Outputs))
+		for i := range outs.Outputs {
+			outs.Indexes[i] = int64(i)
+		}
+	}
+}
+
+func (outs *TxOutputs) Put(index int64, out TxOutput) {
+	outs.ensureIndexes()
+
+	pos := len(outs.Indexes)
+	for i, idx := range outs.Indexes {
+		if idx == index {
+			outs.Outputs[i] = out
+			return
+		}
+		if idx > index {
+			pos = i
+			break
+		}
+	}
+
+	outs.Indexes = append(outs.Indexes[:pos], append([]int64{index}, outs.Indexes[pos:]...)...)
+	outs.Outputs = append(outs.Outputs[:pos], append([]TxOutput{out}, outs.Outputs[pos:]...)...)
+}
+
+func (outs *TxOutputs) Remove(index int64) (TxOutput, bool) {
+	for i, out := range outs.Outputs {
+		if outs.IndexAt(i) != index {
+			continue
+		}
+
+		outs.ensureIndexes()
+		outs.Indexes = append(outs.Indexes[:i], outs.Indexes[i+1:]...)
+		outs.Outputs = append(outs.Outputs[:i], outs.Outputs[i+1:]...)
+
+		return out, true
+	}
+
+	return TxOutput{}, false
 }
 
 func (outs *TxOutputs) Serialize() ([]byte, error) {
@@ -2,6 +2,8 @@ package blockchain
 
 import (
 	"bytes"
+	"core-blockchain/chaincfg"
+	"core-blockchain/coinselect"
 	"core-blockchain/wallet"
 	"crypto/ecdsa"
 	"crypto/elliptic"
@@ -9,7 +11,6 @@ import (
 	"crypto/sha256"
 	"encoding/binary"
 	"encoding/hex"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"math/big"
@@ -24,9 +25,19 @@ type Transaction struct {
 	Outputs []TxOutput
 }
 
-func NewTransaction(w *wallet.Wallet, to string, amount, fee float64, utxo *UTXOSet, height int64) (*Transaction, error) {
-	if fee < float64(1/PER_COIN) {
-		return nil, fmt.Errorf("fee must be greater than or equal 1/%d (%f)", PER_COIN, float64(1/PER_COIN))
+// NewTransaction builds and signs a transfer from the wallet at from;
+// amount and fee are base units. strategy picks the outputs it spends, change
+// below coinselect.DustThreshold is added to the fee. Change goes back to from,
+// or to a fresh change address of an HD pool, in which case the pool has to
+// be saved afterwards.
+func NewTransaction(wallets *wallet.WalletPool, from, to string, amount, fee int64, utxo *UTXOSet, height int64, strategy coinselect.Strategy) (*Transaction, error) {
+	if fee < 1 {
+		return nil, fmt.Errorf("fee must be greater than or equal 1/%d", PER_COIN)
+	}
+
+	w, err := wallets.GetWallet(from)
+	if err != nil {
+		return nil, err
 	}
 
 	var inputs []TxInput
@@ -34,45 +45,42 @@ func NewTransaction(w *wallet.Wallet, to string, amount, fee float64, utxo *UTXO
 
 	publicKeyHash := wallet.PublicKeyHash(w.PublicKey)
 
-	acc, validOutputs, err := utxo.FindSpendableOutputs(publicKeyHash, amount+fee)
+	coins, err := utxo.SpendableCoins(publicKeyHash)
 	if err != nil {
 		return nil, err
 	}
 
-	amountCoin := NewCoinAmountFromFloat(amount)
-	feeCoin := NewCoinAmountFromFloat(fee)
-
-	if acc < amountCoin.Add(feeCoin).ToFloat() {
-		err := errors.New("you dont have enough amount")
+	selection, err := coinselect.Select(strategy, coins, amount, fee)
+	if err != nil {
 		return nil, err
 	}
 
-	from := string(w.Address())
-
-	for txId, outs := range validOutputs {
-		txID, err := hex.DecodeString(txId)
+	for _, coin := range selection.Coins {
+		txID, err := hex.DecodeString(coin.TxID)
 
 		if err != nil {
 			return nil, err
 		}
 
-		for _, out := range outs {
-			input := TxInput{txID, int64(out), nil, w.PublicKey}
-			inputs = append(inputs, input)
-		}
+		input := TxInput{ID: txID, Out: coin.Out, PubKey: w.PublicKey}
+		inputs = append(inputs, input)
 	}
 
 	outputs = append(outputs, *NewTxOutput(amount, to))
 
-	if acc >= amountCoin.Add(feeCoin).ToFloat() {
-		rest := NewCoinAmountFromFloat(acc)
-		rest = rest.Sub(amountCoin)
-		rest = rest.Sub(feeCoin)
-
-		outputs = append(outputs, *NewTxOutput(rest.ToFloat(), from))
+	if selection.Change > 0 {
+		change, err := wallets.ChangeAddress(from)
+		if err != nil {
+			return nil, err
+		}
+		outputs = append(outputs, *NewTxOutput(selection.Change, change))
 	}
 
 	tx := Transaction{nil, inputs, outputs}
+	if err := tx.CheckScripts(height); err != nil {
+		return nil, err
+	}
+
 	txIdhash, err := tx.Hash(height)
 
 	if err != nil {
@@ -81,7 +89,123 @@ func NewTransaction(w *wallet.Wallet, to string, amount, fee float64, utxo *UTXO
 
 	tx.ID = txIdhash
 
-	utxo.Blockchain.SignTransaction(w.PrivateKey, &tx)
+	utxo.Blockchain.SignTransaction(w.PrivateKey, &tx, height)
+
+	return &tx, nil
+}
+
+// Recipient is one output of a wallet transaction, Amount is in base units.
+// SubtractFee pays a share of the fee out of Amount.
+type Recipient struct {
+	Address     string
+	Amount      int64
+	SubtractFee bool
+}
+
+// NewWalletTransaction pays recipients from coins, unspent outputs of the
+// wallets in the pool, and signs every input with the key of its wallet.
+// strategy picks the coins; change below coinselect.DustThreshold is added
+// to the fee, the rest goes to a change address of the pool, see
+// WalletPool.ChangeAddress. The fee is split evenly between the recipients
+// with SubtractFee, the first of them paying the remainder.
+func NewWalletTransaction(wallets *wallet.WalletPool, coins []UnspentOutput, recipients []Recipient, fee int64, strategy coinselect.Strategy, height int64) (*Transaction, error) {
+	if fee < 1 {
+		return nil, fmt.Errorf("fee must be greater than or equal 1/%d", PER_COIN)
+	}
+	if len(recipients) == 0 {
+		return nil, errors.New("no recipients")
+	}
+
+	var total int64
+	subtractors := 0
+	for _, r := range recipients {
+		if r.Amount <= 0 {
+			return nil, fmt.Errorf("amount to %s must be positive", r.Address)
+		}
+		total += r.Amount
+		if r.SubtractFee {
+			subtractors++
+		}
+	}
+
+	target := total
+	if subtractors > 0 {
+		target -= fee
+	}
+
+	owned := make(map[string]UnspentOutput, len(coins))
+	candidates := make([]coinselect.Coin, 0, len(coins))
+	for _, coin := range coins {
+		txID := hex.EncodeToString(coin.TxID)
+		owned[fmt.Sprintf("%s:%d", txID, coin.Index)] = coin
+		candidates = append(candidates, coinselect.Coin{TxID: txID, Out: coin.Index, Value: coin.Output.Value})
+	}
+
+	selection, err := coinselect.Select(strategy, candidates, target, fee)
+	if err != nil {
+		return nil, err
+	}
+
+	owners := wallets.PubKeyHashes(false)
+	var inputs []TxInput
+	var signers []wallet.Wallet
+	var prevPubKeyHashes [][]byte
+
+	for _, c := range selection.Coins {
+		coin := owned[fmt.Sprintf("%s:%d", c.TxID, c.Out)]
+
+		w, err := wallets.GetWallet(owners[hex.EncodeToString(coin.Output.PubKeyHash)])
+		if err != nil {
+			return nil, err
+		}
+
+		inputs = append(inputs, TxInput{ID: coin.TxID, Out: coin.Index, PubKey: w.PublicKey})
+		signers = append(signers, w)
+		prevPubKeyHashes = append(prevPubKeyHashes, coin.Output.PubKeyHash)
+	}
+
+	var outputs []TxOutput
+	share, remainder := int64(0), int64(0)
+	if subtractors > 0 {
+		share, remainder = fee/int64(subtractors), fee%int64(subtractors)
+	}
+
+	for _, r := range recipients {
+		amount := r.Amount
+		if r.SubtractFee {
+			amount -= share + remainder
+			remainder = 0
+			if amount <= 0 {
+				return nil, fmt.Errorf("amount to %s is too small to pay the fee", r.Address)
+			}
+		}
+		outputs = append(outputs, *NewTxOutput(amount, r.Address))
+	}
+
+	if selection.Change > 0 {
+		from := owners[hex.EncodeToString(prevPubKeyHashes[0])]
+		change, err := wallets.ChangeAddress(from)
+		if err != nil {
+			return nil, err
+		}
+		outputs = append(outputs, *NewTxOutput(selection.Change, change))
+	}
+
+	tx := Transaction{nil, inputs, outputs}
+	if err := tx.CheckScripts(height); err != nil {
+		return nil, err
+	}
+
+	tx.ID, err = tx.Hash(height)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, w := range signers {
+		if err := tx.signInputAt(i, w.PrivateKey, prevPubKeyHashes[i], height); err != nil {
+			return nil, err
+		}
+	}
 
 	return &tx, nil
 }
@@ -93,7 +217,7 @@ func (tx *Transaction) Hash(height int64) ([]byte, error) {
 	txCopy := *tx
 	txCopy.ID = nil
 
-	SerializeTransaction(&txCopy, buf)
+	SerializeTransactionAt(&txCopy, buf, height)
 
 	binary.Write(buf, binary.LittleEndian, height)
 
@@ -106,7 +230,7 @@ func (tx *Transaction) IsMinerTx() bool {
 	return len(tx.Inputs) == 1 && len(tx.Inputs[0].ID) == 0 && tx.Inputs[0].Out == -1
 }
 
-func (tx *Transaction) Sign(privKey ecdsa.PrivateKey, prevTXs map[string]Transaction) error {
+func (tx *Transaction) Sign(privKey ecdsa.PrivateKey, prevTXs map[string]Transaction, height int64) error {
 	if tx.IsMinerTx() {
 		return nil
 	}
@@ -117,40 +241,68 @@ func (tx *Transaction) Sign(privKey ecdsa.PrivateKey, prevTXs map[string]Transac
 		}
 	}
 
-	txCopy := tx.TrimmedCopy()
-
-	for inId, in := range txCopy.Inputs {
+	for inId, in := range tx.Inputs {
 		prevTX := prevTXs[hex.EncodeToString(in.ID)]
+		prevPubKeyHash := prevTX.Outputs[in.Out].PubKeyHash
 
-		txCopy.Inputs[inId].Signature = nil
-		txCopy.Inputs[inId].PubKey = prevTX.Outputs[in.Out].PubKeyHash
-
-		dataBytes, err := json.Marshal(txCopy)
-		if err != nil {
+		if err := tx.signInputAt(inId, privKey, prevPubKeyHash, height); err != nil {
 			return err
 		}
+	}
 
-		dataToSign := fmt.Sprintf("%x", dataBytes)
+	return nil
+}
 
-		digest := DoubleSHA256([]byte(dataToSign))
+// signInputAt signs input idx the way blocks at height expect it.
+func (tx *Transaction) signInputAt(idx int, privKey ecdsa.PrivateKey, prevPubKeyHash []byte, height int64) error {
+	signature, err := tx.inputSignature(idx, privKey, prevPubKeyHash, SigHashAll, height)
+	if err != nil {
+		return err
+	}
 
-		r, s, err := ecdsa.Sign(rand.Reader, &privKey, digest)
+	tx.Inputs[idx].Signature = signature
+
+	return nil
+}
 
+// inputSignature returns the signature of input idx for a block at height.
+// Legacy signatures always commit to the whole transaction, so hashType has
+// to be SigHashAll below IntegerAmountHeight.
+func (tx *Transaction) inputSignature(idx int, privKey ecdsa.PrivateKey, prevPubKeyHash []byte, hashType SigHashType, height int64) ([]byte, error) {
+	if IsIntegerAmountActive(height) {
+		digest, err := tx.SigHash(idx, prevPubKeyHash, hashType)
 		if err != nil {
-			return err
+			return nil, err
 		}
-		signature := append(r.Bytes(), s.Bytes()...)
 
-		tx.Inputs[inId].Signature = signature
-		txCopy.Inputs[inId].PubKey = nil
+		r, s, err := ecdsa.Sign(rand.Reader, &privKey, digest)
+		if err != nil {
+			return nil, err
+		}
+
+		return EncodeSignature(r, s, hashType), nil
 	}
 
-	return nil
+	if hashType != SigHashAll {
+		return nil, fmt.Errorf("%w: only SigHashAll below height %d", ErrInvalidSigHashType, ActiveParams().IntegerAmountHeight)
+	}
+
+	digest, err := tx.legacySigHash(idx, prevPubKeyHash)
+	if err != nil {
+		return nil, err
+	}
+
+	r, s, err := ecdsa.Sign(rand.Reader, &privKey, digest)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(r.Bytes(), s.Bytes()...), nil
 }
 
 func (tx *Transaction) BalanceCheck(prevTXs map[string]Transaction) bool {
-	var totalInput float64 = 0
-	var totalOutput float64 = 0
+	var totalInput int64 = 0
+	var totalOutput int64 = 0
 
 	for _, in := range tx.Inputs {
 		txID := hex.EncodeToString(in.ID)
@@ -163,8 +315,9 @@ func (tx *Transaction) BalanceCheck(prevTXs map[string]Transaction) bool {
 			return false
 		}
 
-		pubKeyHash := wallet.PublicKeyHash(in.PubKey)
-		if !bytes.Equal(pubKeyHash, prevTx.Outputs[in.Out].PubKeyHash) {
+		// Script outputs are checked by VerifyInputScript.
+		prevOut := prevTx.Outputs[in.Out]
+		if len(prevOut.LockingScript) == 0 && !bytes.Equal(wallet.PublicKeyHash(in.PubKey), prevOut.PubKeyHash) {
 			return false
 		}
 
@@ -178,7 +331,12 @@ func (tx *Transaction) BalanceCheck(prevTXs map[string]Transaction) bool {
 	return totalInput >= totalOutput
 }
 
-func (tx *Transaction) Verify(prevTXs map[string]Transaction) bool {
+func (tx *Transaction) Verify(prevTXs map[string]Transaction, height int64) bool {
+	if err := tx.CheckScripts(height); err != nil {
+		log.Errorf("Invalid scripts in transaction %x: %v", tx.ID, err)
+		return false
+	}
+
 	if tx.IsMinerTx() {
 		return true
 	}
@@ -194,47 +352,72 @@ func (tx *Transaction) Verify(prevTXs map[string]Transaction) bool {
 		return false
 	}
 
-	txCopy := tx.TrimmedCopy()
-	curve := elliptic.P256()
-
 	for inId, in := range tx.Inputs {
-		prevTX := prevTXs[hex.EncodeToString(in.ID)]
+		prevOut := prevTXs[hex.EncodeToString(in.ID)].Outputs[in.Out]
+
+		if IsScriptActive(height) {
+			if err := tx.VerifyInputScript(inId, prevOut); err != nil {
+				log.Errorf("Script of input %d failed: %v", inId, err)
+				return false
+			}
+			continue
+		}
+
+		if !tx.verifyInputAt(inId, prevOut.PubKeyHash, height) {
+			return false
+		}
+	}
 
-		txCopy.Inputs[inId].Signature = nil
-		txCopy.Inputs[inId].PubKey = prevTX.Outputs[in.Out].PubKeyHash
+	return true
 
-		r := big.Int{}
-		s := big.Int{}
-		SigLen := len(in.Signature)
-		r.SetBytes(in.Signature[:(SigLen / 2)])
-		s.SetBytes(in.Signature[(SigLen / 2):])
+}
+
+// verifyInputAt checks the signature of input idx against its public key the
+// way blocks at height do.
+func (tx *Transaction) verifyInputAt(inId int, prevPubKeyHash []byte, height int64) bool {
+	in := tx.Inputs[inId]
+	curve := elliptic.P256()
+
+	x := big.Int{}
+	y := big.Int{}
+	keyLen := len(in.PubKey)
+	x.SetBytes(in.PubKey[:(keyLen / 2)])
+	y.SetBytes(in.PubKey[(keyLen / 2):])
 
-		x := big.Int{}
-		y := big.Int{}
-		keyLen := len(in.PubKey)
-		x.SetBytes(in.PubKey[:(keyLen / 2)])
-		y.SetBytes(in.PubKey[(keyLen / 2):])
+	var r, s *big.Int
+	var digest []byte
 
-		dataByte, err := json.Marshal(txCopy)
+	if IsIntegerAmountActive(height) {
+		var hashType SigHashType
+		var err error
+
+		r, s, hashType, err = DecodeSignature(in.Signature)
 		if err != nil {
-			log.Errorf("Failed to JSON marshal transaction: %v", err)
+			log.Errorf("Invalid signature for input %d: %v", inId, err)
 			return false
 		}
-		dataToVerify := fmt.Sprintf("%x", dataByte)
-
-		digest := DoubleSHA256([]byte(dataToVerify))
-
-		rawPubKey := ecdsa.PublicKey{Curve: curve, X: &x, Y: &y}
 
-		if !ecdsa.Verify(&rawPubKey, digest, &r, &s) {
+		digest, err = tx.SigHash(inId, prevPubKeyHash, hashType)
+		if err != nil {
+			log.Errorf("Failed to compute sighash for input %d: %v", inId, err)
 			return false
 		}
+	} else {
+		SigLen := len(in.Signature)
+		r = new(big.Int).SetBytes(in.Signature[:(SigLen / 2)])
+		s = new(big.Int).SetBytes(in.Signature[(SigLen / 2):])
 
-		txCopy.Inputs[inId].PubKey = nil
+		var err error
+		digest, err = tx.legacySigHash(inId, prevPubKeyHash)
+		if err != nil {
+			log.Errorf("Failed to JSON marshal transaction: %v", err)
+			return false
+		}
 	}
 
-	return true
+	rawPubKey := ecdsa.PublicKey{Curve: curve, X: &x, Y: &y}
 
+	return ecdsa.Verify(&rawPubKey, digest, r, s)
 }
 
 func (tx *Transaction) TrimmedCopy() Transaction {
@@ -252,8 +435,9 @@ func (tx *Transaction) TrimmedCopy() Transaction {
 
 	for _, out := range tx.Outputs {
 		outputs = append(outputs, TxOutput{
-			Value:      out.Value,
-			PubKeyHash: out.PubKeyHash,
+			Value:         out.Value,
+			PubKeyHash:    out.PubKeyHash,
+			LockingScript: out.LockingScript,
 		})
 	}
 
@@ -277,37 +461,48 @@ func (tx *Transaction) String() string {
 		lines = append(lines, fmt.Sprintf("		Out: %d", input.Out))
 		lines = append(lines, fmt.Sprintf(" 	 	Signature: %x", input.Signature))
 		lines = append(lines, fmt.Sprintf("		PubKey: %x", input.PubKey))
+		if len(input.UnlockingScript) > 0 {
+			lines = append(lines, fmt.Sprintf("		UnlockingScript: %s", DisassembleScript(input.UnlockingScript)))
+		}
 	}
 
 	for i, output := range tx.Outputs {
 		lines = append(lines, fmt.Sprintf(" Output: (%d): ", i))
-		lines = append(lines, fmt.Sprintf(" 	 	Value: %f", output.Value))
+		lines = append(lines, fmt.Sprintf(" 	 	Value: %s", NewCoinAmountFromUnits(output.Value)))
 		lines = append(lines, fmt.Sprintf("		PubkeyHash: %x", output.PubKeyHash))
+		if len(output.LockingScript) > 0 {
+			lines = append(lines, fmt.Sprintf("		LockingScript: %s", DisassembleScript(output.LockingScript)))
+		}
 	}
 
 	return strings.Join(lines, "\n")
 }
 
-func InitGenesisTx(height int64) (*Transaction, error) {
+func InitGenesisTx(params *chaincfg.Params, height int64) (*Transaction, error) {
 
-	pubkey, err := hex.DecodeString("5d5807642aea55229a534a596b0b98c76346abccf85c83d17e7e80cfc9eef4682c85ff581cc4ca8d26244e9d3d9ed0695241c76ac288bb3a9b3b7802da0db4b7")
+	pubkey, err := hex.DecodeString(params.GenesisPubKey)
 
 	if err != nil {
 		log.Panicf("Failed to decode pubKey: %v", err)
 	}
 
+	pubKeyHash, err := hex.DecodeString(params.GenesisPubKeyHash)
+	if err != nil {
+		log.Panicf("Failed to decode pubKeyHash: %v", err)
+	}
+
 	txIn := TxInput{
 		ID:        []byte{},
 		Out:       -1,
 		Signature: []byte{},
 		PubKey:    pubkey,
 	}
-	txOut := NewTxOutput(111_111_111.11111111, "1LacjauKAjDJA34hjS9xJ2uEez7pQYqh5N")
+	txOut := TxOutput{Value: params.GenesisReward, PubKeyHash: pubKeyHash}
 
 	tx := Transaction{
 		ID:      nil,
 		Inputs:  []TxInput{txIn},
-		Outputs: []TxOutput{*txOut},
+		Outputs: []TxOutput{txOut},
 	}
 
 	txIdHash, err := tx.Hash(height)
@@ -0,0 +1,228 @@
+package blockchain
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/dgraph-io/badger"
+	log "github.com/sirupsen/logrus"
+)
+
+var (
+	txIndexPrefix = []byte("txi-")
+)
+
+const (
+	// TxIndexKey marks that the txindex keyspace has been built. Blocks only
+	// write index entries once it is present, see Reindex.
+	TxIndexKey = "txindex"
+)
+
+// TxLocation points at a main chain transaction: the block that holds it and
+// its position inside Block.Transactions.
+type TxLocation struct {
+	BlockHash []byte
+	Position  uint32
+}
+
+type TxIndex struct {
+	Blockchain *Blockchain
+}
+
+func txIndexKey(txID []byte) []byte {
+	key := make([]byte, 0, len(txIndexPrefix)+len(txID))
+	key = append(key, txIndexPrefix...)
+	return append(key, txID...)
+}
+
+func txIndexEnabled(txn *badger.Txn) (bool, error) {
+	_, err := txn.Get([]byte(TxIndexKey))
+	if err != nil {
+		if errors.Is(err, badger.ErrKeyNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	return true, nil
+}
+
+func (ti *TxIndex) Enabled() bool {
+	enabled := false
+
+	err := ti.Blockchain.Database.View(func(txn *badger.Txn) error {
+		var err error
+		enabled, err = txIndexEnabled(txn)
+		return err
+	})
+	if err != nil {
+		log.Errorf("Read txindex state with error: %v", err)
+		return false
+	}
+
+	return enabled
+}
+
+// Lookup returns where txID sits on the main chain. The bool is false when
+// the transaction is not indexed.
+func (ti *TxIndex) Lookup(txID []byte) (*TxLocation, bool, error) {
+	var loc TxLocation
+	found := false
+
+	err := ti.Blockchain.Database.View(func(txn *badger.Txn) error {
+		item, err := txn.Get(txIndexKey(txID))
+		if err != nil {
+			if errors.Is(err, badger.ErrKeyNotFound) {
+				return nil
+			}
+			return err
+		}
+
+		v, err := item.ValueCopy(nil)
+		if err != nil {
+			return err
+		}
+
+		loc, err = GobDecode[TxLocation](v)
+		if err != nil {
+			return err
+		}
+		found = true
+
+		return nil
+	})
+	if err != nil {
+		return nil, false, err
+	}
+
+	if !found {
+		return nil, false, nil
+	}
+
+	return &loc, true, nil
+}
+
+// ConnectBlock indexes every transaction of bl. It is a no-op while the
+// index has not been built.
+func (ti *TxIndex) ConnectBlock(txn *badger.Txn, bl *Block) error {
+	enabled, err := txIndexEnabled(txn)
+	if err != nil || !enabled {
+		return err
+	}
+
+	return ti.putBlock(txn, bl, true)
+}
+
+// DisconnectBlock removes the entries bl wrote. Entries already pointing at
+// another block are left alone.
+func (ti *TxIndex) DisconnectBlock(txn *badger.Txn, bl *Block) error {
+	enabled, err := txIndexEnabled(txn)
+	if err != nil || !enabled {
+		return err
+	}
+
+	for _, tx := range bl.Transactions {
+		key := txIndexKey(tx.ID)
+
+		item, err := txn.Get(key)
+		if err != nil {
+			if errors.Is(err, badger.ErrKeyNotFound) {
+				continue
+			}
+			return err
+		}
+
+		v, err := item.ValueCopy(nil)
+		if err != nil {
+			return err
+		}
+
+		loc, err := GobDecode[TxLocation](v)
+		if err != nil {
+			return err
+		}
+
+		if !bytes.Equal(loc.BlockHash, bl.Hash) {
+			continue
+		}
+
+		if err := txn.Delete(key); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (ti *TxIndex) putBlock(txn *badger.Txn, bl *Block, overwrite bool) error {
+	for pos, tx := range bl.Transactions {
+		key := txIndexKey(tx.ID)
+
+		if !overwrite {
+			if _, err := txn.Get(key); err == nil {
+				continue
+			} else if !errors.Is(err, badger.ErrKeyNotFound) {
+				return err
+			}
+		}
+
+		data, err := GobEncode(TxLocation{BlockHash: bl.Hash, Position: uint32(pos)})
+		if err != nil {
+			return err
+		}
+
+		if err := txn.Set(key, data); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Reindex drops the txindex keyspace and rebuilds it from the main chain,
+// then marks it as built so new blocks keep it up to date.
+func (ti *TxIndex) Reindex() (int, error) {
+	err := ti.Blockchain.Database.Update(func(txn *badger.Txn) error {
+		return txn.Delete([]byte(TxIndexKey))
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	utxoSet := UTXOSet{Blockchain: ti.Blockchain}
+	utxoSet.DeteleByPrefix(txIndexPrefix)
+
+	iter, err := ti.Blockchain.Iterator()
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+
+	for {
+		block, err := iter.Next()
+		if err != nil {
+			return count, err
+		}
+
+		// Walking from the tip, the newest occurrence of a txid wins.
+		err = ti.Blockchain.Database.Update(func(txn *badger.Txn) error {
+			return ti.putBlock(txn, block, false)
+		})
+		if err != nil {
+			return count, fmt.Errorf("index block %x: %w", block.Hash, err)
+		}
+		count += len(block.Transactions)
+
+		if len(block.PrevHash) == 0 {
+			break
+		}
+	}
+
+	err = ti.Blockchain.Database.Update(func(txn *badger.Txn) error {
+		return txn.Set([]byte(TxIndexKey), []byte{1})
+	})
+
+	return count, err
+}
@@ -2,28 +2,91 @@ package blockchain
 
 import (
 	"bytes"
+	"core-blockchain/coinselect"
 	"encoding/hex"
+	"errors"
+	"fmt"
 
 	"github.com/dgraph-io/badger"
 )
 
 var (
 	utxoPrefix = []byte("UTXO-")
+	undoPrefix = []byte("undo-")
 	// prefixLength = len(utxoPrefix)
 )
 
+const (
+	UTXOVersionKey = "utxo-version"
+	utxoVersion    = "3"
+)
+
+var ErrUndoNotFound = errors.New("undo data not found")
+
 type UTXOSet struct {
 	Blockchain *Blockchain
 }
 
+// SpentOutput records an output consumed by a block so it can be restored
+// when the block is disconnected.
+type SpentOutput struct {
+	TxID   []byte
+	Index  int64
+	Output TxOutput
+	Height int64
+}
+
+type BlockUndo struct {
+	Spent []SpentOutput
+}
+
+func utxoKey(txID []byte) []byte {
+	key := make([]byte, 0, len(utxoPrefix)+len(txID))
+	key = append(key, utxoPrefix...)
+	return append(key, txID...)
+}
+
+func undoKey(blockHash []byte) []byte {
+	key := make([]byte, 0, len(undoPrefix)+len(blockHash))
+	key = append(key, undoPrefix...)
+	return append(key, blockHash...)
+}
+
+func getOutputs(txn *badger.Txn, key []byte) (*TxOutputs, error) {
+	item, err := txn.Get(key)
+	if err != nil {
+		return nil, err
+	}
+
+	v, err := item.ValueCopy(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return DeSerializeOuputs(v)
+}
+
+func putOutputs(txn *badger.Txn, key []byte, outs *TxOutputs) error {
+	if len(outs.Outputs) == 0 {
+		return txn.Delete(key)
+	}
+
+	serialize, err := outs.Serialize()
+	if err != nil {
+		return err
+	}
+
+	return txn.Set(key, serialize)
+}
+
 func (u *UTXOSet) FindUTXOPrefix(txID []byte) (*TxOutputs, []byte, error) {
 	var outs TxOutputs
 	var key []byte
 
 	err := u.Blockchain.Database.View(func(txn *badger.Txn) error {
-		k := append(utxoPrefix, txID...)
+		k := utxoKey(txID)
 
-		item, err := txn.Get([]byte(k))
+		item, err := txn.Get(k)
 		if err != nil {
 			return err
 		}
@@ -53,9 +116,9 @@ func (u *UTXOSet) FindUTXOPrefix(txID []byte) (*TxOutputs, []byte, error) {
 	return &outs, key, nil
 }
 
-func (u *UTXOSet) FindSpendableOutputs(publicKeyHash []byte, amount float64) (float64, map[string][]int, error) {
+func (u *UTXOSet) FindSpendableOutputs(publicKeyHash []byte, amount int64) (int64, map[string][]int, error) {
 	unspentOuts := make(map[string][]int)
-	accumulated := NewCoinAmountFromFloat(0.0)
+	var accumulated int64
 
 	err := u.Blockchain.Database.View(func(txn *badger.Txn) error {
 		opts := badger.DefaultIteratorOptions
@@ -82,13 +145,12 @@ func (u *UTXOSet) FindSpendableOutputs(publicKeyHash []byte, amount float64) (fl
 			key := item.Key()
 			txID := hex.EncodeToString(bytes.TrimPrefix(key, prefix))
 
-			for outIdx, out := range outs.Outputs {
-				if out.IsLockWithKey(publicKeyHash) && accumulated.ToFloat() < amount {
-					unspentOuts[txID] = append(unspentOuts[txID], outIdx)
-					value := NewCoinAmountFromFloat(out.Value)
-					accumulated = accumulated.Add(value)
+			for i, out := range outs.Outputs {
+				if out.IsLockWithKey(publicKeyHash) && accumulated < amount {
+					unspentOuts[txID] = append(unspentOuts[txID], int(outs.IndexAt(i)))
+					accumulated += out.Value
 
-					if accumulated.ToFloat() >= amount {
+					if accumulated >= amount {
 						break
 					}
 				}
@@ -102,9 +164,215 @@ func (u *UTXOSet) FindSpendableOutputs(publicKeyHash []byte, amount float64) (fl
 		return 0, nil, err
 	}
 
-	return accumulated.ToFloat(), unspentOuts, nil
+	return accumulated, unspentOuts, nil
+}
+
+// SpendableCoins lists every unspent output locked to publicKeyHash for
+// coin selection.
+func (u *UTXOSet) SpendableCoins(publicKeyHash []byte) ([]coinselect.Coin, error) {
+	var coins []coinselect.Coin
+
+	err := u.Blockchain.Database.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+
+		opts.PrefetchValues = true
+		it := txn.NewIterator(opts)
+
+		defer it.Close()
+
+		prefix := utxoPrefix
+
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			item := it.Item()
+
+			v, err := item.ValueCopy(nil)
+			if err != nil {
+				return err
+			}
+			outs, err := DeSerializeOuputs(v)
+			if err != nil {
+				return err
+			}
+
+			txID := hex.EncodeToString(bytes.TrimPrefix(item.Key(), prefix))
+
+			for i, out := range outs.Outputs {
+				if out.IsLockWithKey(publicKeyHash) {
+					coins = append(coins, coinselect.Coin{TxID: txID, Out: outs.IndexAt(i), Value: out.Value})
+				}
+			}
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return coins, nil
+}
+
+// UnspentOutput is output Index of transaction TxID, created at Height.
+type UnspentOutput struct {
+	TxID   []byte
+	Index  int64
+	Output TxOutput
+	Height int64
+}
+
+// Unspent lists the unspent outputs locked to every address hash match
+// accepts, see TxOutput.AddressHash.
+func (u *UTXOSet) Unspent(match func(pubKeyHash []byte) bool) ([]UnspentOutput, error) {
+	var unspent []UnspentOutput
+
+	err := u.Blockchain.Database.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+
+		opts.PrefetchValues = true
+		it := txn.NewIterator(opts)
+
+		defer it.Close()
+
+		for it.Seek(utxoPrefix); it.ValidForPrefix(utxoPrefix); it.Next() {
+			item := it.Item()
+
+			v, err := item.ValueCopy(nil)
+			if err != nil {
+				return err
+			}
+			outs, err := DeSerializeOuputs(v)
+			if err != nil {
+				return err
+			}
+
+			txID := bytes.TrimPrefix(item.KeyCopy(nil), utxoPrefix)
+
+			for i, out := range outs.Outputs {
+				if hash := out.AddressHash(); hash != nil && match(hash) {
+					unspent = append(unspent, UnspentOutput{
+						TxID:   txID,
+						Index:  outs.IndexAt(i),
+						Output: out,
+						Height: outs.Height,
+					})
+				}
+			}
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return unspent, nil
+}
+
+// SpentOutputs returns the outputs the block spent, in the order of its
+// inputs. Blocks connected before the UTXO set was last rebuilt have none
+// and fail with ErrUndoNotFound.
+func (u *UTXOSet) SpentOutputs(blockHash []byte) ([]SpentOutput, error) {
+	var spent []SpentOutput
+
+	err := u.Blockchain.Database.View(func(txn *badger.Txn) error {
+		undo, err := readUndo(txn, blockHash)
+		if err != nil {
+			return err
+		}
+		spent = undo.Spent
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return spent, nil
+}
+
+// PubKeyHashes returns the hex encoded address hashes, public key or script
+// hashes, that unspent outputs are locked to.
+func (u *UTXOSet) PubKeyHashes() (map[string]bool, error) {
+	hashes := make(map[string]bool)
+
+	err := u.Blockchain.Database.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+
+		opts.PrefetchValues = true
+		it := txn.NewIterator(opts)
+
+		defer it.Close()
+
+		for it.Seek(utxoPrefix); it.ValidForPrefix(utxoPrefix); it.Next() {
+			v, err := it.Item().ValueCopy(nil)
+			if err != nil {
+				return err
+			}
+			outs, err := DeSerializeOuputs(v)
+			if err != nil {
+				return err
+			}
+
+			for _, out := range outs.Outputs {
+				if hash := out.AddressHash(); hash != nil {
+					hashes[hex.EncodeToString(hash)] = true
+				}
+			}
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return hashes, nil
+}
+
+// Balances sums the unspent outputs of every address hash match accepts,
+// keyed by the hex encoded hash.
+func (u *UTXOSet) Balances(match func(pubKeyHash []byte) bool) (map[string]int64, error) {
+	balances := make(map[string]int64)
+
+	err := u.Blockchain.Database.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+
+		opts.PrefetchValues = true
+		it := txn.NewIterator(opts)
+
+		defer it.Close()
+
+		for it.Seek(utxoPrefix); it.ValidForPrefix(utxoPrefix); it.Next() {
+			v, err := it.Item().ValueCopy(nil)
+			if err != nil {
+				return err
+			}
+			outs, err := DeSerializeOuputs(v)
+			if err != nil {
+				return err
+			}
+
+			for _, out := range outs.Outputs {
+				if hash := out.AddressHash(); hash != nil && match(hash) {
+					balances[hex.EncodeToString(hash)] += out.Value
+				}
+			}
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return balances, nil
 }
 
+// FindUnSpentTransactions returns the unspent outputs paying to the address
+// hash pubKeyHash, see TxOutput.AddressHash.
 func (u *UTXOSet) FindUnSpentTransactions(pubKeyHash []byte) ([]TxOutput, error) {
 	var UTXOs []TxOutput
 
@@ -131,7 +399,7 @@ func (u *UTXOSet) FindUnSpentTransactions(pubKeyHash []byte) ([]TxOutput, error)
 			}
 
 			for _, out := range outs.Outputs {
-				if out.IsLockWithKey(pubKeyHash) {
+				if bytes.Equal(out.AddressHash(), pubKeyHash) {
 					UTXOs = append(UTXOs, out)
 				}
 			}
@@ -171,109 +439,181 @@ func (u *UTXOSet) CountTransactions() (int, error) {
 	return counter, nil
 }
 
+// FindOutput returns the unspent output referenced by txID and index.
+func (u *UTXOSet) FindOutput(txID []byte, index int64) (TxOutput, bool, error) {
+	outs, _, err := u.FindUTXOPrefix(txID)
+	if err != nil {
+		if errors.Is(err, badger.ErrKeyNotFound) {
+			return TxOutput{}, false, nil
+		}
+		return TxOutput{}, false, err
+	}
+
+	out, ok := outs.Find(index)
+	return out, ok, nil
+}
+
+// Update applies the outputs spent and created by bl to the UTXO set and
+// stores the undo data needed to revert it.
 func (u *UTXOSet) Update(bl *Block) error {
-	db := u.Blockchain.Database
+	return u.Blockchain.Database.Update(func(txn *badger.Txn) error {
+		return u.ConnectBlock(txn, bl)
+	})
+}
 
-	err := db.Update(func(txn *badger.Txn) error {
-		for _, tx := range bl.Transactions {
-			if tx.IsMinerTx() {
-				newOutputs := TxOutputs{
-					Outputs: tx.Outputs,
-				}
+// Rollback reverts the changes a previous Update made for bl.
+func (u *UTXOSet) Rollback(bl *Block) error {
+	return u.Blockchain.Database.Update(func(txn *badger.Txn) error {
+		return u.DisconnectBlock(txn, bl)
+	})
+}
+
+func (u *UTXOSet) ConnectBlock(txn *badger.Txn, bl *Block) error {
+	undo := BlockUndo{}
 
-				txID := append(utxoPrefix, tx.ID...)
-				serialize, err := newOutputs.Serialize()
+	for _, tx := range bl.Transactions {
+		if !tx.IsMinerTx() {
+			for _, in := range tx.Inputs {
+				key := utxoKey(in.ID)
+
+				outs, err := getOutputs(txn, key)
 				if err != nil {
-					return err
+					return fmt.Errorf("input %x:%d not found in UTXO set: %w", in.ID, in.Out, err)
 				}
 
-				err = txn.Set(txID, serialize)
+				out, ok := outs.Remove(in.Out)
+				if !ok {
+					return fmt.Errorf("input %x:%d already spent", in.ID, in.Out)
+				}
 
-				if err != nil {
+				undo.Spent = append(undo.Spent, SpentOutput{
+					TxID:   in.ID,
+					Index:  in.Out,
+					Output: out,
+					Height: outs.Height,
+				})
+
+				if err := putOutputs(txn, key, outs); err != nil {
 					return err
 				}
-			} else {
-				for _, in := range tx.Inputs {
-					updatedOutputs := TxOutputs{}
-					inID := append(utxoPrefix, tx.ID...)
-
-					item, err := txn.Get(inID)
-					if err != nil {
-						return err
-					}
+			}
+		}
 
-					v, err := item.ValueCopy(nil)
-					if err != nil {
-						return err
-					}
+		newOutputs := TxOutputs{Height: bl.Height}
+		for outIdx, out := range tx.Outputs {
+			newOutputs.Outputs = append(newOutputs.Outputs, out)
+			newOutputs.Indexes = append(newOutputs.Indexes, int64(outIdx))
+		}
 
-					outs, err := DeSerializeOuputs(v)
-					if err != nil {
-						return err
-					}
+		if err := putOutputs(txn, utxoKey(tx.ID), &newOutputs); err != nil {
+			return err
+		}
+	}
 
-					for outIdx, out := range outs.Outputs {
-						if int64(outIdx) != in.Out {
-							updatedOutputs.Outputs = append(updatedOutputs.Outputs, out)
-						}
-					}
+	data, err := GobEncode(undo)
+	if err != nil {
+		return err
+	}
 
-					if len(updatedOutputs.Outputs) == 0 {
-						if err := txn.Delete(inID); err != nil {
-							return err
-						}
-					} else {
-						serialize, err := updatedOutputs.Serialize()
-						if err != nil {
-							return err
-						}
-
-						if err := txn.Set(inID, serialize); err != nil {
-							return err
-						}
-					}
-				}
+	return txn.Set(undoKey(bl.Hash), data)
+}
 
-				newOutputs := TxOutputs{
-					Outputs: tx.Outputs,
-				}
+func (u *UTXOSet) DisconnectBlock(txn *badger.Txn, bl *Block) error {
+	item, err := txn.Get(undoKey(bl.Hash))
+	if err != nil {
+		if errors.Is(err, badger.ErrKeyNotFound) {
+			return fmt.Errorf("block %x: %w", bl.Hash, ErrUndoNotFound)
+		}
+		return err
+	}
 
-				txID := append(utxoPrefix, tx.ID...)
-				serialize, err := newOutputs.Serialize()
-				if err != nil {
-					return err
-				}
+	v, err := item.ValueCopy(nil)
+	if err != nil {
+		return err
+	}
 
-				err = txn.Set(txID, serialize)
+	undo, err := GobDecode[BlockUndo](v)
+	if err != nil {
+		return err
+	}
 
-				if err != nil {
-					return err
-				}
+	for i := len(undo.Spent) - 1; i >= 0; i-- {
+		spent := undo.Spent[i]
+		key := utxoKey(spent.TxID)
 
+		outs, err := getOutputs(txn, key)
+		if err != nil {
+			if !errors.Is(err, badger.ErrKeyNotFound) {
+				return err
 			}
+			outs = &TxOutputs{Height: spent.Height}
 		}
 
-		return nil
+		outs.Put(spent.Index, spent.Output)
+
+		if err := putOutputs(txn, key, outs); err != nil {
+			return err
+		}
+	}
+
+	// Outputs created by the block go last so that ones spent and restored
+	// within the same block are dropped as well.
+	for _, tx := range bl.Transactions {
+		if err := txn.Delete(utxoKey(tx.ID)); err != nil {
+			return err
+		}
+	}
+
+	return txn.Delete(undoKey(bl.Hash))
+}
+
+// EnsureIndexed rebuilds the UTXO set when it was written by an older
+// layout that did not keep output indexes or heights.
+func (u *UTXOSet) EnsureIndexed() error {
+	var current []byte
+
+	err := u.Blockchain.Database.View(func(txn *badger.Txn) error {
+		item, err := txn.Get([]byte(UTXOVersionKey))
+		if err != nil {
+			if errors.Is(err, badger.ErrKeyNotFound) {
+				return nil
+			}
+			return err
+		}
+
+		current, err = item.ValueCopy(nil)
+		return err
 	})
+	if err != nil {
+		return err
+	}
 
-	return err
+	if string(current) == utxoVersion {
+		return nil
+	}
+
+	return u.Compute()
 }
 
 func (u *UTXOSet) Compute() error {
 	db := u.Blockchain.Database
 
 	u.DeteleByPrefix(utxoPrefix)
+	u.DeteleByPrefix(undoPrefix)
 
-	UTXO, _ := u.Blockchain.FindUTXO()
+	UTXO, err := u.Blockchain.FindUTXO()
+	if err != nil {
+		return err
+	}
 
-	err := db.Update(func(txn *badger.Txn) error {
+	err = db.Update(func(txn *badger.Txn) error {
 		for txId, outs := range UTXO {
 			key, err := hex.DecodeString(txId)
 			if err != nil {
 				return err
 			}
 
-			key = append(utxoPrefix, key...)
+			key = utxoKey(key)
 			serialize, err := outs.Serialize()
 			if err != nil {
 				return err
@@ -286,7 +626,7 @@ func (u *UTXOSet) Compute() error {
 			}
 		}
 
-		return nil
+		return txn.Set([]byte(UTXOVersionKey), []byte(utxoVersion))
 	})
 
 	return err
@@ -0,0 +1,232 @@
+package blockchain
+
+import (
+	"bytes"
+	"core-blockchain/chaincfg"
+	"crypto/sha256"
+	"fmt"
+	"maps"
+	"testing"
+
+	"github.com/dgraph-io/badger"
+)
+
+// newTestChain opens an empty database in a temporary directory. Nothing
+// is written to it, tests lay out the state they need.
+func newTestChain(t *testing.T) *Blockchain {
+	t.Helper()
+
+	db, err := badger.Open(badger.DefaultOptions(t.TempDir()).WithLogger(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	return &Blockchain{Database: db, Params: &chaincfg.RegTestParams}
+}
+
+func testTxID(name string) []byte {
+	hash := sha256.Sum256([]byte(name))
+	return hash[:]
+}
+
+func outpoint(name string, index int64) string {
+	return fmt.Sprintf("%x:%d", testTxID(name), index)
+}
+
+// testTx spends inputs, given as tx name and output index, into outputs of
+// the given values paying pubKeyHash.
+func testTx(name string, inputs []string, pubKeyHash []byte, values ...int64) *Transaction {
+	tx := &Transaction{ID: testTxID(name)}
+
+	for _, in := range inputs {
+		var prev string
+		var out int64
+		fmt.Sscanf(in, "%s %d", &prev, &out)
+		tx.Inputs = append(tx.Inputs, TxInput{ID: testTxID(prev), Out: out, PubKey: []byte(prev)})
+	}
+
+	for _, value := range values {
+		tx.Outputs = append(tx.Outputs, TxOutput{Value: value, PubKeyHash: pubKeyHash})
+	}
+
+	return tx
+}
+
+type utxoEntry struct {
+	Value  int64
+	Height int64
+}
+
+// utxoEntries lists the UTXO set by outpoint.
+func utxoEntries(t *testing.T, bc *Blockchain) map[string]utxoEntry {
+	t.Helper()
+
+	entries := make(map[string]utxoEntry)
+
+	err := bc.Database.View(func(txn *badger.Txn) error {
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+
+		for it.Seek(utxoPrefix); it.ValidForPrefix(utxoPrefix); it.Next() {
+			v, err := it.Item().ValueCopy(nil)
+			if err != nil {
+				return err
+			}
+
+			outs, err := DeSerializeOuputs(v)
+			if err != nil {
+				return err
+			}
+
+			txID := bytes.TrimPrefix(it.Item().KeyCopy(nil), utxoPrefix)
+			for i, out := range outs.Outputs {
+				entries[fmt.Sprintf("%x:%d", txID, outs.IndexAt(i))] = utxoEntry{Value: out.Value, Height: outs.Height}
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return entries
+}
+
+func TestUTXOConnectDisconnect(t *testing.T) {
+	pubKeyHash := bytes.Repeat([]byte{1}, 20)
+
+	tests := []struct {
+		name string
+		// base is stored at height 1 before the block is connected,
+		// legacy entries without Indexes.
+		base   []*Transaction
+		legacy bool
+		block  []*Transaction
+		want   map[string]utxoEntry
+	}{
+		{
+			name:  "spend confirmed output",
+			base:  []*Transaction{testTx("a", nil, pubKeyHash, 100, 200)},
+			block: []*Transaction{testTx("b", []string{"a 0"}, pubKeyHash, 90)},
+			want: map[string]utxoEntry{
+				outpoint("a", 1): {200, 1},
+				outpoint("b", 0): {90, 2},
+			},
+		},
+		{
+			name: "spend output created in the same block",
+			base: []*Transaction{testTx("a", nil, pubKeyHash, 100)},
+			block: []*Transaction{
+				testTx("b", []string{"a 0"}, pubKeyHash, 50, 40),
+				testTx("c", []string{"b 1"}, pubKeyHash, 30),
+			},
+			want: map[string]utxoEntry{
+				outpoint("b", 0): {50, 2},
+				outpoint("c", 0): {30, 2},
+			},
+		},
+		{
+			name: "spend every output created in the same block",
+			base: []*Transaction{testTx("a", nil, pubKeyHash, 100)},
+			block: []*Transaction{
+				testTx("b", []string{"a 0"}, pubKeyHash, 90),
+				testTx("c", []string{"b 0"}, pubKeyHash, 80),
+			},
+			want: map[string]utxoEntry{
+				outpoint("c", 0): {80, 2},
+			},
+		},
+		{
+			name:   "spend from legacy entry",
+			base:   []*Transaction{testTx("a", nil, pubKeyHash, 10, 20, 30)},
+			legacy: true,
+			block:  []*Transaction{testTx("b", []string{"a 0", "a 2"}, pubKeyHash, 35)},
+			want: map[string]utxoEntry{
+				outpoint("a", 1): {20, 1},
+				outpoint("b", 0): {35, 2},
+			},
+		},
+		{
+			name: "coinbase",
+			block: []*Transaction{
+				{ID: testTxID("coinbase"), Inputs: []TxInput{{Out: -1}}, Outputs: []TxOutput{{Value: 50, PubKeyHash: pubKeyHash}}},
+			},
+			want: map[string]utxoEntry{
+				outpoint("coinbase", 0): {50, 2},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bc := newTestChain(t)
+			utxoSet := UTXOSet{Blockchain: bc}
+
+			err := bc.Database.Update(func(txn *badger.Txn) error {
+				for _, tx := range tt.base {
+					outs := &TxOutputs{Outputs: tx.Outputs, Height: 1}
+					for i := range tx.Outputs {
+						if !tt.legacy {
+							outs.Indexes = append(outs.Indexes, int64(i))
+						}
+					}
+					if err := putOutputs(txn, utxoKey(tx.ID), outs); err != nil {
+						return err
+					}
+				}
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			before := utxoEntries(t, bc)
+			block := &Block{Hash: testTxID(tt.name), Height: 2, Transactions: tt.block}
+
+			if err := utxoSet.Update(block); err != nil {
+				t.Fatalf("connect: %v", err)
+			}
+			if got := utxoEntries(t, bc); !maps.Equal(got, tt.want) {
+				t.Errorf("after connect %v, want %v", got, tt.want)
+			}
+
+			if err := utxoSet.Rollback(block); err != nil {
+				t.Fatalf("disconnect: %v", err)
+			}
+			if got := utxoEntries(t, bc); !maps.Equal(got, before) {
+				t.Errorf("after disconnect %v, want %v", got, before)
+			}
+
+			if err := utxoSet.Rollback(block); err == nil {
+				t.Error("second disconnect succeeded without undo data")
+			}
+		})
+	}
+}
+
+func TestUTXOConnectDoubleSpend(t *testing.T) {
+	pubKeyHash := bytes.Repeat([]byte{1}, 20)
+
+	bc := newTestChain(t)
+	utxoSet := UTXOSet{Blockchain: bc}
+
+	base := &Block{Hash: testTxID("base"), Height: 1, Transactions: []*Transaction{testTx("a", nil, pubKeyHash, 100)}}
+	if err := utxoSet.Update(base); err != nil {
+		t.Fatal(err)
+	}
+
+	block := &Block{Hash: testTxID("double"), Height: 2, Transactions: []*Transaction{
+		testTx("b", []string{"a 0"}, pubKeyHash, 90),
+		testTx("c", []string{"a 0"}, pubKeyHash, 80),
+	}}
+	if err := utxoSet.Update(block); err == nil {
+		t.Fatal("block spending an output twice connected")
+	}
+
+	want := map[string]utxoEntry{outpoint("a", 0): {100, 1}}
+	if got := utxoEntries(t, bc); !maps.Equal(got, want) {
+		t.Errorf("failed connect left %v, want %v", got, want)
+	}
+}
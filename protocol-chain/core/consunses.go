@@ -90,14 +90,22 @@ func (bc *Blockchain) Reorganize(newBlock *Block, callback func([]*Transaction))
 		return errors.New("⚠️ Reorg failed — no common ancestor or fork too deep")
 	}
 
-	var memoryPool []*Transaction
-	UTXOs, err := bc.FindUTXO()
-	if err != nil {
-		return err
+	inNewChain := make(map[string]bool)
+	for _, tx := range newBlock.Transactions {
+		inNewChain[hex.EncodeToString(tx.ID)] = true
+	}
+	for _, nb := range newChain {
+		if nb.Height <= commonAncestorHeight {
+			continue
+		}
+		for _, tx := range nb.Transactions {
+			inNewChain[hex.EncodeToString(tx.ID)] = true
+		}
 	}
 
+	var memoryPool []*Transaction
 	for _, oldBlock := range oldChain {
-		if oldBlock.Height < commonAncestorHeight {
+		if oldBlock.Height <= commonAncestorHeight {
 			continue
 		}
 		for _, tx := range oldBlock.Transactions {
@@ -105,61 +113,115 @@ func (bc *Blockchain) Reorganize(newBlock *Block, callback func([]*Transaction))
 				continue
 			}
 			txID := hex.EncodeToString(tx.ID)
-			if _, exists := UTXOs[txID]; !exists {
+			if !inNewChain[txID] {
 				memoryPool = append(memoryPool, tx)
 				log.Debugf("↩️ Rollback tx %s to mempool", txID[:8])
 			}
 		}
 	}
 
-	for _, nb := range newChain {
-		if nb.Height < commonAncestorHeight {
-			continue
+	utxoSet := UTXOSet{Blockchain: bc}
+	txIndex := TxIndex{Blockchain: bc}
+	addrIndex := AddrIndex{Blockchain: bc}
+	headerIndex := HeaderIndex{Blockchain: bc}
+
+	switchChain := func(txn *badger.Txn, withUTXO bool) error {
+		// Old chain is ordered tip first, so blocks are disconnected newest to oldest.
+		for _, oldBlock := range oldChain {
+			if oldBlock.Height <= commonAncestorHeight {
+				continue
+			}
+			if withUTXO {
+				if err := addrIndex.DisconnectBlock(txn, oldBlock); err != nil {
+					return err
+				}
+				if err := utxoSet.DisconnectBlock(txn, oldBlock); err != nil {
+					return err
+				}
+				log.Debugf("⏪ Disconnected block %x height=%d", oldBlock.Hash[:6], oldBlock.Height)
+			}
+			if err := txIndex.DisconnectBlock(txn, oldBlock); err != nil {
+				return err
+			}
+			if oldBlock.Height > newBlock.Height {
+				keyCheckpoint := fmt.Sprintf("%s%d", CheckpointPrefix, oldBlock.Height)
+				if err := txn.Delete([]byte(keyCheckpoint)); err != nil {
+					return err
+				}
+			}
 		}
 
-		for ix, memoTx := range memoryPool {
-			for _, tx := range nb.Transactions {
-				if bytes.Equal(tx.ID, memoTx.ID) {
-					memoryPool = append(memoryPool[:ix], memoryPool[ix+1:]...)
-					log.Debugf("🧹 Removed tx %x from rollback list (already in new chain)", tx.ID[:6])
-				}
+		connect := make([]*Block, 0, len(newChain)+1)
+		for i := len(newChain) - 1; i >= 0; i-- {
+			if newChain[i].Height > commonAncestorHeight {
+				connect = append(connect, newChain[i])
 			}
 		}
+		connect = append(connect, newBlock)
 
-		err := bc.Database.Update(func(txn *badger.Txn) error {
+		for _, nb := range connect {
+			if withUTXO {
+				if err := utxoSet.ConnectBlock(txn, nb); err != nil {
+					return fmt.Errorf("connect block %x: %w", nb.Hash[:6], err)
+				}
+				if err := addrIndex.ConnectBlock(txn, nb); err != nil {
+					return err
+				}
+				log.Debugf("⏩ Connected block %x height=%d", nb.Hash[:6], nb.Height)
+			}
+			if err := txIndex.ConnectBlock(txn, nb); err != nil {
+				return err
+			}
 			keyCheckpoint := fmt.Sprintf("%s%d", CheckpointPrefix, nb.Height)
-			return txn.Set([]byte(keyCheckpoint), nb.Hash)
-		})
-		if err != nil {
-			return err
+			if err := txn.Set([]byte(keyCheckpoint), nb.Hash); err != nil {
+				return err
+			}
 		}
-	}
 
-	if len(memoryPool) > 0 {
-		callback(memoryPool)
-		log.Warnf("⚠️ Reorg rollback %d tx(s) from old chain", len(memoryPool))
-	}
-
-	err = bc.Database.Update(func(txn *badger.Txn) error {
 		log.Infof("🏁 Switching to new best chain — tip=%x height=%d", newBlock.Hash[:6], newBlock.Height)
-		if err := txn.Set([]byte(BestHeightPrefix), newBlock.Hash); err != nil {
+		if err := txn.Set(newBlock.Hash, SerializeBlock(newBlock)); err != nil {
 			return err
 		}
-		bc.LastHash = newBlock.Hash
 
-		keyCheckpoint := fmt.Sprintf("%s%d", CheckpointPrefix, newBlock.Height)
-		if err := txn.Set([]byte(keyCheckpoint), newBlock.Hash); err != nil {
+		if err := headerIndex.PutBlock(txn, newBlock); err != nil {
 			return err
 		}
 
-		return txn.Set(newBlock.Hash, SerializeBlock(newBlock))
+		return txn.Set([]byte(BestHeightPrefix), newBlock.Hash)
+	}
+
+	err = bc.Database.Update(func(txn *badger.Txn) error {
+		return switchChain(txn, true)
 	})
 
-	if err == nil {
-		log.Infof("✅ Reorg completed — new tip=%x height=%d", newBlock.Hash[:6], newBlock.Height)
+	if errors.Is(err, ErrUndoNotFound) {
+		log.Warnf("⚠️ Reorg: %v — rebuilding UTXO set from the new chain", err)
+		err = bc.Database.Update(func(txn *badger.Txn) error {
+			return switchChain(txn, false)
+		})
+		if err == nil {
+			bc.LastHash = newBlock.Hash
+			err = utxoSet.Compute()
+		}
+		if err == nil && addrIndex.Enabled() {
+			_, err = addrIndex.Reindex()
+		}
+	}
+
+	if err != nil {
+		return err
+	}
+
+	bc.LastHash = newBlock.Hash
+
+	if len(memoryPool) > 0 {
+		callback(memoryPool)
+		log.Warnf("⚠️ Reorg rollback %d tx(s) from old chain", len(memoryPool))
 	}
 
-	return err
+	log.Infof("✅ Reorg completed — new tip=%x height=%d", newBlock.Hash[:6], newBlock.Height)
+
+	return nil
 }
 
 func (bc *Blockchain) CalcWork(nBits uint32) *big.Int {
@@ -198,23 +260,44 @@ func (bc *Blockchain) AddBlock(block *Block, callback func([]*Transaction)) erro
 
 	if newChainWork.Cmp(currentTip.NChainWork) > 0 {
 		if bytes.Equal(currentTip.Hash, block.PrevHash) {
+			utxoSet := UTXOSet{Blockchain: bc}
+			txIndex := TxIndex{Blockchain: bc}
+			addrIndex := AddrIndex{Blockchain: bc}
+			headerIndex := HeaderIndex{Blockchain: bc}
 			err := bc.Database.Update(func(txn *badger.Txn) error {
+				log.Info("🔢 Updating UTXO set...")
+				if err := utxoSet.ConnectBlock(txn, block); err != nil {
+					return fmt.Errorf("UTXO update failed: %w", err)
+				}
+
+				if err := txIndex.ConnectBlock(txn, block); err != nil {
+					return fmt.Errorf("txindex update failed: %w", err)
+				}
+
+				if err := addrIndex.ConnectBlock(txn, block); err != nil {
+					return fmt.Errorf("addrindex update failed: %w", err)
+				}
+
 				if err := txn.Set([]byte(BestHeightPrefix), block.Hash); err != nil {
 					return err
 				}
-				bc.LastHash = block.Hash
 
 				keyCheckpoint := fmt.Sprintf("%s%d", CheckpointPrefix, block.Height)
 				if err := txn.Set([]byte(keyCheckpoint), block.Hash); err != nil {
 					return err
 				}
 
+				if err := headerIndex.PutBlock(txn, block); err != nil {
+					return err
+				}
+
 				return txn.Set(block.Hash, SerializeBlock(block))
 			})
 
 			if err != nil {
 				return fmt.Errorf("❌ Add block failed: %x %w", block.Hash[:6], err)
 			}
+			bc.LastHash = block.Hash
 
 		} else {
 			log.Infof("🔄 Reorg needed — block=%x", block.Hash[:6])
@@ -225,7 +308,12 @@ func (bc *Blockchain) AddBlock(block *Block, callback func([]*Transaction)) erro
 		}
 	} else {
 		log.Warnf("🧩 Lower chain work — saving disconnected block %x", block.Hash[:6])
+		headerIndex := HeaderIndex{Blockchain: bc}
 		err := bc.Database.Update(func(txn *badger.Txn) error {
+			if err := headerIndex.PutBlock(txn, block); err != nil {
+				return err
+			}
+
 			return txn.Set(block.Hash, SerializeBlock(block))
 		})
 		if err != nil {
@@ -234,12 +322,6 @@ func (bc *Blockchain) AddBlock(block *Block, callback func([]*Transaction)) erro
 		log.Infof("💾 Disconnected block %x saved", block.Hash[:6])
 	}
 
-	log.Info("🔢 Updating UTXO set...")
-	utxoSet := UTXOSet{Blockchain: bc}
-	if err := utxoSet.Compute(); err != nil {
-		return fmt.Errorf("❌ UTXO update failed: %v", err)
-	}
-
 	log.Infof("✅ Block %x added successfully", block.Hash[:6])
 	return nil
 }
@@ -3,11 +3,20 @@ package blockchain
 import (
 	"fmt"
 	"math/big"
+	"strconv"
 	"strings"
 )
 
 const CoinPrecision = PER_COIN
 
+// IsIntegerAmountActive reports whether transactions at height encode output
+// values as integer base units instead of float64 coins, see
+// chaincfg.Params.IntegerAmountHeight. Blocks below it keep the legacy
+// encoding so existing chains still validate.
+func IsIntegerAmountActive(height int64) bool {
+	return height >= ActiveParams().IntegerAmountHeight
+}
+
 type CoinAmount struct {
 	value *big.Int
 }
@@ -23,7 +32,17 @@ func NewCoinAmountFromFloat(v float64) *CoinAmount {
 	return &CoinAmount{value: i}
 }
 
+func NewCoinAmountFromUnits(v int64) *CoinAmount {
+	return &CoinAmount{value: big.NewInt(v)}
+}
+
+// NewCoinAmountFromString parses a decimal coin amount such as "12.5" exactly.
+// Digits beyond the coin precision are truncated.
 func NewCoinAmountFromString(s string) (*CoinAmount, error) {
+	if !strings.ContainsAny(s, "eE") {
+		return parseDecimalAmount(s)
+	}
+
 	f, ok := new(big.Float).SetString(s)
 	if !ok {
 		return nil, fmt.Errorf("invalid numeric string: %s", s)
@@ -79,8 +98,15 @@ func (c *CoinAmount) ToFloat() float64 {
 }
 
 func (c *CoinAmount) String() string {
-	f := new(big.Float).Quo(new(big.Float).SetInt(c.value), big.NewFloat(float64(CoinPrecision)))
-	str := f.Text('f', 8)
+	abs := new(big.Int).Abs(c.value)
+	whole, frac := new(big.Int).QuoRem(abs, big.NewInt(CoinPrecision), new(big.Int))
+
+	sign := ""
+	if c.value.Sign() < 0 {
+		sign = "-"
+	}
+
+	str := fmt.Sprintf("%s%s.%08d", sign, whole.String(), frac.Int64())
 	return strings.TrimRight(strings.TrimRight(str, "0"), ".")
 }
 
@@ -88,6 +114,59 @@ func (c *CoinAmount) Raw() *big.Int {
 	return new(big.Int).Set(c.value)
 }
 
+func (c *CoinAmount) Units() int64 {
+	return c.value.Int64()
+}
+
+func parseDecimalAmount(s string) (*CoinAmount, error) {
+	str := strings.TrimSpace(s)
+
+	negative := strings.HasPrefix(str, "-")
+	str = strings.TrimLeft(str, "+-")
+
+	whole, frac, _ := strings.Cut(str, ".")
+	if whole == "" && frac == "" {
+		return nil, fmt.Errorf("invalid numeric string: %s", s)
+	}
+
+	if len(frac) > 8 {
+		frac = frac[:8]
+	}
+	frac += strings.Repeat("0", 8-len(frac))
+
+	value, ok := new(big.Int).SetString(whole+frac, 10)
+	if !ok {
+		return nil, fmt.Errorf("invalid numeric string: %s", s)
+	}
+
+	if negative {
+		value.Neg(value)
+	}
+
+	return &CoinAmount{value: value}, nil
+}
+
+// LegacyFloatToUnits converts a pre-activation float64 output value into base
+// units. The value is rounded to the coin precision first, so converting it
+// back with UnitsToLegacyFloat yields the very same float64.
+func LegacyFloatToUnits(v float64) int64 {
+	amount, err := parseDecimalAmount(strconv.FormatFloat(v, 'f', 8, 64))
+	if err != nil {
+		return 0
+	}
+
+	return amount.Units()
+}
+
+func UnitsToLegacyFloat(units int64) float64 {
+	v, err := strconv.ParseFloat(NewCoinAmountFromUnits(units).String(), 64)
+	if err != nil {
+		return 0
+	}
+
+	return v
+}
+
 func SumFees(totalInput, totalOutput *CoinAmount) *CoinAmount {
 	return totalInput.Sub(totalOutput)
 }
@@ -0,0 +1,94 @@
+package blockchain
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"testing"
+)
+
+// sighashVectors is the layout of testdata/sighash_vectors.json, shared with
+// the server which has to produce the same digests.
+type sighashVectors struct {
+	Inputs []struct {
+		ID  string `json:"id"`
+		Out int64  `json:"out"`
+	} `json:"inputs"`
+	Outputs []struct {
+		Value      int64  `json:"value"`
+		PubKeyHash string `json:"pubKeyHash"`
+	} `json:"outputs"`
+	PrevPubKeyHashes []string `json:"prevPubKeyHashes"`
+	Vectors          []struct {
+		Input    int    `json:"input"`
+		HashType uint32 `json:"hashType"`
+		Preimage string `json:"preimage"`
+		SigHash  string `json:"sighash"`
+		Error    string `json:"error"`
+	} `json:"vectors"`
+}
+
+func decodeHex(t *testing.T, s string) []byte {
+	t.Helper()
+
+	data, err := hex.DecodeString(s)
+	if err != nil {
+		t.Fatalf("decode %q: %v", s, err)
+	}
+
+	return data
+}
+
+func TestSigHashVectors(t *testing.T) {
+	data, err := os.ReadFile("testdata/sighash_vectors.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var vectors sighashVectors
+	if err := json.Unmarshal(data, &vectors); err != nil {
+		t.Fatal(err)
+	}
+	if len(vectors.Vectors) == 0 {
+		t.Fatal("no vectors")
+	}
+
+	tx := &Transaction{}
+	for _, in := range vectors.Inputs {
+		tx.Inputs = append(tx.Inputs, TxInput{ID: decodeHex(t, in.ID), Out: in.Out})
+	}
+	for _, out := range vectors.Outputs {
+		tx.Outputs = append(tx.Outputs, TxOutput{Value: out.Value, PubKeyHash: decodeHex(t, out.PubKeyHash)})
+	}
+
+	for _, v := range vectors.Vectors {
+		prevPubKeyHash := decodeHex(t, vectors.PrevPubKeyHashes[v.Input])
+		hashType := SigHashType(v.HashType)
+
+		preimage, err := tx.SigHashPreimage(v.Input, prevPubKeyHash, hashType)
+		if v.Error != "" {
+			if err == nil || err.Error() != v.Error {
+				t.Errorf("input %d type 0x%x: error %v, want %q", v.Input, v.HashType, err, v.Error)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("input %d type 0x%x: %v", v.Input, v.HashType, err)
+			continue
+		}
+
+		if !bytes.Equal(preimage, decodeHex(t, v.Preimage)) {
+			t.Errorf("input %d type 0x%x: preimage %x, want %s", v.Input, v.HashType, preimage, v.Preimage)
+		}
+
+		sigHash, err := tx.SigHash(v.Input, prevPubKeyHash, hashType)
+		if err != nil {
+			t.Errorf("input %d type 0x%x: %v", v.Input, v.HashType, err)
+			continue
+		}
+		if !bytes.Equal(sigHash, decodeHex(t, v.SigHash)) {
+			t.Errorf("input %d type 0x%x: sighash %x, want %s", v.Input, v.HashType, sigHash, v.SigHash)
+		}
+	}
+}
@@ -6,8 +6,8 @@ if [ "$1" != "" ]; then
   exec ./app "$@" --InstanceId "$INSTANCE_ID"
 else
   echo "🔧 Initializing blockchain instance..."
-  ./app init --Address "$WALLET_ADDRESS" --InstanceId "$INSTANCE_ID"
+  ./app init --Address "$WALLET_ADDRESS" --InstanceId "$INSTANCE_ID" --Network "${NETWORK:-mainnet}"
 
   echo "🚀 Starting blockchain node..."
-  exec ./app startNode --Port "$PORT" --InstanceId "$INSTANCE_ID" $START_NODE_FLAGS
+  exec ./app startNode --Port "$PORT" --InstanceId "$INSTANCE_ID" --Network "${NETWORK:-mainnet}" $START_NODE_FLAGS
 fi
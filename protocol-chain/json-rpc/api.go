@@ -29,6 +29,36 @@ func (api *API) registerHandlers() {
 		"API.GetCommonBlock":        api.HandleGetCommonBlock,
 		"API.GetBlockByHeight":      api.HandleGetBlockByHeight,
 		"API.GetBlockByHeightRange": api.HandleGetBlocksByHeightRange,
+		"API.GetAddressHistory":     api.HandleGetAddressHistory,
+		"API.GetAddressUTXOs":       api.HandleGetAddressUTXOs,
+		"API.ListBanned":            api.HandleListBanned,
+		"API.SetBan":                api.HandleSetBan,
+		"API.ClearBanned":           api.HandleClearBanned,
+		"API.GenerateToAddress":     api.HandleGenerateToAddress,
+		"API.GenerateBlock":         api.HandleGenerateBlock,
+		"API.SaveMempool":           api.HandleSaveMempool,
+		"API.LoadMempool":           api.HandleLoadMempool,
+		"API.EstimateFee":           api.HandleEstimateFee,
+		"API.WalletUnlock":          api.HandleWalletUnlock,
+		"API.WalletLock":            api.HandleWalletLock,
+		"API.ImportAddress":         api.HandleImportAddress,
+		"API.ImportPubKey":          api.HandleImportPubKey,
+		"API.CreateMultiSig":        api.HandleCreateMultiSig,
+		"API.GetWalletBalance":      api.HandleGetWalletBalance,
+		"API.GetNewAddress":         api.HandleGetNewAddress,
+		"API.ListAddresses":         api.HandleListAddresses,
+		"API.ListUnspent":           api.HandleListUnspent,
+		"API.SendToAddress":         api.HandleSendToAddress,
+		"API.SendMany":              api.HandleSendMany,
+		"API.ListTransactions":      api.HandleListTransactions,
+		"API.GetTransaction":        api.HandleGetTransaction,
+		"API.CreatePSBT":            api.HandleCreatePSBT,
+		"API.DecodePSBT":            api.HandleDecodePSBT,
+		"API.UpdatePSBT":            api.HandleUpdatePSBT,
+		"API.SignPSBT":              api.HandleSignPSBT,
+		"API.CombinePSBT":           api.HandleCombinePSBT,
+		"API.FinalizePSBT":          api.HandleFinalizePSBT,
+		"API.SendPSBT":              api.HandleSendPSBT,
 	}
 }
 
@@ -0,0 +1,49 @@
+package jsonrpc
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Call sends one request to the JSON-RPC HTTP server at addr (host:port)
+// and decodes the result into result.
+func Call(addr, method string, params any, result any) error {
+	rawParams, e := json.Marshal(params)
+	if e != nil {
+		return e
+	}
+
+	body, e := json.Marshal(JSONRPCRequest{
+		JSONRPC: "2.0",
+		Method:  method,
+		Params:  rawParams,
+		ID:      time.Now().Unix(),
+	})
+	if e != nil {
+		return e
+	}
+
+	resp, e := http.Post(fmt.Sprintf("http://%s/__jsonrpc", addr), "application/json", bytes.NewReader(body))
+	if e != nil {
+		return e
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("rpc call failed: status %d", resp.StatusCode)
+	}
+
+	var res JSONRPCResponse
+	if e := json.NewDecoder(resp.Body).Decode(&res); e != nil {
+		return e
+	}
+
+	if res.Error != nil {
+		return fmt.Errorf("%s (code %d)", res.Error.Message, res.Error.Code)
+	}
+
+	return json.Unmarshal(res.Result, result)
+}
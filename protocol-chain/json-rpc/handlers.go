@@ -3,8 +3,12 @@ package jsonrpc
 import (
 	"core-blockchain/cmd/utils"
 	"core-blockchain/common/err"
+	blockchain "core-blockchain/core"
 	"core-blockchain/json-rpc/types"
+	"core-blockchain/wallet"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 
 	log "github.com/sirupsen/logrus"
 )
@@ -19,12 +23,15 @@ var handlers = map[string]HandleFunc{}
 
 func (api *API) HandleCreateWallet(params json.RawMessage) (any, *err.RPCError) {
 
-	wallet, e := api.cmd.CreateWallet()
+	address, e := api.cmd.CreateWallet("")
+	if errors.Is(e, wallet.ErrWalletLocked) {
+		return nil, err.ErrWalletLocked()
+	}
 	if e != nil {
 		return nil, err.ErrInternal("Internal Error")
 	}
 
-	return wallet, nil
+	return address, nil
 }
 
 func (api *API) HandleGetBalance(params json.RawMessage) (any, *err.RPCError) {
@@ -38,6 +45,28 @@ func (api *API) HandleGetBalance(params json.RawMessage) (any, *err.RPCError) {
 	return result, nil
 }
 
+func (api *API) HandleGetAddressHistory(params json.RawMessage) (any, *err.RPCError) {
+	var args []types.AddressPageAPIArgs
+	if e := json.Unmarshal(params, &args); e != nil || len(args) != 1 {
+		return nil, err.ErrInvalidArgument("Invalid parameters")
+	}
+
+	result := api.cmd.GetAddressHistory(args[0].Address, args[0].Offset, args[0].Limit)
+
+	return result, nil
+}
+
+func (api *API) HandleGetAddressUTXOs(params json.RawMessage) (any, *err.RPCError) {
+	var args []types.AddressPageAPIArgs
+	if e := json.Unmarshal(params, &args); e != nil || len(args) != 1 {
+		return nil, err.ErrInvalidArgument("Invalid parameters")
+	}
+
+	result := api.cmd.GetAddressUTXOs(args[0].Address, args[0].Offset, args[0].Limit)
+
+	return result, nil
+}
+
 func (api *API) HandleGetBlockchain(params json.RawMessage) (any, *err.RPCError) {
 
 	var args []types.GetBlockchainAPIArgs
@@ -147,3 +176,246 @@ func (api *API) GetBlockByHash(params json.RawMessage) (any, *err.RPCError) {
 func (api *API) GetAllUTXOs(params json.RawMessage) (any, *err.RPCError) {
 	return api.cmd.GetAllUTXOs(), nil
 }
+
+func (api *API) HandleListBanned(params json.RawMessage) (any, *err.RPCError) {
+	return api.cmd.ListBanned(), nil
+}
+
+func (api *API) HandleSetBan(params json.RawMessage) (any, *err.RPCError) {
+	var args []types.SetBanAPIArgs
+	if e := json.Unmarshal(params, &args); e != nil || len(args) != 1 {
+		return nil, err.ErrInvalidArgument("Invalid parameters")
+	}
+
+	return api.cmd.SetBan(args[0].PeerID, args[0].Command, args[0].BanTime, args[0].Reason), nil
+}
+
+func (api *API) HandleClearBanned(params json.RawMessage) (any, *err.RPCError) {
+	return api.cmd.ClearBanned(), nil
+}
+
+func (api *API) HandleGenerateToAddress(params json.RawMessage) (any, *err.RPCError) {
+	var args []types.GenerateToAddressAPIArgs
+	if e := json.Unmarshal(params, &args); e != nil || len(args) != 1 {
+		return nil, err.ErrInvalidArgument("Invalid parameters")
+	}
+
+	return api.cmd.GenerateToAddress(args[0].NBlocks, args[0].Address), nil
+}
+
+func (api *API) HandleGenerateBlock(params json.RawMessage) (any, *err.RPCError) {
+	var args []types.GenerateBlockAPIArgs
+	if e := json.Unmarshal(params, &args); e != nil || len(args) != 1 {
+		return nil, err.ErrInvalidArgument("Invalid parameters")
+	}
+
+	return api.cmd.GenerateBlock(args[0].TxIDs, args[0].Address), nil
+}
+
+func (api *API) HandleSaveMempool(params json.RawMessage) (any, *err.RPCError) {
+	return api.cmd.SaveMempool(), nil
+}
+
+func (api *API) HandleLoadMempool(params json.RawMessage) (any, *err.RPCError) {
+	return api.cmd.LoadMempool(), nil
+}
+
+func (api *API) HandleWalletUnlock(params json.RawMessage) (any, *err.RPCError) {
+	var args []types.WalletUnlockAPIArgs
+	if e := json.Unmarshal(params, &args); e != nil || len(args) != 1 {
+		return nil, err.ErrInvalidArgument("Invalid parameters")
+	}
+
+	return api.cmd.WalletUnlock(args[0].Passphrase, args[0].Timeout), nil
+}
+
+func (api *API) HandleWalletLock(params json.RawMessage) (any, *err.RPCError) {
+	return api.cmd.WalletLock(), nil
+}
+
+func (api *API) HandleImportAddress(params json.RawMessage) (any, *err.RPCError) {
+	var args []types.WalletAPIArgs
+	if e := json.Unmarshal(params, &args); e != nil || len(args) != 1 {
+		return nil, err.ErrInvalidArgument("Invalid parameters")
+	}
+
+	return api.cmd.ImportAddress(args[0].Address), nil
+}
+
+func (api *API) HandleImportPubKey(params json.RawMessage) (any, *err.RPCError) {
+	var args []types.ImportPubKeyAPIArgs
+	if e := json.Unmarshal(params, &args); e != nil || len(args) != 1 {
+		return nil, err.ErrInvalidArgument("Invalid parameters")
+	}
+
+	return api.cmd.ImportPubKey(args[0].PubKey), nil
+}
+
+func (api *API) HandleCreateMultiSig(params json.RawMessage) (any, *err.RPCError) {
+	var args []types.CreateMultiSigAPIArgs
+	if e := json.Unmarshal(params, &args); e != nil || len(args) != 1 {
+		return nil, err.ErrInvalidArgument("Invalid parameters")
+	}
+
+	return api.cmd.CreateMultiSig(args[0].Required, args[0].PubKeys), nil
+}
+
+func (api *API) HandleGetWalletBalance(params json.RawMessage) (any, *err.RPCError) {
+	var args []types.WalletBalanceAPIArgs
+	if e := json.Unmarshal(params, &args); e != nil || len(args) != 1 {
+		return nil, err.ErrInvalidArgument("Invalid parameters")
+	}
+
+	return api.cmd.GetWalletBalance(args[0].IncludeWatchOnly), nil
+}
+
+func (api *API) HandleGetNewAddress(params json.RawMessage) (any, *err.RPCError) {
+	var args []types.NewAddressAPIArgs
+	if e := json.Unmarshal(params, &args); e != nil || len(args) != 1 {
+		return nil, err.ErrInvalidArgument("Invalid parameters")
+	}
+
+	return api.cmd.GetNewAddress(args[0].Change), nil
+}
+
+func (api *API) HandleListAddresses(params json.RawMessage) (any, *err.RPCError) {
+	var args []types.WalletBalanceAPIArgs
+	if e := json.Unmarshal(params, &args); e != nil || len(args) != 1 {
+		return nil, err.ErrInvalidArgument("Invalid parameters")
+	}
+
+	return api.cmd.ListAddresses(args[0].IncludeWatchOnly), nil
+}
+
+func (api *API) HandleListUnspent(params json.RawMessage) (any, *err.RPCError) {
+	var args []types.ListUnspentAPIArgs
+	if e := json.Unmarshal(params, &args); e != nil || len(args) != 1 {
+		return nil, err.ErrInvalidArgument("Invalid parameters")
+	}
+
+	return api.cmd.ListUnspent(args[0].MinConf, args[0].MaxConf, args[0].Addresses, args[0].IncludeWatchOnly), nil
+}
+
+func (api *API) HandleSendToAddress(params json.RawMessage) (any, *err.RPCError) {
+	var args []types.SendToAddressAPIArgs
+	if e := json.Unmarshal(params, &args); e != nil || len(args) != 1 {
+		return nil, err.ErrInvalidArgument("Invalid parameters")
+	}
+
+	a := args[0]
+	return api.cmd.SendToAddress(a.Address, a.Amount, a.Fee, a.SubtractFeeFromAmount, a.MinConf, a.CoinSelection), nil
+}
+
+func (api *API) HandleSendMany(params json.RawMessage) (any, *err.RPCError) {
+	var args []types.SendManyAPIArgs
+	if e := json.Unmarshal(params, &args); e != nil || len(args) != 1 {
+		return nil, err.ErrInvalidArgument("Invalid parameters")
+	}
+
+	a := args[0]
+	return api.cmd.SendMany(a.Amounts, a.Fee, a.SubtractFeeFrom, a.MinConf, a.CoinSelection), nil
+}
+
+func (api *API) HandleListTransactions(params json.RawMessage) (any, *err.RPCError) {
+	var args []types.ListTransactionsAPIArgs
+	if e := json.Unmarshal(params, &args); e != nil || len(args) != 1 {
+		return nil, err.ErrInvalidArgument("Invalid parameters")
+	}
+
+	return api.cmd.ListTransactions(args[0].Count, args[0].Skip, args[0].IncludeWatchOnly), nil
+}
+
+func (api *API) HandleGetTransaction(params json.RawMessage) (any, *err.RPCError) {
+	var args []types.GetTransactionAPIArgs
+	if e := json.Unmarshal(params, &args); e != nil || len(args) != 1 {
+		return nil, err.ErrInvalidArgument("Invalid parameters")
+	}
+
+	return api.cmd.GetTransaction(args[0].TxID, args[0].IncludeWatchOnly), nil
+}
+
+func (api *API) HandleCreatePSBT(params json.RawMessage) (any, *err.RPCError) {
+	var args []types.CreatePSBTAPIArgs
+	if e := json.Unmarshal(params, &args); e != nil || len(args) != 1 {
+		return nil, err.ErrInvalidArgument("Invalid parameters")
+	}
+
+	inputs := make([]blockchain.TxInput, 0, len(args[0].Inputs))
+	for _, in := range args[0].Inputs {
+		txID, e := hex.DecodeString(in.TxID)
+		if e != nil || len(txID) == 0 {
+			return nil, err.ErrInvalidArgument("Invalid txid", in.TxID)
+		}
+		inputs = append(inputs, blockchain.TxInput{ID: txID, Out: in.Out})
+	}
+
+	outputs := make([]blockchain.Recipient, 0, len(args[0].Outputs))
+	for _, out := range args[0].Outputs {
+		outputs = append(outputs, blockchain.Recipient{Address: out.Address, Amount: out.Amount})
+	}
+
+	return api.cmd.CreatePSBT(inputs, outputs, args[0].Height), nil
+}
+
+func (api *API) HandleDecodePSBT(params json.RawMessage) (any, *err.RPCError) {
+	var args []types.PSBTAPIArgs
+	if e := json.Unmarshal(params, &args); e != nil || len(args) != 1 {
+		return nil, err.ErrInvalidArgument("Invalid parameters")
+	}
+
+	return api.cmd.DecodePSBT(args[0].PSBT), nil
+}
+
+func (api *API) HandleUpdatePSBT(params json.RawMessage) (any, *err.RPCError) {
+	var args []types.UpdatePSBTAPIArgs
+	if e := json.Unmarshal(params, &args); e != nil || len(args) != 1 {
+		return nil, err.ErrInvalidArgument("Invalid parameters")
+	}
+
+	return api.cmd.UpdatePSBT(args[0].PSBT, args[0].RedeemScripts), nil
+}
+
+func (api *API) HandleSignPSBT(params json.RawMessage) (any, *err.RPCError) {
+	var args []types.SignPSBTAPIArgs
+	if e := json.Unmarshal(params, &args); e != nil || len(args) != 1 {
+		return nil, err.ErrInvalidArgument("Invalid parameters")
+	}
+
+	return api.cmd.SignPSBT(args[0].PSBT, args[0].SigHashType), nil
+}
+
+func (api *API) HandleCombinePSBT(params json.RawMessage) (any, *err.RPCError) {
+	var args []types.CombinePSBTAPIArgs
+	if e := json.Unmarshal(params, &args); e != nil || len(args) != 1 {
+		return nil, err.ErrInvalidArgument("Invalid parameters")
+	}
+
+	return api.cmd.CombinePSBT(args[0].PSBTs), nil
+}
+
+func (api *API) HandleFinalizePSBT(params json.RawMessage) (any, *err.RPCError) {
+	var args []types.FinalizePSBTAPIArgs
+	if e := json.Unmarshal(params, &args); e != nil || len(args) != 1 {
+		return nil, err.ErrInvalidArgument("Invalid parameters")
+	}
+
+	return api.cmd.FinalizePSBT(args[0].PSBT, args[0].Extract), nil
+}
+
+func (api *API) HandleSendPSBT(params json.RawMessage) (any, *err.RPCError) {
+	var args []types.PSBTAPIArgs
+	if e := json.Unmarshal(params, &args); e != nil || len(args) != 1 {
+		return nil, err.ErrInvalidArgument("Invalid parameters")
+	}
+
+	return api.cmd.SendPSBT(args[0].PSBT), nil
+}
+
+func (api *API) HandleEstimateFee(params json.RawMessage) (any, *err.RPCError) {
+	var args []types.EstimateFeeAPIArgs
+	if e := json.Unmarshal(params, &args); e != nil || len(args) != 1 {
+		return nil, err.ErrInvalidArgument("Invalid parameters")
+	}
+
+	return api.cmd.EstimateFee(args[0].TargetBlocks), nil
+}
@@ -19,6 +19,12 @@ type GetBlockchainAPIArgs struct {
 	Max       uint64 `json:"max"`
 }
 
+type AddressPageAPIArgs struct {
+	Address string `json:"address"`
+	Offset  int64  `json:"offset"`
+	Limit   int64  `json:"limit"`
+}
+
 type GetAPIBlockArgs struct {
 	Height int64 `json:"height"`
 }
@@ -35,3 +41,138 @@ type SendTxAPIArgs struct {
 type GetMiningTxsAPIArgs struct {
 	Verbose bool `json:"verbose"`
 }
+
+type GenerateToAddressAPIArgs struct {
+	NBlocks int64  `json:"nblocks"`
+	Address string `json:"address"`
+}
+
+// GenerateBlockAPIArgs.Address falls back to the miner address of the node.
+type GenerateBlockAPIArgs struct {
+	TxIDs   []string `json:"txids"`
+	Address string   `json:"address"`
+}
+
+type SetBanAPIArgs struct {
+	PeerID  string `json:"peerId"`
+	Command string `json:"command"`
+	BanTime int64  `json:"banTime"`
+	Reason  string `json:"reason"`
+}
+
+type EstimateFeeAPIArgs struct {
+	TargetBlocks int64 `json:"targetBlocks"`
+}
+
+// ImportPubKeyAPIArgs.PubKey is hex encoded.
+type ImportPubKeyAPIArgs struct {
+	PubKey string `json:"pubKey"`
+}
+
+// CreateMultiSigAPIArgs.PubKeys are hex encoded.
+type CreateMultiSigAPIArgs struct {
+	Required int      `json:"required"`
+	PubKeys  []string `json:"pubKeys"`
+}
+
+type WalletBalanceAPIArgs struct {
+	IncludeWatchOnly bool `json:"includeWatchOnly"`
+}
+
+type NewAddressAPIArgs struct {
+	Change bool `json:"change"`
+}
+
+// ListUnspentAPIArgs.MaxConf of 0 sets no upper bound. Addresses limits the
+// outputs to those addresses when set.
+type ListUnspentAPIArgs struct {
+	MinConf          int64    `json:"minConf"`
+	MaxConf          int64    `json:"maxConf"`
+	Addresses        []string `json:"addresses"`
+	IncludeWatchOnly bool     `json:"includeWatchOnly"`
+}
+
+// SendToAddressAPIArgs amounts are in base units. CoinSelection is one of
+// the coinselect strategies, the default one when empty.
+type SendToAddressAPIArgs struct {
+	Address               string `json:"address"`
+	Amount                int64  `json:"amount"`
+	Fee                   int64  `json:"fee"`
+	SubtractFeeFromAmount bool   `json:"subtractFeeFromAmount"`
+	MinConf               int64  `json:"minConf"`
+	CoinSelection         string `json:"coinSelection"`
+}
+
+// SendManyAPIArgs.Amounts maps addresses to base units. SubtractFeeFrom
+// lists the addresses that pay the fee out of their amount.
+type SendManyAPIArgs struct {
+	Amounts         map[string]int64 `json:"amounts"`
+	Fee             int64            `json:"fee"`
+	SubtractFeeFrom []string         `json:"subtractFeeFrom"`
+	MinConf         int64            `json:"minConf"`
+	CoinSelection   string           `json:"coinSelection"`
+}
+
+type ListTransactionsAPIArgs struct {
+	Count            int64 `json:"count"`
+	Skip             int64 `json:"skip"`
+	IncludeWatchOnly bool  `json:"includeWatchOnly"`
+}
+
+type GetTransactionAPIArgs struct {
+	TxID             string `json:"txid"`
+	IncludeWatchOnly bool   `json:"includeWatchOnly"`
+}
+
+type PSBTInputArgs struct {
+	TxID string `json:"txid"`
+	Out  int64  `json:"out"`
+}
+
+// PSBTOutputArgs.Amount is in base units.
+type PSBTOutputArgs struct {
+	Address string `json:"address"`
+	Amount  int64  `json:"amount"`
+}
+
+// CreatePSBTAPIArgs.Height is the height the transaction is built for, the
+// next block when 0.
+type CreatePSBTAPIArgs struct {
+	Inputs  []PSBTInputArgs  `json:"inputs"`
+	Outputs []PSBTOutputArgs `json:"outputs"`
+	Height  int64            `json:"height"`
+}
+
+// PSBTAPIArgs.PSBT is base64 encoded.
+type PSBTAPIArgs struct {
+	PSBT string `json:"psbt"`
+}
+
+// UpdatePSBTAPIArgs.RedeemScripts are hex encoded, see CreateMultiSig. Each
+// goes to the inputs spending its script hash.
+type UpdatePSBTAPIArgs struct {
+	PSBT          string   `json:"psbt"`
+	RedeemScripts []string `json:"redeemScripts"`
+}
+
+// SignPSBTAPIArgs.SigHashType is ALL, NONE or SINGLE, optionally with
+// |ANYONECANPAY. It applies to the inputs that do not ask for one.
+type SignPSBTAPIArgs struct {
+	PSBT        string `json:"psbt"`
+	SigHashType string `json:"sigHashType"`
+}
+
+type CombinePSBTAPIArgs struct {
+	PSBTs []string `json:"psbts"`
+}
+
+type FinalizePSBTAPIArgs struct {
+	PSBT    string `json:"psbt"`
+	Extract bool   `json:"extract"`
+}
+
+// WalletUnlockAPIArgs.Timeout is in seconds.
+type WalletUnlockAPIArgs struct {
+	Passphrase string `json:"passphrase"`
+	Timeout    int64  `json:"timeout"`
+}
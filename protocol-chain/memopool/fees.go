@@ -0,0 +1,200 @@
+package memopool
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+const (
+	// MaxEstimateTarget is the furthest confirmation target, in blocks, the
+	// fee estimator answers for.
+	MaxEstimateTarget = 48
+
+	// Fee rate buckets are base units per serialized byte, spaced
+	// exponentially from the default min relay fee rate up.
+	feeBucketMin     = 0.001
+	feeBucketMax     = 1e6
+	feeBucketSpacing = 1.1
+
+	// feeDecay is applied to the counts once per block, so a block weighs
+	// half as much after about 350 blocks.
+	feeDecay = 0.998
+	// feeSuccessShare is the share of transactions in a fee rate range that
+	// has to confirm within the target for the range to pass.
+	feeSuccessShare = 0.85
+	// feeMinTxs is how many (decayed) transactions a range needs before it
+	// is trusted.
+	feeMinTxs = 2.0
+)
+
+var (
+	ErrInvalidTarget = errors.New("invalid confirmation target")
+	ErrNoEstimate    = errors.New("insufficient data to estimate fee")
+)
+
+// FeeEstimate is a fee rate, in base units per serialized byte, that got
+// transactions confirmed within Blocks blocks.
+type FeeEstimate struct {
+	FeeRate float64
+	Blocks  int
+}
+
+type trackedTx struct {
+	height int64
+	bucket int
+	rate   float64
+}
+
+// feeEstimator learns how fast transactions confirm by fee rate. Every
+// transaction entering the pool without pool parents goes into the bucket of
+// its fee rate, and when a block confirms it, the number of blocks it waited
+// is recorded. Transactions still in the pool count against their bucket once
+// they waited longer than the target asked for. Counts decay every block so
+// recent blocks weigh most.
+type feeEstimator struct {
+	buckets []float64 // lower bound of each bucket
+	// confirmed[t][b] counts bucket b transactions that confirmed within
+	// t+1 blocks.
+	confirmed [][]float64
+	txs       []float64 // confirmed transactions per bucket
+	rateSum   []float64 // fee rates of the confirmed transactions per bucket
+	tracked   map[string]trackedTx
+	height    int64
+}
+
+func newFeeEstimator() *feeEstimator {
+	fe := &feeEstimator{tracked: map[string]trackedTx{}}
+
+	for rate := feeBucketMin; rate < feeBucketMax; rate *= feeBucketSpacing {
+		fe.buckets = append(fe.buckets, rate)
+	}
+
+	fe.confirmed = make([][]float64, MaxEstimateTarget)
+	for t := range fe.confirmed {
+		fe.confirmed[t] = make([]float64, len(fe.buckets))
+	}
+	fe.txs = make([]float64, len(fe.buckets))
+	fe.rateSum = make([]float64, len(fe.buckets))
+
+	return fe
+}
+
+func (fe *feeEstimator) bucket(rate float64) int {
+	b := sort.Search(len(fe.buckets), func(i int) bool {
+		return fe.buckets[i] > rate
+	}) - 1
+
+	return max(b, 0)
+}
+
+// track starts timing txID. Nothing is tracked before the first block is
+// seen, there is no height to count from.
+func (fe *feeEstimator) track(txID string, fee int64, size int) {
+	if fe.height == 0 || size <= 0 {
+		return
+	}
+
+	rate := float64(fee) / float64(size)
+	fe.tracked[txID] = trackedTx{height: fe.height, bucket: fe.bucket(rate), rate: rate}
+}
+
+// processBlock decays the counts, records the tracked transactions among
+// confirmed and stops tracking the ones inPool no longer holds.
+func (fe *feeEstimator) processBlock(height int64, confirmed []string, inPool func(string) bool) {
+	if height > fe.height {
+		for t := range fe.confirmed {
+			for b := range fe.confirmed[t] {
+				fe.confirmed[t][b] *= feeDecay
+			}
+		}
+		for b := range fe.txs {
+			fe.txs[b] *= feeDecay
+			fe.rateSum[b] *= feeDecay
+		}
+	}
+
+	for _, txID := range confirmed {
+		tx, ok := fe.tracked[txID]
+		if !ok {
+			continue
+		}
+		delete(fe.tracked, txID)
+
+		blocks := max(height-tx.height, 1)
+		for t := blocks - 1; t < MaxEstimateTarget; t++ {
+			fe.confirmed[t][tx.bucket]++
+		}
+		fe.txs[tx.bucket]++
+		fe.rateSum[tx.bucket] += tx.rate
+	}
+
+	// Replaced, evicted and expired transactions tell nothing about how
+	// long confirming takes.
+	for txID := range fe.tracked {
+		if !inPool(txID) {
+			delete(fe.tracked, txID)
+		}
+	}
+
+	fe.height = height
+}
+
+// estimate walks the buckets from the highest fee rate down, grouping
+// buckets until a range holds feeMinTxs transactions, and returns the average
+// fee rate of the lowest range in which feeSuccessShare of the transactions
+// confirmed within target blocks.
+func (fe *feeEstimator) estimate(target int) (float64, bool) {
+	t := target - 1
+
+	stuck := make([]float64, len(fe.buckets))
+	for _, tx := range fe.tracked {
+		if fe.height-tx.height >= int64(target) {
+			stuck[tx.bucket]++
+		}
+	}
+
+	var confirmed, total, rateSum, txs float64
+	best := -1.0
+
+	for b := len(fe.buckets) - 1; b >= 0; b-- {
+		confirmed += fe.confirmed[t][b]
+		total += fe.txs[b] + stuck[b]
+		rateSum += fe.rateSum[b]
+		txs += fe.txs[b]
+
+		if total < feeMinTxs {
+			continue
+		}
+		if confirmed/total < feeSuccessShare {
+			break
+		}
+
+		if txs > 0 {
+			best = rateSum / txs
+		}
+		confirmed, total, rateSum, txs = 0, 0, 0, 0
+	}
+
+	return best, best >= 0
+}
+
+// EstimateFee returns the fee rate expected to confirm a transaction within
+// target blocks. When the data does not cover target it tries the later
+// targets, FeeEstimate.Blocks tells which one answered.
+func (memo *Memopool) EstimateFee(target int) (FeeEstimate, error) {
+	if target < 1 || target > MaxEstimateTarget {
+		return FeeEstimate{}, fmt.Errorf("%w: %d not in 1..%d", ErrInvalidTarget, target, MaxEstimateTarget)
+	}
+
+	memo.mu.RLock()
+	defer memo.mu.RUnlock()
+
+	for blocks := target; blocks <= MaxEstimateTarget; blocks++ {
+		if rate, ok := memo.fees.estimate(blocks); ok {
+			return FeeEstimate{FeeRate: rate, Blocks: blocks}, nil
+		}
+	}
+
+	return FeeEstimate{}, ErrNoEstimate
+}
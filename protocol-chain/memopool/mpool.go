@@ -2,173 +2,860 @@ package memopool
 
 import (
 	"bytes"
+	"container/heap"
 	blockchain "core-blockchain/core"
 	"encoding/hex"
+	"errors"
+	"fmt"
 	"maps"
 	"slices"
 	"sort"
+	"sync"
+	"time"
 
 	log "github.com/sirupsen/logrus"
 )
 
+// TxInfo.Fee is expressed in base units, Size is the serialized size in
+// bytes and Time when the transaction entered the pool. Parents lists the
+// pool transactions it spent from when it was validated.
 type TxInfo struct {
-	Fee         float64
+	Fee         int64
+	Size        int
+	Time        time.Time
+	Parents     []string
 	Transaction blockchain.Transaction
 }
 
+func (info *TxInfo) FeeRate() int64 {
+	return FeeRate(info.Fee, info.Size)
+}
+
+var ErrDoubleSpend = errors.New("transaction spends an output already spent in the mempool")
+
+// Memopool holds unconfirmed transactions. Pending ones wait for a miner,
+// queued ones are picked for the block being mined. Every method is safe for
+// concurrent use. spends maps each outpoint spent by a pooled transaction to
+// that transaction, so two pool transactions never spend the same output.
+// It also links children to the pool parents whose outputs they spend.
 type Memopool struct {
-	Pending map[string]TxInfo
-	Queued  map[string]TxInfo
+	mu      sync.RWMutex
+	pending map[string]TxInfo
+	queued  map[string]TxInfo
+	spends  map[string]string
+
+	Policy Policy
+	minFee rollingMinFee
+	fees   *feeEstimator
 }
 
-func GetTxInfo(tx *blockchain.Transaction, bl *blockchain.Blockchain) *TxInfo {
-	if !bl.VerifyTransaction(tx) {
-		log.Infof("Transaction ID: %s is not valid", hex.EncodeToString(tx.ID))
-		return nil
+func New(policy Policy) *Memopool {
+	return &Memopool{
+		pending: map[string]TxInfo{},
+		queued:  map[string]TxInfo{},
+		spends:  map[string]string{},
+		Policy:  policy,
+		fees:    newFeeEstimator(),
 	}
+}
 
-	totalInput := blockchain.NewCoinAmountFromFloat(0.0)
-	for _, in := range tx.Inputs {
-		prevTx, err := bl.FindTransaction(in.ID)
-		if err != nil {
-			log.Error("Transaction Not Found")
-			continue
-		}
+func outpointKey(txID []byte, out int64) string {
+	return fmt.Sprintf("%x:%d", txID, out)
+}
+
+// GetTxInfo validates tx against the chain and the outputs of pool
+// transactions, and returns nil when it is invalid.
+func (memo *Memopool) GetTxInfo(tx *blockchain.Transaction, bl *blockchain.Blockchain) *TxInfo {
+	parents := memo.Parents(tx)
 
-		out := prevTx.Outputs[in.Out]
-		value := blockchain.NewCoinAmountFromFloat(out.Value)
-		totalInput = totalInput.Add(value)
+	if !bl.VerifyTransactionWithParents(tx, parents) {
+		log.Infof("Transaction ID: %s is not valid", hex.EncodeToString(tx.ID))
+		return nil
+	}
 
+	totalInput, err := bl.InputValue(tx, parents)
+	if err != nil {
+		log.Errorf("Transaction %s inputs: %v", hex.EncodeToString(tx.ID), err)
+		return nil
 	}
 
-	totalOutput := blockchain.NewCoinAmountFromFloat(0.0)
+	totalOutput := blockchain.ZeroAmount()
 	for _, out := range tx.Outputs {
-		value := blockchain.NewCoinAmountFromFloat(out.Value)
+		value := blockchain.NewCoinAmountFromUnits(out.Value)
 		totalOutput = totalOutput.Add(value)
 	}
 
+	buf := new(bytes.Buffer)
+	blockchain.SerializeTransaction(tx, buf)
+
 	return &TxInfo{
-		Fee:         blockchain.SumFees(totalInput, totalOutput).ToFloat(),
+		Fee:         blockchain.SumFees(totalInput, totalOutput).Units(),
+		Size:        buf.Len(),
+		Parents:     slices.Sorted(maps.Keys(parents)),
 		Transaction: *tx,
 	}
 }
 
+// Parents returns the pool transactions tx spends from, keyed by hex ID.
+func (memo *Memopool) Parents(tx *blockchain.Transaction) map[string]blockchain.Transaction {
+	memo.mu.RLock()
+	defer memo.mu.RUnlock()
+
+	parents := make(map[string]blockchain.Transaction)
+	for _, parentID := range memo.parentIDsLocked(tx) {
+		info, _ := memo.getLocked(parentID)
+		parents[parentID] = info.Transaction
+	}
+
+	return parents
+}
+
 func (memo *Memopool) GetTxByID(txID string) *blockchain.Transaction {
-	if _, exists := memo.Pending[txID]; exists {
-		tx := memo.Pending[txID].Transaction
-		return &tx
+	memo.mu.RLock()
+	defer memo.mu.RUnlock()
+
+	info, ok := memo.getLocked(txID)
+	if !ok {
+		return nil
+	}
+
+	tx := info.Transaction
+	return &tx
+}
+
+func (memo *Memopool) getLocked(txID string) (TxInfo, bool) {
+	if info, ok := memo.pending[txID]; ok {
+		return info, true
 	}
 
-	if _, exists := memo.Queued[txID]; exists {
-		tx := memo.Queued[txID].Transaction
-		return &tx
+	info, ok := memo.queued[txID]
+	return info, ok
+}
+
+// Add admits tx under the pool policy. It drops expired transactions first
+// and, when the pool is full, evicts transactions paying a lower fee rate
+// than tx. A tx spending outputs already spent in the pool replaces those
+// spends under the full replace-by-fee rules when Policy.FullRBF is on, and
+// is rejected as a double spend otherwise. tx.Time is kept when it is set and
+// not in the future. It returns the ids of the transactions tx replaced,
+// the error tells why tx was rejected.
+func (memo *Memopool) Add(tx TxInfo) ([]string, error) {
+	txID := hex.EncodeToString(tx.Transaction.ID)
+
+	memo.mu.Lock()
+	defer memo.mu.Unlock()
+
+	if memo.hasLocked(txID) {
+		return nil, ErrAlreadyInPool
+	}
+
+	now := time.Now()
+	memo.expireLocked(now)
+
+	for _, parentID := range tx.Parents {
+		if !memo.hasLocked(parentID) {
+			return nil, fmt.Errorf("%w: %s", ErrMissingParent, parentID)
+		}
+	}
+
+	var replaced map[string]TxInfo
+	if conflicts := memo.conflictsLocked(&tx.Transaction); len(conflicts) > 0 {
+		var err error
+		if replaced, err = memo.replacementLocked(tx, conflicts); err != nil {
+			return nil, err
+		}
+	}
+
+	policy := memo.Policy
+	if tx.Size > policy.MaxBytes {
+		return nil, fmt.Errorf("%w: %d > %d bytes", ErrTxTooLarge, tx.Size, policy.MaxBytes)
+	}
+
+	rate := tx.FeeRate()
+	if min := memo.minFeeRateLocked(now); rate < min {
+		return nil, rejectFeeTooLow(rate, min)
+	}
+
+	ancestors := memo.ancestorsLocked(tx)
+	if err := memo.checkChainLimits(ancestors); err != nil {
+		return nil, err
+	}
+
+	evict, evictRate, err := memo.evictionSet(tx.Size, rate, ancestors, replaced)
+	if err != nil {
+		return nil, err
+	}
+
+	replacedIDs := slices.Sorted(maps.Keys(replaced))
+	for _, id := range replacedIDs {
+		memo.removeLocked(id)
+		log.Infof("Mempool replaced tx %s with %s", id, txID)
+	}
+
+	for _, victim := range evict {
+		victimID := hex.EncodeToString(victim.Transaction.ID)
+		memo.removeLocked(victimID)
+		log.Infof("Mempool full, evicted tx %s (fee rate %d units/kB)", victimID, victim.FeeRate())
+	}
+	if len(evict) > 0 {
+		memo.minFee.bump(evictRate+policy.IncrementalFeeRate, now)
+	}
+
+	if tx.Time.IsZero() || tx.Time.After(now) {
+		tx.Time = now
+	}
+	memo.insertLocked(memo.pending, tx)
+
+	if len(tx.Parents) == 0 {
+		memo.fees.track(txID, tx.Fee, tx.Size)
+	}
+
+	return replacedIDs, nil
+}
+
+// conflictsLocked returns the pool transactions spending one of the outputs
+// tx spends.
+func (memo *Memopool) conflictsLocked(tx *blockchain.Transaction) []string {
+	var conflicts []string
+	for _, in := range tx.Inputs {
+		spender, ok := memo.spends[outpointKey(in.ID, in.Out)]
+		if ok && !slices.Contains(conflicts, spender) {
+			conflicts = append(conflicts, spender)
+		}
+	}
+
+	return conflicts
+}
+
+// replacementLocked checks tx against the full replace-by-fee rules and returns
+// what it would evict: the conflicting transactions and their descendants.
+// tx has to pay a higher fee rate than each transaction it conflicts with
+// and a higher fee than everything it evicts, plus its own relay at the
+// incremental fee rate, and may not spend outputs of what it evicts.
+func (memo *Memopool) replacementLocked(tx TxInfo, conflicts []string) (map[string]TxInfo, error) {
+	policy := memo.Policy
+	if !policy.FullRBF {
+		return nil, fmt.Errorf("%w: conflicts with %s", ErrDoubleSpend, conflicts[0])
+	}
+
+	rate := tx.FeeRate()
+	replaced := make(map[string]TxInfo)
+
+	for _, conflictID := range conflicts {
+		conflict, _ := memo.getLocked(conflictID)
+		if rate <= conflict.FeeRate() {
+			return nil, fmt.Errorf("%w: fee rate %d units/kB does not beat %d of %s", ErrReplacementRejected, rate, conflict.FeeRate(), conflictID)
+		}
+
+		replaced[conflictID] = conflict
+		maps.Copy(replaced, memo.descendantsLocked(conflictID))
+	}
+
+	if len(replaced) > policy.MaxReplacements {
+		return nil, fmt.Errorf("%w: evicts %d transactions, max %d", ErrReplacementRejected, len(replaced), policy.MaxReplacements)
+	}
+
+	for _, parentID := range tx.Parents {
+		if _, ok := replaced[parentID]; ok {
+			return nil, fmt.Errorf("%w: spends outputs of %s it replaces", ErrReplacementRejected, parentID)
+		}
+	}
+
+	var replacedFee int64
+	for _, info := range replaced {
+		replacedFee += info.Fee
+	}
+
+	if minFee := replacedFee + policy.IncrementalFeeRate*int64(tx.Size)/1000; tx.Fee <= replacedFee || tx.Fee < minFee {
+		return nil, fmt.Errorf("%w: fee %d does not beat %d of the replaced transactions", ErrReplacementRejected, tx.Fee, minFee)
+	}
+
+	return replaced, nil
+}
+
+// parentIDsLocked returns the pool transactions tx spends from.
+func (memo *Memopool) parentIDsLocked(tx *blockchain.Transaction) []string {
+	var parents []string
+	for _, in := range tx.Inputs {
+		parentID := hex.EncodeToString(in.ID)
+		if memo.hasLocked(parentID) && !slices.Contains(parents, parentID) {
+			parents = append(parents, parentID)
+		}
+	}
+
+	return parents
+}
+
+// childIDsLocked returns the pool transactions spending outputs of tx.
+func (memo *Memopool) childIDsLocked(tx *blockchain.Transaction) []string {
+	var children []string
+	for i := range tx.Outputs {
+		child, ok := memo.spends[outpointKey(tx.ID, int64(i))]
+		if ok && !slices.Contains(children, child) {
+			children = append(children, child)
+		}
+	}
+
+	return children
+}
+
+// packageLocked returns tx after its pool ancestors that are not in skip,
+// parents before children.
+func (memo *Memopool) packageLocked(tx TxInfo, skip map[string]bool) []TxInfo {
+	var pkg []TxInfo
+	visited := make(map[string]bool)
+
+	var visit func(info TxInfo)
+	visit = func(info TxInfo) {
+		txID := hex.EncodeToString(info.Transaction.ID)
+		if visited[txID] || skip[txID] {
+			return
+		}
+		visited[txID] = true
+
+		for _, parentID := range memo.parentIDsLocked(&info.Transaction) {
+			parent, _ := memo.getLocked(parentID)
+			visit(parent)
+		}
+		pkg = append(pkg, info)
+	}
+	visit(tx)
+
+	return pkg
+}
+
+// ancestorsLocked returns the pool transactions tx depends on, directly or
+// not, keyed by ID.
+func (memo *Memopool) ancestorsLocked(tx TxInfo) map[string]TxInfo {
+	pkg := memo.packageLocked(tx, nil)
+
+	ancestors := make(map[string]TxInfo, len(pkg)-1)
+	for _, info := range pkg[:len(pkg)-1] {
+		ancestors[hex.EncodeToString(info.Transaction.ID)] = info
+	}
+
+	return ancestors
+}
+
+// descendantsLocked returns the pool transactions depending on txID,
+// directly or not, keyed by ID.
+func (memo *Memopool) descendantsLocked(txID string) map[string]TxInfo {
+	descendants := make(map[string]TxInfo)
+
+	queue := []string{txID}
+	for len(queue) > 0 {
+		info, ok := memo.getLocked(queue[0])
+		queue = queue[1:]
+		if !ok {
+			continue
+		}
+
+		for _, child := range memo.childIDsLocked(&info.Transaction) {
+			if _, seen := descendants[child]; seen {
+				continue
+			}
+			descendants[child], _ = memo.getLocked(child)
+			queue = append(queue, child)
+		}
+	}
+
+	return descendants
+}
+
+// checkChainLimits rejects a transaction with ancestors when the chain it
+// would join gets longer than the policy allows.
+func (memo *Memopool) checkChainLimits(ancestors map[string]TxInfo) error {
+	if len(ancestors)+1 > memo.Policy.MaxAncestors {
+		return fmt.Errorf("%w: %d ancestors", ErrTooLongChain, len(ancestors))
+	}
+
+	for ancestorID := range ancestors {
+		if n := len(memo.descendantsLocked(ancestorID)) + 2; n > memo.Policy.MaxDescendants {
+			return fmt.Errorf("%w: %s would have %d descendants", ErrTooLongChain, ancestorID, n-1)
+		}
 	}
 
 	return nil
 }
 
-func (memo *Memopool) Add(tx TxInfo) {
+func (memo *Memopool) insertLocked(pool map[string]TxInfo, tx TxInfo) {
 	txID := hex.EncodeToString(tx.Transaction.ID)
 
-	if memo.HasTX(txID) {
-		return
+	pool[txID] = tx
+	for _, in := range tx.Transaction.Inputs {
+		memo.spends[outpointKey(in.ID, in.Out)] = txID
+	}
+}
+
+// removeLocked drops txID from both pools and the spend index.
+func (memo *Memopool) removeLocked(txID string) (TxInfo, bool) {
+	info, ok := memo.getLocked(txID)
+	if !ok {
+		return TxInfo{}, false
+	}
+
+	delete(memo.pending, txID)
+	delete(memo.queued, txID)
+
+	for _, in := range info.Transaction.Inputs {
+		key := outpointKey(in.ID, in.Out)
+		if memo.spends[key] == txID {
+			delete(memo.spends, key)
+		}
+	}
+
+	return info, true
+}
+
+// removeWithDescendantsLocked drops txID and every pool transaction spending
+// its outputs, directly or not. It returns the ids it removed.
+func (memo *Memopool) removeWithDescendantsLocked(txID string) []string {
+	info, ok := memo.removeLocked(txID)
+	if !ok {
+		return nil
 	}
 
-	memo.Pending[txID] = tx
+	removed := []string{txID}
+	for i := range info.Transaction.Outputs {
+		if child, ok := memo.spends[outpointKey(info.Transaction.ID, int64(i))]; ok {
+			removed = append(removed, memo.removeWithDescendantsLocked(child)...)
+		}
+	}
+
+	return removed
+}
+
+// RemoveForBlock drops the transactions confirmed by block along with pool
+// transactions that spend the same outputs, which the block made invalid,
+// and their descendants, and feeds the confirmations to the fee estimator.
+// It returns the ids of the conflicts it dropped.
+func (memo *Memopool) RemoveForBlock(block *blockchain.Block) []string {
+	memo.mu.Lock()
+	defer memo.mu.Unlock()
+
+	txs := block.Transactions
+
+	confirmed := make([]string, 0, len(txs))
+	for _, tx := range txs {
+		txID := hex.EncodeToString(tx.ID)
+		memo.removeLocked(txID)
+		confirmed = append(confirmed, txID)
+	}
+
+	var conflicts []string
+	for _, tx := range txs {
+		for _, conflict := range memo.conflictsLocked(tx) {
+			conflicts = append(conflicts, memo.removeWithDescendantsLocked(conflict)...)
+		}
+	}
+
+	if len(conflicts) > 0 {
+		log.Infof("Mempool dropped %d transactions conflicting with the block", len(conflicts))
+	}
+
+	memo.fees.processBlock(block.Height, confirmed, memo.hasLocked)
+
+	return conflicts
+}
+
+// evictionSet returns the lowest paying transactions that have to leave for
+// size more bytes to fit, along with the highest fee rate it gives up. A
+// transaction leaves with its descendants and is ranked by the fee rate of
+// that package, so a child paying well keeps its parent in. Only packages
+// paying less than rate and not in keep are given up for it. Transactions
+// in replaced are leaving anyway and count as free space.
+func (memo *Memopool) evictionSet(size int, rate int64, keep, replaced map[string]TxInfo) ([]TxInfo, int64, error) {
+	excess := memo.bytesLocked() + size - memo.Policy.MaxBytes
+	for _, info := range replaced {
+		excess -= info.Size
+	}
+	if excess <= 0 {
+		return nil, 0, nil
+	}
+
+	type candidate struct {
+		txs  []TxInfo
+		rate int64
+	}
+
+	all := memo.allLocked()
+	candidates := make([]candidate, 0, len(all))
+	for _, info := range all {
+		txs := []TxInfo{info}
+		fee, pkgSize := info.Fee, info.Size
+		for _, d := range memo.descendantsLocked(hex.EncodeToString(info.Transaction.ID)) {
+			txs = append(txs, d)
+			fee += d.Fee
+			pkgSize += d.Size
+		}
+		candidates = append(candidates, candidate{txs: txs, rate: FeeRate(fee, pkgSize)})
+	}
+
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].rate < candidates[j].rate
+	})
+
+	var evict []TxInfo
+	var evictRate int64
+	evicted := make(map[string]bool, len(replaced))
+	for id := range replaced {
+		evicted[id] = true
+	}
+
+	for _, c := range candidates {
+		if excess <= 0 {
+			break
+		}
+
+		txID := hex.EncodeToString(c.txs[0].Transaction.ID)
+		if _, ok := keep[txID]; ok || evicted[txID] {
+			continue
+		}
+		if c.rate >= rate {
+			return nil, 0, fmt.Errorf("%w: fee rate %d units/kB does not beat the pool", ErrMempoolFull, rate)
+		}
+
+		for _, info := range c.txs {
+			id := hex.EncodeToString(info.Transaction.ID)
+			if evicted[id] {
+				continue
+			}
+			evicted[id] = true
+			evict = append(evict, info)
+			excess -= info.Size
+		}
+		evictRate = max(evictRate, c.rate)
+	}
+
+	if excess > 0 {
+		return nil, 0, fmt.Errorf("%w: fee rate %d units/kB does not beat the pool", ErrMempoolFull, rate)
+	}
+
+	return evict, evictRate, nil
+}
+
+// All returns every pooled transaction, pending and queued.
+func (memo *Memopool) All() []TxInfo {
+	memo.mu.RLock()
+	defer memo.mu.RUnlock()
+
+	return memo.allLocked()
+}
+
+// Spender returns the id of the pool transaction spending output out of
+// txID.
+func (memo *Memopool) Spender(txID []byte, out int64) (string, bool) {
+	memo.mu.RLock()
+	defer memo.mu.RUnlock()
+
+	spender, ok := memo.spends[outpointKey(txID, out)]
+	return spender, ok
+}
+
+func (memo *Memopool) allLocked() []TxInfo {
+	all := make([]TxInfo, 0, len(memo.pending)+len(memo.queued))
+	all = append(all, slices.Collect(maps.Values(memo.pending))...)
+	return append(all, slices.Collect(maps.Values(memo.queued))...)
+}
+
+// Bytes returns the serialized size of every pooled transaction.
+func (memo *Memopool) Bytes() int {
+	memo.mu.RLock()
+	defer memo.mu.RUnlock()
+
+	return memo.bytesLocked()
+}
+
+func (memo *Memopool) bytesLocked() int {
+	total := 0
+	for _, info := range memo.pending {
+		total += info.Size
+	}
+	for _, info := range memo.queued {
+		total += info.Size
+	}
+
+	return total
+}
+
+// MinFeeRate is the fee rate a transaction has to pay to enter the pool:
+// MinRelayFeeRate, raised after evictions while the pool is full.
+func (memo *Memopool) MinFeeRate() int64 {
+	memo.mu.Lock()
+	defer memo.mu.Unlock()
+
+	return memo.minFeeRateLocked(time.Now())
+}
+
+func (memo *Memopool) minFeeRateLocked(now time.Time) int64 {
+	return max(memo.Policy.MinRelayFeeRate, memo.minFee.get(memo.Policy, now))
+}
+
+// Expire drops transactions that entered the pool longer than
+// Policy.Expiry before now and returns their ids.
+func (memo *Memopool) Expire(now time.Time) []string {
+	memo.mu.Lock()
+	defer memo.mu.Unlock()
+
+	return memo.expireLocked(now)
+}
+
+func (memo *Memopool) expireLocked(now time.Time) []string {
+	if memo.Policy.Expiry <= 0 {
+		return nil
+	}
+
+	// Descendants of an expired transaction can not be mined without it.
+	var expired []string
+	for _, info := range memo.allLocked() {
+		if now.Sub(info.Time) > memo.Policy.Expiry {
+			txID := hex.EncodeToString(info.Transaction.ID)
+			expired = append(expired, memo.removeWithDescendantsLocked(txID)...)
+		}
+	}
+
+	if len(expired) > 0 {
+		log.Infof("Mempool expired %d transactions", len(expired))
+	}
+
+	return expired
 }
 
 func (memo *Memopool) HasPending(txID string) bool {
-	_, exists := memo.Pending[txID]
+	memo.mu.RLock()
+	defer memo.mu.RUnlock()
+
+	_, exists := memo.pending[txID]
 	return exists
 }
 
 func (memo *Memopool) HasTX(txID string) bool {
-	if _, exists := memo.Pending[txID]; exists {
-		return true
-	}
+	memo.mu.RLock()
+	defer memo.mu.RUnlock()
 
-	if _, exists := memo.Queued[txID]; exists {
-		return true
-	}
+	return memo.hasLocked(txID)
+}
+
+func (memo *Memopool) hasLocked(txID string) bool {
+	_, exists := memo.getLocked(txID)
+	return exists
+}
+
+// PendingCount returns how many transactions wait for a miner.
+func (memo *Memopool) PendingCount() int {
+	memo.mu.RLock()
+	defer memo.mu.RUnlock()
+
+	return len(memo.pending)
+}
+
+// QueuedTxs returns the transactions picked for the block being mined.
+func (memo *Memopool) QueuedTxs() []TxInfo {
+	memo.mu.RLock()
+	defer memo.mu.RUnlock()
 
-	return false
+	return slices.Collect(maps.Values(memo.queued))
 }
 
 func (memo *Memopool) GetTransactionHashes() (txs [][]byte) {
-	for _, tx := range memo.Pending {
+	memo.mu.RLock()
+	defer memo.mu.RUnlock()
+
+	for _, tx := range memo.pending {
 		txs = append(txs, tx.Transaction.ID)
 
 	}
 	return txs
 }
 
+// RemoveFromAll drops txID and its descendants from both pools.
 func (memo *Memopool) RemoveFromAll(txID string) {
-	delete(memo.Queued, txID)
-	delete(memo.Pending, txID)
+	memo.mu.Lock()
+	defer memo.mu.Unlock()
+
+	memo.removeWithDescendantsLocked(txID)
 }
 
+// Move puts tx in the pending or queued pool. A transaction that is not in
+// the pool yet is skipped when it conflicts with one that is.
 func (memo *Memopool) Move(tx TxInfo, to string) {
+	memo.mu.Lock()
+	defer memo.mu.Unlock()
+
+	memo.moveLocked(tx, to)
+}
+
+func (memo *Memopool) moveLocked(tx TxInfo, to string) {
+	txID := hex.EncodeToString(tx.Transaction.ID)
+
+	if current, ok := memo.removeLocked(txID); ok {
+		tx.Time = current.Time
+	} else if conflicts := memo.conflictsLocked(&tx.Transaction); len(conflicts) > 0 {
+		log.Infof("Mempool skipped tx %s: conflicts with %s", txID, conflicts[0])
+		return
+	}
+
+	if tx.Time.IsZero() {
+		tx.Time = time.Now()
+	}
+
 	if to == MEMO_MOVE_FLAG_PENDING {
-		txID := hex.EncodeToString(tx.Transaction.ID)
-		memo.Remove(txID, MEMO_MOVE_FLAG_QUEUED)
-		memo.Pending[txID] = tx
+		memo.insertLocked(memo.pending, tx)
 	}
 
 	if to == MEMO_MOVE_FLAG_QUEUED {
-		txID := hex.EncodeToString(tx.Transaction.ID)
-		memo.Remove(txID, MEMO_MOVE_FLAG_PENDING)
-		memo.Queued[txID] = tx
+		memo.insertLocked(memo.queued, tx)
 	}
 }
 
 func (memo *Memopool) Remove(txID string, from string) {
+	memo.mu.Lock()
+	defer memo.mu.Unlock()
+
 	if from == MEMO_MOVE_FLAG_QUEUED {
-		delete(memo.Queued, txID)
+		if _, ok := memo.queued[txID]; ok {
+			memo.removeWithDescendantsLocked(txID)
+		}
 		return
 	}
 
 	if from == MEMO_MOVE_FLAG_PENDING {
-		delete(memo.Pending, txID)
+		if _, ok := memo.pending[txID]; ok {
+			memo.removeWithDescendantsLocked(txID)
+		}
 		return
 	}
 }
 
 func (memo *Memopool) ClearAll() {
-	memo.Pending = map[string]TxInfo{}
-	memo.Queued = map[string]TxInfo{}
+	memo.mu.Lock()
+	defer memo.mu.Unlock()
+
+	memo.pending = map[string]TxInfo{}
+	memo.queued = map[string]TxInfo{}
+	memo.spends = map[string]string{}
 }
 
-func (memo *Memopool) SelectHighFeeTx() map[string]blockchain.Transaction {
-	maxSizeBlock := blockchain.MaxBlockSize // mb
+// packageFee is the fee and size of a candidate together with its
+// unselected ancestors. version tells its current heap entry from the ones
+// pushed before the package shrank.
+type packageFee struct {
+	fee     int64
+	size    int
+	version int
+}
 
-	// Reset queue before selecting highest-fee transactions for the next block
-	memo.Queued = make(map[string]TxInfo, len(memo.Pending))
+type packageEntry struct {
+	txID    string
+	rate    int64
+	version int
+}
 
-	totalSize := 0
+// packageHeap pops the best fee rate first, ties go to the lower ID so the
+// selection does not depend on map order.
+type packageHeap []packageEntry
 
-	txPendings := slices.Collect(maps.Values(memo.Pending))
+func (h packageHeap) Len() int { return len(h) }
 
-	sort.Slice(txPendings, func(i, j int) bool {
-		return txPendings[i].Fee > txPendings[j].Fee
-	})
+func (h packageHeap) Less(i, j int) bool {
+	if h[i].rate != h[j].rate {
+		return h[i].rate > h[j].rate
+	}
+	return h[i].txID < h[j].txID
+}
 
-	txs := make(map[string]blockchain.Transaction, 0)
+func (h packageHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
 
-	for _, tx := range txPendings {
+func (h *packageHeap) Push(x any) { *h = append(*h, x.(packageEntry)) }
 
-		buf := new(bytes.Buffer)
-		blockchain.SerializeTransaction(&tx.Transaction, buf)
+func (h *packageHeap) Pop() any {
+	old := *h
+	entry := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return entry
+}
 
-		txSize := len(buf.Bytes())
-		totalSize += txSize
+// SelectHighFeeTx queues the pending transactions for the next block and
+// returns them with parents before their children. It repeatedly takes the
+// transaction whose ancestor package, itself and its unselected ancestors,
+// pays the best fee rate, so a child paying a high fee pulls in the parents
+// it depends on. Taking a package only changes the packages of its
+// descendants, so only those are recomputed.
+func (memo *Memopool) SelectHighFeeTx() []blockchain.Transaction {
+	memo.mu.Lock()
+	defer memo.mu.Unlock()
 
-		if totalSize > maxSizeBlock {
-			break
+	maxSizeBlock := blockchain.MaxBlockSize // mb
+
+	// Return the previous selection before choosing the next block
+	for txID, info := range memo.queued {
+		memo.pending[txID] = info
+	}
+	memo.queued = make(map[string]TxInfo, len(memo.pending))
+
+	candidates := make(map[string]*packageFee, len(memo.pending))
+	packages := make(packageHeap, 0, len(memo.pending))
+	for txID, info := range memo.pending {
+		pf := &packageFee{}
+		for _, p := range memo.packageLocked(info, nil) {
+			pf.fee += p.Fee
+			pf.size += p.Size
+		}
+		candidates[txID] = pf
+		packages = append(packages, packageEntry{txID: txID, rate: FeeRate(pf.fee, pf.size)})
+	}
+	heap.Init(&packages)
+
+	selected := make(map[string]bool)
+	var block []TxInfo
+	totalSize := 0
+
+	for packages.Len() > 0 {
+		entry := heap.Pop(&packages).(packageEntry)
+		pf, ok := candidates[entry.txID]
+		if !ok || pf.version != entry.version {
+			continue
 		}
+		delete(candidates, entry.txID)
 
-		memo.Move(tx, MEMO_MOVE_FLAG_QUEUED)
+		if totalSize+pf.size > maxSizeBlock {
+			continue
+		}
+		totalSize += pf.size
+
+		info, _ := memo.getLocked(entry.txID)
+		pkg := memo.packageLocked(info, selected)
+		for _, p := range pkg {
+			txID := hex.EncodeToString(p.Transaction.ID)
+			selected[txID] = true
+			delete(candidates, txID)
+			block = append(block, p)
+		}
+
+		updated := make(map[string]bool)
+		for _, p := range pkg {
+			for childID := range memo.descendantsLocked(hex.EncodeToString(p.Transaction.ID)) {
+				child, ok := candidates[childID]
+				if !ok {
+					continue
+				}
+				child.fee -= p.Fee
+				child.size -= p.Size
+				updated[childID] = true
+			}
+		}
+
+		for txID := range updated {
+			child := candidates[txID]
+			child.version++
+			heap.Push(&packages, packageEntry{txID: txID, rate: FeeRate(child.fee, child.size), version: child.version})
+		}
+	}
 
-		txs[hex.EncodeToString(tx.Transaction.ID)] = tx.Transaction
+	txs := make([]blockchain.Transaction, 0, len(block))
+	for _, tx := range block {
+		memo.moveLocked(tx, MEMO_MOVE_FLAG_QUEUED)
+		txs = append(txs, tx.Transaction)
 	}
 
 	return txs
@@ -0,0 +1,105 @@
+package memopool
+
+import (
+	blockchain "core-blockchain/core"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"time"
+)
+
+const savedPoolVersion = 1
+
+var ErrSavedPoolVersion = errors.New("unknown saved mempool version")
+
+// SavedTx is a pool transaction as Save writes it, Time is when it entered
+// the pool.
+type SavedTx struct {
+	Transaction blockchain.Transaction
+	Time        time.Time
+}
+
+type savedPool struct {
+	Version int
+	Txs     []SavedTx
+}
+
+// Save writes every pooled transaction to file, parents before children,
+// and returns how many it wrote.
+func (memo *Memopool) Save(file string) (int, error) {
+	memo.mu.RLock()
+	txs := memo.sortedLocked()
+	memo.mu.RUnlock()
+
+	saved := savedPool{Version: savedPoolVersion, Txs: make([]SavedTx, 0, len(txs))}
+	for _, info := range txs {
+		saved.Txs = append(saved.Txs, SavedTx{Transaction: info.Transaction, Time: info.Time})
+	}
+
+	data, err := blockchain.GobEncode(saved)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := os.MkdirAll(path.Dir(file), 0o755); err != nil {
+		return 0, err
+	}
+
+	// Write aside first so a crash never leaves a truncated file behind.
+	tmp := file + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return 0, err
+	}
+	if err := os.Rename(tmp, file); err != nil {
+		return 0, err
+	}
+
+	return len(saved.Txs), nil
+}
+
+// ReadSaved returns the transactions Save wrote to file in the order it
+// wrote them, none when there is no file.
+func ReadSaved(file string) ([]SavedTx, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	saved, err := blockchain.GobDecode[savedPool](data)
+	if err != nil {
+		return nil, err
+	}
+
+	if saved.Version != savedPoolVersion {
+		return nil, fmt.Errorf("%w: %d", ErrSavedPoolVersion, saved.Version)
+	}
+
+	return saved.Txs, nil
+}
+
+// sortedLocked returns every pooled transaction, oldest first but parents
+// before their children.
+func (memo *Memopool) sortedLocked() []TxInfo {
+	all := memo.allLocked()
+	sort.SliceStable(all, func(i, j int) bool {
+		return all[i].Time.Before(all[j].Time)
+	})
+
+	done := make(map[string]bool, len(all))
+	sorted := make([]TxInfo, 0, len(all))
+
+	for _, info := range all {
+		for _, tx := range memo.packageLocked(info, done) {
+			done[hex.EncodeToString(tx.Transaction.ID)] = true
+			sorted = append(sorted, tx)
+		}
+	}
+
+	return sorted
+}
@@ -0,0 +1,122 @@
+package memopool
+
+import (
+	"core-blockchain/common/env"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+var conf = env.New()
+
+var (
+	ErrInvalidTx     = errors.New("transaction failed validation")
+	ErrAlreadyInPool = errors.New("transaction already in mempool")
+	ErrTxTooLarge    = errors.New("transaction larger than the mempool")
+	ErrFeeTooLow     = errors.New("mempool min fee not met")
+	ErrMempoolFull   = errors.New("mempool full")
+	ErrMissingParent = errors.New("unconfirmed parent left the mempool")
+	ErrTooLongChain  = errors.New("too many unconfirmed ancestors or descendants")
+
+	ErrReplacementRejected = errors.New("replacement rejected")
+)
+
+const (
+	// DefaultMaxChain bounds the unconfirmed chains a transaction can join.
+	DefaultMaxChain = 25
+	// DefaultMaxReplacements bounds what one replacement can evict.
+	DefaultMaxReplacements = 100
+)
+
+// Policy bounds the mempool. Fee rates are base units per 1000 bytes of
+// serialized transaction.
+type Policy struct {
+	// MaxBytes caps the serialized size of every pooled transaction.
+	MaxBytes int
+	// Expiry drops transactions that stayed this long without confirming.
+	Expiry time.Duration
+	// MinRelayFeeRate is the lowest fee rate accepted when the pool has
+	// room.
+	MinRelayFeeRate int64
+	// IncrementalFeeRate is added to the fee rate of an evicted
+	// transaction to form the dynamic minimum fee rate.
+	IncrementalFeeRate int64
+	// MinFeeHalfLife is how fast the dynamic minimum decays back to
+	// MinRelayFeeRate once the pool stops evicting.
+	MinFeeHalfLife time.Duration
+	// MaxAncestors caps a transaction and its pool ancestors.
+	MaxAncestors int
+	// MaxDescendants caps a pool transaction and its pool descendants.
+	MaxDescendants int
+	// FullRBF lets a transaction paying more replace pool transactions
+	// spending the same outputs. Transactions carry no replaceability
+	// flag, so with it on any unconfirmed transaction can be replaced by
+	// whoever can sign its inputs. It is off by default.
+	FullRBF bool
+	// MaxReplacements caps the transactions one replacement evicts,
+	// descendants included.
+	MaxReplacements int
+}
+
+// DefaultPolicy reads MEMPOOL_MAX_MB, MEMPOOL_EXPIRY_HOURS,
+// MIN_RELAY_FEE_RATE and MEMPOOL_FULL_RBF from the environment.
+func DefaultPolicy() Policy {
+	return Policy{
+		MaxBytes:           int(conf.MempoolMaxMB) << 20,
+		Expiry:             time.Duration(conf.MempoolExpiryHours) * time.Hour,
+		MinRelayFeeRate:    conf.MinRelayFeeRate,
+		IncrementalFeeRate: conf.MinRelayFeeRate,
+		MinFeeHalfLife:     12 * time.Hour,
+		MaxAncestors:       DefaultMaxChain,
+		MaxDescendants:     DefaultMaxChain,
+		FullRBF:            conf.MempoolFullRBF,
+		MaxReplacements:    DefaultMaxReplacements,
+	}
+}
+
+// FeeRate returns fee per 1000 bytes of a transaction of size bytes.
+func FeeRate(fee int64, size int) int64 {
+	if size <= 0 {
+		return 0
+	}
+
+	return fee * 1000 / int64(size)
+}
+
+// rollingMinFee is the dynamic part of the minimum fee rate. It jumps above
+// the fee rate of every evicted transaction and halves every half life
+// after that.
+type rollingMinFee struct {
+	rate    float64
+	updated time.Time
+}
+
+func (r *rollingMinFee) get(policy Policy, now time.Time) int64 {
+	if r.rate == 0 {
+		return 0
+	}
+
+	if policy.MinFeeHalfLife > 0 {
+		halvings := float64(now.Sub(r.updated)) / float64(policy.MinFeeHalfLife)
+		r.rate /= math.Pow(2, halvings)
+		r.updated = now
+	}
+
+	if r.rate < float64(policy.IncrementalFeeRate)/2 {
+		r.rate = 0
+	}
+
+	return int64(math.Ceil(r.rate))
+}
+
+func (r *rollingMinFee) bump(rate int64, now time.Time) {
+	if float64(rate) > r.rate {
+		r.rate = float64(rate)
+	}
+	r.updated = now
+}
+
+func rejectFeeTooLow(rate, min int64) error {
+	return fmt.Errorf("%w: fee rate %d < %d units/kB", ErrFeeTooLow, rate, min)
+}
@@ -0,0 +1,285 @@
+package p2p
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/control"
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	ma "github.com/multiformats/go-multiaddr"
+	log "github.com/sirupsen/logrus"
+)
+
+const bannedFileName = "banned_peers.json"
+
+var (
+	BanThreshold   = 100
+	DefaultBanTime = 24 * time.Hour
+)
+
+var ErrInvalidPeerID = errors.New("invalid peer id")
+
+type BanEntry struct {
+	PeerID    string
+	Reason    string
+	CreatedAt time.Time
+	BanUntil  time.Time
+}
+
+type BanList struct {
+	Peers []BanEntry
+}
+
+// BanManager keeps a misbehavior score per peer and bans peers whose score
+// reaches BanThreshold. Bans outlive restarts through its file. It is
+// plugged into the host as connection gater and into pubsub as blacklist, so
+// banned peers can neither connect nor get messages through.
+type BanManager struct {
+	mu     sync.Mutex
+	host   host.Host
+	file   string
+	scores map[string]int
+	bans   map[string]BanEntry
+}
+
+// NewBanManager loads the bans stored in file.
+func NewBanManager(file string) *BanManager {
+	bm := &BanManager{
+		file:   file,
+		scores: make(map[string]int),
+		bans:   make(map[string]BanEntry),
+	}
+
+	list, err := loadBanList(file)
+	if err != nil {
+		log.Warnf("Failed to load ban list: %v", err)
+		return bm
+	}
+
+	now := time.Now()
+	for _, entry := range list.Peers {
+		if now.Before(entry.BanUntil) {
+			bm.bans[entry.PeerID] = entry
+		}
+	}
+
+	return bm
+}
+
+func loadBanList(file string) (*BanList, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return &BanList{Peers: []BanEntry{}}, nil
+		}
+		return nil, err
+	}
+
+	var list BanList
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, err
+	}
+
+	return &list, nil
+}
+
+func (bm *BanManager) saveLocked() error {
+	list := BanList{Peers: bm.activeLocked()}
+
+	data, err := json.MarshalIndent(list, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(path.Dir(bm.file), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(bm.file, data, 0o644)
+}
+
+// activeLocked drops expired bans and returns the others, soonest to expire
+// first.
+func (bm *BanManager) activeLocked() []BanEntry {
+	now := time.Now()
+	entries := make([]BanEntry, 0, len(bm.bans))
+
+	for id, entry := range bm.bans {
+		if !now.Before(entry.BanUntil) {
+			delete(bm.bans, id)
+			continue
+		}
+		entries = append(entries, entry)
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].BanUntil.Before(entries[j].BanUntil)
+	})
+
+	return entries
+}
+
+// SetHost gives the manager the host whose connections it closes on ban.
+func (bm *BanManager) SetHost(h host.Host) {
+	bm.mu.Lock()
+	defer bm.mu.Unlock()
+
+	bm.host = h
+}
+
+// Misbehaving adds howMuch to the score of pID and bans it for
+// DefaultBanTime once the score reaches BanThreshold. It reports whether the
+// peer got banned.
+func (bm *BanManager) Misbehaving(pID string, howMuch int, reason string) bool {
+	if pID == "" {
+		return false
+	}
+
+	bm.mu.Lock()
+	bm.scores[pID] += howMuch
+	score := bm.scores[pID]
+	bm.mu.Unlock()
+
+	log.Warnf("[PEER::MISBEHAVING] Peer %s: %s (+%d, score %d/%d)", pID, reason, howMuch, score, BanThreshold)
+
+	if score < BanThreshold {
+		return false
+	}
+
+	if err := bm.Ban(pID, DefaultBanTime, reason); err != nil {
+		log.Errorf("[PEER::MISBEHAVING] Failed to ban peer %s: %v", pID, err)
+	}
+
+	return true
+}
+
+// Ban records a ban of pID for duration and disconnects it.
+func (bm *BanManager) Ban(pID string, duration time.Duration, reason string) error {
+	id, err := peer.Decode(pID)
+	if err != nil {
+		return ErrInvalidPeerID
+	}
+
+	now := time.Now()
+
+	bm.mu.Lock()
+	if bm.host != nil && id == bm.host.ID() {
+		bm.mu.Unlock()
+		return ErrInvalidPeerID
+	}
+
+	bm.bans[pID] = BanEntry{
+		PeerID:    pID,
+		Reason:    reason,
+		CreatedAt: now,
+		BanUntil:  now.Add(duration),
+	}
+	delete(bm.scores, pID)
+	err = bm.saveLocked()
+	h := bm.host
+	bm.mu.Unlock()
+
+	log.Warnf("[PEER::BAN] Banned peer %s until %s: %s", pID, now.Add(duration).Format(time.RFC3339), reason)
+
+	if h != nil {
+		if e := h.Network().ClosePeer(id); e != nil {
+			log.Warnf("[PEER::BAN] Failed to disconnect peer %s: %v", pID, e)
+		}
+	}
+
+	return err
+}
+
+// Unban lifts the ban of pID. It reports whether the peer was banned.
+func (bm *BanManager) Unban(pID string) (bool, error) {
+	bm.mu.Lock()
+	defer bm.mu.Unlock()
+
+	if _, ok := bm.bans[pID]; !ok {
+		return false, nil
+	}
+
+	delete(bm.bans, pID)
+	return true, bm.saveLocked()
+}
+
+// ClearBanned lifts every ban and returns how many were active.
+func (bm *BanManager) ClearBanned() (int, error) {
+	bm.mu.Lock()
+	defer bm.mu.Unlock()
+
+	count := len(bm.activeLocked())
+	bm.bans = make(map[string]BanEntry)
+	bm.scores = make(map[string]int)
+
+	return count, bm.saveLocked()
+}
+
+func (bm *BanManager) ListBanned() []BanEntry {
+	bm.mu.Lock()
+	defer bm.mu.Unlock()
+
+	return bm.activeLocked()
+}
+
+func (bm *BanManager) IsBanned(pID string) bool {
+	bm.mu.Lock()
+	defer bm.mu.Unlock()
+
+	entry, ok := bm.bans[pID]
+	return ok && time.Now().Before(entry.BanUntil)
+}
+
+// Misbehaving scores pID on behalf of a message handler and forgets its sync
+// status once it gets banned.
+func (net *Network) Misbehaving(pID string, howMuch int, reason string) {
+	if pID == "" || pID == net.Host.ID().String() {
+		return
+	}
+
+	if net.Bans.Misbehaving(pID, howMuch, reason) {
+		net.syncManager.RemovePeer(pID)
+	}
+}
+
+// Add implements pubsub.Blacklist.
+func (bm *BanManager) Add(p peer.ID) bool {
+	return bm.Ban(p.String(), DefaultBanTime, "blacklisted by pubsub") == nil
+}
+
+// Contains implements pubsub.Blacklist.
+func (bm *BanManager) Contains(p peer.ID) bool {
+	return bm.IsBanned(p.String())
+}
+
+// InterceptPeerDial implements connmgr.ConnectionGater.
+func (bm *BanManager) InterceptPeerDial(p peer.ID) bool {
+	return !bm.IsBanned(p.String())
+}
+
+// InterceptAddrDial implements connmgr.ConnectionGater.
+func (bm *BanManager) InterceptAddrDial(p peer.ID, _ ma.Multiaddr) bool {
+	return !bm.IsBanned(p.String())
+}
+
+// InterceptAccept implements connmgr.ConnectionGater. The remote peer is not
+// known before the security handshake, see InterceptSecured.
+func (bm *BanManager) InterceptAccept(network.ConnMultiaddrs) bool {
+	return true
+}
+
+// InterceptSecured implements connmgr.ConnectionGater.
+func (bm *BanManager) InterceptSecured(_ network.Direction, p peer.ID, _ network.ConnMultiaddrs) bool {
+	return !bm.IsBanned(p.String())
+}
+
+// InterceptUpgraded implements connmgr.ConnectionGater.
+func (bm *BanManager) InterceptUpgraded(network.Conn) (bool, control.DisconnectReason) {
+	return true, 0
+}
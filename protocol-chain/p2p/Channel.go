@@ -29,6 +29,11 @@ type ChannelContent struct {
 	SendFrom string
 	SendTo   string
 	Payload  []byte
+
+	// Origin is the authenticated sender, set on receipt. Direct marks
+	// messages that came over SYNC_PROTOCOL_ID rather than GossipSub.
+	Origin peer.ID `json:"-"`
+	Direct bool    `json:"-"`
 }
 
 func JoinChannel(ctx context.Context, pub *pubsub.PubSub, selfId peer.ID, channelName string, subscribe bool) (*Channel, error) {
@@ -103,6 +108,8 @@ func (channel *Channel) HandleContent(content *pubsub.Message) {
 		return
 	}
 
+	NewContent.Origin = content.GetFrom()
+
 	select {
 	case channel.Content <- NewContent:
 	default:
@@ -0,0 +1,322 @@
+package p2p
+
+import (
+	blockchain "core-blockchain/core"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// blockRequest tracks one block body of the best header chain until it is
+// connected.
+type blockRequest struct {
+	node     *blockchain.HeaderNode
+	peerID   string
+	sentAt   time.Time
+	attempts int
+
+	block *blockchain.Block
+	from  string
+}
+
+// badBlockError reports a downloaded block that could not be connected along
+// with the peer that sent it.
+type badBlockError struct {
+	peerID string
+	err    error
+}
+
+func (e *badBlockError) Error() string {
+	return e.err.Error()
+}
+
+func (e *badBlockError) Unwrap() error {
+	return e.err
+}
+
+// BlockDownloader fetches the bodies of the best header chain from every
+// synced peer at once. Each peer has at most MAX_BLOCKS_IN_FLIGHT_PER_PEER
+// outstanding requests, requests that are not answered within
+// BLOCK_REQUEST_TIMEOUT go to another peer, and bodies arriving out of order
+// wait until their parent is connected.
+type BlockDownloader struct {
+	mu      sync.Mutex
+	connect sync.Mutex
+	net     *Network
+
+	queue    []*blockRequest // ordered by height
+	requests map[string]*blockRequest
+	inFlight map[string]int
+	stalled  map[string]time.Time
+
+	quit chan struct{}
+}
+
+func NewBlockDownloader(net *Network) *BlockDownloader {
+	d := &BlockDownloader{
+		net:      net,
+		requests: make(map[string]*blockRequest),
+		inFlight: make(map[string]int),
+		stalled:  make(map[string]time.Time),
+		quit:     make(chan struct{}),
+	}
+	go d.timeoutLoop()
+	return d
+}
+
+func (d *BlockDownloader) Stop() {
+	close(d.quit)
+}
+
+// Enqueue adds headers whose bodies are missing. Headers that are already
+// queued are skipped.
+func (d *BlockDownloader) Enqueue(nodes []*blockchain.HeaderNode) int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	added := 0
+	for _, node := range nodes {
+		if len(d.queue) >= BLOCK_DOWNLOAD_WINDOW {
+			break
+		}
+
+		key := hex.EncodeToString(node.Hash)
+		if _, ok := d.requests[key]; ok {
+			continue
+		}
+
+		req := &blockRequest{node: node}
+		d.requests[key] = req
+		d.queue = append(d.queue, req)
+		added++
+	}
+
+	sort.SliceStable(d.queue, func(i, j int) bool {
+		return d.queue[i].node.Height < d.queue[j].node.Height
+	})
+
+	return added
+}
+
+// Pending reports how many blocks are queued, requested or waiting to be
+// connected.
+func (d *BlockDownloader) Pending() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return len(d.queue)
+}
+
+// Reset drops every queued request, late answers are ignored.
+func (d *BlockDownloader) Reset() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.resetLocked()
+}
+
+func (d *BlockDownloader) resetLocked() {
+	d.queue = nil
+	d.requests = make(map[string]*blockRequest)
+	d.inFlight = make(map[string]int)
+}
+
+// Schedule hands unassigned blocks to peers with free slots. A peer only
+// gets blocks its reported chain work covers.
+func (d *BlockDownloader) Schedule() {
+	const logName = "[SYNC::DOWNLOAD]"
+
+	connected := make(map[string]bool)
+	for _, p := range d.net.FullNodesChannel.ListPeers() {
+		connected[p.String()] = true
+	}
+
+	peers := d.net.syncManager.Peers()
+	sort.Slice(peers, func(i, j int) bool {
+		return peers[i].TotalWork.Cmp(peers[j].TotalWork) > 0
+	})
+
+	batches := make(map[string][][]byte)
+
+	d.mu.Lock()
+	now := time.Now()
+
+	for _, p := range peers {
+		if !connected[p.ID] {
+			continue
+		}
+		if until, ok := d.stalled[p.ID]; ok {
+			if now.Before(until) {
+				continue
+			}
+			delete(d.stalled, p.ID)
+		}
+
+		for _, req := range d.queue {
+			if d.inFlight[p.ID] >= MAX_BLOCKS_IN_FLIGHT_PER_PEER {
+				break
+			}
+			if req.peerID != "" || req.block != nil {
+				continue
+			}
+			if p.TotalWork.Cmp(req.node.ChainWork) < 0 {
+				break
+			}
+
+			req.peerID = p.ID
+			req.sentAt = now
+			req.attempts++
+			d.inFlight[p.ID]++
+			batches[p.ID] = append(batches[p.ID], req.node.Hash)
+		}
+	}
+	d.mu.Unlock()
+
+	for peerID, hashes := range batches {
+		log.Infof("%s Requesting %d blocks from peer %s", logName, len(hashes), peerID)
+		d.net.SendGetDataSync(peerID, NetGetDataSync{
+			SendFrom: d.net.Host.ID().String(),
+			Hashes:   hashes,
+		})
+	}
+}
+
+// BlockReceived stores the bodies sent by peerID and connects every block
+// whose predecessors are in. It returns how many blocks were expected and an
+// error when one of them could not be connected, in which case the queue is
+// dropped.
+func (d *BlockDownloader) BlockReceived(peerID string, blocks []blockchain.Block) (int, error) {
+	const logName = "[SYNC::DOWNLOAD]"
+
+	accepted := 0
+
+	d.mu.Lock()
+	for i := range blocks {
+		req, ok := d.requests[hex.EncodeToString(blocks[i].Hash)]
+		if !ok || req.block != nil {
+			continue
+		}
+
+		if req.peerID != "" {
+			d.inFlight[req.peerID]--
+		}
+		req.peerID = ""
+		req.block = &blocks[i]
+		req.from = peerID
+		accepted++
+	}
+	d.mu.Unlock()
+
+	if accepted == 0 {
+		log.Warnf("%s Ignored %d unrequested blocks from peer %s", logName, len(blocks), peerID)
+		return 0, nil
+	}
+
+	log.Infof("%s Received %d blocks from peer %s", logName, accepted, peerID)
+
+	return accepted, d.connectReady()
+}
+
+// connectReady adds the leading run of downloaded blocks to the chain.
+func (d *BlockDownloader) connectReady() error {
+	const logName = "[SYNC::DOWNLOAD]"
+
+	d.connect.Lock()
+	defer d.connect.Unlock()
+
+	for {
+		d.mu.Lock()
+		if len(d.queue) == 0 || d.queue[0].block == nil {
+			d.mu.Unlock()
+			return nil
+		}
+		req := d.queue[0]
+		d.queue = d.queue[1:]
+		delete(d.requests, hex.EncodeToString(req.node.Hash))
+		d.mu.Unlock()
+
+		block := req.block
+
+		hasBlock, err := d.net.Blockchain.HasBlock(block.Hash)
+		if err != nil {
+			return fmt.Errorf("check block %x at height %d: %w", block.Hash[:6], block.Height, err)
+		}
+
+		if hasBlock {
+			log.Debugf("%s Skipped block %d (%x): already exists", logName, block.Height, block.Hash[:6])
+			continue
+		}
+
+		if err := d.net.Blockchain.AddBlock(block, d.net.HandleReoganizeTx); err != nil {
+			d.mu.Lock()
+			d.stalled[req.from] = time.Now().Add(BLOCK_REQUEST_TIMEOUT)
+			d.resetLocked()
+			d.mu.Unlock()
+
+			return &badBlockError{
+				peerID: req.from,
+				err:    fmt.Errorf("add block %d (%x) from peer %s: %w", block.Height, block.Hash[:6], req.from, err),
+			}
+		}
+
+		log.Infof("%s Added block %d (%x) to chain", logName, block.Height, block.Hash[:6])
+		MemoryPool.RemoveForBlock(block)
+	}
+}
+
+// expire takes back requests that timed out and keeps their peers out of
+// scheduling for one timeout period. It reports whether a block ran out of
+// retries, which drops the whole queue.
+func (d *BlockDownloader) expire() bool {
+	const logName = "[SYNC::DOWNLOAD]"
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	now := time.Now()
+	for _, req := range d.queue {
+		if req.peerID == "" || now.Sub(req.sentAt) < BLOCK_REQUEST_TIMEOUT {
+			continue
+		}
+
+		log.Warnf("%s Request for block %d (%x) to peer %s timed out", logName, req.node.Height, req.node.Hash[:6], req.peerID)
+
+		d.inFlight[req.peerID]--
+		d.stalled[req.peerID] = now.Add(BLOCK_REQUEST_TIMEOUT)
+		req.peerID = ""
+
+		if req.attempts >= BLOCK_DOWNLOAD_MAX_RETRIES {
+			log.Warnf("%s Giving up on block %d (%x) after %d attempts", logName, req.node.Height, req.node.Hash[:6], req.attempts)
+			d.resetLocked()
+			return true
+		}
+	}
+
+	return false
+}
+
+func (d *BlockDownloader) timeoutLoop() {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if d.Pending() == 0 {
+				continue
+			}
+			if d.expire() {
+				// Start over from a fresh header exchange.
+				go d.net.HandleRequestSync()
+				continue
+			}
+			d.Schedule()
+		case <-d.quit:
+			return
+		}
+	}
+}
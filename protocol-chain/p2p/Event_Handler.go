@@ -7,6 +7,7 @@ import (
 	"core-blockchain/memopool"
 	"encoding/gob"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"math/big"
 	"time"
@@ -108,10 +109,11 @@ func (net *Network) HandleTxMining(content *ChannelContent) {
 	err := dec.Decode(&payload)
 	if err != nil {
 		log.Error("Error decoding transaction request: ", err)
+		net.Misbehaving(content.Origin.String(), PENALTY_MALFORMED_MESSAGE, "malformed mining transactions")
 		return
 	}
 	for _, tx := range payload.Txs {
-		txInfo := memopool.GetTxInfo(&tx, net.Blockchain)
+		txInfo := MemoryPool.GetTxInfo(&tx, net.Blockchain)
 		if txInfo == nil {
 			continue
 		}
@@ -128,6 +130,7 @@ func (net *Network) HandleTx(content *ChannelContent) {
 	dec := gob.NewDecoder(buff)
 	if err := dec.Decode(&payload); err != nil {
 		log.Error("❌ Transaction handling aborted — failed to decode Tx payload")
+		net.Misbehaving(content.Origin.String(), PENALTY_MALFORMED_MESSAGE, "malformed transaction")
 		return
 	}
 
@@ -154,14 +157,21 @@ func (net *Network) HandleTx(content *ChannelContent) {
 
 	txID := hex.EncodeToString(newTx.ID)
 	if !MemoryPool.HasTX(txID) {
-		txInfo := memopool.GetTxInfo(newTx, net.Blockchain)
-		if txInfo == nil {
-			log.Error("🚫 Transaction rejected — invalid or failed validation")
+		replaced, err := net.AcceptTx(newTx)
+		if err != nil {
+			if errors.Is(err, memopool.ErrInvalidTx) {
+				log.Error("🚫 Transaction rejected — invalid or failed validation")
+				net.Misbehaving(content.Origin.String(), PENALTY_INVALID_TX, "invalid transaction")
+				return
+			}
+			log.Infof("Transaction %s not accepted by mempool policy: %v", txID, err)
 			return
 		}
 
-		MemoryPool.Add(*txInfo)
-		log.Infof("✅ Transaction accepted and added to mempool — current size: %d", len(MemoryPool.Pending))
+		log.Infof("✅ Transaction accepted and added to mempool — current size: %d", MemoryPool.PendingCount())
+		if len(replaced) > 0 {
+			log.Infof("Transaction %s replaced %d transactions", txID, len(replaced))
+		}
 
 	}
 
@@ -191,63 +201,50 @@ func (net *Network) HandleGetBlockDataSync(content *ChannelContent) {
 	dec := gob.NewDecoder(buf)
 	if err := dec.Decode(&payload); err != nil {
 		log.Errorf("%s Aborted: cannot decode block data from peer %s", logName, content.SendFrom)
+		net.Misbehaving(content.Origin.String(), PENALTY_MALFORMED_MESSAGE, "malformed block data")
 		return
 	}
 
-	bestPeer := net.syncManager.GetTargetPeer()
-	if payload.SendFrom != bestPeer.ID {
-		log.Warnf("%s Ignored block data from %s (expected best peer %s)", logName, payload.SendFrom, bestPeer.ID)
-		return
-	}
+	// Scoring and scheduling go by the stream the blocks came in on,
+	// SendFrom is whatever the sender chose to put there.
+	from := content.Origin.String()
 
-	log.Infof("%s Received %d blocks from best peer %s (peer height=%d)", logName, len(payload.Blocks), payload.SendFrom, bestPeer.Height)
-
-	isBadPeer := false
-
-	for i := len(payload.Blocks) - 1; i >= 0; i-- {
-		block := payload.Blocks[i]
-
-		if bestPeer.ID != payload.SendFrom {
-			log.Warnf("%s Peer %s no longer matches best peer %s, aborting loop", logName, payload.SendFrom, bestPeer.ID)
-			break
+	accepted, err := net.downloader.BlockReceived(from, payload.Blocks)
+	if err == nil {
+		if accepted == 0 {
+			net.Misbehaving(from, PENALTY_UNSOLICITED_DATA, "unsolicited block data")
+			return
 		}
 
-		hasBlock, err := net.Blockchain.HasBlock(block.Hash)
-		if err != nil {
-			log.Errorf("%s Failed to check block %x at height %d: %v", logName, block.Hash[:6], block.Height, err)
+		if net.downloader.Pending() > 0 {
+			net.downloader.Schedule()
 			return
 		}
 
-		if hasBlock {
-			log.Infof("%s Skipped block %d (%x): already exists", logName, block.Height, block.Hash[:6])
-			continue
-		}
+		net.requestMissingBlocks(logName, from)
+		return
+	}
 
-		if err := net.Blockchain.AddBlock(&block, net.HandleReoganizeTx); err != nil {
-			log.Errorf("%s Cannot add block %d (%x): %v", logName, block.Height, block.Hash[:6], err)
-			isBadPeer = true
-			break
-		}
+	log.Errorf("%s %v", logName, err)
 
-		log.Infof("%s Added block %d (%x) to chain", logName, block.Height, block.Hash[:6])
+	badPeer := from
+	var blockErr *badBlockError
+	if errors.As(err, &blockErr) {
+		badPeer = blockErr.peerID
+		net.Misbehaving(badPeer, PENALTY_INVALID_BLOCK, "invalid block")
 	}
 
+	if bestPeer := net.syncManager.GetTargetPeer(); bestPeer != nil && bestPeer.ID == badPeer {
+		net.syncManager.ClearTarget()
+	}
+	log.Warnf("%s Marked peer %s as bad and cleared download queue", logName, badPeer)
+
 	locator, err := net.Blockchain.GetBlockLocator()
 	if err != nil {
 		log.Errorf("%s Failed to build block locator: %v", logName, err)
 		return
 	}
 
-	excludePeerIDs := []string{net.Host.ID().String()}
-
-	if isBadPeer {
-		net.syncManager.ClearTarget()
-		excludePeerIDs = append(excludePeerIDs, payload.SendFrom)
-		log.Warnf("%s Marked peer %s as bad and cleared target", logName, payload.SendFrom)
-	}
-
-	time.Sleep(time.Second)
-
 	bestHeight, err := net.Blockchain.GetBestHeight()
 	if err != nil {
 		log.Errorf("%s Failed to get best height: %v", logName, err)
@@ -257,7 +254,7 @@ func (net *Network) HandleGetBlockDataSync(content *ChannelContent) {
 	log.Infof("%s Broadcasting header locator (best height=%d)", logName, bestHeight)
 	net.Gossip.Broadcast(
 		net.FullNodesChannel.ListPeers(),
-		excludePeerIDs,
+		[]string{net.Host.ID().String(), badPeer},
 		func(p peer.ID) {
 			net.SendHeaderLocator(p.String(), NetHeaderLocator{
 				SendFrom:   net.Host.ID().String(),
@@ -269,7 +266,7 @@ func (net *Network) HandleGetBlockDataSync(content *ChannelContent) {
 	)
 }
 
-func (net *Network) HandleGetDataSync(content *ChannelContent) {
+func (net *Network) HandleGetDataSync(content *ChannelContent) []byte {
 	const logName = "[SYNC::GET_DATA]"
 
 	buf := new(bytes.Buffer)
@@ -279,7 +276,8 @@ func (net *Network) HandleGetDataSync(content *ChannelContent) {
 	dec := gob.NewDecoder(buf)
 	if err := dec.Decode(&payload); err != nil {
 		log.Errorf("%s Aborted: failed to decode data request from peer %s", logName, content.SendFrom)
-		return
+		net.Misbehaving(content.Origin.String(), PENALTY_MALFORMED_MESSAGE, "malformed block data request")
+		return nil
 	}
 
 	log.Infof("%s Received data request from peer %s (%d block hashes)", logName, payload.SendFrom, len(payload.Hashes))
@@ -289,7 +287,7 @@ func (net *Network) HandleGetDataSync(content *ChannelContent) {
 		block, err := net.Blockchain.GetBlockMainChain(blockByte)
 		if err != nil {
 			log.Errorf("%s Failed to retrieve block for hash %x: %v", logName, blockByte[:6], err)
-			return
+			return nil
 		}
 		blocks = append(blocks, BlockForNetwork(block))
 		log.Debugf("%s Prepared block %d (%x) for response", logName, block.Height, block.Hash[:6])
@@ -298,16 +296,16 @@ func (net *Network) HandleGetDataSync(content *ChannelContent) {
 	bestHeight, err := net.Blockchain.GetBestHeight()
 	if err != nil {
 		log.Errorf("%s Failed to get best height: %v", logName, err)
-		return
+		return nil
 	}
 
-	net.SendBlockDataSync(payload.SendFrom, NetBlockSync{
+	log.Infof("%s Sending %d requested blocks to peer %s (best height=%d)", logName, len(blocks), payload.SendFrom, bestHeight)
+
+	return EncodeMessage(PREFIX_BLOCK_SYNC, NetBlockSync{
 		SendFrom:   net.Host.ID().String(),
 		BestHeight: bestHeight,
 		Blocks:     blocks,
 	})
-
-	log.Infof("%s Sent %d requested blocks to peer %s (best height=%d)", logName, len(blocks), payload.SendFrom, bestHeight)
 }
 
 func (net *Network) HandleGetHeaderSync(content *ChannelContent) {
@@ -320,9 +318,12 @@ func (net *Network) HandleGetHeaderSync(content *ChannelContent) {
 	dec := gob.NewDecoder(buf)
 	if err := dec.Decode(&payload); err != nil {
 		log.Errorf("%s Decode failed: invalid header data from peer", logName)
+		net.Misbehaving(content.Origin.String(), PENALTY_MALFORMED_MESSAGE, "malformed headers")
 		return
 	}
 
+	from := content.Origin.String()
+
 	bestHeight, err := net.Blockchain.GetBestHeight()
 	if err != nil {
 		log.Errorf("%s Failed to get best height: %v", logName, err)
@@ -334,74 +335,127 @@ func (net *Network) HandleGetHeaderSync(content *ChannelContent) {
 
 		if bestPeer != nil && bestPeer.Height >= bestHeight {
 			log.Infof("%s Completed: peer %s chain matches local best height (%d)",
-				logName, payload.SendFrom, bestHeight)
+				logName, from, bestHeight)
 			net.syncCompleted = true
 		} else {
-			net.syncManager.UpdatePeerStatus(payload.SendFrom, payload.BestHeight, big.NewInt(0))
+			net.syncManager.UpdatePeerStatus(from, payload.BestHeight, big.NewInt(0))
 			bestPeer = net.syncManager.GetTargetPeer()
 
 			if bestPeer != nil && bestPeer.Height >= bestHeight {
 				log.Infof("%s Completed: peer %s at height %d (local best: %d)",
-					logName, payload.SendFrom, bestPeer.Height, bestHeight)
+					logName, from, bestPeer.Height, bestHeight)
 				net.syncCompleted = true
 			} else if bestHeight >= bestPeer.Height {
 				log.Infof("%s Completed: local best height %d matches peer %s",
-					logName, bestHeight, payload.SendFrom)
+					logName, bestHeight, from)
 				net.syncCompleted = true
 			} else {
 				log.Infof("%s No new headers from peer %s (local best: %d, peer best: %d)",
-					logName, payload.SendFrom, bestHeight, payload.BestHeight)
+					logName, from, bestHeight, payload.BestHeight)
 			}
 		}
 		return
 	}
 
-	block := payload.Data[len(payload.Data)-1]
-	log.Infof("%s Received %d headers from peer %s", logName, len(payload.Data), payload.SendFrom)
+	log.Infof("%s Received %d headers from peer %s", logName, len(payload.Data), from)
 
-	chainBlock, err := net.Blockchain.GetBlock(block.PrevHash)
-	if err != nil {
-		log.Errorf("%s Failed to validate previous hash from received headers", logName)
-		return
+	// Headers arrive highest first, the index wants them parent first.
+	headers := make([]blockchain.HeaderData, 0, len(payload.Data))
+	for i := len(payload.Data) - 1; i >= 0; i-- {
+		headers = append(headers, blockchain.HeaderData{
+			Hash:    payload.Data[i].Hash,
+			Header:  payload.Data[i].Header,
+			TxCount: payload.Data[i].TxCount,
+		})
 	}
 
-	peerTotalWork := new(big.Int).Set(chainBlock.NChainWork)
-	hashes := make([][]byte, 0)
-
-	for _, header := range payload.Data {
-		work := net.Blockchain.CalcWork(header.Nbits)
-		peerTotalWork = new(big.Int).Add(peerTotalWork, work)
-		hashes = append(hashes, header.Hash)
+	headerIndex := blockchain.HeaderIndex{Blockchain: net.Blockchain}
+	lastHeader, err := headerIndex.ProcessHeaders(headers)
+	if err != nil {
+		log.Warnf("%s Rejected headers from peer %s: %v", logName, from, err)
+		if errors.Is(err, blockchain.ErrInvalidHeader) {
+			net.Misbehaving(content.Origin.String(), PENALTY_INVALID_HEADERS, "invalid headers")
+		} else if errors.Is(err, blockchain.ErrOrphanHeader) {
+			net.Misbehaving(content.Origin.String(), PENALTY_UNSOLICITED_DATA, "headers not connecting to a known header")
+		}
+		if bestPeer := net.syncManager.GetTargetPeer(); bestPeer != nil && bestPeer.ID == from {
+			net.syncManager.ClearTarget()
+		}
+		return
 	}
 
 	net.syncManager.UpdatePeerStatus(
-		payload.SendFrom,
+		from,
 		payload.BestHeight,
-		peerTotalWork,
+		lastHeader.ChainWork,
 	)
 
 	bestPeer := net.syncManager.GetTargetPeer()
 
-	if bestPeer.ID == payload.SendFrom {
-		log.Infof("%s Processing %d headers from best peer %s, requesting corresponding blocks",
-			logName, len(hashes), payload.SendFrom)
+	if bestPeer.ID != from {
+		log.Infof("%s Skipped: peer %s chain has less work than best peer", logName, from)
+		return
+	}
 
-		if bestHeight < bestPeer.Height {
-			net.SendGetDataSync(payload.SendFrom, NetGetDataSync{
-				SendFrom: net.Host.ID().String(),
-				Hashes:   hashes,
-			})
-		} else {
-			net.syncCompleted = true
-			log.Infof("%s Completed: peer %s chain matches local best height (%d)",
-				logName, payload.SendFrom, bestHeight)
+	if len(payload.Data) >= MAX_HEADERS_PER_MSG {
+		locator, err := headerIndex.Locator()
+		if err != nil {
+			log.Errorf("%s Failed to build header locator: %v", logName, err)
+			return
 		}
-	} else {
-		log.Infof("%s Skipped: peer %s chain has less work than best peer", logName, payload.SendFrom)
+
+		log.Infof("%s Header chain continues past height %d, requesting more headers from %s",
+			logName, lastHeader.Height, from)
+		net.SendHeaderLocator(from, NetHeaderLocator{
+			SendFrom:   net.Host.ID().String(),
+			BestHeight: bestHeight,
+			Locator:    locator,
+		})
+		return
+	}
+
+	net.requestMissingBlocks(logName, from)
+}
+
+// requestMissingBlocks queues the bodies of the best header chain once it
+// carries more work than the local tip. The downloader spreads them over
+// every peer that has them, peerID is the one whose headers triggered it.
+func (net *Network) requestMissingBlocks(logName, peerID string) {
+	headerIndex := blockchain.HeaderIndex{Blockchain: net.Blockchain}
+
+	bestHeader, err := headerIndex.Best()
+	if err != nil {
+		log.Errorf("%s Failed to get best header: %v", logName, err)
+		return
 	}
+
+	lastBlock, err := net.Blockchain.GetLastBlock()
+	if err != nil {
+		log.Errorf("%s Failed to get last block: %v", logName, err)
+		return
+	}
+
+	if bestHeader.ChainWork.Cmp(lastBlock.NChainWork) <= 0 {
+		net.syncCompleted = true
+		log.Infof("%s Completed: best header chain matches local tip (height %d)", logName, lastBlock.Height)
+		return
+	}
+
+	missing, err := headerIndex.MissingBlocks(BLOCK_DOWNLOAD_WINDOW)
+	if err != nil {
+		log.Errorf("%s Failed to collect missing blocks: %v", logName, err)
+		return
+	}
+
+	added := net.downloader.Enqueue(missing)
+
+	log.Infof("%s Best header chain at height %d has more work (headers from %s), queued %d of %d missing blocks",
+		logName, bestHeader.Height, peerID, added, len(missing))
+
+	net.downloader.Schedule()
 }
 
-func (net *Network) HandleGetHeaderLocator(content *ChannelContent) {
+func (net *Network) HandleGetHeaderLocator(content *ChannelContent) []byte {
 	const logName = "[SYNC::HEADER_LOCATOR]"
 
 	buf := new(bytes.Buffer)
@@ -411,7 +465,8 @@ func (net *Network) HandleGetHeaderLocator(content *ChannelContent) {
 	dec := gob.NewDecoder(buf)
 	if err := dec.Decode(&payload); err != nil {
 		log.Errorf("%s Decode failed: invalid locator request from peer", logName)
-		return
+		net.Misbehaving(content.Origin.String(), PENALTY_MALFORMED_MESSAGE, "malformed header locator")
+		return nil
 	}
 
 	var commonBlock *blockchain.Block
@@ -426,7 +481,7 @@ func (net *Network) HandleGetHeaderLocator(content *ChannelContent) {
 
 	if commonBlock == nil {
 		log.Warnf("%s No common ancestor block found with requesting peer", logName)
-		return
+		return nil
 	}
 
 	log.Infof("%s Found common block at height %d", logName, commonBlock.Height)
@@ -434,20 +489,20 @@ func (net *Network) HandleGetHeaderLocator(content *ChannelContent) {
 	blocks, err := net.Blockchain.GetBlockRange(commonBlock.Hash, MAX_HEADERS_PER_MSG)
 	if err != nil {
 		log.Errorf("%s Failed to fetch block range for headers", logName)
-		return
+		return nil
 	}
 
 	bestHeight, err := net.Blockchain.GetBestHeight()
 	if err != nil {
 		log.Errorf("%s Failed to get best height: %v", logName, err)
-		return
+		return nil
 	}
 
 	if payload.BestHeight > bestHeight {
 		locator, err := net.Blockchain.GetBlockLocator()
 		if err != nil {
 			log.Errorf("%s Failed to build new header locator: %v", logName, err)
-			return
+			return nil
 		}
 
 		net.syncCompleted = false
@@ -461,34 +516,32 @@ func (net *Network) HandleGetHeaderLocator(content *ChannelContent) {
 
 	if len(blocks) == 0 {
 		log.Infof("%s No new headers to send to peer %s (best height: %d)", logName, payload.SendFrom, bestHeight)
-		net.SendHeaders(payload.SendFrom, NetHeaders{
+		return EncodeMessage(PREFIX_HEADER_SYNC, NetHeaders{
 			SendFrom:   net.Host.ID().String(),
 			BestHeight: bestHeight,
 			Data:       []NetHeadersData{},
 		})
-		return
 	}
 
 	data := make([]NetHeadersData, 0, len(blocks))
 	for _, block := range blocks {
+		header := block.Header()
 		data = append(data, NetHeadersData{
-			Height:   block.Height,
-			PrevHash: block.PrevHash,
-			Hash:     block.Hash,
-			Nbits:    block.NBits,
+			Height:  block.Height,
+			Hash:    block.Hash,
+			Header:  header.Serialize(),
+			TxCount: block.TxCount,
 		})
 	}
 
 	log.Infof("%s Sending %d headers to peer %s for synchronization (up to height %d)",
 		logName, len(data), payload.SendFrom, bestHeight)
 
-	net.SendHeaders(payload.SendFrom, NetHeaders{
+	return EncodeMessage(PREFIX_HEADER_SYNC, NetHeaders{
 		SendFrom:   net.Host.ID().String(),
 		BestHeight: bestHeight,
 		Data:       data,
 	})
-
-	log.Infof("%s Headers sent successfully", logName)
 }
 
 func (net *Network) HandleGetBlockData(content *ChannelContent) {
@@ -502,6 +555,7 @@ func (net *Network) HandleGetBlockData(content *ChannelContent) {
 	err := dec.Decode(&payload)
 	if err != nil {
 		log.Errorf("%s Failed to decode block data request from peer %s: %v", logName, content.SendFrom, err)
+		net.Misbehaving(content.Origin.String(), PENALTY_MALFORMED_MESSAGE, "malformed block")
 		return
 	}
 
@@ -537,18 +591,30 @@ func (net *Network) HandleGetBlockData(content *ChannelContent) {
 		return
 	}
 
+	// Only a block that is bad on any chain costs the sender. A side chain
+	// block is checked against the main chain UTXO set, so AddBlock can
+	// reject blocks an honest peer relays for its fork.
+	if err := block.CheckSanity(); err != nil {
+		log.Errorf("%s Rejected block %x: %v", logName, block.Hash[:6], err)
+		net.Misbehaving(content.Origin.String(), PENALTY_INVALID_BLOCK, "invalid block")
+		return
+	}
+
 	err = net.Blockchain.AddBlock(block, net.HandleReoganizeTx)
 	if err != nil {
 		log.Errorf("%s Failed to add block %x: %v", logName, block.Hash[:6], err)
+
+		// Blocks are only fetched once their parent is known, anything
+		// else was not asked for.
+		if hasParent, _ := net.Blockchain.HasBlock(block.PrevHash); !hasParent {
+			net.Misbehaving(content.Origin.String(), PENALTY_UNSOLICITED_DATA, "block with unknown parent")
+		}
 		return
 	}
 
 	log.Infof("%s Block %d (%x) added to chain", logName, block.Height, block.Hash[:6])
 
-	for _, tx := range block.Transactions {
-		txID := hex.EncodeToString(tx.ID)
-		MemoryPool.RemoveFromAll(txID)
-	}
+	MemoryPool.RemoveForBlock(block)
 
 	if net.Miner && net.IsMining {
 		log.Infof("%s Competing block received while mining: %x", logName, block.Hash[:6])
@@ -573,7 +639,7 @@ func (net *Network) HandleGetBlockData(content *ChannelContent) {
 	log.Infof("%s Completed handling GetBlockData from peer=%s for block hash=%x height=%d", logName, content.SendFrom, block.Hash[:6], block.Height)
 }
 
-func (net *Network) HandleGetData(content *ChannelContent) {
+func (net *Network) HandleGetData(content *ChannelContent) []byte {
 	const logName = "[GOSSIP::DATA]"
 	buf := new(bytes.Buffer)
 	var payload NetGetData
@@ -583,19 +649,24 @@ func (net *Network) HandleGetData(content *ChannelContent) {
 	err := dec.Decode(&payload)
 	if err != nil {
 		log.Errorf("%s Failed to decode GetData request from peer %s: %v", logName, content.SendFrom, err)
-		return
+		net.Misbehaving(content.Origin.String(), PENALTY_MALFORMED_MESSAGE, "malformed getdata request")
+		return nil
 	}
 
 	block, err := net.Blockchain.GetBlockMainChain(payload.Hash)
 	if err != nil {
 		log.Errorf("%s Block %x not found in main chain for peer %s", logName, payload.Hash[:6], payload.SendFrom)
-		return
+		return nil
 	}
 
 	block = BlockForNetwork(block)
-	net.SendFullBlock(payload.SendFrom, &block)
 
-	log.Infof("%s Sent block height=%d hash=%x to peer %s", logName, block.Height, block.Hash[:6], payload.SendFrom)
+	log.Infof("%s Sending block height=%d hash=%x to peer %s", logName, block.Height, block.Hash[:6], payload.SendFrom)
+
+	return EncodeMessage(PREFIX_BLOCK, NetBlock{
+		SendFrom: net.Host.ID().String(),
+		Block:    blockchain.SerializeBlock(&block),
+	})
 }
 
 func (net *Network) HandleGetHeader(content *ChannelContent) {
@@ -609,6 +680,7 @@ func (net *Network) HandleGetHeader(content *ChannelContent) {
 	err := dec.Decode(&payload)
 	if err != nil {
 		log.Errorf("%s Aborted: failed to decode header request payload", logName)
+		net.Misbehaving(content.Origin.String(), PENALTY_MALFORMED_MESSAGE, "malformed header")
 		return
 	}
 
@@ -702,6 +774,7 @@ func (net *Network) HandleGetTransactions(content *ChannelContent) {
 	err := dec.Decode(&payload)
 	if err != nil {
 		log.Errorf("TxPool sync aborted: failed to decode full transaction data from peer: %v", err)
+		net.Misbehaving(content.Origin.String(), PENALTY_MALFORMED_MESSAGE, "malformed transaction data")
 		return
 	}
 
@@ -711,9 +784,10 @@ func (net *Network) HandleGetTransactions(content *ChannelContent) {
 
 	for i, tx := range payload.Transactions {
 		txID := hex.EncodeToString(tx.ID)
-		txInfo := memopool.GetTxInfo(&tx, net.Blockchain)
-		if txInfo != nil && !MemoryPool.HasTX(txID) {
-			MemoryPool.Add(*txInfo)
+		if MemoryPool.HasTX(txID) {
+			continue
+		}
+		if _, err := net.AcceptTx(&tx); err == nil {
 			log.Debugf("TxPool sync: [%d/%d] added transaction %s to local mempool",
 				i+1, len(payload.Transactions), txID)
 		}
@@ -721,7 +795,7 @@ func (net *Network) HandleGetTransactions(content *ChannelContent) {
 
 }
 
-func (net *Network) HandleGetDataTx(content *ChannelContent) {
+func (net *Network) HandleGetDataTx(content *ChannelContent) []byte {
 	buf := new(bytes.Buffer)
 	var payload NetGetDataTransaction
 
@@ -730,7 +804,8 @@ func (net *Network) HandleGetDataTx(content *ChannelContent) {
 	err := dec.Decode(&payload)
 	if err != nil {
 		log.Errorf("TxPool sync aborted: failed to decode get transaction request from peer: %v", err)
-		return
+		net.Misbehaving(content.Origin.String(), PENALTY_MALFORMED_MESSAGE, "malformed transaction request")
+		return nil
 	}
 
 	var txs []blockchain.Transaction
@@ -744,14 +819,10 @@ func (net *Network) HandleGetDataTx(content *ChannelContent) {
 		}
 	}
 
-	net.SendTransactions(
-		payload.SendFrom,
-		NetTransactionData{
-			SendFrom:     net.Host.ID().String(),
-			Transactions: txs,
-		},
-	)
-
+	return EncodeMessage(PREFIX_TXS_Data, NetTransactionData{
+		SendFrom:     net.Host.ID().String(),
+		Transactions: txs,
+	})
 }
 
 func (net *Network) HandleGetTxPoolInv(content *ChannelContent) {
@@ -761,6 +832,7 @@ func (net *Network) HandleGetTxPoolInv(content *ChannelContent) {
 	buf.Write(content.Payload[commandLength:])
 	dec := gob.NewDecoder(buf)
 	if err := dec.Decode(&payload); err != nil {
+		net.Misbehaving(content.Origin.String(), PENALTY_MALFORMED_MESSAGE, "malformed mempool inventory")
 		return
 	}
 
@@ -787,7 +859,7 @@ func (net *Network) HandleGetTxPoolInv(content *ChannelContent) {
 
 }
 
-func (net *Network) HandleGetTxFromPool(content *ChannelContent) {
+func (net *Network) HandleGetTxFromPool(content *ChannelContent) []byte {
 	buf := new(bytes.Buffer)
 	var payload TxFromPool
 
@@ -796,18 +868,22 @@ func (net *Network) HandleGetTxFromPool(content *ChannelContent) {
 	err := dec.Decode(&payload)
 	if err != nil {
 		log.Errorf("❌ Failed to decode 'GetTxFromPool' request: %v", err)
-		return
+		net.Misbehaving(content.Origin.String(), PENALTY_MALFORMED_MESSAGE, "malformed mempool request")
+		return nil
 	}
 
-	if int64(len(MemoryPool.Pending)) >= payload.Count {
-		txs := MemoryPool.GetTransactionHashes()
-		net.SendTxPoolInv(payload.SendFrom, txs)
-	} else {
-		net.SendTxPoolInv(payload.SendFrom, [][]byte{})
+	txHashes := [][]byte{}
+	if int64(MemoryPool.PendingCount()) >= payload.Count {
+		txHashes = MemoryPool.GetTransactionHashes()
 	}
+
+	return EncodeMessage(PREFIX_INVENTORY, NetInventoryTxs{
+		SendFrom: net.Host.ID().String(),
+		TxHashes: txHashes,
+	})
 }
 
-func (net *Network) HandleGetRequestGossipPeer(content *ChannelContent) {
+func (net *Network) HandleGetRequestGossipPeer(content *ChannelContent) []byte {
 	buf := new(bytes.Buffer)
 	var payload NetRequestGossipPeer
 
@@ -816,27 +892,24 @@ func (net *Network) HandleGetRequestGossipPeer(content *ChannelContent) {
 	err := dec.Decode(&payload)
 	if err != nil {
 		log.Errorf("[HandleGetRequestGossipPeer] Failed to decode 'NetRequestGossipPeer' request: %v", err)
-		return
+		net.Misbehaving(content.Origin.String(), PENALTY_MALFORMED_MESSAGE, "malformed gossip peers request")
+		return nil
 	}
 
 	if payload.Count > 20 {
-		return
+		return nil
 	}
 
 	peerList, err := RandomHealthyPeers(int(payload.Count))
 	if err != nil {
 		log.Errorf("[HandleGetRequestGossipPeer] Failed to get random unique peers: %v", err)
-		return
+		return nil
 	}
 
-	net.SendGossipPeers(
-		payload.SendFrom,
-		NetGossipPeers{
-			SendFrom: net.Host.ID().String(),
-			Peers:    peerList.ListAddrs(),
-		},
-	)
-
+	return EncodeMessage(PREFOX_GOSSIP_PEERS, NetGossipPeers{
+		SendFrom: net.Host.ID().String(),
+		Peers:    peerList.ListAddrs(),
+	})
 }
 
 func (net *Network) HandleGetGossipPeers(content *ChannelContent) {
@@ -848,6 +921,7 @@ func (net *Network) HandleGetGossipPeers(content *ChannelContent) {
 	err := dec.Decode(&payload)
 	if err != nil {
 		log.Errorf("[HandleGetGossipPeers] Failed to decode 'NetGossipPeers' request: %v", err)
+		net.Misbehaving(content.Origin.String(), PENALTY_MALFORMED_MESSAGE, "malformed gossip peers")
 		return
 	}
 
@@ -919,14 +993,12 @@ func HandleEvents(net *Network) {
 
 			for _, tx := range txs {
 				txHash := hex.EncodeToString(tx.ID)
-				txInfo := memopool.GetTxInfo(tx, net.Blockchain)
-				if txInfo == nil {
-					log.Errorf("Failed to get transaction info for tx=%s", txHash)
+				if _, err := net.AcceptTx(tx); err != nil && !errors.Is(err, memopool.ErrAlreadyInPool) {
+					log.Warnf("Transaction %s rejected: %v", txHash, err)
 					continue
 				}
 
-				MemoryPool.Add(*txInfo)
-				log.Infof("Transaction %s and fee %f added to mempool, broadcasting to peers...", txHash, txInfo.Fee)
+				log.Infof("Transaction %s in mempool, broadcasting to peers...", txHash)
 
 				net.Gossip.Broadcast(
 					net.FullNodesChannel.ListPeers(),
@@ -0,0 +1,104 @@
+package p2p
+
+import (
+	"context"
+	blockchain "core-blockchain/core"
+	"core-blockchain/memopool"
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	log "github.com/sirupsen/logrus"
+)
+
+var (
+	ErrMineOnDemandDisabled = errors.New("network does not generate blocks on demand")
+	ErrTxNotInMempool       = errors.New("transaction is not in the mempool")
+	ErrInvalidBlockTxs      = errors.New("block transactions are invalid")
+)
+
+// GenerateToAddress mines n blocks paying address, each one filled from the
+// mempool the way the miner loop fills its blocks. It returns the blocks
+// mined before an error, if any.
+func (net *Network) GenerateToAddress(n int, address string) ([]*blockchain.Block, error) {
+	if !net.Blockchain.Params.MineOnDemand {
+		return nil, ErrMineOnDemandDisabled
+	}
+
+	net.generateMu.Lock()
+	defer net.generateMu.Unlock()
+
+	blocks := make([]*blockchain.Block, 0, n)
+	for range n {
+		selected := MemoryPool.SelectHighFeeTx()
+
+		txs := make([]*blockchain.Transaction, 0, len(selected))
+		selectedIDs := make(map[string]bool, len(selected))
+		for i := range selected {
+			txs = append(txs, &selected[i])
+			selectedIDs[hex.EncodeToString(selected[i].ID)] = true
+		}
+
+		block, err := net.generate(txs, address)
+		if err != nil {
+			for _, info := range MemoryPool.QueuedTxs() {
+				if selectedIDs[hex.EncodeToString(info.Transaction.ID)] {
+					MemoryPool.Move(info, memopool.MEMO_MOVE_FLAG_PENDING)
+				}
+			}
+			return blocks, err
+		}
+
+		blocks = append(blocks, block)
+	}
+
+	return blocks, nil
+}
+
+// GenerateBlock mines one block paying address with exactly the mempool
+// transactions txIDs, in that order.
+func (net *Network) GenerateBlock(txIDs []string, address string) (*blockchain.Block, error) {
+	if !net.Blockchain.Params.MineOnDemand {
+		return nil, ErrMineOnDemandDisabled
+	}
+
+	seen := make(map[string]bool, len(txIDs))
+	txs := make([]*blockchain.Transaction, 0, len(txIDs))
+
+	for _, txID := range txIDs {
+		if seen[txID] {
+			return nil, fmt.Errorf("%w: %s listed twice", ErrInvalidBlockTxs, txID)
+		}
+		seen[txID] = true
+
+		tx := MemoryPool.GetTxByID(txID)
+		if tx == nil {
+			return nil, fmt.Errorf("%w: %s", ErrTxNotInMempool, txID)
+		}
+		txs = append(txs, tx)
+	}
+
+	net.generateMu.Lock()
+	defer net.generateMu.Unlock()
+
+	return net.generate(txs, address)
+}
+
+// generate mines txs through MineBlock and announces the block like the
+// miner loop does.
+func (net *Network) generate(txs []*blockchain.Transaction, address string) (*blockchain.Block, error) {
+	block, err := net.Blockchain.MineBlock(txs, address, net.HandleReoganizeTx, context.Background())
+	if errors.Is(err, blockchain.ErrInvalidBlockTx) {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidBlockTxs, err)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	log.Infof("[GENERATE] Mined block %d (%x) with %d txs", block.Height, block.Hash[:6], len(txs))
+
+	MemoryPool.RemoveForBlock(block)
+	net.Blocks <- block
+
+	return block, nil
+}
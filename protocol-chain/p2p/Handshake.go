@@ -0,0 +1,175 @@
+package p2p
+
+import (
+	"bytes"
+	blockchain "core-blockchain/core"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	log "github.com/sirupsen/logrus"
+)
+
+var ErrIncompatiblePeer = errors.New("incompatible peer")
+
+func localServices(bc *blockchain.Blockchain, miner, fullNode, isSeedPeer bool) uint64 {
+	var services uint64
+
+	if fullNode {
+		services |= SERVICE_FULL_NODE
+	}
+	if miner {
+		services |= SERVICE_MINER
+	}
+	if isSeedPeer {
+		services |= SERVICE_SEED
+	}
+
+	txIndex := blockchain.TxIndex{Blockchain: bc}
+	if txIndex.Enabled() {
+		services |= SERVICE_TXINDEX
+	}
+
+	return services
+}
+
+func (net *Network) localVersion() (NetVersion, error) {
+	lastBlock, err := net.Blockchain.GetLastBlock()
+	if err != nil {
+		return NetVersion{}, err
+	}
+
+	genesisHash, err := net.Blockchain.GenesisHash()
+	if err != nil {
+		return NetVersion{}, err
+	}
+
+	return NetVersion{
+		SendFrom:        net.Host.ID().String(),
+		ProtocolVersion: version,
+		ChainID:         net.Blockchain.Params.ChainID,
+		GenesisHash:     genesisHash,
+		BestHeight:      lastBlock.Height,
+		TotalWork:       lastBlock.NChainWork,
+		Services:        net.services,
+		Timestamp:       time.Now().Unix(),
+	}, nil
+}
+
+func checkVersion(local, remote NetVersion) error {
+	if remote.ProtocolVersion < MIN_PEER_PROTOCOL_VERSION {
+		return fmt.Errorf("%w: protocol version %d, need at least %d", ErrIncompatiblePeer, remote.ProtocolVersion, MIN_PEER_PROTOCOL_VERSION)
+	}
+
+	if remote.ChainID != local.ChainID {
+		return fmt.Errorf("%w: chain id %q, want %q", ErrIncompatiblePeer, remote.ChainID, local.ChainID)
+	}
+
+	if !bytes.Equal(remote.GenesisHash, local.GenesisHash) {
+		return fmt.Errorf("%w: genesis %x, want %x", ErrIncompatiblePeer, remote.GenesisHash, local.GenesisHash)
+	}
+
+	if remote.TotalWork == nil || remote.TotalWork.Sign() < 0 || remote.BestHeight < 0 {
+		return fmt.Errorf("%w: invalid chain state", ErrIncompatiblePeer)
+	}
+
+	return nil
+}
+
+// acceptVersion feeds a compatible peer into the SyncManager.
+func (net *Network) acceptVersion(pID peer.ID, remote NetVersion) {
+	net.syncManager.UpdatePeerStatus(pID.String(), remote.BestHeight, remote.TotalWork)
+	net.syncManager.SetPeerVersion(pID.String(), remote.ProtocolVersion, remote.Services)
+
+	log.Infof("[HANDSHAKE] Peer %s: version %d, height %d, services %#x",
+		pID, remote.ProtocolVersion, remote.BestHeight, remote.Services)
+}
+
+func (net *Network) disconnect(pID peer.ID, reason error) {
+	log.Warnf("[HANDSHAKE] Disconnecting peer %s: %v", pID, reason)
+
+	net.syncManager.RemovePeer(pID.String())
+	if err := net.Host.Network().ClosePeer(pID); err != nil {
+		log.Warnf("[HANDSHAKE] Failed to disconnect peer %s: %v", pID, err)
+	}
+}
+
+// handshakeOnConnect starts a handshake with every newly connected peer.
+func (net *Network) handshakeOnConnect() {
+	net.Host.Network().Notify(&network.NotifyBundle{
+		ConnectedF: func(_ network.Network, c network.Conn) {
+			go net.Handshake(c.RemotePeer())
+		},
+	})
+}
+
+// Handshake sends the local version to pID and checks the one it answers
+// with. Peers that do not speak SYNC_PROTOCOL_ID or run another chain are
+// disconnected.
+func (net *Network) Handshake(pID peer.ID) {
+	local, err := net.localVersion()
+	if err != nil {
+		log.Errorf("[HANDSHAKE] Failed to build local version: %v", err)
+		return
+	}
+
+	response, err := net.Request(pID.String(), EncodeMessage(PREFIX_VERSION, local))
+	if err != nil {
+		net.disconnect(pID, fmt.Errorf("%w: %v", ErrIncompatiblePeer, err))
+		return
+	}
+
+	if len(response) < commandLength || BytesToCmd(response[:commandLength]) != PREFIX_VERSION {
+		net.Misbehaving(pID.String(), PENALTY_MALFORMED_MESSAGE, "unexpected handshake response")
+		net.disconnect(pID, ErrIncompatiblePeer)
+		return
+	}
+
+	var remote NetVersion
+	if err := gob.NewDecoder(bytes.NewReader(response[commandLength:])).Decode(&remote); err != nil {
+		net.Misbehaving(pID.String(), PENALTY_MALFORMED_MESSAGE, "malformed version")
+		net.disconnect(pID, ErrIncompatiblePeer)
+		return
+	}
+
+	if err := checkVersion(local, remote); err != nil {
+		net.disconnect(pID, err)
+		return
+	}
+
+	net.acceptVersion(pID, remote)
+}
+
+// HandleVersion answers a handshake with the local version. An incompatible
+// peer still gets the answer, so it can tell why, and is disconnected right
+// after.
+func (net *Network) HandleVersion(content *ChannelContent) []byte {
+	buf := bytes.NewReader(content.Payload[commandLength:])
+
+	var payload NetVersion
+	if err := gob.NewDecoder(buf).Decode(&payload); err != nil {
+		log.Errorf("[HANDSHAKE] Failed to decode version from peer %s: %v", content.Origin, err)
+		net.Misbehaving(content.Origin.String(), PENALTY_MALFORMED_MESSAGE, "malformed version")
+		return nil
+	}
+
+	local, err := net.localVersion()
+	if err != nil {
+		log.Errorf("[HANDSHAKE] Failed to build local version: %v", err)
+		return nil
+	}
+
+	if err := checkVersion(local, payload); err != nil {
+		pID := content.Origin
+		time.AfterFunc(time.Second, func() {
+			net.disconnect(pID, err)
+		})
+	} else {
+		net.acceptVersion(content.Origin, payload)
+	}
+
+	return EncodeMessage(PREFIX_VERSION, local)
+}
@@ -0,0 +1,91 @@
+package p2p
+
+import (
+	"context"
+	blockchain "core-blockchain/core"
+	"core-blockchain/memopool"
+	"errors"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// MEMPOOL_EXPIRY_CHECK is how often idle pools drop expired transactions,
+// Add expires them on the way as well.
+const MEMPOOL_EXPIRY_CHECK = time.Minute
+
+const mempoolFileName = "mempool.dat"
+
+// mempoolFile keeps the mempool across restarts, StartNode points it at the
+// chain data dir.
+var mempoolFile string
+
+// AcceptTx validates tx against the chain and admits it to MemoryPool. It
+// returns the ids of the pool transactions tx replaced, the error tells why
+// it was rejected.
+func (net *Network) AcceptTx(tx *blockchain.Transaction) ([]string, error) {
+	txInfo := MemoryPool.GetTxInfo(tx, net.Blockchain)
+	if txInfo == nil {
+		return nil, memopool.ErrInvalidTx
+	}
+
+	return MemoryPool.Add(*txInfo)
+}
+
+func (net *Network) expireMempool(ctx context.Context) {
+	ticker := time.NewTicker(MEMPOOL_EXPIRY_CHECK)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			MemoryPool.Expire(now)
+		}
+	}
+}
+
+// SaveMempool writes MemoryPool to the chain data dir and returns how many
+// transactions it wrote.
+func (net *Network) SaveMempool() (int, error) {
+	return MemoryPool.Save(mempoolFile)
+}
+
+// LoadMempool re-admits the transactions saved in the chain data dir. Each
+// one is validated against the current UTXO set and the pool policy again,
+// so the ones confirmed, double spent or expired meanwhile are dropped. It
+// returns how many got in and how many were dropped.
+func (net *Network) LoadMempool() (int, int, error) {
+	saved, err := memopool.ReadSaved(mempoolFile)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	now := time.Now()
+	loaded, dropped := 0, 0
+
+	for _, s := range saved {
+		if expiry := MemoryPool.Policy.Expiry; expiry > 0 && now.Sub(s.Time) > expiry {
+			dropped++
+			continue
+		}
+
+		txInfo := MemoryPool.GetTxInfo(&s.Transaction, net.Blockchain)
+		if txInfo == nil {
+			dropped++
+			continue
+		}
+
+		txInfo.Time = s.Time
+		if _, err := MemoryPool.Add(*txInfo); err != nil && !errors.Is(err, memopool.ErrAlreadyInPool) {
+			log.Debugf("Saved transaction %x dropped: %v", s.Transaction.ID, err)
+			dropped++
+			continue
+		}
+
+		loaded++
+	}
+
+	return loaded, dropped, nil
+}
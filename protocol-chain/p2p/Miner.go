@@ -3,8 +3,6 @@ package p2p
 import (
 	"context"
 	blockchain "core-blockchain/core"
-	"maps"
-	"slices"
 	"time"
 
 	"github.com/libp2p/go-libp2p/core/peer"
@@ -67,9 +65,6 @@ func (net *Network) miningLoop(ctx context.Context) {
 			}
 
 			txs := MemoryPool.SelectHighFeeTx()
-			if len(txs) == 0 {
-				txs = map[string]blockchain.Transaction{}
-			}
 
 			miningCtx, cancel := context.WithCancel(ctx)
 			net.tryMine(miningCtx, cancel, txs)
@@ -79,7 +74,7 @@ func (net *Network) miningLoop(ctx context.Context) {
 	}
 }
 
-func (net *Network) tryMine(ctx context.Context, cancel context.CancelFunc, txs map[string]blockchain.Transaction) {
+func (net *Network) tryMine(ctx context.Context, cancel context.CancelFunc, txs []blockchain.Transaction) {
 	if !net.syncCompleted {
 		return
 	}
@@ -88,7 +83,7 @@ func (net *Network) tryMine(ctx context.Context, cancel context.CancelFunc, txs
 	net.mineBlock(ctx, cancel, txs)
 }
 
-func (net *Network) mineBlock(ctx context.Context, cancel context.CancelFunc, txs map[string]blockchain.Transaction) {
+func (net *Network) mineBlock(ctx context.Context, cancel context.CancelFunc, txs []blockchain.Transaction) {
 	chain := net.Blockchain
 	lastBlock, err := chain.GetLastBlock()
 	if err != nil {
@@ -129,6 +124,10 @@ func (net *Network) startMiningWorker(ctx context.Context, resultCh chan<- *bloc
 		resultCh <- nil
 		return
 	}
+	if block == nil {
+		resultCh <- nil
+		return
+	}
 
 	resultCh <- block
 	log.Infof("[Miner] found valid block at height %d!", block.Height)
@@ -154,8 +153,5 @@ func (net *Network) handleMinedBlock(block *blockchain.Block) {
 	log.Infof("New Block Mined: %x", block.Hash)
 	net.Blocks <- block
 
-	keys := slices.Collect(maps.Keys(MemoryPool.Queued))
-	for _, key := range keys {
-		MemoryPool.RemoveFromAll(key)
-	}
+	MemoryPool.RemoveForBlock(block)
 }
@@ -33,9 +33,10 @@ const (
 	GeneralChannel   = "general-channel"
 	MiningChannel    = "mining-channel"
 	FullNodesChannel = "fullnodes-channel"
-	Rendezvous       = "room-chain"
 
-	version       = 1
+	// version is the protocol version sent in the handshake. Version 2 moved
+	// requests and responses to SYNC_PROTOCOL_ID.
+	version       = 2
 	commandLength = 20
 
 	DHT_PREFIX = "/novaChain"
@@ -44,29 +45,28 @@ const (
 )
 
 var (
-	MemoryPool = memopool.Memopool{
-		Pending: map[string]memopool.TxInfo{},
-		Queued:  map[string]memopool.TxInfo{},
-	}
+	MemoryPool   = memopool.New(memopool.DefaultPolicy())
 	MinerAddress = ""
 
 	identityFile      = path.Join(Root, "/.identity")
 	reconnectingPeers sync.Map
 )
 
-var bootstrapPeers = []string{
-	"/ip4/103.139.154.23/tcp/9000/p2p/Qmb51pbTY5Nu7ERPJQLyK7kMQ96JQTSyPdxyWLcW4Zoq58",
-	"/ip4/103.139.154.23/tcp/9001/p2p/12D3KooWDuuLTYMT9jy6RukawRj4ZaNd1wzEtq3kTXTevVwP5Lhq",
-}
-
+// StartNode runs a node of the network described by bc.Params. Known peers
+// and bans are kept apart per network.
 func StartNode(logFile string, bc *blockchain.Blockchain, listenPort, minerAddress string, miner, fullNode, isSeedPeer bool, callback func(*Network)) {
 
 	MinerAddress = minerAddress
+	chainDir := bc.Params.ChainDir(Root)
+	peersFile = path.Join(chainDir, peersFileName)
+	mempoolFile = path.Join(chainDir, mempoolFileName)
 
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	defer bc.Database.Close()
+	// A normal return closes the database the way a signal does, running
+	// the close hooks that save the mempool first.
+	defer helpers.SafeCloseDB(bc)
 	go helpers.CloseDB(bc)
 
 	prvKey, err := LoadOrCreateIdentity(fmt.Sprintf("%s_%s", identityFile, listenPort))
@@ -90,6 +90,8 @@ func StartNode(logFile string, bc *blockchain.Blockchain, listenPort, minerAddre
 		fmt.Sprintf("/ip4/0.0.0.0/tcp/%s/ws", listenPort),
 	)
 
+	bans := NewBanManager(path.Join(chainDir, bannedFileName))
+
 	host, err := libp2p.New(
 		transports,
 		muxers,
@@ -101,19 +103,22 @@ func StartNode(logFile string, bc *blockchain.Blockchain, listenPort, minerAddre
 		libp2p.EnableRelay(),
 		libp2p.EnableRelayService(),
 		libp2p.Security(noise.ID, noise.New),
+		libp2p.ConnectionGater(bans),
 	)
 	if err != nil {
 		log.Error(err)
 		return
 	}
 
+	bans.SetHost(host)
+
 	for _, addr := range host.Addrs() {
 		log.Infoln("Listening on ", addr)
 	}
 
 	log.Info("Host Created: ", host.ID())
 
-	pub, err := pubsub.NewGossipSub(ctx, host)
+	pub, err := pubsub.NewGossipSub(ctx, host, pubsub.WithBlacklist(bans))
 	if err != nil {
 		log.Error(err)
 		return
@@ -162,10 +167,14 @@ func StartNode(logFile string, bc *blockchain.Blockchain, listenPort, minerAddre
 
 		Gossip:      g,
 		syncManager: syncManager,
+		Bans:        bans,
 
 		syncCompleted: false,
 	}
 
+	network.downloader = NewBlockDownloader(network)
+	defer network.downloader.Stop()
+
 	worker := NewWorker(1000, ctx, Error, func(content *ChannelContent) {
 		ui.HandleStream(network, content)
 	})
@@ -173,6 +182,26 @@ func StartNode(logFile string, bc *blockchain.Blockchain, listenPort, minerAddre
 	worker.Start(1)
 	network.worker = worker
 
+	network.services = localServices(bc, miner, fullNode, isSeedPeer)
+
+	if loaded, dropped, err := network.LoadMempool(); err != nil {
+		log.Errorf("Load mempool with error: %v", err)
+	} else if loaded+dropped > 0 {
+		log.Infof("Loaded %d mempool transactions, dropped %d", loaded, dropped)
+	}
+
+	helpers.OnCloseDB(func() {
+		count, err := network.SaveMempool()
+		if err != nil {
+			log.Errorf("Save mempool with error: %v", err)
+			return
+		}
+		log.Infof("Saved %d mempool transactions", count)
+	})
+
+	host.SetStreamHandler(SYNC_PROTOCOL_ID, network.HandleSyncStream)
+	network.handshakeOnConnect()
+
 	callback(network)
 
 	go HandleEvents(network)
@@ -202,6 +231,7 @@ func StartNode(logFile string, bc *blockchain.Blockchain, listenPort, minerAddre
 		go network.MinersEventLoop()
 	}
 
+	go network.expireMempool(ctx)
 	go StartPeerMaintenance(ctx, network.Host)
 	go SyncConnectedPeers(ctx, network.Host)
 
@@ -212,6 +242,11 @@ func StartNode(logFile string, bc *blockchain.Blockchain, listenPort, minerAddre
 }
 
 func SetupDiscovery(ctx context.Context, host host.Host, isSeedPeer bool, net *Network) error {
+	if params := net.Blockchain.Params; params.NoDiscovery {
+		log.Infof("Peer discovery is off on %s", params.Name)
+		return nil
+	}
+
 	mode := dht.ModeAuto
 	if isSeedPeer {
 		mode = dht.ModeServer
@@ -246,11 +281,11 @@ func SetupDiscovery(ctx context.Context, host host.Host, isSeedPeer bool, net *N
 		log.Info("🌱 Seed peer initialized. Waiting for incoming peer connections...")
 	}
 
-	ConnectBootstrapPeers(ctx, host, kademliaDHT)
+	ConnectBootstrapPeers(ctx, host, kademliaDHT, net.Blockchain.Params.BootstrapPeers)
 	bootstrapDHT(ctx, kademliaDHT)
 
 	go MaintainDHTBootstrap(ctx, kademliaDHT)
-	go startDiscoveryTasks(ctx, host, kademliaDHT)
+	go startDiscoveryTasks(ctx, host, kademliaDHT, net.Blockchain.Params.Rendezvous)
 	go RefreshDHT(ctx, host, kademliaDHT)
 	go MonitorConnectivity(ctx, host, kademliaDHT, net)
 	go startReconnectCleaner(ctx, 5*time.Minute, EXPIRY_PEER)
@@ -266,10 +301,10 @@ func bootstrapDHT(ctx context.Context, dhtInstance *dht.IpfsDHT) {
 	}
 }
 
-func startDiscoveryTasks(ctx context.Context, host host.Host, dhtInstance *dht.IpfsDHT) {
+func startDiscoveryTasks(ctx context.Context, host host.Host, dhtInstance *dht.IpfsDHT, rendezvous string) {
 	time.Sleep(10 * time.Second)
-	go AutoAdvertise(ctx, dhtInstance, Rendezvous)
-	go DiscoveryPeers(ctx, host, dhtInstance)
+	go AutoAdvertise(ctx, dhtInstance, rendezvous)
+	go DiscoveryPeers(ctx, host, dhtInstance, rendezvous)
 }
 
 func MaintainDHTBootstrap(ctx context.Context, dht *dht.IpfsDHT) {
@@ -285,7 +320,7 @@ func MaintainDHTBootstrap(ctx context.Context, dht *dht.IpfsDHT) {
 	}
 }
 
-func ConnectBootstrapPeers(ctx context.Context, host host.Host, kademliaDHT *dht.IpfsDHT) {
+func ConnectBootstrapPeers(ctx context.Context, host host.Host, kademliaDHT *dht.IpfsDHT, bootstrapPeers []string) {
 	var wg sync.WaitGroup
 
 	for _, addr := range bootstrapPeers {
@@ -352,7 +387,7 @@ func AutoAdvertise(ctx context.Context, dht *dht.IpfsDHT, rendezvous string) {
 	}
 }
 
-func DiscoveryPeers(ctx context.Context, host host.Host, kademliaDHT *dht.IpfsDHT) {
+func DiscoveryPeers(ctx context.Context, host host.Host, kademliaDHT *dht.IpfsDHT, rendezvous string) {
 	routingDiscovery, backoffStrategy := NewOptionsBackoffDiscovery(kademliaDHT)
 	discoveryWithBackoff, err := backoff.NewBackoffDiscovery(routingDiscovery, backoffStrategy)
 	if err != nil {
@@ -365,7 +400,7 @@ func DiscoveryPeers(ctx context.Context, host host.Host, kademliaDHT *dht.IpfsDH
 		case <-ctx.Done():
 			return
 		default:
-			peerChan, err := discoveryWithBackoff.FindPeers(ctx, Rendezvous)
+			peerChan, err := discoveryWithBackoff.FindPeers(ctx, rendezvous)
 			if err != nil {
 				log.Warnf("Peer discovery failed: %v", err)
 				time.Sleep(5 * time.Second)
@@ -509,7 +544,7 @@ func MonitorConnectivity(ctx context.Context, host host.Host, dht *dht.IpfsDHT,
 				wasOffline = true
 				log.Warn("[NETWORK] Node is offline or isolated. Attempting to reconnect...")
 				bootstrapDHT(ctx, dht)
-				ConnectBootstrapPeers(ctx, host, dht)
+				ConnectBootstrapPeers(ctx, host, dht, net.Blockchain.Params.BootstrapPeers)
 				time.Sleep(3 * time.Second)
 			}
 
@@ -1,8 +1,31 @@
 package p2p
 
+import "time"
+
 const (
 	MAX_HEADERS_PER_MSG = 500
 
+	// Block download during initial sync
+	MAX_BLOCKS_IN_FLIGHT_PER_PEER = 16
+	BLOCK_DOWNLOAD_WINDOW         = 1024
+	BLOCK_REQUEST_TIMEOUT         = 30 * time.Second
+	BLOCK_DOWNLOAD_MAX_RETRIES    = 3
+
+	// Request/response stream protocol
+	SYNC_PROTOCOL_ID       = "/novachain/sync/1.0.0"
+	MAX_STREAM_FRAME_SIZE  = 32 << 20
+	STREAM_REQUEST_TIMEOUT = 20 * time.Second
+
+	// Handshake
+	MIN_PEER_PROTOCOL_VERSION = 2
+
+	// Misbehavior penalties, a peer is banned at BanThreshold
+	PENALTY_MALFORMED_MESSAGE = 20
+	PENALTY_UNSOLICITED_DATA  = 10
+	PENALTY_INVALID_TX        = 10
+	PENALTY_INVALID_HEADERS   = 50
+	PENALTY_INVALID_BLOCK     = 100
+
 	// Prefix Sync block
 	PREFIX_BLOCK          = "block"
 	PREFIX_BLOCK_SYNC     = "block_sync"
@@ -22,4 +45,14 @@ const (
 
 	PREFIX_REQUEST_GOSSIP_PEERS = "request_gossip_peers"
 	PREFOX_GOSSIP_PEERS         = "gossip_peers"
+
+	PREFIX_VERSION = "version"
+)
+
+// Service flags advertised in the handshake
+const (
+	SERVICE_FULL_NODE uint64 = 1 << iota
+	SERVICE_MINER
+	SERVICE_SEED
+	SERVICE_TXINDEX
 )
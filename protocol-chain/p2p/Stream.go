@@ -0,0 +1,216 @@
+package p2p
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sync/atomic"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	log "github.com/sirupsen/logrus"
+)
+
+var nextRequestID atomic.Uint64
+
+var (
+	ErrInvalidFrame      = errors.New("invalid stream frame")
+	ErrRequestIDMismatch = errors.New("response does not match request id")
+)
+
+// streamFrame is one message on SYNC_PROTOCOL_ID, written as
+// length(4) | request id(8) | payload, big endian. The length covers the
+// request id and the payload. The payload is a command prefixed message like
+// the ones published on GossipSub.
+type streamFrame struct {
+	RequestID uint64
+	Payload   []byte
+}
+
+func writeFrame(w io.Writer, frame streamFrame) error {
+	size := 8 + len(frame.Payload)
+	if size > MAX_STREAM_FRAME_SIZE {
+		return fmt.Errorf("%w: %d bytes", ErrInvalidFrame, size)
+	}
+
+	buf := make([]byte, 12, 4+size)
+	binary.BigEndian.PutUint32(buf[:4], uint32(size))
+	binary.BigEndian.PutUint64(buf[4:12], frame.RequestID)
+	buf = append(buf, frame.Payload...)
+
+	_, err := w.Write(buf)
+	return err
+}
+
+func readFrame(r io.Reader) (streamFrame, error) {
+	var head [4]byte
+	if _, err := io.ReadFull(r, head[:]); err != nil {
+		return streamFrame{}, err
+	}
+
+	size := binary.BigEndian.Uint32(head[:])
+	if size < 8 || size > MAX_STREAM_FRAME_SIZE {
+		return streamFrame{}, fmt.Errorf("%w: %d bytes", ErrInvalidFrame, size)
+	}
+
+	body := make([]byte, size)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return streamFrame{}, err
+	}
+
+	return streamFrame{
+		RequestID: binary.BigEndian.Uint64(body[:8]),
+		Payload:   body[8:],
+	}, nil
+}
+
+// isAnnouncement reports whether command may travel over GossipSub. Every
+// other message is a request or a response on SYNC_PROTOCOL_ID.
+func isAnnouncement(command string) bool {
+	switch command {
+	case PREFIX_HEADER, PREFIX_TX, PREFIX_TX_MINING:
+		return true
+	}
+
+	return false
+}
+
+// HandleRequest answers a request received on SYNC_PROTOCOL_ID. It returns
+// the command prefixed response, nil when there is nothing to answer.
+func (net *Network) HandleRequest(content *ChannelContent) []byte {
+	if len(content.Payload) < commandLength {
+		net.Misbehaving(content.Origin.String(), PENALTY_MALFORMED_MESSAGE, "request shorter than command")
+		return nil
+	}
+
+	command := BytesToCmd(content.Payload[:commandLength])
+
+	switch command {
+	case PREFIX_VERSION:
+		return net.HandleVersion(content)
+	case PREFIX_HEADER_LOCATOR:
+		return net.HandleGetHeaderLocator(content)
+	case PREFIX_GET_DATA_SYNC:
+		return net.HandleGetDataSync(content)
+	case PREFIX_GET_DATA:
+		return net.HandleGetData(content)
+	case PREFIX_TX_FROM_POOL:
+		return net.HandleGetTxFromPool(content)
+	case PREFIX_DATA_TX:
+		return net.HandleGetDataTx(content)
+	case PREFIX_REQUEST_GOSSIP_PEERS:
+		return net.HandleGetRequestGossipPeer(content)
+	default:
+		net.Misbehaving(content.Origin.String(), PENALTY_MALFORMED_MESSAGE, fmt.Sprintf("unknown request %q", command))
+		return nil
+	}
+}
+
+// HandleSyncStream serves one request per stream: it reads the request
+// frame and writes the response under the same request id.
+func (net *Network) HandleSyncStream(s network.Stream) {
+	const logName = "[STREAM::SYNC]"
+
+	defer s.Close()
+
+	remote := s.Conn().RemotePeer()
+	s.SetDeadline(time.Now().Add(STREAM_REQUEST_TIMEOUT))
+
+	frame, err := readFrame(s)
+	if err != nil {
+		log.Warnf("%s Failed to read request from peer %s: %v", logName, remote, err)
+		if errors.Is(err, ErrInvalidFrame) {
+			net.Misbehaving(remote.String(), PENALTY_MALFORMED_MESSAGE, "malformed stream frame")
+		}
+		s.Reset()
+		return
+	}
+
+	response := net.HandleRequest(&ChannelContent{
+		Message:  "Stream request",
+		SendFrom: ShortID(remote),
+		Payload:  frame.Payload,
+		Origin:   remote,
+		Direct:   true,
+	})
+
+	if err := writeFrame(s, streamFrame{RequestID: frame.RequestID, Payload: response}); err != nil {
+		log.Warnf("%s Failed to answer request %d from peer %s: %v", logName, frame.RequestID, remote, err)
+		s.Reset()
+	}
+}
+
+// Request sends request to sendTo over SYNC_PROTOCOL_ID and waits up to
+// STREAM_REQUEST_TIMEOUT for the answer. An empty answer means the peer had
+// nothing to send back.
+func (net *Network) Request(sendTo string, request []byte) ([]byte, error) {
+	pid, err := peer.Decode(sendTo)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), STREAM_REQUEST_TIMEOUT)
+	defer cancel()
+
+	s, err := net.Host.NewStream(ctx, pid, SYNC_PROTOCOL_ID)
+	if err != nil {
+		return nil, err
+	}
+	defer s.Close()
+
+	s.SetDeadline(time.Now().Add(STREAM_REQUEST_TIMEOUT))
+
+	id := nextRequestID.Add(1)
+	if err := writeFrame(s, streamFrame{RequestID: id, Payload: request}); err != nil {
+		s.Reset()
+		return nil, err
+	}
+	if err := s.CloseWrite(); err != nil {
+		s.Reset()
+		return nil, err
+	}
+
+	frame, err := readFrame(s)
+	if err != nil {
+		s.Reset()
+		if errors.Is(err, ErrInvalidFrame) {
+			net.Misbehaving(sendTo, PENALTY_MALFORMED_MESSAGE, "malformed stream frame")
+		}
+		return nil, err
+	}
+
+	if frame.RequestID != id {
+		net.Misbehaving(sendTo, PENALTY_MALFORMED_MESSAGE, "response for another request")
+		return nil, fmt.Errorf("%w: got %d, want %d", ErrRequestIDMismatch, frame.RequestID, id)
+	}
+
+	return frame.Payload, nil
+}
+
+// sendRequest runs Request in the background and hands the response to the
+// message worker like any other incoming message.
+func (net *Network) sendRequest(message, sendTo string, request []byte) {
+	go func() {
+		response, err := net.Request(sendTo, request)
+		if err != nil {
+			log.Errorf("%s from %s failed: %v", message, sendTo, err)
+			return
+		}
+
+		if len(response) == 0 {
+			return
+		}
+
+		pid, _ := peer.Decode(sendTo)
+		net.worker.Push(&ChannelContent{
+			Message:  message,
+			SendFrom: ShortID(pid),
+			Payload:  response,
+			Origin:   pid,
+			Direct:   true,
+		})
+	}()
+}
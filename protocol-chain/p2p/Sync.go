@@ -13,6 +13,10 @@ type PeerStatus struct {
 	TotalWork *big.Int
 	IsTarget  bool
 	LastSeen  time.Time
+
+	// Set by the handshake
+	Version  int32
+	Services uint64
 }
 
 var conf = env.New()
@@ -49,6 +53,18 @@ func (sm *SyncManager) UpdatePeerStatus(pID string, height int64, work *big.Int)
 	sm.selectBestPeerLocked()
 }
 
+// SetPeerVersion records the handshake details of a peer already known
+// through UpdatePeerStatus.
+func (sm *SyncManager) SetPeerVersion(pID string, version int32, services uint64) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	if p, ok := sm.peers[pID]; ok {
+		p.Version = version
+		p.Services = services
+	}
+}
+
 func (sm *SyncManager) selectBestPeerLocked() {
 	var best *PeerStatus = sm.target
 	for _, p := range sm.peers {
@@ -78,6 +94,19 @@ func (sm *SyncManager) GetTargetPeer() *PeerStatus {
 	return sm.target
 }
 
+// Peers returns a snapshot of every peer that reported its chain.
+func (sm *SyncManager) Peers() []PeerStatus {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	peers := make([]PeerStatus, 0, len(sm.peers))
+	for _, p := range sm.peers {
+		peers = append(peers, *p)
+	}
+
+	return peers
+}
+
 func (sm *SyncManager) IsSynced(localHeight int64, localWork *big.Int) bool {
 	sm.mu.Lock()
 	defer sm.mu.Unlock()
@@ -98,6 +127,20 @@ func (sm *SyncManager) ClearTarget() {
 	}
 }
 
+func (sm *SyncManager) RemovePeer(pID string) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	if sm.target != nil && sm.target.ID == pID {
+		sm.target = nil
+	}
+	delete(sm.peers, pID)
+
+	if sm.target == nil && len(sm.peers) > 0 {
+		sm.selectBestPeerLocked()
+	}
+}
+
 func (sm *SyncManager) RemoveStalePeersLoop(timeout time.Duration) {
 	sm.mu.Lock()
 	defer sm.mu.Unlock()
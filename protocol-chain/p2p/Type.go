@@ -1,116 +1,142 @@
-package p2p
-
-import (
-	blockchain "core-blockchain/core"
-
-	"github.com/libp2p/go-libp2p/core/host"
-)
-
-type Network struct {
-	Host             host.Host
-	MiningChannel    *Channel
-	FullNodesChannel *Channel
-	Blockchain       *blockchain.Blockchain
-	Blocks           chan *blockchain.Block
-	Transactions     chan []*blockchain.Transaction
-	Miner            bool
-
-	IsMining                   bool
-	competingBlockChan         chan *blockchain.Block
-	peersSyncedWithLocalHeight []string
-	syncCompleted              bool
-
-	// cache block/transaction - Gossip
-	Gossip      *GossipManager
-	syncManager *SyncManager
-
-	worker *Worker[*ChannelContent]
-}
-
-type NetHeadersData struct {
-	Height   int64
-	PrevHash []byte
-	Hash     []byte
-	Nbits    uint32
-}
-
-type NetHeaders struct {
-	SendFrom   string
-	BestHeight int64
-	Data       []NetHeadersData
-}
-
-type NetBlockSync struct {
-	SendFrom   string
-	BestHeight int64
-	Blocks     []blockchain.Block
-}
-
-type NetHeader struct {
-	Hash     []byte
-	Height   int64
-	PrevHash []byte
-	SendFrom string
-}
-
-type NetHeaderLocator struct {
-	SendFrom   string
-	BestHeight int64
-	Locator    [][]byte
-}
-
-type NetGetDataSync struct {
-	SendFrom string
-	Hashes   [][]byte
-}
-
-type NetGetData struct {
-	SendFrom string
-	Height   int64
-	Hash     []byte
-}
-
-type NetTxMining struct {
-	Txs []blockchain.Transaction
-}
-
-type NetBlock struct {
-	SendFrom string
-	Block    []byte
-}
-
-type TxFromPool struct {
-	SendFrom string
-	Count    int64
-}
-
-type NetTx struct {
-	SendFrom    string
-	Transaction []byte
-}
-
-type NetInventoryTxs struct {
-	SendFrom string
-	TxHashes [][]byte
-	Count    uint64
-}
-
-type NetGetDataTransaction struct {
-	SendFrom string
-	TxHashes [][]byte
-}
-
-type NetTransactionData struct {
-	SendFrom     string
-	Transactions []blockchain.Transaction
-}
-
-type NetRequestGossipPeer struct {
-	SendFrom string
-	Count    int64
-}
-
-type NetGossipPeers struct {
-	SendFrom string
-	Peers    []string
-}
+package p2p
+
+import (
+	blockchain "core-blockchain/core"
+	"math/big"
+	"sync"
+
+	"github.com/libp2p/go-libp2p/core/host"
+)
+
+type Network struct {
+	Host             host.Host
+	MiningChannel    *Channel
+	FullNodesChannel *Channel
+	Blockchain       *blockchain.Blockchain
+	Blocks           chan *blockchain.Block
+	Transactions     chan []*blockchain.Transaction
+	Miner            bool
+
+	IsMining                   bool
+	competingBlockChan         chan *blockchain.Block
+	peersSyncedWithLocalHeight []string
+	syncCompleted              bool
+
+	// cache block/transaction - Gossip
+	Gossip      *GossipManager
+	syncManager *SyncManager
+	downloader  *BlockDownloader
+	Bans        *BanManager
+
+	services uint64
+
+	// generateMu serializes blocks mined over RPC.
+	generateMu sync.Mutex
+
+	worker *Worker[*ChannelContent]
+}
+
+// NetHeadersData carries a serialized blockchain.BlockHeader along with the
+// hash of its block. Height is informational, receivers derive it from the
+// header chain. TxCount is needed to check the proof of work of headers
+// below HeaderActivationHeight.
+type NetHeadersData struct {
+	Height  int64
+	Hash    []byte
+	Header  []byte
+	TxCount int64
+}
+
+// NetVersion is exchanged on connect. TotalWork is the cumulative work of
+// the sender's best chain, Services a set of SERVICE_* flags.
+type NetVersion struct {
+	SendFrom        string
+	ProtocolVersion int32
+	ChainID         string
+	GenesisHash     []byte
+	BestHeight      int64
+	TotalWork       *big.Int
+	Services        uint64
+	Timestamp       int64
+}
+
+type NetHeaders struct {
+	SendFrom   string
+	BestHeight int64
+	Data       []NetHeadersData
+}
+
+type NetBlockSync struct {
+	SendFrom   string
+	BestHeight int64
+	Blocks     []blockchain.Block
+}
+
+type NetHeader struct {
+	Hash     []byte
+	Height   int64
+	PrevHash []byte
+	SendFrom string
+}
+
+type NetHeaderLocator struct {
+	SendFrom   string
+	BestHeight int64
+	Locator    [][]byte
+}
+
+type NetGetDataSync struct {
+	SendFrom string
+	Hashes   [][]byte
+}
+
+type NetGetData struct {
+	SendFrom string
+	Height   int64
+	Hash     []byte
+}
+
+type NetTxMining struct {
+	Txs []blockchain.Transaction
+}
+
+type NetBlock struct {
+	SendFrom string
+	Block    []byte
+}
+
+type TxFromPool struct {
+	SendFrom string
+	Count    int64
+}
+
+type NetTx struct {
+	SendFrom    string
+	Transaction []byte
+}
+
+type NetInventoryTxs struct {
+	SendFrom string
+	TxHashes [][]byte
+	Count    uint64
+}
+
+type NetGetDataTransaction struct {
+	SendFrom string
+	TxHashes [][]byte
+}
+
+type NetTransactionData struct {
+	SendFrom     string
+	Transactions []blockchain.Transaction
+}
+
+type NetRequestGossipPeer struct {
+	SendFrom string
+	Count    int64
+}
+
+type NetGossipPeers struct {
+	SendFrom string
+	Peers    []string
+}
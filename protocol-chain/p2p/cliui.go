@@ -191,8 +191,19 @@ func (ui *CLIUI) handleEvents(net *Network, logFile string) {
 
 func (ui *CLIUI) HandleStream(net *Network, content *ChannelContent) {
 	if content.Payload != nil {
+		if len(content.Payload) < commandLength {
+			net.Misbehaving(content.Origin.String(), PENALTY_MALFORMED_MESSAGE, "message shorter than command")
+			return
+		}
+
 		command := BytesToCmd(content.Payload[:commandLength])
 
+		// Requests and responses travel over SYNC_PROTOCOL_ID, see Stream.go.
+		if !content.Direct && !isAnnouncement(command) {
+			log.Warnf("Ignored %s message published on GossipSub by %s", command, content.Origin)
+			return
+		}
+
 		switch command {
 		// Sync Block
 		case PREFIX_HEADER:
@@ -201,32 +212,20 @@ func (ui *CLIUI) HandleStream(net *Network, content *ChannelContent) {
 			net.HandleGetBlockData(content)
 		case PREFIX_TX_MINING:
 			net.HandleTxMining(content)
-		case PREFIX_GET_DATA:
-			net.HandleGetData(content)
-		case PREFIX_HEADER_LOCATOR:
-			net.HandleGetHeaderLocator(content)
 		case PREFIX_HEADER_SYNC:
 			net.HandleGetHeaderSync(content)
-		case PREFIX_GET_DATA_SYNC:
-			net.HandleGetDataSync(content)
 		case PREFIX_BLOCK_SYNC:
 			net.HandleGetBlockDataSync(content)
 
 		// Sync Transaction
 		case PREFIX_TX:
 			net.HandleTx(content)
-		case PREFIX_TX_FROM_POOL:
-			net.HandleGetTxFromPool(content)
 		case PREFIX_INVENTORY:
 			net.HandleGetTxPoolInv(content)
 		case PREFIX_TXS_Data:
 			net.HandleGetTransactions(content)
-		case PREFIX_DATA_TX:
-			net.HandleGetDataTx(content)
 
 			// Gossip Peers
-		case PREFIX_REQUEST_GOSSIP_PEERS:
-			net.HandleGetRequestGossipPeer(content)
 		case PREFOX_GOSSIP_PEERS:
 			net.HandleGetGossipPeers(content)
 		default:
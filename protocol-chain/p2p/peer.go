@@ -17,8 +17,10 @@ import (
 	log "github.com/sirupsen/logrus"
 )
 
+const peersFileName = "known_peers.json"
+
 var (
-	peersFile = path.Join(Root, "/.chain/known_peers.json")
+	peersFile = path.Join(Root, "/.chain", peersFileName)
 	peerLock  sync.Mutex
 
 	PeerMaxAge     = 7 * 24 * time.Hour
@@ -39,6 +39,11 @@ func BlockForNetwork(block blockchain.Block) blockchain.Block {
 	}
 }
 
+// EncodeMessage prefixes the gob encoding of data with command.
+func EncodeMessage(command string, data any) []byte {
+	return append(CmdToBytes(command), GobEncode(data)...)
+}
+
 func CmdToBytes(cmd string) []byte {
 	var bytes [commandLength]byte
 	for i, c := range cmd {
@@ -0,0 +1,98 @@
+package wallet
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"io"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+const (
+	// scrypt cost of the wallet passphrase, about 100ms per attempt.
+	scryptN = 1 << 15
+	scryptR = 8
+	scryptP = 1
+
+	walletKeyLength  = 32
+	walletSaltLength = 16
+)
+
+var (
+	ErrWalletLocked       = errors.New("wallet is locked")
+	ErrWalletEncrypted    = errors.New("wallet is already encrypted")
+	ErrWalletNotEncrypted = errors.New("wallet is not encrypted")
+	ErrWrongPassphrase    = errors.New("wrong passphrase")
+	ErrEmptyPassphrase    = errors.New("passphrase must not be empty")
+)
+
+// KDFParams derive the wallet key from the passphrase with scrypt.
+type KDFParams struct {
+	Salt []byte
+	N    int
+	R    int
+	P    int
+}
+
+func newKDFParams() (KDFParams, error) {
+	salt := make([]byte, walletSaltLength)
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		return KDFParams{}, err
+	}
+
+	return KDFParams{Salt: salt, N: scryptN, R: scryptR, P: scryptP}, nil
+}
+
+func (k KDFParams) deriveKey(passphrase string) ([]byte, error) {
+	if passphrase == "" {
+		return nil, ErrEmptyPassphrase
+	}
+
+	return scrypt.Key([]byte(passphrase), k.Salt, k.N, k.R, k.P, walletKeyLength)
+}
+
+func newWalletAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+// seal encrypts plaintext under key with AES-256-GCM and a random nonce.
+func seal(key, plaintext []byte) (nonce, sealed []byte, err error) {
+	aead, err := newWalletAEAD(key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	nonce = make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, nil, err
+	}
+
+	return nonce, aead.Seal(nil, nonce, plaintext, nil), nil
+}
+
+// open reverses seal. A wrong key fails authentication, reported as
+// ErrWrongPassphrase.
+func open(key, nonce, sealed []byte) ([]byte, error) {
+	aead, err := newWalletAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(nonce) != aead.NonceSize() {
+		return nil, ErrWrongPassphrase
+	}
+
+	plaintext, err := aead.Open(nil, nonce, sealed, nil)
+	if err != nil {
+		return nil, ErrWrongPassphrase
+	}
+
+	return plaintext, nil
+}
@@ -0,0 +1,261 @@
+package wallet
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/tyler-smith/go-bip39"
+)
+
+// HD wallets derive every key from the seed of a BIP39 mnemonic along
+// m/44'/coin'/0'/chain/index. Derivation follows SLIP-10 for NIST P-256, the
+// curve transactions are signed with.
+const (
+	HardenedKeyStart uint32 = 0x80000000
+
+	// ExternalChain holds receive addresses, InternalChain change addresses.
+	ExternalChain uint32 = 0
+	InternalChain uint32 = 1
+
+	// GapLimit is how many unused addresses in a row end a rescan.
+	GapLimit = 20
+
+	hdPurpose      uint32 = 44
+	hdAccount      uint32 = 0
+	hdMasterKey           = "Nist256p1 seed"
+	mnemonicBitLen        = 128
+)
+
+var (
+	ErrInvalidMnemonic = errors.New("invalid mnemonic")
+	ErrHDSeedExists    = errors.New("wallet already has an HD seed")
+	ErrNoHDSeed        = errors.New("wallet has no HD seed")
+)
+
+// HDChain is the seed of an HD pool and the next unused index of its
+// external and internal chain.
+type HDChain struct {
+	Seed []byte
+	Next [2]uint32
+}
+
+// extendedKey is a private key with the chain code its children are derived
+// with.
+type extendedKey struct {
+	key       []byte
+	chainCode []byte
+}
+
+// NewMnemonic returns a fresh 12 word BIP39 mnemonic.
+func NewMnemonic() (string, error) {
+	entropy, err := bip39.NewEntropy(mnemonicBitLen)
+	if err != nil {
+		return "", err
+	}
+
+	return bip39.NewMnemonic(entropy)
+}
+
+func newMasterKey(seed []byte) *extendedKey {
+	n := elliptic.P256().Params().N
+
+	mac := hmac.New(sha512.New, []byte(hdMasterKey))
+	mac.Write(seed)
+	sum := mac.Sum(nil)
+
+	for {
+		k := new(big.Int).SetBytes(sum[:32])
+		if k.Sign() > 0 && k.Cmp(n) < 0 {
+			return &extendedKey{key: sum[:32], chainCode: sum[32:]}
+		}
+
+		mac = hmac.New(sha512.New, []byte(hdMasterKey))
+		mac.Write(sum)
+		sum = mac.Sum(nil)
+	}
+}
+
+// child derives the key at index, hardened from HardenedKeyStart on.
+func (k *extendedKey) child(index uint32) *extendedKey {
+	curve := elliptic.P256()
+	n := curve.Params().N
+
+	var data []byte
+	if index >= HardenedKeyStart {
+		data = append([]byte{0}, k.key...)
+	} else {
+		x, y := curve.ScalarBaseMult(k.key)
+		data = elliptic.MarshalCompressed(curve, x, y)
+	}
+	data = binary.BigEndian.AppendUint32(data, index)
+
+	parent := new(big.Int).SetBytes(k.key)
+
+	for {
+		mac := hmac.New(sha512.New, k.chainCode)
+		mac.Write(data)
+		sum := mac.Sum(nil)
+
+		il := new(big.Int).SetBytes(sum[:32])
+		if il.Cmp(n) < 0 {
+			il.Add(il, parent).Mod(il, n)
+			if il.Sign() > 0 {
+				return &extendedKey{key: il.FillBytes(make([]byte, 32)), chainCode: sum[32:]}
+			}
+		}
+
+		data = append([]byte{1}, sum[32:]...)
+		data = binary.BigEndian.AppendUint32(data, index)
+	}
+}
+
+func (k *extendedKey) wallet(path string) *Wallet {
+	curve := elliptic.P256()
+
+	private := ecdsa.PrivateKey{D: new(big.Int).SetBytes(k.key)}
+	private.PublicKey.Curve = curve
+	private.PublicKey.X, private.PublicKey.Y = curve.ScalarBaseMult(k.key)
+
+	// Coordinates are padded to 32 bytes, verifiers split the key in half.
+	public := append(private.PublicKey.X.FillBytes(make([]byte, 32)), private.PublicKey.Y.FillBytes(make([]byte, 32))...)
+
+	return &Wallet{PrivateKey: private, PublicKey: public, Path: path}
+}
+
+// deriveWallet returns the wallet at index of chain below the account of
+// the pool's network.
+func (wp *WalletPool) deriveWallet(chain, index uint32) *Wallet {
+	coinType := wp.Params.HDCoinType
+
+	key := newMasterKey(wp.HD.Seed)
+	for _, i := range []uint32{hdPurpose + HardenedKeyStart, coinType + HardenedKeyStart, hdAccount + HardenedKeyStart, chain, index} {
+		key = key.child(i)
+	}
+
+	return key.wallet(fmt.Sprintf("m/%d'/%d'/%d'/%d/%d", hdPurpose, coinType, hdAccount, chain, index))
+}
+
+func (wp *WalletPool) IsHD() bool {
+	return wp.HD != nil
+}
+
+// SetMnemonic makes the pool derive its new wallets from mnemonic. Wallets
+// already in the pool are kept.
+func (wp *WalletPool) SetMnemonic(mnemonic string) error {
+	if wp.IsLocked() {
+		return ErrWalletLocked
+	}
+	if wp.IsHD() {
+		return ErrHDSeedExists
+	}
+
+	seed, err := bip39.NewSeedWithErrorChecking(mnemonic, "")
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidMnemonic, err)
+	}
+
+	wp.HD = &HDChain{Seed: seed}
+
+	if wp.IsEncrypted() {
+		if err := wp.seal(); err != nil {
+			wp.HD = nil
+			return err
+		}
+	}
+
+	return nil
+}
+
+// NewAddress derives the next unused wallet of chain.
+func (wp *WalletPool) NewAddress(chain uint32) (string, error) {
+	if wp.IsLocked() {
+		return "", ErrWalletLocked
+	}
+	if !wp.IsHD() {
+		return "", ErrNoHDSeed
+	}
+	if chain > InternalChain {
+		return "", fmt.Errorf("unknown HD chain %d", chain)
+	}
+
+	wallet := wp.deriveWallet(chain, wp.HD.Next[chain])
+	address := string(wallet.Address(wp.Params))
+
+	wp.Wallets[address] = wallet
+	wp.HD.Next[chain]++
+	// Its key makes a watched address spendable.
+	watched, wasWatched := wp.Watched[address]
+	delete(wp.Watched, address)
+
+	if wp.IsEncrypted() {
+		if err := wp.seal(); err != nil {
+			delete(wp.Wallets, address)
+			wp.HD.Next[chain]--
+			if wasWatched {
+				wp.Watched[address] = watched
+			}
+			return "", err
+		}
+	}
+
+	return address, nil
+}
+
+// ChangeAddress returns a fresh change address of an HD pool, from for any
+// other pool.
+func (wp *WalletPool) ChangeAddress(from string) (string, error) {
+	if !wp.IsHD() {
+		return from, nil
+	}
+
+	return wp.NewAddress(InternalChain)
+}
+
+// Rescan walks both chains of an HD pool from index 0 and adds every wallet
+// whose public key hash used reports, until GapLimit unused wallets follow
+// each other. The next indexes move past the last used wallet. It returns
+// how many used wallets it found.
+func (wp *WalletPool) Rescan(used func(pubKeyHash []byte) bool) (int, error) {
+	if wp.IsLocked() {
+		return 0, ErrWalletLocked
+	}
+	if !wp.IsHD() {
+		return 0, ErrNoHDSeed
+	}
+
+	found := 0
+
+	for _, chain := range []uint32{ExternalChain, InternalChain} {
+		gap := 0
+		for index := uint32(0); gap < GapLimit; index++ {
+			wallet := wp.deriveWallet(chain, index)
+			if !used(PublicKeyHash(wallet.PublicKey)) {
+				gap++
+				continue
+			}
+
+			gap = 0
+			found++
+			address := string(wallet.Address(wp.Params))
+			wp.Wallets[address] = wallet
+			delete(wp.Watched, address)
+			if index >= wp.HD.Next[chain] {
+				wp.HD.Next[chain] = index + 1
+			}
+		}
+	}
+
+	if wp.IsEncrypted() {
+		if err := wp.seal(); err != nil {
+			return 0, err
+		}
+	}
+
+	return found, nil
+}
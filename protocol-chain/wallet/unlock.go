@@ -0,0 +1,101 @@
+package wallet
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// UnlockSession keeps the key of an encrypted wallet file for a while, so a
+// running node can sign with the wallets it loads from the file without the
+// passphrase. The key lives in memory only and is dropped on Lock or when the
+// timeout passes.
+type UnlockSession struct {
+	mu    sync.Mutex
+	key   []byte
+	until time.Time
+	timer *time.Timer
+}
+
+// Unlock checks passphrase against the encrypted pool wp and keeps its key
+// for timeout. wp is left unlocked. It returns when the session locks again.
+func (s *UnlockSession) Unlock(wp *WalletPool, passphrase string, timeout time.Duration) (time.Time, error) {
+	if err := wp.Unlock(passphrase); err != nil {
+		return time.Time{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.lockLocked()
+
+	var timer *time.Timer
+	timer = time.AfterFunc(timeout, func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		// A later Unlock replaced this timer.
+		if s.timer == timer {
+			s.lockLocked()
+		}
+	})
+
+	s.key = append([]byte(nil), wp.key...)
+	s.until = time.Now().Add(timeout)
+	s.timer = timer
+
+	return s.until, nil
+}
+
+// Lock drops the key before the timeout.
+func (s *UnlockSession) Lock() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.lockLocked()
+}
+
+func (s *UnlockSession) lockLocked() {
+	if s.timer != nil {
+		s.timer.Stop()
+		s.timer = nil
+	}
+
+	clear(s.key)
+	s.key = nil
+	s.until = time.Time{}
+}
+
+// Until returns when the session locks, zero while it is locked.
+func (s *UnlockSession) Until() time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.until
+}
+
+// Open unlocks wp with the session key so its wallets can sign. A plain pool
+// needs nothing. It fails with ErrWalletLocked while the session is locked or
+// when the file was encrypted under another passphrase since Unlock.
+func (s *UnlockSession) Open(wp *WalletPool) error {
+	if !wp.IsEncrypted() {
+		return nil
+	}
+
+	s.mu.Lock()
+	key := append([]byte(nil), s.key...)
+	s.mu.Unlock()
+
+	if len(key) == 0 {
+		return ErrWalletLocked
+	}
+
+	if err := wp.unlockWithKey(key); err != nil {
+		if errors.Is(err, ErrWrongPassphrase) {
+			return ErrWalletLocked
+		}
+		return err
+	}
+
+	return nil
+}
@@ -2,6 +2,7 @@ package wallet
 
 import (
 	"bytes"
+	"core-blockchain/chaincfg"
 	"core-blockchain/common/env"
 	"crypto/ecdsa"
 	"crypto/elliptic"
@@ -18,12 +19,13 @@ var conf = env.New()
 
 var (
 	checkSumlength = conf.WalletAddressCheckSum
-	version        = byte(0x00)
 )
 
 type Wallet struct {
 	PrivateKey ecdsa.PrivateKey
 	PublicKey  []byte
+	// Path is the derivation path of an HD wallet, empty otherwise.
+	Path string
 }
 
 type WalletSerializable struct {
@@ -31,19 +33,28 @@ type WalletSerializable struct {
 	PublicKeyX []byte
 	PublicKeyY []byte
 	PublicKey  []byte
+	Path       string
 }
 
 func NewWallet() *Wallet {
 	private, public := NewKeyPair()
-	return &Wallet{private, public}
+	return &Wallet{PrivateKey: private, PublicKey: public}
 }
 
-func ValidateAddress(address string) bool {
+// ValidateAddress reports whether address is well formed and belongs to the
+// network of params, be it a public key hash or a script hash address.
+func ValidateAddress(address string, params *chaincfg.Params) bool {
 	if len(address) != 34 {
 		return false
 	}
 
 	fullHash := Base58Decode([]byte(address))
+	if int64(len(fullHash)) <= checkSumlength {
+		return false
+	}
+	if fullHash[0] != params.AddressVersion && fullHash[0] != params.ScriptAddressVersion {
+		return false
+	}
 
 	checkSumFromHash := fullHash[int64(len(fullHash))-checkSumlength:]
 	version := fullHash[0]
@@ -53,21 +64,35 @@ func ValidateAddress(address string) bool {
 	return bytes.Equal(checkSumFromHash, checkSum)
 }
 
-func (w *Wallet) Address() []byte {
-	pubHash := PublicKeyHash(w.PublicKey)
-	versionedHash := append([]byte{version}, pubHash...)
+// IsScriptAddress reports whether address, assumed valid, pays to a script
+// hash rather than to a public key hash.
+func IsScriptAddress(address string) bool {
+	fullHash := Base58Decode([]byte(address))
 
-	checksum := CheckSum(versionedHash)
+	return len(fullHash) > 0 && chaincfg.IsScriptAddressVersion(fullHash[0])
+}
 
-	fullHash := append(versionedHash, checksum...)
-	address := Base58Encode(fullHash)
+func (w *Wallet) Address(params *chaincfg.Params) []byte {
+	return PubKeyToAddr(w.PublicKey, params)
+}
 
-	return address
+func PubKeyToAddr(pubKey []byte, params *chaincfg.Params) []byte {
+	return PubKeyHashToAddr(PublicKeyHash(pubKey), params)
+}
+
+// PubKeyHashToAddr returns the address paying to pubHash.
+func PubKeyHashToAddr(pubHash []byte, params *chaincfg.Params) []byte {
+	return encodeAddress(params.AddressVersion, pubHash)
+}
+
+// ScriptHashToAddr returns the address paying to the script hashing to
+// scriptHash.
+func ScriptHashToAddr(scriptHash []byte, params *chaincfg.Params) []byte {
+	return encodeAddress(params.ScriptAddressVersion, scriptHash)
 }
 
-func PubKeyToAddr(pubKey []byte) []byte {
-	pubHash := PublicKeyHash(pubKey)
-	versionedHash := append([]byte{version}, pubHash...)
+func encodeAddress(version byte, hash []byte) []byte {
+	versionedHash := append([]byte{version}, hash...)
 
 	checksum := CheckSum(versionedHash)
 
@@ -92,6 +117,7 @@ func (w *Wallet) Serialize() (*WalletSerializable, error) {
 		PublicKeyX: pubKeyX,
 		PublicKeyY: pubKeyY,
 		PublicKey:  w.PublicKey,
+		Path:       w.Path,
 	}, nil
 }
 
@@ -105,6 +131,7 @@ func (ws *WalletSerializable) Deserialize() (*Wallet, error) {
 	return &Wallet{
 		PrivateKey: *priv,
 		PublicKey:  ws.PublicKey,
+		Path:       ws.Path,
 	}, nil
 }
 
@@ -117,7 +144,9 @@ func NewKeyPair() (ecdsa.PrivateKey, []byte) {
 		log.Panic(err)
 	}
 
-	pub := append(private.PublicKey.X.Bytes(), private.PublicKey.Y.Bytes()...)
+	// Coordinates are padded, a key shorter than 64 bytes is split in the
+	// wrong place when it is verified.
+	pub := append(private.PublicKey.X.FillBytes(make([]byte, 32)), private.PublicKey.Y.FillBytes(make([]byte, 32))...)
 
 	return *private, pub
 
@@ -2,33 +2,63 @@ package wallet
 
 import (
 	"bytes"
+	"core-blockchain/chaincfg"
+	"crypto/ecdsa"
 	"crypto/elliptic"
 	"encoding/gob"
-	"errors"
 	"fmt"
+	"math/big"
 
 	"os"
 	"path"
 	"path/filepath"
 	"runtime"
-
-	log "github.com/sirupsen/logrus"
 )
 
 var (
 	_, file, _, _ = runtime.Caller(0)
 
 	Root           = filepath.Join(filepath.Dir(file), "../")
-	walletPath     = path.Join(Root, "/.chain/")
 	walletFileName = ".wallets"
+
+	// encryptedWalletMagic starts an encrypted wallet file, a plain one is a
+	// bare gob stream.
+	encryptedWalletMagic = []byte("NOVAWENC")
 )
 
+const encryptedWalletVersion = 1
+
+// WalletPool holds the wallets of one network, addresses are encoded for
+// Params. An encrypted pool loads locked: its wallets carry public keys only
+// until Unlock decrypts the private keys. An HD pool derives its wallets
+// from the seed in HD, which is sealed with the private keys. Watched holds
+// the watch-only addresses, which are never sealed.
 type WalletPool struct {
 	Wallets map[string]*Wallet
+	Watched map[string]*WatchOnly
+	Params  *chaincfg.Params
+	HD      *HDChain
+
+	encrypted *EncryptedWalletFile
+	key       []byte
 }
 
 type WalletPoolSerializable struct {
 	Wallets map[string]*WalletSerializable
+	HD      *HDChain
+	Watched map[string]*WatchOnly
+}
+
+// EncryptedWalletFile is the content of an encrypted wallet file after its
+// magic. Addresses and public keys stay readable, Sealed holds the
+// WalletPoolSerializable of every wallet under the passphrase key.
+type EncryptedWalletFile struct {
+	Version    int
+	KDF        KDFParams
+	PublicKeys map[string][]byte
+	Watched    map[string]*WatchOnly
+	Nonce      []byte
+	Sealed     []byte
 }
 
 func ChainExists(path string) bool {
@@ -39,32 +69,57 @@ func ChainExists(path string) bool {
 	return true
 }
 
-func InitializeWallets(cwd bool) (*WalletPool, error) {
-	var walletPool WalletPool
+func InitializeWallets(cwd bool, params *chaincfg.Params) (*WalletPool, error) {
+	walletPool := WalletPool{Params: params}
 
 	err := walletPool.LoadFile(cwd)
 
 	return &walletPool, err
 }
 
+// GetWallet returns the wallet of address to sign with. Watch-only
+// addresses have nothing to sign with.
 func (wp *WalletPool) GetWallet(address string) (Wallet, error) {
-	var wallet *Wallet
-	var ok bool
-	w := *wp
-	if wallet, ok = w.Wallets[address]; !ok {
-		return *new(Wallet), errors.New("invalid address")
+	if wp.IsWatchOnly(address) {
+		return *new(Wallet), ErrWatchOnly
+	}
+
+	wallet, ok := wp.Wallets[address]
+	if !ok {
+		return *new(Wallet), ErrInvalidAddress
+	}
+
+	if wp.IsLocked() {
+		return *new(Wallet), ErrWalletLocked
 	}
 
 	return *wallet, nil
 }
 
-func (wp *WalletPool) AddWallet() string {
+// AddWallet creates a wallet, the next receive wallet of an HD pool. An
+// encrypted pool has to be unlocked to seal the new private key.
+func (wp *WalletPool) AddWallet() (string, error) {
+	if wp.IsLocked() {
+		return "", ErrWalletLocked
+	}
+
+	if wp.IsHD() {
+		return wp.NewAddress(ExternalChain)
+	}
+
 	wallet := NewWallet()
-	address := string(wallet.Address())
+	address := string(wallet.Address(wp.Params))
 
 	wp.Wallets[address] = wallet
 
-	return address
+	if wp.IsEncrypted() {
+		if err := wp.seal(); err != nil {
+			delete(wp.Wallets, address)
+			return "", err
+		}
+	}
+
+	return address, nil
 }
 
 func (wp *WalletPool) GetAllAddress() []string {
@@ -76,110 +131,353 @@ func (wp *WalletPool) GetAllAddress() []string {
 	return addresses
 }
 
-func (wp *WalletPool) LoadFile(cwd bool) error {
-	if !ChainExists(walletPath) {
-		err := os.MkdirAll(walletPath, 0755)
-		if err != nil {
-			return err
-		}
-		fmt.Println(".chain directory created:", walletPath)
+func (wp *WalletPool) IsEncrypted() bool {
+	return wp.encrypted != nil
+}
+
+// IsLocked reports whether the pool is encrypted and its private keys are
+// not decrypted.
+func (wp *WalletPool) IsLocked() bool {
+	return wp.encrypted != nil && wp.key == nil
+}
+
+// Encrypt seals the private keys under passphrase and locks the pool.
+// SaveFile writes them encrypted from then on.
+func (wp *WalletPool) Encrypt(passphrase string) error {
+	if wp.IsEncrypted() {
+		return ErrWalletEncrypted
 	}
 
-	walletFile := path.Join(walletPath, walletFileName)
+	kdf, err := newKDFParams()
+	if err != nil {
+		return err
+	}
 
-	if cwd {
-		dir, err := os.Getwd()
-		if err != nil {
-			return err
-		}
-		walletFile = path.Join(dir, walletFileName)
+	key, err := kdf.deriveKey(passphrase)
+	if err != nil {
+		return err
 	}
 
-	if _, err := os.Stat(walletFile); os.IsNotExist(err) {
-		file, err := os.Create(walletFile)
-		if err != nil {
-			return err
+	wp.encrypted = &EncryptedWalletFile{Version: encryptedWalletVersion, KDF: kdf}
+	wp.key = key
+
+	if err := wp.seal(); err != nil {
+		clear(key)
+		wp.encrypted = nil
+		wp.key = nil
+		return err
+	}
+
+	wp.Lock()
+
+	return nil
+}
+
+// Unlock decrypts the private keys with passphrase.
+func (wp *WalletPool) Unlock(passphrase string) error {
+	if !wp.IsEncrypted() {
+		return ErrWalletNotEncrypted
+	}
+
+	key, err := wp.encrypted.KDF.deriveKey(passphrase)
+	if err != nil {
+		return err
+	}
+
+	return wp.unlockWithKey(key)
+}
+
+func (wp *WalletPool) unlockWithKey(key []byte) error {
+	plaintext, err := open(key, wp.encrypted.Nonce, wp.encrypted.Sealed)
+	if err != nil {
+		return err
+	}
+
+	content, err := decodeWallets(plaintext)
+	if err != nil {
+		return err
+	}
+
+	wp.Wallets = content.Wallets
+	wp.HD = content.HD
+	wp.key = key
+
+	return nil
+}
+
+// Lock drops the decrypted private keys and HD seed and keeps the public
+// keys. It does nothing for a plain pool.
+func (wp *WalletPool) Lock() {
+	if !wp.IsEncrypted() {
+		return
+	}
+
+	// Zero the secrets in place like UnlockSession does, dropping them
+	// leaves the bytes on the heap until they are reused.
+	for addr, w := range wp.Wallets {
+		if w.PrivateKey.D != nil {
+			clear(w.PrivateKey.D.Bits())
 		}
-		file.Close()
-		fmt.Println(".wallets file created:", walletPath)
+		wp.Wallets[addr] = publicOnlyWallet(w.PublicKey)
 	}
 
-	walletPool := WalletPoolSerializable{
-		Wallets: map[string]*WalletSerializable{},
+	if wp.HD != nil {
+		clear(wp.HD.Seed)
 	}
-	fileContent, err := os.ReadFile(walletFile)
+
+	clear(wp.key)
+	wp.key = nil
+	wp.HD = nil
+}
+
+// ChangePassphrase seals the private keys under newPassphrase. The pool
+// stays locked or unlocked as it was.
+func (wp *WalletPool) ChangePassphrase(oldPassphrase, newPassphrase string) error {
+	if !wp.IsEncrypted() {
+		return ErrWalletNotEncrypted
+	}
+
+	oldKey, err := wp.encrypted.KDF.deriveKey(oldPassphrase)
 	if err != nil {
 		return err
 	}
 
-	if len(fileContent) == 0 {
-		wp.Wallets = make(map[string]*Wallet)
+	plaintext, err := open(oldKey, wp.encrypted.Nonce, wp.encrypted.Sealed)
+	clear(oldKey)
+	if err != nil {
+		return err
+	}
+	defer clear(plaintext)
+
+	kdf, err := newKDFParams()
+	if err != nil {
+		return err
+	}
+
+	newKey, err := kdf.deriveKey(newPassphrase)
+	if err != nil {
+		return err
+	}
+
+	nonce, sealed, err := seal(newKey, plaintext)
+	if err != nil {
+		clear(newKey)
+		return err
+	}
+
+	wp.encrypted.KDF = kdf
+	wp.encrypted.Nonce = nonce
+	wp.encrypted.Sealed = sealed
+
+	if wp.key == nil {
+		clear(newKey)
 		return nil
 	}
 
-	gob.Register(elliptic.P256())
-	decoder := gob.NewDecoder(bytes.NewReader(fileContent))
-	err = decoder.Decode(&walletPool)
+	clear(wp.key)
+	wp.key = newKey
+
+	return nil
+}
 
+// seal encrypts every wallet under the pool key into the encrypted file.
+func (wp *WalletPool) seal() error {
+	plaintext, err := encodeWallets(wp.Wallets, wp.HD, nil)
 	if err != nil {
 		return err
 	}
 
-	ws := WalletPool{
-		Wallets: map[string]*Wallet{},
+	nonce, sealed, err := seal(wp.key, plaintext)
+	if err != nil {
+		return err
 	}
 
-	for addr, wallet := range walletPool.Wallets {
-		w, err := wallet.Deserialize()
-		if err != nil {
-			return err
-		}
-		ws.Wallets[addr] = w
+	publicKeys := make(map[string][]byte, len(wp.Wallets))
+	for addr, w := range wp.Wallets {
+		publicKeys[addr] = w.PublicKey
 	}
 
-	wp.Wallets = ws.Wallets
+	wp.encrypted.PublicKeys = publicKeys
+	wp.encrypted.Nonce = nonce
+	wp.encrypted.Sealed = sealed
 
 	return nil
 }
 
-func (wp *WalletPool) SaveFile(cwd bool) {
-	walletFile := path.Join(walletPath, walletFileName)
+func publicOnlyWallet(pubKey []byte) *Wallet {
+	half := len(pubKey) / 2
 
-	if cwd {
-		dir, err := os.Getwd()
-		if err != nil {
-			log.Error(err)
-			return
-		}
-		walletFile = path.Join(dir, walletFileName)
+	w := &Wallet{PublicKey: pubKey}
+	w.PrivateKey.PublicKey = ecdsa.PublicKey{
+		Curve: elliptic.P256(),
+		X:     new(big.Int).SetBytes(pubKey[:half]),
+		Y:     new(big.Int).SetBytes(pubKey[half:]),
 	}
 
+	return w
+}
+
+func encodeWallets(wallets map[string]*Wallet, hd *HDChain, watched map[string]*WatchOnly) ([]byte, error) {
 	var content bytes.Buffer
 	gob.Register(elliptic.P256())
 
 	walletPool := WalletPoolSerializable{
 		Wallets: map[string]*WalletSerializable{},
+		HD:      hd,
+		Watched: watched,
 	}
 
-	for addr, wallet := range wp.Wallets {
+	for addr, wallet := range wallets {
 		w, err := wallet.Serialize()
 		if err != nil {
-			log.Error(err)
-			return
+			return nil, err
 		}
 		walletPool.Wallets[addr] = w
 	}
 
 	encoder := gob.NewEncoder(&content)
-	err := encoder.Encode(&walletPool)
+	if err := encoder.Encode(&walletPool); err != nil {
+		return nil, err
+	}
+
+	return content.Bytes(), nil
+}
+
+// decodeWallets returns a pool holding the wallets, HD seed and watch-only
+// addresses of data.
+func decodeWallets(data []byte) (*WalletPool, error) {
+	walletPool := WalletPoolSerializable{
+		Wallets: map[string]*WalletSerializable{},
+	}
+
+	gob.Register(elliptic.P256())
+	decoder := gob.NewDecoder(bytes.NewReader(data))
+	if err := decoder.Decode(&walletPool); err != nil {
+		return nil, err
+	}
+
+	wallets := map[string]*Wallet{}
+
+	for addr, wallet := range walletPool.Wallets {
+		w, err := wallet.Deserialize()
+		if err != nil {
+			return nil, err
+		}
+		wallets[addr] = w
+	}
+
+	return &WalletPool{Wallets: wallets, Watched: walletPool.Watched, HD: walletPool.HD}, nil
+}
+
+func (wp *WalletPool) filePath(cwd bool) (string, error) {
+	if cwd {
+		dir, err := os.Getwd()
+		if err != nil {
+			return "", err
+		}
+		return path.Join(dir, walletFileName), nil
+	}
+
+	return path.Join(wp.Params.ChainDir(Root), walletFileName), nil
+}
+
+func (wp *WalletPool) LoadFile(cwd bool) error {
+	walletPath := wp.Params.ChainDir(Root)
+	if !ChainExists(walletPath) {
+		err := os.MkdirAll(walletPath, 0755)
+		if err != nil {
+			return err
+		}
+		fmt.Println(".chain directory created:", walletPath)
+	}
+
+	walletFile, err := wp.filePath(cwd)
 	if err != nil {
-		log.Error(err)
-		return
+		return err
 	}
 
-	err = os.WriteFile(walletFile, content.Bytes(), 0644)
+	if _, err := os.Stat(walletFile); os.IsNotExist(err) {
+		file, err := os.OpenFile(walletFile, os.O_CREATE|os.O_WRONLY, 0600)
+		if err != nil {
+			return err
+		}
+		file.Close()
+		fmt.Println(".wallets file created:", walletPath)
+	}
+
+	fileContent, err := os.ReadFile(walletFile)
 	if err != nil {
-		log.Error(err)
-		return
+		return err
+	}
+
+	if len(fileContent) == 0 {
+		wp.Wallets = make(map[string]*Wallet)
+		return nil
+	}
+
+	if bytes.HasPrefix(fileContent, encryptedWalletMagic) {
+		var encrypted EncryptedWalletFile
+		decoder := gob.NewDecoder(bytes.NewReader(fileContent[len(encryptedWalletMagic):]))
+		if err := decoder.Decode(&encrypted); err != nil {
+			return err
+		}
+
+		if encrypted.Version != encryptedWalletVersion {
+			return fmt.Errorf("unknown wallet file version %d", encrypted.Version)
+		}
+
+		wp.encrypted = &encrypted
+		wp.Watched = encrypted.Watched
+		wp.Wallets = make(map[string]*Wallet, len(encrypted.PublicKeys))
+		for addr, pubKey := range encrypted.PublicKeys {
+			wp.Wallets[addr] = publicOnlyWallet(pubKey)
+		}
+
+		return nil
+	}
+
+	content, err := decodeWallets(fileContent)
+	if err != nil {
+		return err
+	}
+
+	wp.Wallets = content.Wallets
+	wp.Watched = content.Watched
+	wp.HD = content.HD
+
+	return nil
+}
+
+// SaveFile writes the wallets readable by the owner only, encrypted when the
+// pool is.
+func (wp *WalletPool) SaveFile(cwd bool) error {
+	walletFile, err := wp.filePath(cwd)
+	if err != nil {
+		return err
 	}
+
+	var content []byte
+
+	if wp.IsEncrypted() {
+		wp.encrypted.Watched = wp.Watched
+
+		var buf bytes.Buffer
+		buf.Write(encryptedWalletMagic)
+		if err := gob.NewEncoder(&buf).Encode(wp.encrypted); err != nil {
+			return err
+		}
+		content = buf.Bytes()
+	} else {
+		content, err = encodeWallets(wp.Wallets, wp.HD, wp.Watched)
+		if err != nil {
+			return err
+		}
+	}
+
+	tmp := walletFile + ".tmp"
+	if err := os.WriteFile(tmp, content, 0600); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, walletFile)
 }
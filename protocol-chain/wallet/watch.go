@@ -0,0 +1,120 @@
+package wallet
+
+import (
+	"bytes"
+	"crypto/elliptic"
+	"encoding/hex"
+	"errors"
+	"math/big"
+)
+
+var (
+	ErrWatchOnly        = errors.New("address is watch-only")
+	ErrAddressInWallet  = errors.New("address is already in the wallet")
+	ErrInvalidAddress   = errors.New("invalid address")
+	ErrInvalidPublicKey = errors.New("invalid public key")
+)
+
+// WatchOnly is an address the pool follows without holding its private key.
+// PublicKey is empty when only the address was imported.
+type WatchOnly struct {
+	PubKeyHash []byte
+	PublicKey  []byte
+}
+
+// AddressPubKeyHash returns the hash encoded in address, the script hash of
+// a script address.
+func AddressPubKeyHash(address string) []byte {
+	fullHash := Base58Decode([]byte(address))
+
+	return fullHash[1 : int64(len(fullHash))-checkSumlength]
+}
+
+// ValidatePublicKey reports whether pubKey is a point of the curve in the
+// X | Y form wallets use.
+func ValidatePublicKey(pubKey []byte) bool {
+	if len(pubKey) == 0 || len(pubKey) > 64 || len(pubKey)%2 != 0 {
+		return false
+	}
+
+	half := len(pubKey) / 2
+	x := new(big.Int).SetBytes(pubKey[:half])
+	y := new(big.Int).SetBytes(pubKey[half:])
+
+	return elliptic.P256().IsOnCurve(x, y)
+}
+
+// ImportAddress watches address. It needs no unlocked pool, watch-only
+// entries are stored readable in the wallet file.
+func (wp *WalletPool) ImportAddress(address string) error {
+	if !ValidateAddress(address, wp.Params) {
+		return ErrInvalidAddress
+	}
+	if _, ok := wp.Wallets[address]; ok {
+		return ErrAddressInWallet
+	}
+	if _, ok := wp.Watched[address]; ok {
+		return nil
+	}
+
+	wp.watch(address, &WatchOnly{PubKeyHash: AddressPubKeyHash(address)})
+
+	return nil
+}
+
+// ImportPubKey watches the address of pubKey and returns it. A watched
+// address learns its public key this way.
+func (wp *WalletPool) ImportPubKey(pubKey []byte) (string, error) {
+	if !ValidatePublicKey(pubKey) {
+		return "", ErrInvalidPublicKey
+	}
+
+	address := string(PubKeyToAddr(pubKey, wp.Params))
+	if _, ok := wp.Wallets[address]; ok {
+		return "", ErrAddressInWallet
+	}
+
+	wp.watch(address, &WatchOnly{PubKeyHash: PublicKeyHash(pubKey), PublicKey: bytes.Clone(pubKey)})
+
+	return address, nil
+}
+
+func (wp *WalletPool) watch(address string, entry *WatchOnly) {
+	if wp.Watched == nil {
+		wp.Watched = make(map[string]*WatchOnly)
+	}
+
+	wp.Watched[address] = entry
+}
+
+func (wp *WalletPool) IsWatchOnly(address string) bool {
+	_, ok := wp.Watched[address]
+	return ok
+}
+
+func (wp *WalletPool) GetWatchOnlyAddresses() []string {
+	var addresses []string
+	for address := range wp.Watched {
+		addresses = append(addresses, address)
+	}
+
+	return addresses
+}
+
+// PubKeyHashes maps the hex encoded public key hash of every wallet, and of
+// the watched addresses when watchOnly is set, to its address.
+func (wp *WalletPool) PubKeyHashes(watchOnly bool) map[string]string {
+	hashes := make(map[string]string, len(wp.Wallets)+len(wp.Watched))
+
+	for address, w := range wp.Wallets {
+		hashes[hex.EncodeToString(PublicKeyHash(w.PublicKey))] = address
+	}
+
+	if watchOnly {
+		for address, entry := range wp.Watched {
+			hashes[hex.EncodeToString(entry.PubKeyHash)] = address
+		}
+	}
+
+	return hashes
+}
@@ -329,8 +329,8 @@ offset $1 limit $2;
 
 
 -- name: CreateTxInput :one
-insert into tx_inputs (tx_id, input_tx_id, out_index, sig, b_id, pub_key)
-values ($1, $2, $3, $4, $5, $6) returning *;
+insert into tx_inputs (tx_id, input_tx_id, out_index, sig, b_id, pub_key, unlocking_script)
+values ($1, $2, $3, $4, $5, $6, $7) returning *;
 
 -- name: GetListTxInputByTxID :many
 select * from tx_inputs where tx_id = $1;
@@ -344,8 +344,8 @@ select * from tx_inputs where b_id = $1;
 
 
 -- name: CreateTxOutput :one
-insert into tx_outputs (tx_id, value, pub_key_hash, b_id, index)
-values ($1, $2, $3, $4, $5) returning *;
+insert into tx_outputs (tx_id, value, pub_key_hash, b_id, index, script_type, locking_script)
+values ($1, $2, $3, $4, $5, $6, $7) returning *;
 
 -- name: FindListTxOutputByBlockID :many
 select * from tx_outputs where b_id = $1;
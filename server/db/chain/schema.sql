@@ -42,7 +42,8 @@ CREATE TABLE IF NOT EXISTS tx_inputs (
     out_index BIGINT CHECK (out_index >= -1) NOT NULL,
     sig TEXT,
     b_id VARCHAR(64) NOT NULL REFERENCES blocks(b_id) ON DELETE CASCADE,
-    pub_key TEXT
+    pub_key TEXT,
+    unlocking_script TEXT
 );
 
 CREATE INDEX idx_txinputs_block_id ON tx_inputs(b_id);
@@ -55,12 +56,13 @@ CREATE TABLE IF NOT EXISTS tx_outputs (
     index BIGINT NOT NULL CHECK (index >= -1),
     value NUMERIC(20, 8) NOT NULL CHECK (value >= 0),
     b_id VARCHAR(64) NOT NULL REFERENCES blocks(b_id) ON DELETE CASCADE,
-    pub_key_hash VARCHAR(40) NOT NULL
-
+    pub_key_hash VARCHAR(40) NOT NULL,
+    script_type VARCHAR(16) NOT NULL DEFAULT 'pubkeyhash',
+    locking_script TEXT
 );
 
 CREATE INDEX IF NOT EXISTS idx_txoutputs_pubkeyhash_trgm
 ON tx_outputs USING gin (pub_key_hash gin_trgm_ops);
 CREATE INDEX idx_txoutputs_block_id ON tx_outputs(b_id);
 CREATE INDEX idx_txoutputs_pubkeyhash ON tx_outputs(pub_key_hash);
-CREATE INDEX idx_txoutputs_txid_index ON tx_outputs(tx_id, index);
\ No newline at end of file
+CREATE INDEX idx_txoutputs_txid_index ON tx_outputs(tx_id, index);
\ No newline at end of file
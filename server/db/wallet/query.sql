@@ -45,6 +45,11 @@ UPDATE wallets
 SET balance = balance - $1
 WHERE address = $2 AND public_key = $3 AND balance >= $1;
 
+-- name: DecreaseWalletBalanceByPubKeyHash :exec
+UPDATE wallets
+SET balance = balance - $1
+WHERE public_key_hash = $2 AND balance >= $1;
+
 -- name: UpdateWalletLastLogin :exec
 UPDATE wallets
 SET last_login = now()
@@ -2,18 +2,22 @@ package transaction
 
 import (
 	"ChainServer/internal/common/apperror"
+	"ChainServer/internal/common/coinselect"
 	"ChainServer/internal/common/dto"
 	"ChainServer/internal/common/utils"
 	"encoding/hex"
 	"time"
 )
 
+// TransactionDataDto.CoinSelection picks how the inputs are chosen: bnb,
+// largest, smallest or all. It is left out of the signed data when empty.
 type TransactionDataDto struct {
-	Fee       float64 `json:"fee" validate:"required,gt=0"`
-	Amount    float64 `json:"amount" validate:"required,gt=0"`
-	To        string  `json:"to" validate:"required,len=34"`
-	Timestamp int64   `json:"timestamp" validate:"required,gt=0"`
-	Priority  uint64  `json:"priority" validate:"required,gte=0"`
+	Fee           float64 `json:"fee" validate:"required,gt=0"`
+	Amount        float64 `json:"amount" validate:"required,gt=0"`
+	To            string  `json:"to" validate:"required,len=34"`
+	Timestamp     int64   `json:"timestamp" validate:"required,gt=0"`
+	Priority      uint64  `json:"priority" validate:"required,gte=0"`
+	CoinSelection string  `json:"coinSelection,omitempty" validate:"omitempty,oneof=bnb largest smallest all"`
 }
 
 type NewTransactionDto struct {
@@ -64,11 +68,17 @@ func (s *SendTransactionDto) ValidateAndParse() (any, error) {
 			return nil, apperror.BadRequest("pubkey is not format", nil)
 		}
 
+		unlockingScript, err := hex.DecodeString(in.UnlockingScript)
+		if err != nil {
+			return nil, apperror.BadRequest("unlocking script is not format", nil)
+		}
+
 		input := TxInput{
-			ID:        inId,
-			Out:       in.Out,
-			Signature: sigByte,
-			PubKey:    pubkey,
+			ID:              inId,
+			Out:             in.Out,
+			Signature:       sigByte,
+			PubKey:          pubkey,
+			UnlockingScript: unlockingScript,
 		}
 		inputs = append(inputs, input)
 	}
@@ -79,9 +89,15 @@ func (s *SendTransactionDto) ValidateAndParse() (any, error) {
 			return nil, apperror.BadRequest("pubkey is not format", nil)
 		}
 
+		lockingScript, err := hex.DecodeString(out.LockingScript)
+		if err != nil {
+			return nil, apperror.BadRequest("locking script is not format", nil)
+		}
+
 		outputs = append(outputs, TxOutput{
-			Value:      out.Value,
-			PubKeyHash: pubKeyHash,
+			Value:         out.Value,
+			PubKeyHash:    pubKeyHash,
+			LockingScript: lockingScript,
 		})
 	}
 
@@ -129,12 +145,18 @@ func (tx *NewTransactionDto) ValidateAndParse() (any, error) {
 		return nil, apperror.BadRequest("Invalid format to address", nil)
 	}
 
+	strategy, err := coinselect.ParseStrategy(tx.Data.CoinSelection)
+	if err != nil {
+		return nil, apperror.BadRequest("Coin selection must be one of bnb, largest, smallest or all.", nil)
+	}
+
 	parsed := &NewTransactionParsed{
 		Data: TransactionDataParsed{
-			Fee:       tx.Data.Fee,
-			Amount:    tx.Data.Amount,
-			To:        toAddrBytes,
-			Timestamp: time.Unix(tx.Data.Timestamp, 0),
+			Fee:           tx.Data.Fee,
+			Amount:        tx.Data.Amount,
+			To:            toAddrBytes,
+			Timestamp:     time.Unix(tx.Data.Timestamp, 0),
+			CoinSelection: strategy,
 		},
 		Sig: sigBytes,
 	}
@@ -142,6 +164,50 @@ func (tx *NewTransactionDto) ValidateAndParse() (any, error) {
 	return parsed, nil
 }
 
+type PSBTDto struct {
+	Psbt string `json:"psbt" validate:"required,base64"`
+}
+
+func (d *PSBTDto) ValidateAndParse() (any, error) {
+	psbt, err := DecodePSBT(d.Psbt)
+	if err != nil {
+		return nil, apperror.BadRequest(err.Error(), nil)
+	}
+
+	return psbt, nil
+}
+
+type SendPSBTDto struct {
+	Psbt     string `json:"psbt" validate:"required,base64"`
+	Priority uint   `json:"priority" validate:"required,gte=0"`
+}
+
+func (d *SendPSBTDto) ValidateAndParse() (any, error) {
+	psbt, err := DecodePSBT(d.Psbt)
+	if err != nil {
+		return nil, apperror.BadRequest(err.Error(), nil)
+	}
+
+	return SendPSBTParsed{PSBT: psbt, Priority: d.Priority}, nil
+}
+
+type CombinePSBTDto struct {
+	Psbts []string `json:"psbts" validate:"required,min=1,max=16,dive,required,base64"`
+}
+
+func (d *CombinePSBTDto) ValidateAndParse() (any, error) {
+	var psbts []*PSBT
+	for _, s := range d.Psbts {
+		psbt, err := DecodePSBT(s)
+		if err != nil {
+			return nil, apperror.BadRequest(err.Error(), nil)
+		}
+		psbts = append(psbts, psbt)
+	}
+
+	return psbts, nil
+}
+
 type GetTransactionSearchDto struct {
 	B_Hash          string `query:"b_hash" validate:"required,hexadecimal,len=64"`
 	Search_Tx_Query string `query:"q" validate:"omitempty"`
@@ -152,6 +218,10 @@ type GetTransactionPendingDto struct {
 	dto.PaginationQuery
 }
 
+type GetFeeEstimatesDto struct {
+	Size int64 `query:"size" validate:"omitempty,gt=0,lte=1000000"`
+}
+
 type GetTransactionDetailDto struct {
 	TxHash string `params:"tx_hash" validate:"required,hexadecimal,len=64"`
 }
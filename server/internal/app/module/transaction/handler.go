@@ -89,6 +89,87 @@ func (h *TransactionHandler) SendTransaction(c *fiber.Ctx) error {
 	)
 }
 
+func (h *TransactionHandler) CreatePSBT(c *fiber.Ctx) error {
+	dto, apperr := helpers.GetLocalBody[*NewTransactionParsed](c)
+	if apperr != nil {
+		return apperr.Response(c)
+	}
+
+	walletPayload, apperr := helpers.GetLocalWallet(c)
+	if apperr != nil {
+		return apperr.Response(c)
+	}
+
+	psbt, apperr := h.service.CreatePSBT(walletPayload, *dto)
+	if apperr != nil {
+		return apperr.Response(c)
+	}
+
+	return response.Success(
+		c,
+		psbt,
+		"Create PSBT Successfully",
+		fiber.StatusCreated,
+	)
+}
+
+func (h *TransactionHandler) SendPSBT(c *fiber.Ctx) error {
+	walletPayload, apperr := helpers.GetLocalWallet(c)
+	if apperr != nil {
+		return apperr.Response(c)
+	}
+
+	dto, apperr := helpers.GetLocalBody[SendPSBTParsed](c)
+	if apperr != nil {
+		return apperr.Response(c)
+	}
+
+	sent, apperr := h.service.SendPSBT(walletPayload, dto)
+	if apperr != nil {
+		return apperr.Response(c)
+	}
+
+	return response.Success(
+		c,
+		sent,
+		"Send PSBT Successfully",
+		fiber.StatusCreated,
+	)
+}
+
+func (h *TransactionHandler) DecodePSBT(c *fiber.Ctx) error {
+	psbt, apperr := helpers.GetLocalBody[*PSBT](c)
+	if apperr != nil {
+		return apperr.Response(c)
+	}
+
+	return response.Success(
+		c,
+		h.service.DecodePSBT(*psbt),
+		"Decode PSBT successfully",
+		fiber.StatusOK,
+	)
+}
+
+func (h *TransactionHandler) CombinePSBT(c *fiber.Ctx) error {
+	psbts, apperr := helpers.GetLocalBody[[]*PSBT](c)
+	if apperr != nil {
+		return apperr.Response(c)
+	}
+
+	combined, apperr := h.service.CombinePSBT(*psbts)
+	if apperr != nil {
+		return apperr.Response(c)
+	}
+
+	return response.Success(
+		c,
+		combined,
+		"Combine PSBTs successfully",
+		fiber.StatusOK,
+	)
+}
+
 func (h *TransactionHandler) GetListTransactionPending(c *fiber.Ctx) error {
 
 	wallet, apperr := helpers.GetLocalWallet(c)
@@ -156,6 +237,25 @@ func (h *TransactionHandler) GetPendingTransactions(c *fiber.Ctx) error {
 	)
 }
 
+func (h *TransactionHandler) GetFeeEstimates(c *fiber.Ctx) error {
+	queries, apperr := helpers.GetLocalQuery[GetFeeEstimatesDto](c)
+	if apperr != nil {
+		return apperr.Response(c)
+	}
+
+	estimates, appErr := h.service.GetFeeEstimates(queries)
+	if appErr != nil {
+		return appErr.Response(c)
+	}
+
+	return response.Success(
+		c,
+		estimates,
+		"Get fee estimates successfully",
+		fiber.StatusOK,
+	)
+}
+
 func (h *TransactionHandler) GetTxSummary(c *fiber.Ctx) error {
 	auth, appErr := helpers.GetLocalWallet(c)
 	if appErr != nil {
@@ -2,6 +2,7 @@ package transaction
 
 import (
 	"ChainServer/internal/common/apperror"
+	"ChainServer/internal/common/chaincfg"
 	"ChainServer/internal/common/utils"
 	dbPendingTx "ChainServer/internal/db/pendingTx"
 	dbutxo "ChainServer/internal/db/utxo"
@@ -10,7 +11,6 @@ import (
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
-	"strconv"
 
 	log "github.com/sirupsen/logrus"
 )
@@ -26,18 +26,44 @@ type Transaction struct {
 	Outputs []TxOutput
 }
 
+// TxOutput.Value is expressed in base units. LockingScript replaces
+// PubKeyHash for outputs paying to a script, see the node's TxOutput.
 type TxOutput struct {
-	Value      float64
-	PubKeyHash []byte
+	Value         int64
+	PubKeyHash    []byte
+	LockingScript []byte
 }
 
-type TxInput struct {
+// legacyTransaction mirrors the JSON layout signed before the network's
+// IntegerAmountHeight, when output values were float64 coins.
+type legacyTransaction struct {
+	ID      []byte
+	Inputs  []legacyTxInput
+	Outputs []legacyTxOutput
+}
+
+type legacyTxInput struct {
 	ID        []byte
 	Out       int64
 	Signature []byte
 	PubKey    []byte
 }
 
+type legacyTxOutput struct {
+	Value      float64
+	PubKeyHash []byte
+}
+
+// TxInput.UnlockingScript replaces Signature and PubKey for inputs
+// spending a LockingScript.
+type TxInput struct {
+	ID              []byte
+	Out             int64
+	Signature       []byte
+	PubKey          []byte
+	UnlockingScript []byte
+}
+
 type TxInputWithDataToSign struct {
 	ID         []byte `json:"id"`
 	Out        int64  `json:"out"`
@@ -82,8 +108,9 @@ func (tx *Transaction) TrimmedCopy() Transaction {
 
 	for _, out := range tx.Outputs {
 		outputs = append(outputs, TxOutput{
-			Value:      out.Value,
-			PubKeyHash: out.PubKeyHash,
+			Value:         out.Value,
+			PubKeyHash:    out.PubKeyHash,
+			LockingScript: out.LockingScript,
 		})
 	}
 
@@ -96,9 +123,29 @@ func (tx *Transaction) TrimmedCopy() Transaction {
 	return txCopy
 }
 
-func (tx *Transaction) SerializeAndHexEncode() string {
+func (tx *Transaction) SerializeAndHexEncode(height int64) string {
+	var payload any = tx
+
+	if height < chaincfg.Active().IntegerAmountHeight {
+		legacy := legacyTransaction{ID: tx.ID}
+		for _, in := range tx.Inputs {
+			legacy.Inputs = append(legacy.Inputs, legacyTxInput{
+				ID:        in.ID,
+				Out:       in.Out,
+				Signature: in.Signature,
+				PubKey:    in.PubKey,
+			})
+		}
+		for _, out := range tx.Outputs {
+			legacy.Outputs = append(legacy.Outputs, legacyTxOutput{
+				Value:      utils.UnitsToLegacyFloat(out.Value),
+				PubKeyHash: out.PubKeyHash,
+			})
+		}
+		payload = legacy
+	}
 
-	serializedBytes, err := json.Marshal(tx)
+	serializedBytes, err := json.Marshal(payload)
 	if err != nil {
 		log.Panicf("Failed to JSON marshal transaction: %v", err.Error())
 	}
@@ -110,36 +157,77 @@ func (tx *Transaction) SerializeAndHexEncode() string {
 	return hex.EncodeToString(data)
 }
 
+// HasScripts reports whether tx carries an unlocking or locking script.
+func (tx *Transaction) HasScripts() bool {
+	for _, in := range tx.Inputs {
+		if len(in.UnlockingScript) > 0 {
+			return true
+		}
+	}
+	for _, out := range tx.Outputs {
+		if len(out.LockingScript) > 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
+// AddressHash returns the hash the address of out encodes, nil for
+// non standard scripts.
+func (out *TxOutput) AddressHash() []byte {
+	if len(out.LockingScript) == 0 {
+		return out.PubKeyHash
+	}
+	if scriptType, hash := utils.ClassifyScript(out.LockingScript); scriptType == utils.ScriptTypeScriptHash {
+		return hash
+	}
+
+	return nil
+}
+
+// Address returns the address out pays to, empty when it has none.
+func (out *TxOutput) Address() string {
+	hash := out.AddressHash()
+	if hash == nil {
+		return ""
+	}
+	if len(out.LockingScript) > 0 {
+		return string(utils.ScriptHashToAddress(hash))
+	}
+
+	return string(utils.PubKeyHashToAddress(hash))
+}
+
 func (tx *Transaction) BalanceCheck(prevTXs map[string]dbutxo.Utxo) bool {
-	totalInput := 0.0
-	totalOutput := 0.0
+	totalInput := utils.ZeroAmount()
+	totalOutput := utils.ZeroAmount()
 
 	for _, in := range tx.Inputs {
 		prevTx, ok := prevTXs[hex.EncodeToString(in.ID)]
 		if !ok {
 			return false
 		}
-		value, err := strconv.ParseFloat(prevTx.Value, 64)
+		value, err := utils.NewCoinAmountFromString(prevTx.Value)
 		if err != nil {
 			log.Error("Balance Check Error: ", err)
 			return false
 		}
-		totalInput += value
+		totalInput = totalInput.Add(value)
 	}
 
 	for _, out := range tx.Outputs {
-		totalOutput += out.Value
+		totalOutput = totalOutput.Add(utils.NewCoinAmountFromUnits(out.Value))
 	}
 
-	fee := totalInput - totalOutput
-	if fee < 0 {
-		return false
-	}
+	fee := utils.SumFees(totalInput, totalOutput)
 
-	return totalInput >= totalOutput
+	return !fee.IsNegative()
 }
 
-func (tx *Transaction) WithSigning(prevTXs map[string]dbutxo.Utxo) (*TransactionWithSigning, *apperror.AppError) {
+// WithSigning returns the digests each input has to sign for a transaction
+// that will be mined at height.
+func (tx *Transaction) WithSigning(prevTXs map[string]dbutxo.Utxo, height int64) (*TransactionWithSigning, *apperror.AppError) {
 
 	var inputs []TxInputWithDataToSign
 
@@ -162,10 +250,21 @@ func (tx *Transaction) WithSigning(prevTXs map[string]dbutxo.Utxo) (*Transaction
 			return nil, apperror.Internal("Something went wrong, please try again", nil)
 		}
 
-		txCopy.Inputs[inID].Signature = nil
-		txCopy.Inputs[inID].PubKey = pubKeyHash
+		var dataToSign string
+		if height >= chaincfg.Active().IntegerAmountHeight {
+			digest, err := tx.SigHash(inID, pubKeyHash, SigHashAll)
+			if err != nil {
+				return nil, apperror.BadRequest(err.Error(), nil)
+			}
+			dataToSign = hex.EncodeToString(digest)
+		} else {
+			txCopy.Inputs[inID].Signature = nil
+			txCopy.Inputs[inID].PubKey = pubKeyHash
+
+			dataToSign = txCopy.SerializeAndHexEncode(height)
 
-		dataToSign := txCopy.SerializeAndHexEncode()
+			txCopy.Inputs[inID].PubKey = nil
+		}
 
 		TxID, err := hex.DecodeString(prevTx.TxID)
 		if err != nil {
@@ -179,8 +278,6 @@ func (tx *Transaction) WithSigning(prevTXs map[string]dbutxo.Utxo) (*Transaction
 			PubKey:     in.PubKey,
 			DataToSign: dataToSign,
 		})
-
-		txCopy.Inputs[inID].PubKey = nil
 	}
 
 	txWithSigning := TransactionWithSigning{
@@ -0,0 +1,555 @@
+package transaction
+
+import (
+	"ChainServer/internal/common/chaincfg"
+	"ChainServer/internal/common/utils"
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"maps"
+	"math/big"
+	"slices"
+	"strings"
+)
+
+// PSBT mirrors the node's partially signed transaction container and its
+// serialization, see the node's core/PSBT.go for the layout. The server
+// creates PSBTs for its wallets and finalizes the ones signers send back, it
+// never signs. It does not run scripts either, inputs spending a script hash
+// count once the node finalized them.
+const PSBTVersion uint32 = 2
+
+var psbtMagic = []byte{'p', 's', 'b', 't', 0xff}
+
+var (
+	ErrInvalidPSBT        = errors.New("invalid psbt")
+	ErrPSBTMismatch       = errors.New("psbts spend different transactions")
+	ErrPSBTNotFinal       = errors.New("psbt is not finalized")
+	ErrMissingPrevOutput  = errors.New("previous output of input is unknown")
+	ErrPrevOutputMismatch = errors.New("previous output does not match")
+	ErrInvalidPartialSig  = errors.New("invalid partial signature")
+	ErrPSBTScriptInput    = errors.New("psbt inputs must spend a public key hash")
+)
+
+type PSBT struct {
+	Height  int64
+	Tx      Transaction
+	Inputs  []PSBTInput
+	Outputs []PSBTOutput
+}
+
+// PSBTInput.SigHashType 0 signs with SigHashAll. PartialSigs maps hex encoded
+// public keys to their signature. RedeemScript and FinalScript belong to
+// inputs spending a script hash.
+type PSBTInput struct {
+	PrevOutput     *TxOutput
+	SigHashType    SigHashType
+	Path           string
+	RedeemScript   []byte
+	PartialSigs    map[string][]byte
+	FinalSignature []byte
+	FinalScript    []byte
+}
+
+// IsFinal reports whether the input carries what Extract puts in the
+// transaction.
+func (in *PSBTInput) IsFinal() bool {
+	return len(in.FinalSignature) > 0 || len(in.FinalScript) > 0
+}
+
+// redeems reports whether script is the redeem script prev commits to.
+func redeems(script []byte, prev *TxOutput) bool {
+	return prev != nil && bytes.Equal(utils.NewPayToScriptHashScript(utils.ScriptHash(script)), prev.LockingScript)
+}
+
+type PSBTOutput struct {
+	PubKey []byte
+	Path   string
+}
+
+// NewPSBT wraps the unsigned tx for signing at height. prevOutputs holds the
+// output every input spends, in input order.
+func NewPSBT(tx *Transaction, prevOutputs []TxOutput, height int64) (*PSBT, error) {
+	if len(tx.Inputs) == 0 || len(tx.Outputs) == 0 || len(prevOutputs) != len(tx.Inputs) {
+		return nil, fmt.Errorf("%w: transaction needs inputs, outputs and previous outputs", ErrInvalidPSBT)
+	}
+
+	p := &PSBT{
+		Height:  height,
+		Tx:      Transaction{ID: bytes.Clone(tx.ID)},
+		Inputs:  make([]PSBTInput, len(tx.Inputs)),
+		Outputs: make([]PSBTOutput, len(tx.Outputs)),
+	}
+
+	for i, in := range tx.Inputs {
+		if len(in.Signature) > 0 || len(in.UnlockingScript) > 0 {
+			return nil, fmt.Errorf("%w: input %d is signed", ErrInvalidPSBT, i)
+		}
+		if len(prevOutputs[i].LockingScript) > 0 {
+			return nil, fmt.Errorf("input %d: %w", i, ErrPSBTScriptInput)
+		}
+		if len(in.PubKey) > 0 && !bytes.Equal(utils.PublicKeyHash(in.PubKey), prevOutputs[i].PubKeyHash) {
+			return nil, fmt.Errorf("input %d: %w", i, ErrPrevOutputMismatch)
+		}
+
+		p.Tx.Inputs = append(p.Tx.Inputs, TxInput{ID: bytes.Clone(in.ID), Out: in.Out, PubKey: bytes.Clone(in.PubKey)})
+		p.Inputs[i].PrevOutput = &TxOutput{Value: prevOutputs[i].Value, PubKeyHash: bytes.Clone(prevOutputs[i].PubKeyHash)}
+	}
+	for _, out := range tx.Outputs {
+		p.Tx.Outputs = append(p.Tx.Outputs, TxOutput{Value: out.Value, PubKeyHash: bytes.Clone(out.PubKeyHash), LockingScript: bytes.Clone(out.LockingScript)})
+	}
+
+	return p, nil
+}
+
+// SetOutputKey marks output idx as locked to pubKey, a change output.
+func (p *PSBT) SetOutputKey(idx int, pubKey []byte) error {
+	if idx < 0 || idx >= len(p.Outputs) {
+		return fmt.Errorf("output index %d out of range", idx)
+	}
+	if !bytes.Equal(utils.PublicKeyHash(pubKey), p.Tx.Outputs[idx].PubKeyHash) {
+		return fmt.Errorf("output %d: public key does not match", idx)
+	}
+
+	p.Outputs[idx].PubKey = bytes.Clone(pubKey)
+
+	return nil
+}
+
+func (p *PSBT) unsignedTx() []byte {
+	buf := new(bytes.Buffer)
+	p.Tx.serializeBinary(buf)
+	return buf.Bytes()
+}
+
+// Combine merges the data other collected for the same transaction.
+func (p *PSBT) Combine(other *PSBT) error {
+	if p.Height != other.Height || !bytes.Equal(p.unsignedTx(), other.unsignedTx()) {
+		return ErrPSBTMismatch
+	}
+
+	for i := range p.Inputs {
+		in, o := &p.Inputs[i], &other.Inputs[i]
+
+		if o.PrevOutput != nil {
+			if in.PrevOutput == nil {
+				in.PrevOutput = &TxOutput{Value: o.PrevOutput.Value, PubKeyHash: bytes.Clone(o.PrevOutput.PubKeyHash), LockingScript: bytes.Clone(o.PrevOutput.LockingScript)}
+			} else if in.PrevOutput.Value != o.PrevOutput.Value || !bytes.Equal(in.PrevOutput.PubKeyHash, o.PrevOutput.PubKeyHash) ||
+				!bytes.Equal(in.PrevOutput.LockingScript, o.PrevOutput.LockingScript) {
+				return fmt.Errorf("input %d: %w", i, ErrPrevOutputMismatch)
+			}
+		}
+
+		if len(o.RedeemScript) > 0 {
+			if !redeems(o.RedeemScript, in.PrevOutput) {
+				return fmt.Errorf("input %d: %w", i, ErrPrevOutputMismatch)
+			}
+			in.RedeemScript = bytes.Clone(o.RedeemScript)
+		}
+
+		if o.SigHashType != 0 {
+			if in.SigHashType != 0 && in.SigHashType != o.SigHashType {
+				return fmt.Errorf("input %d: conflicting sighash types", i)
+			}
+			in.SigHashType = o.SigHashType
+		}
+
+		if in.Path == "" {
+			in.Path = o.Path
+		}
+
+		for key, sig := range o.PartialSigs {
+			if in.PartialSigs == nil {
+				in.PartialSigs = make(map[string][]byte)
+			}
+			if _, ok := in.PartialSigs[key]; !ok {
+				in.PartialSigs[key] = bytes.Clone(sig)
+			}
+		}
+
+		if !in.IsFinal() {
+			in.FinalSignature = bytes.Clone(o.FinalSignature)
+			in.FinalScript = bytes.Clone(o.FinalScript)
+		}
+	}
+
+	for i := range p.Outputs {
+		if len(p.Outputs[i].PubKey) == 0 {
+			p.Outputs[i] = PSBTOutput{PubKey: bytes.Clone(other.Outputs[i].PubKey), Path: other.Outputs[i].Path}
+		}
+	}
+
+	return nil
+}
+
+// IsSigned reports whether every input carries the signature of its key.
+func (p *PSBT) IsSigned() bool {
+	for i, in := range p.Inputs {
+		if in.IsFinal() {
+			continue
+		}
+		if _, ok := in.PartialSigs[hex.EncodeToString(p.Tx.Inputs[i].PubKey)]; !ok || len(p.Tx.Inputs[i].PubKey) == 0 {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (p *PSBT) IsFinalized() bool {
+	for _, in := range p.Inputs {
+		if !in.IsFinal() {
+			return false
+		}
+	}
+
+	return true
+}
+
+// verifyInput checks signature as the one of input idx, the way the node
+// does for a block at height.
+func (p *PSBT) verifyInput(idx int, signature []byte) bool {
+	pubKey := p.Tx.Inputs[idx].PubKey
+	prevPubKeyHash := p.Inputs[idx].PrevOutput.PubKeyHash
+
+	var r, s *big.Int
+	var digest []byte
+
+	if p.Height >= chaincfg.Active().IntegerAmountHeight {
+		var hashType SigHashType
+		var err error
+
+		r, s, hashType, err = DecodeSignature(signature)
+		if err != nil {
+			return false
+		}
+
+		digest, err = p.Tx.SigHash(idx, prevPubKeyHash, hashType)
+		if err != nil {
+			return false
+		}
+	} else {
+		r = new(big.Int).SetBytes(signature[:len(signature)/2])
+		s = new(big.Int).SetBytes(signature[len(signature)/2:])
+
+		txCopy := p.Tx.TrimmedCopy()
+		txCopy.Inputs[idx].PubKey = prevPubKeyHash
+
+		var err error
+		digest, err = hex.DecodeString(txCopy.SerializeAndHexEncode(p.Height))
+		if err != nil {
+			return false
+		}
+	}
+
+	x := new(big.Int).SetBytes(pubKey[:len(pubKey)/2])
+	y := new(big.Int).SetBytes(pubKey[len(pubKey)/2:])
+
+	return ecdsa.Verify(&ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y}, digest, r, s)
+}
+
+// Finalize checks the signature of the key of every input and moves it to
+// FinalSignature. It reports whether every input is final.
+func (p *PSBT) Finalize() (bool, error) {
+	for i := range p.Inputs {
+		in := &p.Inputs[i]
+		if in.IsFinal() {
+			continue
+		}
+
+		pubKey := p.Tx.Inputs[i].PubKey
+		signature, ok := in.PartialSigs[hex.EncodeToString(pubKey)]
+		if !ok || len(pubKey) == 0 {
+			continue
+		}
+		if in.PrevOutput == nil {
+			return false, fmt.Errorf("input %d: %w", i, ErrMissingPrevOutput)
+		}
+
+		if !p.verifyInput(i, signature) {
+			return false, fmt.Errorf("input %d: %w", i, ErrInvalidPartialSig)
+		}
+
+		in.FinalSignature = signature
+		in.PartialSigs = nil
+	}
+
+	return p.IsFinalized(), nil
+}
+
+// Fee returns what the inputs leave over the outputs, false while a
+// previous output is unknown.
+func (p *PSBT) Fee() (int64, bool) {
+	var fee int64
+
+	for _, in := range p.Inputs {
+		if in.PrevOutput == nil {
+			return 0, false
+		}
+		fee += in.PrevOutput.Value
+	}
+	for _, out := range p.Tx.Outputs {
+		fee -= out.Value
+	}
+
+	return fee, true
+}
+
+// Extract returns the signed transaction of a finalized PSBT.
+func (p *PSBT) Extract() (*Transaction, error) {
+	if !p.IsFinalized() {
+		return nil, ErrPSBTNotFinal
+	}
+
+	if fee, ok := p.Fee(); !ok || fee < 0 {
+		return nil, fmt.Errorf("%w: outputs exceed inputs", ErrInvalidPSBT)
+	}
+
+	tx := &Transaction{ID: bytes.Clone(p.Tx.ID)}
+	for i, in := range p.Tx.Inputs {
+		tx.Inputs = append(tx.Inputs, TxInput{
+			ID:              bytes.Clone(in.ID),
+			Out:             in.Out,
+			Signature:       bytes.Clone(p.Inputs[i].FinalSignature),
+			PubKey:          bytes.Clone(in.PubKey),
+			UnlockingScript: bytes.Clone(p.Inputs[i].FinalScript),
+		})
+	}
+	for _, out := range p.Tx.Outputs {
+		tx.Outputs = append(tx.Outputs, TxOutput{Value: out.Value, PubKeyHash: bytes.Clone(out.PubKeyHash), LockingScript: bytes.Clone(out.LockingScript)})
+	}
+
+	return tx, nil
+}
+
+func (p *PSBT) Serialize() []byte {
+	buf := new(bytes.Buffer)
+
+	buf.Write(psbtMagic)
+	binary.Write(buf, binary.LittleEndian, PSBTVersion)
+	binary.Write(buf, binary.LittleEndian, p.Height)
+	p.Tx.serializeBinary(buf)
+
+	for _, in := range p.Inputs {
+		var prev TxOutput
+		if in.PrevOutput != nil {
+			prev = *in.PrevOutput
+		}
+		writeBytes(buf, prev.PubKeyHash)
+		binary.Write(buf, binary.LittleEndian, prev.Value)
+		binary.Write(buf, binary.LittleEndian, uint32(in.SigHashType))
+		writeBytes(buf, []byte(in.Path))
+
+		keys := slices.Sorted(maps.Keys(in.PartialSigs))
+		binary.Write(buf, binary.LittleEndian, uint32(len(keys)))
+		for _, key := range keys {
+			pubKey, _ := hex.DecodeString(key)
+			writeBytes(buf, pubKey)
+			writeBytes(buf, in.PartialSigs[key])
+		}
+
+		writeBytes(buf, in.FinalSignature)
+		writeBytes(buf, prev.LockingScript)
+		writeBytes(buf, in.RedeemScript)
+		writeBytes(buf, in.FinalScript)
+	}
+
+	for _, out := range p.Outputs {
+		writeBytes(buf, out.PubKey)
+		writeBytes(buf, []byte(out.Path))
+	}
+
+	return buf.Bytes()
+}
+
+func (p *PSBT) Base64() string {
+	return base64.StdEncoding.EncodeToString(p.Serialize())
+}
+
+// psbtReader reads untrusted PSBT data, every length is checked against what
+// is left. The first error sticks.
+type psbtReader struct {
+	buf *bytes.Buffer
+	err error
+}
+
+func (r *psbtReader) read(v any) {
+	if r.err == nil && binary.Read(r.buf, binary.LittleEndian, v) != nil {
+		r.err = fmt.Errorf("%w: truncated", ErrInvalidPSBT)
+	}
+}
+
+func (r *psbtReader) bytes() []byte {
+	var length uint32
+	r.read(&length)
+	if r.err != nil || length == 0 {
+		return nil
+	}
+	if int(length) > r.buf.Len() {
+		r.err = fmt.Errorf("%w: truncated", ErrInvalidPSBT)
+		return nil
+	}
+
+	return bytes.Clone(r.buf.Next(int(length)))
+}
+
+// scriptMarker consumes the marker of a transaction with scripts, see
+// serializeBinary, and reports whether it was there.
+func (r *psbtReader) scriptMarker() bool {
+	if r.err != nil || r.buf.Len() < 4 || binary.LittleEndian.Uint32(r.buf.Bytes()) != scriptMarker {
+		return false
+	}
+	r.buf.Next(4)
+
+	return true
+}
+
+// count reads an item count, each item taking at least min bytes.
+func (r *psbtReader) count(min int) int {
+	var n uint32
+	r.read(&n)
+	if r.err == nil && int(n) > r.buf.Len()/min {
+		r.err = fmt.Errorf("%w: count %d", ErrInvalidPSBT, n)
+	}
+	if r.err != nil {
+		return 0
+	}
+
+	return int(n)
+}
+
+func DeserializePSBT(data []byte) (*PSBT, error) {
+	if !bytes.HasPrefix(data, psbtMagic) {
+		return nil, fmt.Errorf("%w: bad magic", ErrInvalidPSBT)
+	}
+
+	r := &psbtReader{buf: bytes.NewBuffer(data[len(psbtMagic):])}
+
+	var version uint32
+	r.read(&version)
+	if r.err == nil && (version == 0 || version > PSBTVersion) {
+		return nil, fmt.Errorf("%w: version %d", ErrInvalidPSBT, version)
+	}
+
+	p := &PSBT{}
+	r.read(&p.Height)
+
+	p.Tx.ID = r.bytes()
+	scripts := r.scriptMarker()
+	for range r.count(16) {
+		in := TxInput{ID: r.bytes()}
+		r.read(&in.Out)
+		in.Signature = r.bytes()
+		in.PubKey = r.bytes()
+		if scripts {
+			in.UnlockingScript = r.bytes()
+		}
+		p.Tx.Inputs = append(p.Tx.Inputs, in)
+	}
+	for range r.count(12) {
+		out := TxOutput{}
+		r.read(&out.Value)
+		out.PubKeyHash = r.bytes()
+		if scripts {
+			out.LockingScript = r.bytes()
+		}
+		p.Tx.Outputs = append(p.Tx.Outputs, out)
+	}
+
+	for range p.Tx.Inputs {
+		in := PSBTInput{}
+
+		prev := TxOutput{PubKeyHash: r.bytes()}
+		r.read(&prev.Value)
+
+		var hashType uint32
+		r.read(&hashType)
+		in.SigHashType = SigHashType(hashType)
+		in.Path = string(r.bytes())
+
+		for range r.count(8) {
+			pubKey, signature := r.bytes(), r.bytes()
+			if in.PartialSigs == nil {
+				in.PartialSigs = make(map[string][]byte)
+			}
+			in.PartialSigs[hex.EncodeToString(pubKey)] = signature
+		}
+
+		in.FinalSignature = r.bytes()
+		if version > 1 {
+			prev.LockingScript = r.bytes()
+			in.RedeemScript = r.bytes()
+			in.FinalScript = r.bytes()
+		}
+
+		if len(prev.PubKeyHash) > 0 || len(prev.LockingScript) > 0 {
+			in.PrevOutput = &prev
+		}
+		p.Inputs = append(p.Inputs, in)
+	}
+
+	for range p.Tx.Outputs {
+		out := PSBTOutput{PubKey: r.bytes()}
+		out.Path = string(r.bytes())
+		p.Outputs = append(p.Outputs, out)
+	}
+
+	if r.err != nil {
+		return nil, r.err
+	}
+	if r.buf.Len() > 0 {
+		return nil, fmt.Errorf("%w: %d trailing bytes", ErrInvalidPSBT, r.buf.Len())
+	}
+
+	if err := p.check(); err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+func (p *PSBT) check() error {
+	if len(p.Tx.Inputs) == 0 || len(p.Tx.Outputs) == 0 {
+		return fmt.Errorf("%w: transaction needs inputs and outputs", ErrInvalidPSBT)
+	}
+
+	for i, in := range p.Tx.Inputs {
+		if len(in.Signature) > 0 || len(in.UnlockingScript) > 0 {
+			return fmt.Errorf("%w: input %d of the unsigned transaction is signed", ErrInvalidPSBT, i)
+		}
+		if len(in.ID) == 0 {
+			return fmt.Errorf("%w: input %d has no previous transaction", ErrInvalidPSBT, i)
+		}
+		if t := p.Inputs[i].SigHashType; t != 0 && !t.IsValid() {
+			return fmt.Errorf("%w: input %d: %v", ErrInvalidPSBT, i, ErrInvalidSigHashType)
+		}
+		if prev := p.Inputs[i].PrevOutput; prev != nil && len(in.PubKey) > 0 && !bytes.Equal(utils.PublicKeyHash(in.PubKey), prev.PubKeyHash) {
+			return fmt.Errorf("%w: input %d: %v", ErrInvalidPSBT, i, ErrPrevOutputMismatch)
+		}
+		if prev := p.Inputs[i].PrevOutput; prev != nil && len(prev.LockingScript) > 0 {
+			if scriptType, _ := utils.ClassifyScript(prev.LockingScript); scriptType != utils.ScriptTypeScriptHash {
+				return fmt.Errorf("%w: input %d: %v", ErrInvalidPSBT, i, ErrPSBTScriptInput)
+			}
+		}
+		if redeem := p.Inputs[i].RedeemScript; len(redeem) > 0 && !redeems(redeem, p.Inputs[i].PrevOutput) {
+			return fmt.Errorf("%w: input %d: %v", ErrInvalidPSBT, i, ErrPrevOutputMismatch)
+		}
+	}
+
+	return nil
+}
+
+// DecodePSBT parses the base64 form of a PSBT.
+func DecodePSBT(s string) (*PSBT, error) {
+	data, err := base64.StdEncoding.DecodeString(strings.TrimSpace(s))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPSBT, err)
+	}
+
+	return DeserializePSBT(data)
+}
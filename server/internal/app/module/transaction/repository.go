@@ -13,6 +13,7 @@ type RpcTransactionRepository interface {
 	SendTx(txs []Transaction) (*RPCSendTxResponse, error)
 	FetchMiningTxIDs() (*RPCGetMiningTxResponse[string], error)
 	FetchMiningTxsFull() (*RPCGetMiningTxResponse[dto.Transaction], error)
+	EstimateFee(targetBlocks int64) (*RPCEstimateFeeResponse, error)
 }
 
 type DbTransactionRepository interface {
@@ -38,6 +39,7 @@ type DbTransactionRepository interface {
 	SearchFuzzyTransactionsByBlock(ctx context.Context, arg dbchain.SearchFuzzyTransactionsByBlockParams) ([]dbchain.Transaction, error)
 	CountFuzzyTransactionsByBlock(ctx context.Context, arg dbchain.CountFuzzyTransactionsByBlockParams) (int64, error)
 	GetTxSummaryByPubkeyHash(ctx context.Context, pub_key_hash string) (dbchain.GetTxSummaryByPubKeyHashRow, error)
+	GetBestHeight(ctx context.Context, tx *sql.Tx) (int64, error)
 
 	// ---------------- Pending Transactions ----------------
 	GetCountPendingTxsByStatus(ctx context.Context, arg []string) (int64, error)
@@ -117,6 +117,16 @@ func (r *dbTransactionRepository) GetCountTransaction(ctx context.Context) (int6
 	return r.queries.GetCountTransaction(ctx)
 }
 
+func (r *dbTransactionRepository) GetBestHeight(ctx context.Context, tx *sql.Tx) (int64, error) {
+	q := r.queries
+
+	if tx != nil {
+		q = r.queries.WithTx(tx)
+	}
+
+	return q.GetBestHeight(ctx)
+}
+
 func (r *dbTransactionRepository) FindListTxInputByBlockHash(ctx context.Context, b_id string, tx *sql.Tx) ([]dbchain.TxInput, error) {
 	q := r.queries
 
@@ -53,6 +53,40 @@ func (r *rpcTransactionRepository) SendTx(txs []Transaction) (*RPCSendTxResponse
 	return &res, nil
 }
 
+func (r *rpcTransactionRepository) EstimateFee(targetBlocks int64) (*RPCEstimateFeeResponse, error) {
+	params := []any{
+		map[string]any{
+			"targetBlocks": targetBlocks,
+		},
+	}
+
+	data, err := client.CallRPC(
+		r.env.Fullnode_RPC_URL,
+		"API.EstimateFee",
+		params,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var rpcResp client.RPCResponse
+	if err := json.Unmarshal(data, &rpcResp); err != nil {
+		return nil, err
+	}
+
+	if rpcResp.Error != nil {
+		return nil, fmt.Errorf("%s", rpcResp.Error.Message)
+	}
+
+	var res RPCEstimateFeeResponse
+	if err := json.Unmarshal(rpcResp.Result, &res); err != nil {
+		return nil, err
+	}
+
+	return &res, nil
+}
+
 func fetchMiningTxs[T any](url string, params []any) (*RPCGetMiningTxResponse[T], error) {
 	data, err := client.CallRPC(url, "API.GetMiningTxs", params)
 	if err != nil {
@@ -13,8 +13,8 @@ type TransactionRoutes struct {
 	transactionGroup fiber.Router
 }
 
-func NewTransactionRoutes(dbRepo DbTransactionRepository, utxoRepo DbUTXORepository) *TransactionRoutes {
-	service := NewTransactionService(dbRepo, utxoRepo)
+func NewTransactionRoutes(rpcRepo RpcTransactionRepository, dbRepo DbTransactionRepository, utxoRepo DbUTXORepository) *TransactionRoutes {
+	service := NewTransactionService(rpcRepo, dbRepo, utxoRepo)
 	handler := NewTransactionHandler(service)
 
 	return &TransactionRoutes{handler: handler}
@@ -37,6 +37,21 @@ func (r *TransactionRoutes) RegisterPublic(router fiber.Router) {
 		r.handler.GetPendingTransactions,
 	)
 
+	publicGroup.Get("/fee-estimates",
+		middlewares.ValidateQuery[GetFeeEstimatesDto](false),
+		r.handler.GetFeeEstimates,
+	)
+
+	publicGroup.Post("/psbt/decode",
+		middlewares.ValidateBody[PSBTDto](false),
+		r.handler.DecodePSBT,
+	)
+
+	publicGroup.Post("/psbt/combine",
+		middlewares.ValidateBody[CombinePSBTDto](false),
+		r.handler.CombinePSBT,
+	)
+
 	publicGroup.Get("/:tx_hash",
 		middlewares.ValidateParams[GetTransactionDetailDto](false),
 		r.handler.GetDetailTransaction,
@@ -73,6 +88,20 @@ func (r *TransactionRoutes) RegisterPrivate(router fiber.Router) {
 		r.handler.SendTransaction,
 	)
 
+	privateGroup.Post("/psbt/new",
+		middlewares.DecryptBodyMiddleware(nil),
+		middlewares.ValidateBody[NewTransactionDto](true),
+		VerifyCreateSignatureMiddleware,
+		r.handler.CreatePSBT,
+	)
+
+	// The signatures in the PSBT authorize the spend, the body is not signed
+	// again.
+	privateGroup.Post("/psbt/send",
+		middlewares.ValidateBody[SendPSBTDto](false),
+		r.handler.SendPSBT,
+	)
+
 	privateGroup.Get("/summary",
 		r.handler.GetTxSummary,
 	)
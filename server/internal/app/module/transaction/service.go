@@ -3,6 +3,7 @@ package transaction
 import (
 	"ChainServer/internal/app/module/utxo"
 	"ChainServer/internal/common/apperror"
+	"ChainServer/internal/common/chaincfg"
 	"ChainServer/internal/common/constants"
 	"ChainServer/internal/common/dto"
 	"ChainServer/internal/common/env"
@@ -14,12 +15,14 @@ import (
 	dbchain "ChainServer/internal/db/chain"
 	dbPendingTx "ChainServer/internal/db/pendingTx"
 	dbutxo "ChainServer/internal/db/utxo"
+	"bytes"
 	"context"
 	"database/sql"
 	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"math"
 	"strconv"
 	"strings"
 
@@ -27,20 +30,34 @@ import (
 )
 
 type TransactionService struct {
+	rpcRepo  RpcTransactionRepository
 	dbRepo   DbTransactionRepository
 	utxoRepo utxo.DbUTXORepository
 }
 
 func NewTransactionService(
+	rpcRepo RpcTransactionRepository,
 	dbRepo DbTransactionRepository,
 	utxoRepo utxo.DbUTXORepository,
 ) *TransactionService {
 	return &TransactionService{
+		rpcRepo:  rpcRepo,
 		dbRepo:   dbRepo,
 		utxoRepo: utxoRepo,
 	}
 }
 
+// nextBlockHeight returns the height the transaction being built is expected to be mined at.
+func (s *TransactionService) nextBlockHeight(ctx context.Context) (int64, *apperror.AppError) {
+	bestHeight, err := s.dbRepo.GetBestHeight(ctx, nil)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		log.Errorf("Get best height error: %v", err)
+		return 0, apperror.Internal("Something went wrong. Please try again.", nil)
+	}
+
+	return bestHeight + 1, nil
+}
+
 func (s *TransactionService) GetListTransaction(dto dto.PaginationQuery) ([]dbchain.Transaction, *response.PaginationMeta, *apperror.AppError) {
 	ctx := context.Background()
 
@@ -76,14 +93,15 @@ func (s *TransactionService) GetListTransaction(dto dto.PaginationQuery) ([]dbch
 	return txs, pagination, nil
 }
 
-func (s *TransactionService) CreateNewTransaction(payload *utils.JWTPayload[types.JWTWalletAuthPayload], dto *NewTransactionParsed) (*string, *apperror.AppError) {
-	ctx := context.Background()
-
+// buildTransaction builds the unsigned transaction dto asks for from the
+// wallet's UTXOs, with the UTXOs it may spend by TxID and the height it is
+// expected to be mined at.
+func (s *TransactionService) buildTransaction(ctx context.Context, payload *utils.JWTPayload[types.JWTWalletAuthPayload], dto *NewTransactionParsed) (*Transaction, map[string]dbutxo.Utxo, int64, *apperror.AppError) {
 	internalErrCommon := apperror.Internal("Something went wrong. Please try again.", nil)
 
 	pubKeyBytes, err := hex.DecodeString(payload.Data.Pubkey)
 	if err != nil {
-		return nil, internalErrCommon
+		return nil, nil, 0, internalErrCommon
 	}
 
 	pubKeyHash := utils.PublicKeyHash(pubKeyBytes)
@@ -91,53 +109,64 @@ func (s *TransactionService) CreateNewTransaction(payload *utils.JWTPayload[type
 	utxos, err := s.utxoRepo.FindUTXOs(ctx, hex.EncodeToString(pubKeyHash), nil)
 
 	if err != nil {
-		return nil, internalErrCommon
+		return nil, nil, 0, internalErrCommon
 	}
 
-	acc := utils.NewCoinAmountFromFloat(0.0)
-	var spendable []dbutxo.Utxo
+	amount, err := utils.NewCoinAmountFromString(strconv.FormatFloat(dto.Data.Amount, 'f', 8, 64))
+	if err != nil {
+		return nil, nil, 0, apperror.BadRequest("Invalid amount", nil)
+	}
 
-	prevTxs := map[string]dbutxo.Utxo{}
+	fee, err := utils.NewCoinAmountFromString(strconv.FormatFloat(dto.Data.Fee, 'f', 8, 64))
+	if err != nil {
+		return nil, nil, 0, apperror.BadRequest("Invalid fee", nil)
+	}
 
+	prevTxs := map[string]dbutxo.Utxo{}
 	for _, utxo := range utxos {
-		value, err := strconv.ParseFloat(utxo.Value, 64)
-		if err != nil {
-			log.Errorf("Failed To Parse utxo: %v", err)
-			return nil, internalErrCommon
-		}
-		valueTx := utils.NewCoinAmountFromFloat(value)
-		acc = acc.Add(valueTx)
-		spendable = append(spendable, utxo)
 		prevTxs[utxo.TxID] = utxo
-
-		amount := utils.NewCoinAmountFromFloat(dto.Data.Amount)
-		fee := utils.NewCoinAmountFromFloat(dto.Data.Fee)
-
-		if acc.Cmp(amount.Add(fee)) >= 0 {
-			break
-		}
 	}
 
 	fromAddrByte, err := utils.Base58Decode(strings.Trim(payload.Data.Address, " "))
 	if err != nil {
-		return nil, internalErrCommon
+		return nil, nil, 0, internalErrCommon
+	}
+
+	height, apperr := s.nextBlockHeight(ctx)
+	if apperr != nil {
+		return nil, nil, 0, apperr
+	}
+
+	if scriptHeight := chaincfg.Active().ScriptHeight; utils.IsScriptAddressHash(dto.Data.To) && height < scriptHeight {
+		return nil, nil, 0, apperror.BadRequest(fmt.Sprintf("script addresses can only be paid from block %d on", scriptHeight), nil)
 	}
 
 	tx, apperr := NewTransaction(
 		pubKeyBytes,
 		fromAddrByte,
 		dto.Data.To,
-		dto.Data.Amount,
-		dto.Data.Fee,
-		spendable,
-		acc.ToFloat(),
+		amount,
+		fee,
+		utxos,
+		dto.Data.CoinSelection,
 	)
 
+	if apperr != nil {
+		return nil, nil, 0, apperr
+	}
+
+	return tx, prevTxs, height, nil
+}
+
+func (s *TransactionService) CreateNewTransaction(payload *utils.JWTPayload[types.JWTWalletAuthPayload], dto *NewTransactionParsed) (*string, *apperror.AppError) {
+	internalErrCommon := apperror.Internal("Something went wrong. Please try again.", nil)
+
+	tx, prevTxs, height, apperr := s.buildTransaction(context.Background(), payload, dto)
 	if apperr != nil {
 		return nil, apperr
 	}
 
-	txWithSigning, apperr := tx.WithSigning(prevTxs)
+	txWithSigning, apperr := tx.WithSigning(prevTxs, height)
 	if apperr != nil {
 		return nil, apperr
 	}
@@ -201,7 +230,12 @@ func (s *TransactionService) SendTransaction(payload *utils.JWTPayload[types.JWT
 		prevTxs[tx.TxID] = tx
 	}
 
-	sigOk := VerifyTransactionSig(&dto.Transaction, prevTxs)
+	height, apperr := s.nextBlockHeight(ctx)
+	if apperr != nil {
+		return apperr
+	}
+
+	sigOk := VerifyTransactionSig(&dto.Transaction, prevTxs, height)
 
 	if !sigOk {
 		return apperror.BadRequest("Transaction signature verification failed.", nil)
@@ -265,6 +299,205 @@ func (s *TransactionService) SendTransaction(payload *utils.JWTPayload[types.JWT
 	return nil
 }
 
+// CreatePSBT builds the transaction dto asks for like CreateNewTransaction but
+// hands it out as a PSBT, so it can be signed offline or by several parties
+// and sent back through SendPSBT.
+func (s *TransactionService) CreatePSBT(payload *utils.JWTPayload[types.JWTWalletAuthPayload], dto *NewTransactionParsed) (*PSBTResult, *apperror.AppError) {
+	internalErrCommon := apperror.Internal("Something went wrong. Please try again.", nil)
+
+	tx, prevTxs, height, apperr := s.buildTransaction(context.Background(), payload, dto)
+	if apperr != nil {
+		return nil, apperr
+	}
+
+	prevOutputs := make([]TxOutput, 0, len(tx.Inputs))
+	for _, in := range tx.Inputs {
+		utxo := prevTxs[hex.EncodeToString(in.ID)]
+
+		value, err := utils.NewCoinAmountFromString(utxo.Value)
+		if err != nil {
+			log.Errorf("Parse utxo value error: %v", err)
+			return nil, internalErrCommon
+		}
+
+		pubKeyHash, err := hex.DecodeString(utxo.PubKeyHash)
+		if err != nil {
+			log.Errorf("Decode utxo public key hash error: %v", err)
+			return nil, internalErrCommon
+		}
+
+		prevOutputs = append(prevOutputs, TxOutput{Value: value.Units(), PubKeyHash: pubKeyHash})
+	}
+
+	psbt, err := NewPSBT(tx, prevOutputs, height)
+	if err != nil {
+		return nil, apperror.BadRequest(err.Error(), nil)
+	}
+
+	// The change goes back to the wallet, signers can tell it apart from the
+	// payment by its key.
+	pubKeyBytes, err := hex.DecodeString(payload.Data.Pubkey)
+	if err != nil {
+		return nil, internalErrCommon
+	}
+	for i, out := range tx.Outputs {
+		if bytes.Equal(out.PubKeyHash, utils.PublicKeyHash(pubKeyBytes)) {
+			if err := psbt.SetOutputKey(i, pubKeyBytes); err != nil {
+				return nil, internalErrCommon
+			}
+		}
+	}
+
+	return &PSBTResult{Psbt: psbt.Base64(), Complete: psbt.IsSigned()}, nil
+}
+
+// SendPSBT finalizes a signed PSBT of the wallet and sends its transaction
+// like SendTransaction. The receiver is the first output not paying the
+// wallet, the amount what those outputs receive and the fee what the
+// wallet's UTXOs leave over.
+func (s *TransactionService) SendPSBT(payload *utils.JWTPayload[types.JWTWalletAuthPayload], dto *SendPSBTParsed) (*SendTransactionDataParsed, *apperror.AppError) {
+	ctx := context.Background()
+
+	complete, err := dto.PSBT.Finalize()
+	if err != nil {
+		return nil, apperror.BadRequest(err.Error(), nil)
+	}
+	if !complete {
+		return nil, apperror.BadRequest("PSBT is not fully signed", nil)
+	}
+
+	tx, err := dto.PSBT.Extract()
+	if err != nil {
+		return nil, apperror.BadRequest(err.Error(), nil)
+	}
+
+	pubKeyBytes, err := hex.DecodeString(payload.Data.Pubkey)
+	if err != nil {
+		return nil, apperror.BadRequest("Invalid wallet public key format.", err)
+	}
+	pubKeyHash := utils.PublicKeyHash(pubKeyBytes)
+
+	utxos, err := s.utxoRepo.FindUTXOs(ctx, hex.EncodeToString(pubKeyHash), nil)
+	if err != nil {
+		log.Errorf("FindUTXO error: %v", err)
+		return nil, apperror.Internal("Transaction processing failled. Please try again later.", nil)
+	}
+
+	prevTxs := map[string]dbutxo.Utxo{}
+	for _, utxo := range utxos {
+		prevTxs[utxo.TxID] = utxo
+	}
+
+	fee := utils.ZeroAmount()
+	for _, in := range tx.Inputs {
+		utxo, ok := prevTxs[hex.EncodeToString(in.ID)]
+		if !ok {
+			return nil, apperror.BadRequest("PSBT spends outputs the wallet does not own", nil)
+		}
+
+		value, err := utils.NewCoinAmountFromString(utxo.Value)
+		if err != nil {
+			log.Errorf("Parse utxo value error: %v", err)
+			return nil, apperror.Internal("Transaction processing failled. Please try again later.", nil)
+		}
+		fee = fee.Add(value)
+	}
+
+	amount, total := utils.ZeroAmount(), utils.ZeroAmount()
+	var payTo *TxOutput
+	for i, out := range tx.Outputs {
+		value := utils.NewCoinAmountFromUnits(out.Value)
+		total = total.Add(value)
+
+		if len(out.LockingScript) == 0 && bytes.Equal(out.PubKeyHash, pubKeyHash) {
+			continue
+		}
+		if payTo == nil {
+			payTo = &tx.Outputs[i]
+		}
+		amount = amount.Add(value)
+	}
+	fee = fee.Sub(total)
+
+	// A transaction paying only the wallet itself sends it everything.
+	receiver := payload.Data.Address
+	if payTo != nil {
+		receiver = payTo.Address()
+	} else {
+		amount = total
+	}
+
+	send := &SendTransactionDataParsed{
+		Fee:          fee.ToFloat(),
+		Amount:       amount.ToFloat(),
+		ReceiverAddr: receiver,
+		Priority:     dto.Priority,
+		Transaction:  *tx,
+	}
+
+	if apperr := s.SendTransaction(payload, send); apperr != nil {
+		return nil, apperr
+	}
+
+	return send, nil
+}
+
+// DecodePSBT describes psbt for display.
+func (s *TransactionService) DecodePSBT(psbt *PSBT) *DecodedPSBT {
+	decoded := &DecodedPSBT{
+		Psbt:      psbt.Base64(),
+		Height:    psbt.Height,
+		TxID:      hex.EncodeToString(psbt.Tx.ID),
+		Complete:  psbt.IsSigned(),
+		Finalized: psbt.IsFinalized(),
+	}
+
+	if fee, ok := psbt.Fee(); ok {
+		coins := utils.NewCoinAmountFromUnits(fee).ToFloat()
+		decoded.Fee = &coins
+	}
+
+	for i, in := range psbt.Tx.Inputs {
+		input := DecodedPSBTInput{
+			TxID:        hex.EncodeToString(in.ID),
+			Out:         in.Out,
+			PubKey:      hex.EncodeToString(in.PubKey),
+			SigHashType: uint32(psbt.Inputs[i].SigHashType),
+			Path:        psbt.Inputs[i].Path,
+			Signatures:  len(psbt.Inputs[i].PartialSigs),
+			Final:       psbt.Inputs[i].IsFinal(),
+		}
+		if prev := psbt.Inputs[i].PrevOutput; prev != nil {
+			input.Address = prev.Address()
+			input.Value = utils.NewCoinAmountFromUnits(prev.Value).ToFloat()
+		}
+		decoded.Inputs = append(decoded.Inputs, input)
+	}
+
+	for i, out := range psbt.Tx.Outputs {
+		decoded.Outputs = append(decoded.Outputs, DecodedPSBTOutput{
+			Address: out.Address(),
+			Value:   utils.NewCoinAmountFromUnits(out.Value).ToFloat(),
+			PubKey:  hex.EncodeToString(psbt.Outputs[i].PubKey),
+			Path:    psbt.Outputs[i].Path,
+		})
+	}
+
+	return decoded
+}
+
+// CombinePSBT merges the signatures of PSBTs of the same transaction.
+func (s *TransactionService) CombinePSBT(psbts []*PSBT) (*PSBTResult, *apperror.AppError) {
+	combined := psbts[0]
+	for _, psbt := range psbts[1:] {
+		if err := combined.Combine(psbt); err != nil {
+			return nil, apperror.BadRequest(err.Error(), nil)
+		}
+	}
+
+	return &PSBTResult{Psbt: combined.Base64(), Complete: combined.IsSigned()}, nil
+}
+
 func (s *TransactionService) TransactionPending(payload *utils.JWTPayload[types.JWTWalletAuthPayload], pagination *dto.PaginationQuery) ([]dbPendingTx.PendingTxsByAddressAndStatusRow, *response.PaginationMeta, *apperror.AppError) {
 	ctx := context.Background()
 
@@ -279,6 +512,7 @@ func (s *TransactionService) TransactionPending(payload *utils.JWTPayload[types.
 		Offset:  (int32(page) - 1) * int32(limit),
 		Status: []string{
 			string(constants.TxStatusPending),
+			string(constants.TxStatusReplaced),
 		},
 	}, nil)
 
@@ -389,6 +623,7 @@ func (s *TransactionService) GetPendingTransactions(queries *GetTransactionPendi
 		Statuses: []string{
 			string(constants.TxStatusFailed),
 			string(constants.TxStatusPending),
+			string(constants.TxStatusReplaced),
 		},
 		Offset: (page - 1) * limit,
 		Limit:  limit,
@@ -421,6 +656,57 @@ func (s *TransactionService) GetPendingTransactions(queries *GetTransactionPendi
 	return txs, pagination, nil
 }
 
+// GetFeeEstimates suggests the economy, normal and priority fees for a
+// transaction of queries.Size bytes from the node's fee estimator. A faster
+// priority never suggests a lower fee rate than a slower one.
+func (s *TransactionService) GetFeeEstimates(queries *GetFeeEstimatesDto) (*FeeEstimates, *apperror.AppError) {
+	size := queries.Size
+	if size == 0 {
+		size = constants.DEFAULT_TX_SIZE
+	}
+
+	estimates := &FeeEstimates{Size: size}
+	suggestions := []struct {
+		priority uint
+		to       *FeeSuggestion
+	}{
+		{constants.PriorityLow, &estimates.Economy},
+		{constants.PriorityNormal, &estimates.Normal},
+		{constants.PriorityHigh, &estimates.Priority},
+	}
+
+	minRate := 0.0
+	for _, sg := range suggestions {
+		target := constants.Priorities[sg.priority].TargetBlocks
+
+		res, err := s.rpcRepo.EstimateFee(target)
+		if err != nil {
+			log.Errorf("Estimate fee for %d blocks error: %v", target, err)
+			return nil, apperror.Internal("Fee estimation is unavailable. Please try again.", nil)
+		}
+
+		suggestion := FeeSuggestion{
+			Priority:     sg.priority,
+			TargetBlocks: target,
+			FeeRate:      constants.FALLBACK_FEE_RATE,
+		}
+		if res.Error == nil {
+			suggestion.FeeRate = res.FeeRate
+			suggestion.Estimated = true
+		}
+
+		suggestion.FeeRate = max(suggestion.FeeRate, minRate)
+		minRate = suggestion.FeeRate
+
+		units := max(int64(math.Ceil(suggestion.FeeRate*float64(size))), 1)
+		suggestion.Fee = utils.NewCoinAmountFromUnits(units).ToFloat()
+
+		*sg.to = suggestion
+	}
+
+	return estimates, nil
+}
+
 func (s *TransactionService) GetTxSummaryByPubKeyHash(
 	auth *utils.JWTPayload[types.JWTWalletAuthPayload],
 ) (*dbchain.GetTxSummaryByPubKeyHashRow, *apperror.AppError) {
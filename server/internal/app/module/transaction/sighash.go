@@ -0,0 +1,157 @@
+package transaction
+
+import (
+	"ChainServer/internal/common/utils"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// SigHashType mirrors the node's sighash flags. From the network's
+// IntegerAmountHeight on, inputs sign the binary sighash below instead of the
+// JSON encoded trimmed copy.
+type SigHashType uint32
+
+const (
+	SigHashAll          SigHashType = 0x01
+	SigHashNone         SigHashType = 0x02
+	SigHashSingle       SigHashType = 0x03
+	SigHashAnyoneCanPay SigHashType = 0x80
+
+	SignatureLength = 64
+)
+
+var (
+	ErrInvalidSigHashType = errors.New("invalid sighash type")
+	ErrSigHashSingleIndex = errors.New("sighash single without matching output")
+	ErrInvalidSignature   = errors.New("invalid signature encoding")
+)
+
+func (t SigHashType) Base() SigHashType {
+	return t &^ SigHashAnyoneCanPay
+}
+
+func (t SigHashType) IsValid() bool {
+	base := t.Base()
+	return t <= 0xff && base >= SigHashAll && base <= SigHashSingle
+}
+
+func writeBytes(buf *bytes.Buffer, data []byte) {
+	binary.Write(buf, binary.LittleEndian, uint32(len(data)))
+	buf.Write(data)
+}
+
+// scriptMarker takes the place of the input count of transactions with
+// scripts, as in the node's encoding.
+const scriptMarker uint32 = 0xffffffff
+
+// serializeBinary matches the node's SerializeTransaction byte for byte.
+func (tx *Transaction) serializeBinary(buf *bytes.Buffer) {
+	writeBytes(buf, tx.ID)
+
+	scripts := tx.HasScripts()
+	if scripts {
+		binary.Write(buf, binary.LittleEndian, scriptMarker)
+	}
+
+	binary.Write(buf, binary.LittleEndian, uint32(len(tx.Inputs)))
+	for _, in := range tx.Inputs {
+		writeBytes(buf, in.ID)
+		binary.Write(buf, binary.LittleEndian, in.Out)
+		writeBytes(buf, in.Signature)
+		writeBytes(buf, in.PubKey)
+		if scripts {
+			writeBytes(buf, in.UnlockingScript)
+		}
+	}
+
+	binary.Write(buf, binary.LittleEndian, uint32(len(tx.Outputs)))
+	for _, out := range tx.Outputs {
+		binary.Write(buf, binary.LittleEndian, out.Value)
+		writeBytes(buf, out.PubKeyHash)
+		if scripts {
+			writeBytes(buf, out.LockingScript)
+		}
+	}
+}
+
+// SigHashPreimage returns the bytes the signature of input idx commits to.
+// See the node's Transaction.SigHashPreimage for the layout.
+func (tx *Transaction) SigHashPreimage(idx int, prevPubKeyHash []byte, hashType SigHashType) ([]byte, error) {
+	if !hashType.IsValid() {
+		return nil, fmt.Errorf("%w: 0x%x", ErrInvalidSigHashType, uint32(hashType))
+	}
+
+	if idx < 0 || idx >= len(tx.Inputs) {
+		return nil, fmt.Errorf("input index %d out of range", idx)
+	}
+
+	txCopy := Transaction{}
+
+	for i, in := range tx.Inputs {
+		if hashType&SigHashAnyoneCanPay != 0 && i != idx {
+			continue
+		}
+
+		input := TxInput{ID: in.ID, Out: in.Out}
+		if i == idx {
+			input.PubKey = prevPubKeyHash
+		}
+
+		txCopy.Inputs = append(txCopy.Inputs, input)
+	}
+
+	switch hashType.Base() {
+	case SigHashAll:
+		txCopy.Outputs = append(txCopy.Outputs, tx.Outputs...)
+	case SigHashSingle:
+		if idx >= len(tx.Outputs) {
+			return nil, ErrSigHashSingleIndex
+		}
+
+		for i := 0; i < idx; i++ {
+			txCopy.Outputs = append(txCopy.Outputs, TxOutput{Value: -1})
+		}
+		txCopy.Outputs = append(txCopy.Outputs, tx.Outputs[idx])
+	}
+
+	buf := new(bytes.Buffer)
+	txCopy.serializeBinary(buf)
+	binary.Write(buf, binary.LittleEndian, uint32(hashType))
+
+	return buf.Bytes(), nil
+}
+
+func (tx *Transaction) SigHash(idx int, prevPubKeyHash []byte, hashType SigHashType) ([]byte, error) {
+	preimage, err := tx.SigHashPreimage(idx, prevPubKeyHash, hashType)
+	if err != nil {
+		return nil, err
+	}
+
+	return utils.DoubleSHA256(preimage), nil
+}
+
+// DecodeSignature splits r||s and the optional trailing hash type byte.
+// Plain 64 byte signatures are SigHashAll.
+func DecodeSignature(sig []byte) (*big.Int, *big.Int, SigHashType, error) {
+	hashType := SigHashAll
+
+	switch len(sig) {
+	case SignatureLength:
+	case SignatureLength + 1:
+		hashType = SigHashType(sig[SignatureLength])
+	default:
+		return nil, nil, 0, ErrInvalidSignature
+	}
+
+	if !hashType.IsValid() {
+		return nil, nil, 0, ErrInvalidSigHashType
+	}
+
+	r := new(big.Int).SetBytes(sig[:SignatureLength/2])
+	s := new(big.Int).SetBytes(sig[SignatureLength/2 : SignatureLength])
+
+	return r, s, hashType, nil
+}
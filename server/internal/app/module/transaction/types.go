@@ -2,16 +2,18 @@ package transaction
 
 import (
 	"ChainServer/internal/common/client"
+	"ChainServer/internal/common/coinselect"
 	dbchain "ChainServer/internal/db/chain"
 	"time"
 )
 
 type TransactionDataParsed struct {
-	Fee       float64
-	Amount    float64
-	To        []byte // base58
-	Timestamp time.Time
-	Message   string
+	Fee           float64
+	Amount        float64
+	To            []byte // base58
+	Timestamp     time.Time
+	Message       string
+	CoinSelection coinselect.Strategy
 }
 
 type NewTransactionParsed struct {
@@ -28,10 +30,19 @@ type SendTransactionDataParsed struct {
 }
 
 type RPCSendTxResponse struct {
-	Message string
-	ListTxs []string
-	Count   int64
-	Error   *client.RPCError
+	Message  string
+	ListTxs  []string
+	Count    int64
+	Replaced []RPCReplacedTx
+	Error    *client.RPCError
+}
+
+// RPCReplacedTx is a mempool transaction evicted by ReplacedBy, which spends
+// the same inputs for a higher fee. Transactions do not opt in to this, the
+// node replaces them only when it runs full RBF.
+type RPCReplacedTx struct {
+	TxID       string
+	ReplacedBy string
 }
 
 type RPCGetMiningTxResponse[T any] struct {
@@ -41,6 +52,78 @@ type RPCGetMiningTxResponse[T any] struct {
 	Error   *client.RPCError
 }
 
+// RPCEstimateFeeResponse.FeeRate is in base units per serialized byte,
+// Blocks is the target the node estimated it for.
+type RPCEstimateFeeResponse struct {
+	FeeRate float64
+	Blocks  int64
+	Error   *client.RPCError
+}
+
+// FeeSuggestion is the fee, in coins, a transaction of FeeEstimates.Size
+// bytes pays to confirm within TargetBlocks blocks. Priority is the value to
+// send the transaction with. Estimated is false when the node lacked data
+// and FeeRate is FALLBACK_FEE_RATE.
+type FeeSuggestion struct {
+	Priority     uint
+	TargetBlocks int64
+	FeeRate      float64
+	Fee          float64
+	Estimated    bool
+}
+
+type FeeEstimates struct {
+	Size     int64
+	Economy  FeeSuggestion
+	Normal   FeeSuggestion
+	Priority FeeSuggestion
+}
+
+// PSBTResult.Psbt is the base64 PSBT, Complete tells whether every input is
+// signed.
+type PSBTResult struct {
+	Psbt     string
+	Complete bool
+}
+
+// DecodedPSBT.Fee is nil while a previous output is unknown.
+type DecodedPSBT struct {
+	Psbt      string
+	Height    int64
+	TxID      string
+	Inputs    []DecodedPSBTInput
+	Outputs   []DecodedPSBTOutput
+	Fee       *float64
+	Complete  bool
+	Finalized bool
+}
+
+// DecodedPSBTInput.Address and Value describe the spent output, Signatures
+// counts the partial signatures collected.
+type DecodedPSBTInput struct {
+	TxID        string
+	Out         int64
+	PubKey      string
+	Address     string
+	Value       float64
+	SigHashType uint32
+	Path        string
+	Signatures  int
+	Final       bool
+}
+
+type DecodedPSBTOutput struct {
+	Address string
+	Value   float64
+	PubKey  string
+	Path    string
+}
+
+type SendPSBTParsed struct {
+	PSBT     *PSBT
+	Priority uint
+}
+
 type DetailTransaction struct {
 	dbchain.GetDetailTxRow
 	Difficulty int64
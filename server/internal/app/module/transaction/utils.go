@@ -2,23 +2,33 @@ package transaction
 
 import (
 	"ChainServer/internal/common/apperror"
+	"ChainServer/internal/common/chaincfg"
+	"ChainServer/internal/common/coinselect"
+	"ChainServer/internal/common/constants"
 	"ChainServer/internal/common/env"
+	"ChainServer/internal/common/utils"
 	dbutxo "ChainServer/internal/db/utxo"
 	"crypto/ecdsa"
 	"crypto/elliptic"
 	"crypto/sha256"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"math/big"
-	"strconv"
 
 	log "github.com/sirupsen/logrus"
 )
 
-func newTxOutput(amount float64, to []byte) TxOutput {
-	pubKeyHash := to[1 : len(to)-int(env.Cfg.CheckSumLength)]
+// newTxOutput pays amount to the base58 decoded address to, with a
+// pay-to-script-hash script for script addresses.
+func newTxOutput(amount int64, to []byte) TxOutput {
+	hash := to[1 : len(to)-int(env.Cfg.CheckSumLength)]
 
-	output := TxOutput{Value: amount, PubKeyHash: pubKeyHash}
+	if utils.IsScriptAddressHash(to) {
+		return TxOutput{Value: amount, LockingScript: utils.NewPayToScriptHashScript(hash)}
+	}
+
+	output := TxOutput{Value: amount, PubKeyHash: hash}
 
 	return output
 }
@@ -34,32 +44,38 @@ func transactionHash(tx *Transaction) []byte {
 	return hash[:]
 }
 
-func NewTransaction(pubkey []byte, from, to []byte, amount, fee float64, utxos []dbutxo.Utxo, accUtxo float64) (*Transaction, *apperror.AppError) {
-	PER_COIN := 100_000_000
-
-	if fee < float64(1/PER_COIN) {
-		return nil, apperror.BadRequest(fmt.Sprintf("fee must be greater than or equal 1/%d (%f)", PER_COIN, float64(1/PER_COIN)), nil)
+// NewTransaction spends the utxos strategy picks to pay amount to to. Change
+// below coinselect.DustThreshold is added to the fee.
+func NewTransaction(pubkey []byte, from, to []byte, amount, fee *utils.CoinAmount, utxos []dbutxo.Utxo, strategy coinselect.Strategy) (*Transaction, *apperror.AppError) {
+	if fee.Units() < 1 {
+		return nil, apperror.BadRequest(fmt.Sprintf("fee must be greater than or equal 1/%d", constants.PER_COIN), nil)
 	}
 
 	var inputs []TxInput
 	var outputs []TxOutput
 
-	if accUtxo < amount+fee {
-		return nil, apperror.BadRequest("you dont have enough amount", nil)
-	}
-
-	var currentAcc float64
-
+	coins := make([]coinselect.Coin, 0, len(utxos))
 	for _, utxo := range utxos {
-		value, err := strconv.ParseFloat(utxo.Value, 64)
+		value, err := utils.NewCoinAmountFromString(utxo.Value)
 
 		if err != nil {
 			log.Error("New Transaction Error: ", err)
 			return nil, apperror.Internal("Something went wrong. Please try again.", nil)
 		}
-		currentAcc += value
 
-		txID, err := hex.DecodeString(utxo.TxID)
+		coins = append(coins, coinselect.Coin{TxID: utxo.TxID, Out: utxo.OutputIndex, Value: value.Units()})
+	}
+
+	selection, err := coinselect.Select(strategy, coins, amount.Units(), fee.Units())
+	if errors.Is(err, coinselect.ErrInsufficientFunds) {
+		return nil, apperror.BadRequest("you dont have enough amount", nil)
+	}
+	if err != nil {
+		return nil, apperror.BadRequest(err.Error(), nil)
+	}
+
+	for _, coin := range selection.Coins {
+		txID, err := hex.DecodeString(coin.TxID)
 		if err != nil {
 			log.Error("New Transaction Error: ", err)
 			return nil, apperror.Internal("Something went wrong. Please try again.", nil)
@@ -67,22 +83,18 @@ func NewTransaction(pubkey []byte, from, to []byte, amount, fee float64, utxos [
 
 		newInput := TxInput{
 			ID:        txID,
-			Out:       utxo.OutputIndex,
+			Out:       coin.Out,
 			Signature: nil,
 			PubKey:    pubkey,
 		}
 
 		inputs = append(inputs, newInput)
-
-		if currentAcc >= amount+fee {
-			break
-		}
 	}
 
-	outputs = append(outputs, newTxOutput(amount, to))
+	outputs = append(outputs, newTxOutput(amount.Units(), to))
 
-	if currentAcc >= amount+fee {
-		outputs = append(outputs, newTxOutput(accUtxo-amount-fee, from))
+	if selection.Change > 0 {
+		outputs = append(outputs, newTxOutput(selection.Change, from))
 	}
 
 	tx := Transaction{
@@ -95,7 +107,7 @@ func NewTransaction(pubkey []byte, from, to []byte, amount, fee float64, utxos [
 	return &tx, nil
 }
 
-func VerifyTransactionSig(tx *Transaction, utxos map[string]dbutxo.Utxo) bool {
+func VerifyTransactionSig(tx *Transaction, utxos map[string]dbutxo.Utxo, height int64) bool {
 	curve := elliptic.P256()
 
 	for _, in := range tx.Inputs {
@@ -104,35 +116,67 @@ func VerifyTransactionSig(tx *Transaction, utxos map[string]dbutxo.Utxo) bool {
 		}
 	}
 
-	txWithSigning, apperr := tx.WithSigning(utxos)
-
-	if apperr != nil {
+	if !tx.BalanceCheck(utxos) {
 		return false
 	}
 
-	for inID, in := range tx.Inputs {
-		r := big.Int{}
-		s := big.Int{}
-		SigLen := len(in.Signature)
-		r.SetBytes(in.Signature[:(SigLen / 2)])
-		s.SetBytes(in.Signature[(SigLen / 2):])
+	var txWithSigning *TransactionWithSigning
+	if height < chaincfg.Active().IntegerAmountHeight {
+		var apperr *apperror.AppError
+		txWithSigning, apperr = tx.WithSigning(utxos, height)
+
+		if apperr != nil {
+			return false
+		}
+	}
 
+	for inID, in := range tx.Inputs {
 		x := big.Int{}
 		y := big.Int{}
 		keyLen := len(in.PubKey)
 		x.SetBytes(in.PubKey[:(keyLen / 2)])
 		y.SetBytes(in.PubKey[(keyLen / 2):])
 
-		dataToVerify := txWithSigning.Inputs[inID].DataToSign
-		dataToVerifyBytes, err := hex.DecodeString(dataToVerify)
-		if err != nil {
-			log.Error(err)
-			return false
+		var r, s *big.Int
+		var dataToVerifyBytes []byte
+
+		if txWithSigning == nil {
+			var hashType SigHashType
+			var err error
+
+			r, s, hashType, err = DecodeSignature(in.Signature)
+			if err != nil {
+				log.Error(err)
+				return false
+			}
+
+			pubKeyHash, err := hex.DecodeString(utxos[hex.EncodeToString(in.ID)].PubKeyHash)
+			if err != nil {
+				log.Error(err)
+				return false
+			}
+
+			dataToVerifyBytes, err = tx.SigHash(inID, pubKeyHash, hashType)
+			if err != nil {
+				log.Error(err)
+				return false
+			}
+		} else {
+			SigLen := len(in.Signature)
+			r = new(big.Int).SetBytes(in.Signature[:(SigLen / 2)])
+			s = new(big.Int).SetBytes(in.Signature[(SigLen / 2):])
+
+			var err error
+			dataToVerifyBytes, err = hex.DecodeString(txWithSigning.Inputs[inID].DataToSign)
+			if err != nil {
+				log.Error(err)
+				return false
+			}
 		}
 
 		rawPubKey := ecdsa.PublicKey{Curve: curve, X: &x, Y: &y}
 
-		SigOk := ecdsa.Verify(&rawPubKey, dataToVerifyBytes, &r, &s)
+		SigOk := ecdsa.Verify(&rawPubKey, dataToVerifyBytes, r, s)
 
 		if !SigOk {
 			return false
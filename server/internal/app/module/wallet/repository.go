@@ -17,6 +17,7 @@ type DBWalletRepository interface {
 	GetWalletByPubkey(ctx context.Context, pubkey []byte, tx *sql.Tx) (*dbwallet.Wallet, error)
 	IncreaseWalletBalance(ctx context.Context, args dbwallet.IncreaseWalletBalanceParams, tx *sql.Tx) error
 	DecreaseWalletBalance(ctx context.Context, args dbwallet.DecreaseWalletBalanceParams, tx *sql.Tx) error
+	DecreaseWalletBalanceByPubKeyHash(ctx context.Context, args dbwallet.DecreaseWalletBalanceByPubKeyHashParams, tx *sql.Tx) error
 	CreateWalletAccessLog(ctx context.Context, args dbwallet.CreateWalletAccessLogParams, tx *sql.Tx) error
 	GetListAccessLogByWalletID(ctx context.Context, args dbwallet.GetListAccessLogByWalletIDParams, tx *sql.Tx) ([]dbwallet.WalletAccessLog, error)
 	GetWalletByPubKeyHash(ctx context.Context, PubkeyHash string, tx *sql.Tx) (*dbwallet.Wallet, error)
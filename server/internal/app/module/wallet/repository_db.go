@@ -96,6 +96,16 @@ func (r *WalletDBRepository) DecreaseWalletBalance(ctx context.Context, args dbw
 	return q.DecreaseWalletBalance(ctx, args)
 }
 
+func (r *WalletDBRepository) DecreaseWalletBalanceByPubKeyHash(ctx context.Context, args dbwallet.DecreaseWalletBalanceByPubKeyHashParams, tx *sql.Tx) error {
+	q := r.queries
+
+	if tx != nil {
+		q = r.queries.WithTx(tx)
+	}
+
+	return q.DecreaseWalletBalanceByPubKeyHash(ctx, args)
+}
+
 func (r *WalletDBRepository) CreateWalletAccessLog(ctx context.Context, args dbwallet.CreateWalletAccessLogParams, tx *sql.Tx) error {
 	q := r.queries
 
@@ -13,7 +13,6 @@ import (
 	"database/sql"
 	"encoding/hex"
 	"errors"
-	"fmt"
 	"time"
 
 	log "github.com/sirupsen/logrus"
@@ -130,7 +129,7 @@ func (s *WalletService) ImportWallet(dto *dto.WalletParsed) (*string, *apperror.
 				PublicKey:     helpers.StringToNullString(hex.EncodeToString(dto.PublicKey)),
 				Address:       helpers.StringToNullString(dto.Addr),
 				PublicKeyHash: pubKeyHash,
-				Balance:       fmt.Sprintf("%f", balance.Balance),
+				Balance:       utils.NewCoinAmountFromUnits(balance.Balance).String(),
 				CreateAt: sql.NullTime{
 					Time:  time.Now(),
 					Valid: true,
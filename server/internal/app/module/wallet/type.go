@@ -2,11 +2,12 @@ package wallet
 
 import "ChainServer/internal/common/apperror"
 
+// Balance.Balance is reported by the node in base units.
 type Balance struct {
-	Balance   float64 `json:"balance"`
-	Address   string  `json:"address"`
-	Timestamp int64   `json:"timestamp"`
-	Error     *Error  `json:"error,omitempty"`
+	Balance   int64  `json:"balance"`
+	Address   string `json:"address"`
+	Timestamp int64  `json:"timestamp"`
+	Error     *Error `json:"error,omitempty"`
 }
 
 type Error struct {
@@ -23,6 +23,7 @@ func GetAllModuleRouters() []ModuleRouter {
 		),
 
 		transaction.NewTransactionRoutes(
+			transaction.NewRPCTransactionRepo(),
 			transaction.NewDbTransactionRepository(),
 			utxo.NewDbUTXORepository(),
 		),
@@ -2,11 +2,14 @@ package bootstrap
 
 import (
 	"ChainServer/internal/cache/redis"
+	"ChainServer/internal/common/chaincfg"
 	"ChainServer/internal/common/env"
 	"ChainServer/internal/common/gobtypes"
 	"ChainServer/internal/common/logger"
 	"ChainServer/internal/common/types"
 	"time"
+
+	log "github.com/sirupsen/logrus"
 )
 
 func Init() {
@@ -14,6 +17,10 @@ func Init() {
 
 	logger.InitLogger(env.Cfg.AppEnv)
 
+	if err := chaincfg.Init(); err != nil {
+		log.Fatalf("NETWORK: %v", err)
+	}
+
 	redis.InitRedis(types.RedisConfig{
 		URL:          env.Cfg.Redis_Url,
 		MaxRetries:   10,
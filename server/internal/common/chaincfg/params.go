@@ -0,0 +1,72 @@
+package chaincfg
+
+import (
+	"ChainServer/internal/common/env"
+	"fmt"
+	"strings"
+)
+
+// Params mirrors the consensus activation heights of the node's
+// chaincfg.Params. The server follows the network named by NETWORK.
+type Params struct {
+	Name string
+
+	// IntegerAmountHeight is the first height whose output values are
+	// integer base units in hashes and signatures.
+	IntegerAmountHeight int64
+	// ScriptHeight is the first height whose outputs may be locked by
+	// scripts and paid to script addresses.
+	ScriptHeight int64
+}
+
+var MainNetParams = Params{
+	Name:                "mainnet",
+	IntegerAmountHeight: 50_000,
+	ScriptHeight:        60_000,
+}
+
+var TestNetParams = Params{
+	Name:                "testnet",
+	IntegerAmountHeight: 0,
+	ScriptHeight:        0,
+}
+
+var RegTestParams = Params{
+	Name:                "regtest",
+	IntegerAmountHeight: 0,
+	ScriptHeight:        0,
+}
+
+var networks = []*Params{&MainNetParams, &TestNetParams, &RegTestParams}
+
+// ByName returns the params of the network called name.
+func ByName(name string) (*Params, error) {
+	for _, params := range networks {
+		if strings.EqualFold(params.Name, name) {
+			return params, nil
+		}
+	}
+
+	return nil, fmt.Errorf("unknown network %q", name)
+}
+
+// Active returns the params of the network the server follows. Init has
+// checked env.Cfg.Network, mainnet is only returned before it ran.
+func Active() *Params {
+	if env.Cfg == nil {
+		return &MainNetParams
+	}
+
+	params, err := ByName(env.Cfg.Network)
+	if err != nil {
+		return &MainNetParams
+	}
+
+	return params
+}
+
+// Init checks that env.Cfg.Network names a known network.
+func Init() error {
+	_, err := ByName(env.Cfg.Network)
+	return err
+}
@@ -0,0 +1,219 @@
+// This file is a copy of protocol-chain/coinselect/coinselect.go, the node
+// and the server are separate modules. Change both together, TestSameAsNode
+// fails while they differ.
+
+// Package coinselect picks the unspent outputs that fund a transaction.
+package coinselect
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+type Strategy string
+
+const (
+	// BranchAndBound looks for a set of coins matching the target closely
+	// enough to leave no change, and falls back to LargestFirst.
+	BranchAndBound Strategy = "bnb"
+	// LargestFirst spends the fewest, largest coins.
+	LargestFirst Strategy = "largest"
+	// SmallestFirst spends the smallest coins first, consolidating dust.
+	SmallestFirst Strategy = "smallest"
+	// SpendAll sweeps every coin, whatever is not sent comes back as change.
+	SpendAll Strategy = "all"
+
+	DefaultStrategy = BranchAndBound
+)
+
+const (
+	// DustThreshold is the smallest change worth an output. Smaller change
+	// is left to the fee.
+	DustThreshold int64 = 1000
+
+	// maxBnBTries bounds the branch and bound search.
+	maxBnBTries = 100_000
+)
+
+var (
+	ErrInsufficientFunds = errors.New("you dont have enough amount")
+	ErrUnknownStrategy   = errors.New("unknown coin selection strategy")
+)
+
+// Coin is an unspent output, Value is in base units.
+type Coin struct {
+	TxID  string
+	Out   int64
+	Value int64
+}
+
+// Selection lists the coins to spend. Change is what goes back to the
+// sender, zero when it would have been dust. Fee is the fee asked for plus
+// the dust left over.
+type Selection struct {
+	Coins  []Coin
+	Total  int64
+	Change int64
+	Fee    int64
+}
+
+// ParseStrategy maps an RPC or API parameter to a Strategy. An empty name
+// picks DefaultStrategy.
+func ParseStrategy(name string) (Strategy, error) {
+	switch s := Strategy(name); s {
+	case "":
+		return DefaultStrategy, nil
+	case BranchAndBound, LargestFirst, SmallestFirst, SpendAll:
+		return s, nil
+	}
+
+	return "", fmt.Errorf("%w: %q", ErrUnknownStrategy, name)
+}
+
+// Select picks coins paying amount plus fee with strategy.
+func Select(strategy Strategy, coins []Coin, amount, fee int64) (*Selection, error) {
+	target := amount + fee
+
+	var available int64
+	for _, c := range coins {
+		available += c.Value
+	}
+	if available < target {
+		return nil, ErrInsufficientFunds
+	}
+
+	var picked []Coin
+
+	switch strategy {
+	case BranchAndBound:
+		picked = branchAndBound(coins, target, DustThreshold)
+		if picked == nil {
+			picked = accumulate(coins, target, largestFirst)
+		}
+	case LargestFirst:
+		picked = accumulate(coins, target, largestFirst)
+	case SmallestFirst:
+		picked = accumulate(coins, target, smallestFirst)
+	case SpendAll:
+		picked = append([]Coin(nil), coins...)
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnknownStrategy, strategy)
+	}
+
+	return newSelection(picked, target, fee), nil
+}
+
+func newSelection(coins []Coin, target, fee int64) *Selection {
+	sel := &Selection{Coins: coins, Fee: fee}
+	for _, c := range coins {
+		sel.Total += c.Value
+	}
+
+	sel.Change = sel.Total - target
+	if sel.Change < DustThreshold {
+		sel.Fee += sel.Change
+		sel.Change = 0
+	}
+
+	return sel
+}
+
+func largestFirst(a, b Coin) bool  { return a.Value > b.Value }
+func smallestFirst(a, b Coin) bool { return a.Value < b.Value }
+
+// accumulate takes coins in order until they cover target. When the change
+// left would be dust, it keeps taking coins so the change is worth an output,
+// and settles for the dust when the coins run out.
+func accumulate(coins []Coin, target int64, less func(a, b Coin) bool) []Coin {
+	sorted := append([]Coin(nil), coins...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return less(sorted[i], sorted[j])
+	})
+
+	var total int64
+	covered := -1
+	for i, c := range sorted {
+		total += c.Value
+		if total < target {
+			continue
+		}
+		if covered < 0 {
+			covered = i
+		}
+		if change := total - target; change == 0 || change >= DustThreshold {
+			return sorted[:i+1]
+		}
+	}
+
+	return sorted[:covered+1]
+}
+
+// branchAndBound searches, largest coins first, for a set worth between
+// target and target+window, which needs no change output. It returns nil when
+// there is none or the search ran out of tries.
+func branchAndBound(coins []Coin, target, window int64) []Coin {
+	sorted := append([]Coin(nil), coins...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Value > sorted[j].Value
+	})
+
+	// remaining[i] is the value of sorted[i:].
+	remaining := make([]int64, len(sorted)+1)
+	for i := len(sorted) - 1; i >= 0; i-- {
+		remaining[i] = remaining[i+1] + sorted[i].Value
+	}
+
+	var (
+		best      []int
+		bestWaste int64 = -1
+		picked    []int
+		tries     int
+	)
+
+	var search func(i int, total int64)
+	search = func(i int, total int64) {
+		if tries >= maxBnBTries {
+			return
+		}
+		tries++
+
+		if total > target+window || total+remaining[i] < target {
+			return
+		}
+		if total >= target {
+			if waste := total - target; bestWaste < 0 || waste < bestWaste {
+				best = append(best[:0], picked...)
+				bestWaste = waste
+			}
+			return
+		}
+		if i == len(sorted) {
+			return
+		}
+
+		picked = append(picked, i)
+		search(i+1, total+sorted[i].Value)
+		picked = picked[:len(picked)-1]
+
+		// Skipping a coin worth the same as the one just tried only
+		// repeats that branch.
+		next := i + 1
+		for next < len(sorted) && sorted[next].Value == sorted[i].Value {
+			next++
+		}
+		search(next, total)
+	}
+	search(0, 0)
+
+	if bestWaste < 0 {
+		return nil
+	}
+
+	selected := make([]Coin, 0, len(best))
+	for _, i := range best {
+		selected = append(selected, sorted[i])
+	}
+
+	return selected
+}
@@ -0,0 +1,40 @@
+package coinselect
+
+import (
+	"bytes"
+	"errors"
+	"io/fs"
+	"os"
+	"testing"
+)
+
+const nodeSource = "../../../../protocol-chain/coinselect/coinselect.go"
+
+// TestSameAsNode checks that this package still matches the node's, apart
+// from the comment naming where it was copied from.
+func TestSameAsNode(t *testing.T) {
+	node, err := os.ReadFile(nodeSource)
+	if errors.Is(err, fs.ErrNotExist) {
+		t.Skipf("%s not checked out", nodeSource)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	copied, err := os.ReadFile("coinselect.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	copied = bytes.ReplaceAll(copied, []byte("\r\n"), []byte("\n"))
+	node = bytes.ReplaceAll(node, []byte("\r\n"), []byte("\n"))
+
+	_, copied, ok := bytes.Cut(copied, []byte("\n\n"))
+	if !ok {
+		t.Fatal("coinselect.go has no copy header")
+	}
+
+	if !bytes.Equal(copied, node) {
+		t.Errorf("coinselect.go differs from %s", nodeSource)
+	}
+}
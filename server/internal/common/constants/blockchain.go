@@ -2,4 +2,12 @@ package constants
 
 const (
 	PER_COIN = 100_000_000
+
+	// DEFAULT_TX_SIZE is about the serialized size, in bytes, of a
+	// transaction with one input and two outputs.
+	DEFAULT_TX_SIZE int64 = 300
+
+	// FALLBACK_FEE_RATE, in base units per byte, is suggested when the node
+	// has not seen enough blocks to estimate fees.
+	FALLBACK_FEE_RATE = 20.0
 )
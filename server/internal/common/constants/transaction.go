@@ -1,8 +1,11 @@
 package constants
 
+// PriorityInfo.TargetBlocks is the confirmation target, in blocks, the fee
+// suggested for the priority is estimated for.
 type PriorityInfo struct {
-	Name string
-	Rate float64
+	Name         string
+	Rate         float64
+	TargetBlocks int64
 }
 
 type TxStatus string
@@ -11,7 +14,8 @@ const (
 	TxStatusPending TxStatus = "pending" // transaction in mempool, waiting to be picked
 	TxStatusMined   TxStatus = "mined"   // included in a block, not yet finalized
 
-	TxStatusFailed TxStatus = "failed" // invalid transaction (e.g., insufficient funds, bad signature)
+	TxStatusFailed   TxStatus = "failed"   // invalid transaction (e.g., insufficient funds, bad signature)
+	TxStatusReplaced TxStatus = "replaced" // evicted from the mempool by a full-RBF replacement paying a higher fee
 )
 
 const (
@@ -22,15 +26,18 @@ const (
 
 var Priorities = map[uint]PriorityInfo{
 	PriorityLow: {
-		Name: "Low",
-		Rate: 0.1,
+		Name:         "Low",
+		Rate:         0.1,
+		TargetBlocks: 24,
 	},
 	PriorityNormal: {
-		Name: "Normal",
-		Rate: 0.6,
+		Name:         "Normal",
+		Rate:         0.6,
+		TargetBlocks: 6,
 	},
 	PriorityHigh: {
-		Name: "High",
-		Rate: 0.9,
+		Name:         "High",
+		Rate:         0.9,
+		TargetBlocks: 1,
 	},
 }
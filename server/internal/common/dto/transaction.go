@@ -1,15 +1,19 @@
 package dto
 
 type TxInput struct {
-	ID        string `json:"ID"`
-	Out       int64  `json:"Out"`
-	Signature string `json:"Signature"`
-	PubKey    string `json:"PubKey"`
+	ID              string `json:"ID"`
+	Out             int64  `json:"Out"`
+	Signature       string `json:"Signature"`
+	PubKey          string `json:"PubKey"`
+	UnlockingScript string `json:"UnlockingScript,omitempty"`
 }
 
+// TxOutput.Value is expressed in base units. LockingScript is set instead of
+// PubKeyHash for outputs paying to a script.
 type TxOutput struct {
-	Value      float64 `json:"Value"`
-	PubKeyHash string  `json:"PubKeyHash"`
+	Value         int64  `json:"Value"`
+	PubKeyHash    string `json:"PubKeyHash"`
+	LockingScript string `json:"LockingScript,omitempty"`
 }
 
 type Transaction struct {
@@ -33,6 +33,7 @@ type Env struct {
 	Encode_data_secret_Key          []byte
 	Sync_block_batch_size           int64
 	Redis_Url                       string
+	Network                         string
 }
 
 var Cfg *Env
@@ -54,6 +55,7 @@ func InitEnv() {
 			Encode_data_secret_Key:          GetEnvAsBytes("ENCODE_DATA_SECRET_KEY", []byte("")),
 			Sync_block_batch_size:           GetEnvAsInt("SYNC_BLOCK_BATCH_SIZE", 100),
 			Redis_Url:                       GetEnvAsString("REDIS_URL", "redis://localhost:6379"),
+			Network:                         GetEnvAsString("NETWORK", "mainnet"),
 		}
 	})
 }
@@ -4,6 +4,7 @@ import (
 	"ChainServer/internal/common/constants"
 	"fmt"
 	"math/big"
+	"strconv"
 	"strings"
 )
 
@@ -24,7 +25,17 @@ func NewCoinAmountFromFloat(v float64) *CoinAmount {
 	return &CoinAmount{value: i}
 }
 
+func NewCoinAmountFromUnits(v int64) *CoinAmount {
+	return &CoinAmount{value: big.NewInt(v)}
+}
+
+// NewCoinAmountFromString parses a decimal coin amount such as a NUMERIC(20,8)
+// column exactly. Digits beyond the coin precision are truncated.
 func NewCoinAmountFromString(s string) (*CoinAmount, error) {
+	if !strings.ContainsAny(s, "eE") {
+		return parseDecimalAmount(s)
+	}
+
 	f, ok := new(big.Float).SetString(s)
 	if !ok {
 		return nil, fmt.Errorf("invalid numeric string: %s", s)
@@ -77,8 +88,15 @@ func (c *CoinAmount) ToFloat() float64 {
 }
 
 func (c *CoinAmount) String() string {
-	f := new(big.Float).Quo(new(big.Float).SetInt(c.value), big.NewFloat(float64(CoinPrecision)))
-	str := f.Text('f', 8)
+	abs := new(big.Int).Abs(c.value)
+	whole, frac := new(big.Int).QuoRem(abs, big.NewInt(CoinPrecision), new(big.Int))
+
+	sign := ""
+	if c.value.Sign() < 0 {
+		sign = "-"
+	}
+
+	str := fmt.Sprintf("%s%s.%08d", sign, whole.String(), frac.Int64())
 	return strings.TrimRight(strings.TrimRight(str, "0"), ".")
 }
 
@@ -86,6 +104,49 @@ func (c *CoinAmount) Raw() *big.Int {
 	return new(big.Int).Set(c.value)
 }
 
+func (c *CoinAmount) Units() int64 {
+	return c.value.Int64()
+}
+
+func parseDecimalAmount(s string) (*CoinAmount, error) {
+	str := strings.TrimSpace(s)
+
+	negative := strings.HasPrefix(str, "-")
+	str = strings.TrimLeft(str, "+-")
+
+	whole, frac, _ := strings.Cut(str, ".")
+	if whole == "" && frac == "" {
+		return nil, fmt.Errorf("invalid numeric string: %s", s)
+	}
+
+	if len(frac) > 8 {
+		frac = frac[:8]
+	}
+	frac += strings.Repeat("0", 8-len(frac))
+
+	value, ok := new(big.Int).SetString(whole+frac, 10)
+	if !ok {
+		return nil, fmt.Errorf("invalid numeric string: %s", s)
+	}
+
+	if negative {
+		value.Neg(value)
+	}
+
+	return &CoinAmount{value: value}, nil
+}
+
+// UnitsToLegacyFloat converts base units into the float64 coin value that
+// pre-activation transactions carried.
+func UnitsToLegacyFloat(units int64) float64 {
+	v, err := strconv.ParseFloat(NewCoinAmountFromUnits(units).String(), 64)
+	if err != nil {
+		return 0
+	}
+
+	return v
+}
+
 func SumFees(totalInput, totalOutput *CoinAmount) *CoinAmount {
 	return totalInput.Sub(totalOutput)
 }
@@ -0,0 +1,44 @@
+package utils
+
+// Script types stored with synced outputs, matching the node's ScriptClass
+// names.
+const (
+	ScriptTypePubKeyHash  = "pubkeyhash"
+	ScriptTypeScriptHash  = "scripthash"
+	ScriptTypeNonStandard = "nonstandard"
+)
+
+const (
+	opDup         = 0x76
+	opEqual       = 0x87
+	opEqualVerify = 0x88
+	opHash160     = 0xa9
+	opCheckSig    = 0xac
+)
+
+// NewPayToScriptHashScript returns OP_HASH160 <scriptHash> OP_EQUAL.
+func NewPayToScriptHashScript(scriptHash []byte) []byte {
+	script := []byte{opHash160, byte(len(scriptHash))}
+	script = append(script, scriptHash...)
+
+	return append(script, opEqual)
+}
+
+// ScriptHash is the hash a pay-to-script-hash output commits to.
+func ScriptHash(script []byte) []byte {
+	return PublicKeyHash(script)
+}
+
+// ClassifyScript returns the script type of a locking script and the hash
+// its address encodes, nil for non standard scripts.
+func ClassifyScript(script []byte) (string, []byte) {
+	switch {
+	case len(script) == 25 && script[0] == opDup && script[1] == opHash160 && script[2] == 20 &&
+		script[23] == opEqualVerify && script[24] == opCheckSig:
+		return ScriptTypePubKeyHash, script[3:23]
+	case len(script) == 23 && script[0] == opHash160 && script[1] == 20 && script[22] == opEqual:
+		return ScriptTypeScriptHash, script[2:22]
+	}
+
+	return ScriptTypeNonStandard, nil
+}
@@ -10,6 +10,7 @@ import (
 
 var (
 	version        = byte(0x00)
+	scriptVersion  = byte(0x05)
 	checkSumlength = 4
 )
 
@@ -34,8 +35,21 @@ func CheckSum(data []byte) []byte {
 }
 
 func PubKeyToAddress(pubkey []byte) []byte {
-	pubHash := PublicKeyHash(pubkey)
-	versionedHash := append([]byte{version}, pubHash...)
+	return PubKeyHashToAddress(PublicKeyHash(pubkey))
+}
+
+func PubKeyHashToAddress(pubHash []byte) []byte {
+	return encodeAddress(version, pubHash)
+}
+
+// ScriptHashToAddress encodes the hash of a redeem script as a
+// pay-to-script-hash address.
+func ScriptHashToAddress(scriptHash []byte) []byte {
+	return encodeAddress(scriptVersion, scriptHash)
+}
+
+func encodeAddress(version byte, hash []byte) []byte {
+	versionedHash := append([]byte{version}, hash...)
 
 	checksum := CheckSum(versionedHash)
 
@@ -63,3 +77,21 @@ func ValidateAddress(address string) bool {
 
 	return bytes.Equal(checkSumFromHash, checkSum)
 }
+
+// IsScriptAddress reports whether address is a valid pay-to-script-hash
+// address.
+func IsScriptAddress(address string) bool {
+	if !ValidateAddress(address) {
+		return false
+	}
+
+	fullHash, err := Base58Decode(address)
+
+	return err == nil && IsScriptAddressHash(fullHash)
+}
+
+// IsScriptAddressHash reports whether fullHash, a base58 decoded address,
+// pays to a script hash.
+func IsScriptAddressHash(fullHash []byte) bool {
+	return len(fullHash) > 0 && fullHash[0] == scriptVersion
+}
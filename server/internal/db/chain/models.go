@@ -36,20 +36,23 @@ type Transaction struct {
 }
 
 type TxInput struct {
-	ID        uuid.UUID
-	TxID      string
-	InputTxID sql.NullString
-	OutIndex  int64
-	Sig       sql.NullString
-	BID       string
-	PubKey    sql.NullString
+	ID              uuid.UUID
+	TxID            string
+	InputTxID       sql.NullString
+	OutIndex        int64
+	Sig             sql.NullString
+	BID             string
+	PubKey          sql.NullString
+	UnlockingScript sql.NullString
 }
 
 type TxOutput struct {
-	ID         uuid.UUID
-	TxID       string
-	Index      int64
-	Value      string
-	BID        string
-	PubKeyHash string
+	ID            uuid.UUID
+	TxID          string
+	Index         int64
+	Value         string
+	BID           string
+	PubKeyHash    string
+	ScriptType    string
+	LockingScript sql.NullString
 }
@@ -270,17 +270,18 @@ func (q *Queries) CreateTransaction(ctx context.Context, arg CreateTransactionPa
 }
 
 const createTxInput = `-- name: CreateTxInput :one
-insert into tx_inputs (tx_id, input_tx_id, out_index, sig, b_id, pub_key)
-values ($1, $2, $3, $4, $5, $6) returning id, tx_id, input_tx_id, out_index, sig, b_id, pub_key
+insert into tx_inputs (tx_id, input_tx_id, out_index, sig, b_id, pub_key, unlocking_script)
+values ($1, $2, $3, $4, $5, $6, $7) returning id, tx_id, input_tx_id, out_index, sig, b_id, pub_key, unlocking_script
 `
 
 type CreateTxInputParams struct {
-	TxID      string
-	InputTxID sql.NullString
-	OutIndex  int64
-	Sig       sql.NullString
-	BID       string
-	PubKey    sql.NullString
+	TxID            string
+	InputTxID       sql.NullString
+	OutIndex        int64
+	Sig             sql.NullString
+	BID             string
+	PubKey          sql.NullString
+	UnlockingScript sql.NullString
 }
 
 func (q *Queries) CreateTxInput(ctx context.Context, arg CreateTxInputParams) (TxInput, error) {
@@ -291,6 +292,7 @@ func (q *Queries) CreateTxInput(ctx context.Context, arg CreateTxInputParams) (T
 		arg.Sig,
 		arg.BID,
 		arg.PubKey,
+		arg.UnlockingScript,
 	)
 	var i TxInput
 	err := row.Scan(
@@ -301,21 +303,24 @@ func (q *Queries) CreateTxInput(ctx context.Context, arg CreateTxInputParams) (T
 		&i.Sig,
 		&i.BID,
 		&i.PubKey,
+		&i.UnlockingScript,
 	)
 	return i, err
 }
 
 const createTxOutput = `-- name: CreateTxOutput :one
-insert into tx_outputs (tx_id, value, pub_key_hash, b_id, index)
-values ($1, $2, $3, $4, $5) returning id, tx_id, index, value, b_id, pub_key_hash
+insert into tx_outputs (tx_id, value, pub_key_hash, b_id, index, script_type, locking_script)
+values ($1, $2, $3, $4, $5, $6, $7) returning id, tx_id, index, value, b_id, pub_key_hash, script_type, locking_script
 `
 
 type CreateTxOutputParams struct {
-	TxID       string
-	Value      string
-	PubKeyHash string
-	BID        string
-	Index      int64
+	TxID          string
+	Value         string
+	PubKeyHash    string
+	BID           string
+	Index         int64
+	ScriptType    string
+	LockingScript sql.NullString
 }
 
 func (q *Queries) CreateTxOutput(ctx context.Context, arg CreateTxOutputParams) (TxOutput, error) {
@@ -325,6 +330,8 @@ func (q *Queries) CreateTxOutput(ctx context.Context, arg CreateTxOutputParams)
 		arg.PubKeyHash,
 		arg.BID,
 		arg.Index,
+		arg.ScriptType,
+		arg.LockingScript,
 	)
 	var i TxOutput
 	err := row.Scan(
@@ -334,6 +341,8 @@ func (q *Queries) CreateTxOutput(ctx context.Context, arg CreateTxOutputParams)
 		&i.Value,
 		&i.BID,
 		&i.PubKeyHash,
+		&i.ScriptType,
+		&i.LockingScript,
 	)
 	return i, err
 }
@@ -359,7 +368,7 @@ func (q *Queries) DeleteBlockByHeight(ctx context.Context, height int64) error {
 }
 
 const findListTxOutputByBlockID = `-- name: FindListTxOutputByBlockID :many
-select id, tx_id, index, value, b_id, pub_key_hash from tx_outputs where b_id = $1
+select id, tx_id, index, value, b_id, pub_key_hash, script_type, locking_script from tx_outputs where b_id = $1
 `
 
 func (q *Queries) FindListTxOutputByBlockID(ctx context.Context, bID string) ([]TxOutput, error) {
@@ -378,6 +387,8 @@ func (q *Queries) FindListTxOutputByBlockID(ctx context.Context, bID string) ([]
 			&i.Value,
 			&i.BID,
 			&i.PubKeyHash,
+			&i.ScriptType,
+			&i.LockingScript,
 		); err != nil {
 			return nil, err
 		}
@@ -393,7 +404,7 @@ func (q *Queries) FindListTxOutputByBlockID(ctx context.Context, bID string) ([]
 }
 
 const findTxInputByBlockID = `-- name: FindTxInputByBlockID :many
-select id, tx_id, input_tx_id, out_index, sig, b_id, pub_key from tx_inputs where b_id = $1
+select id, tx_id, input_tx_id, out_index, sig, b_id, pub_key, unlocking_script from tx_inputs where b_id = $1
 `
 
 func (q *Queries) FindTxInputByBlockID(ctx context.Context, bID string) ([]TxInput, error) {
@@ -413,6 +424,7 @@ func (q *Queries) FindTxInputByBlockID(ctx context.Context, bID string) ([]TxInp
 			&i.Sig,
 			&i.BID,
 			&i.PubKey,
+			&i.UnlockingScript,
 		); err != nil {
 			return nil, err
 		}
@@ -1063,7 +1075,7 @@ func (q *Queries) GetListTransactions(ctx context.Context, arg GetListTransactio
 }
 
 const getListTxInputByTxID = `-- name: GetListTxInputByTxID :many
-select id, tx_id, input_tx_id, out_index, sig, b_id, pub_key from tx_inputs where tx_id = $1
+select id, tx_id, input_tx_id, out_index, sig, b_id, pub_key, unlocking_script from tx_inputs where tx_id = $1
 `
 
 func (q *Queries) GetListTxInputByTxID(ctx context.Context, txID string) ([]TxInput, error) {
@@ -1083,6 +1095,7 @@ func (q *Queries) GetListTxInputByTxID(ctx context.Context, txID string) ([]TxIn
 			&i.Sig,
 			&i.BID,
 			&i.PubKey,
+			&i.UnlockingScript,
 		); err != nil {
 			return nil, err
 		}
@@ -1098,7 +1111,7 @@ func (q *Queries) GetListTxInputByTxID(ctx context.Context, txID string) ([]TxIn
 }
 
 const getListTxOutputByTxId = `-- name: GetListTxOutputByTxId :many
-select id, tx_id, index, value, b_id, pub_key_hash from tx_outputs where tx_id = $1
+select id, tx_id, index, value, b_id, pub_key_hash, script_type, locking_script from tx_outputs where tx_id = $1
 `
 
 func (q *Queries) GetListTxOutputByTxId(ctx context.Context, txID string) ([]TxOutput, error) {
@@ -1117,6 +1130,8 @@ func (q *Queries) GetListTxOutputByTxId(ctx context.Context, txID string) ([]TxO
 			&i.Value,
 			&i.BID,
 			&i.PubKeyHash,
+			&i.ScriptType,
+			&i.LockingScript,
 		); err != nil {
 			return nil, err
 		}
@@ -1351,7 +1366,7 @@ func (q *Queries) GetTransactionByTxID(ctx context.Context, txID string) (Transa
 }
 
 const getTxInputByTxID = `-- name: GetTxInputByTxID :one
-select id, tx_id, input_tx_id, out_index, sig, b_id, pub_key from tx_inputs where tx_id = $1 limit 1
+select id, tx_id, input_tx_id, out_index, sig, b_id, pub_key, unlocking_script from tx_inputs where tx_id = $1 limit 1
 `
 
 func (q *Queries) GetTxInputByTxID(ctx context.Context, txID string) (TxInput, error) {
@@ -1365,12 +1380,13 @@ func (q *Queries) GetTxInputByTxID(ctx context.Context, txID string) (TxInput, e
 		&i.Sig,
 		&i.BID,
 		&i.PubKey,
+		&i.UnlockingScript,
 	)
 	return i, err
 }
 
 const getTxOutputByTxID = `-- name: GetTxOutputByTxID :one
-select id, tx_id, index, value, b_id, pub_key_hash from tx_outputs where tx_id = $1 limit 1
+select id, tx_id, index, value, b_id, pub_key_hash, script_type, locking_script from tx_outputs where tx_id = $1 limit 1
 `
 
 func (q *Queries) GetTxOutputByTxID(ctx context.Context, txID string) (TxOutput, error) {
@@ -1383,12 +1399,14 @@ func (q *Queries) GetTxOutputByTxID(ctx context.Context, txID string) (TxOutput,
 		&i.Value,
 		&i.BID,
 		&i.PubKeyHash,
+		&i.ScriptType,
+		&i.LockingScript,
 	)
 	return i, err
 }
 
 const getTxOutputByTxIDAndIndex = `-- name: GetTxOutputByTxIDAndIndex :one
-select id, tx_id, index, value, b_id, pub_key_hash from tx_outputs where tx_id = $1 and index = $2 limit 1
+select id, tx_id, index, value, b_id, pub_key_hash, script_type, locking_script from tx_outputs where tx_id = $1 and index = $2 limit 1
 `
 
 type GetTxOutputByTxIDAndIndexParams struct {
@@ -1406,6 +1424,8 @@ func (q *Queries) GetTxOutputByTxIDAndIndex(ctx context.Context, arg GetTxOutput
 		&i.Value,
 		&i.BID,
 		&i.PubKeyHash,
+		&i.ScriptType,
+		&i.LockingScript,
 	)
 	return i, err
 }
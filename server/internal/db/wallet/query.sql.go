@@ -99,6 +99,22 @@ func (q *Queries) DecreaseWalletBalance(ctx context.Context, arg DecreaseWalletB
 	return err
 }
 
+const decreaseWalletBalanceByPubKeyHash = `-- name: DecreaseWalletBalanceByPubKeyHash :exec
+UPDATE wallets
+SET balance = balance - $1
+WHERE public_key_hash = $2 AND balance >= $1
+`
+
+type DecreaseWalletBalanceByPubKeyHashParams struct {
+	Balance       string
+	PublicKeyHash string
+}
+
+func (q *Queries) DecreaseWalletBalanceByPubKeyHash(ctx context.Context, arg DecreaseWalletBalanceByPubKeyHashParams) error {
+	_, err := q.db.ExecContext(ctx, decreaseWalletBalanceByPubKeyHash, arg.Balance, arg.PublicKeyHash)
+	return err
+}
+
 const existsWalletByAddrAndPubkey = `-- name: ExistsWalletByAddrAndPubkey :one
 SELECT EXISTS(SELECT 1 FROM wallets WHERE address = $1 AND public_key = $2)
 `
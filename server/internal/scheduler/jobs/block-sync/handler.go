@@ -17,9 +17,7 @@ import (
 	"database/sql"
 	"encoding/hex"
 	"errors"
-	"fmt"
 	"math/big"
-	"strconv"
 	"time"
 
 	log "github.com/sirupsen/logrus"
@@ -48,12 +46,14 @@ func (j *jobBlockSync) handleCreateUtxo(block *chain.Block, sqlTx *sql.Tx) error
 		}
 
 		for idx, out := range tx.Outputs {
-			log.Debugf("handleCreateUtxo: Creating UTXO for tx=%s output idx=%d value=%f pubKeyHash=%s in block=%s", tx.ID, idx, out.Value, out.PubKeyHash, block.Hash)
+			value := utils.NewCoinAmountFromUnits(out.Value).String()
+			_, pubKeyHash := outputAddressHash(out)
+			log.Debugf("handleCreateUtxo: Creating UTXO for tx=%s output idx=%d value=%s pubKeyHash=%s in block=%s", tx.ID, idx, value, pubKeyHash, block.Hash)
 			params := dbutxo.CreateUTXOParams{
 				TxID:        tx.ID,
 				OutputIndex: int64(idx),
-				Value:       fmt.Sprintf("%f", out.Value),
-				PubKeyHash:  out.PubKeyHash,
+				Value:       value,
+				PubKeyHash:  pubKeyHash,
 				BlockID:     block.Hash,
 			}
 
@@ -61,7 +61,7 @@ func (j *jobBlockSync) handleCreateUtxo(block *chain.Block, sqlTx *sql.Tx) error
 				log.Errorf("handleCreateUtxo: Failed to create UTXO for tx=%s output idx=%d in block=%s: %v", tx.ID, idx, block.Hash, err)
 				return err
 			} else {
-				log.Infof("handleCreateUtxo: New UTXO created TxID=%s OutputIndex=%d PubKeyHash=%s Value=%s in block=%s: %v", tx.ID, idx, out.PubKeyHash, utxo.Value, block.Hash, utxo)
+				log.Infof("handleCreateUtxo: New UTXO created TxID=%s OutputIndex=%d PubKeyHash=%s Value=%s in block=%s: %v", tx.ID, idx, pubKeyHash, utxo.Value, block.Hash, utxo)
 			}
 		}
 	}
@@ -82,12 +82,13 @@ func (j *jobBlockSync) handleCreateInput(ins []dto.TxInput, b_id, txHash string,
 		}
 
 		args := dbchain.CreateTxInputParams{
-			TxID:      txHash,
-			InputTxID: helpers.StringToNullString(in.ID),
-			OutIndex:  in.Out,
-			Sig:       helpers.StringToNullString(in.Signature),
-			BID:       b_id,
-			PubKey:    helpers.StringToNullString(in.PubKey),
+			TxID:            txHash,
+			InputTxID:       helpers.StringToNullString(in.ID),
+			OutIndex:        in.Out,
+			Sig:             helpers.StringToNullString(in.Signature),
+			BID:             b_id,
+			PubKey:          helpers.StringToNullString(in.PubKey),
+			UnlockingScript: helpers.StringToNullString(in.UnlockingScript),
 		}
 		txIn, err := j.dbTrans.CreateTxInput(ctx, args, tx)
 		if err != nil {
@@ -159,6 +160,31 @@ func (j *jobBlockSync) handleCreateInput(ins []dto.TxInput, b_id, txHash string,
 				return err
 			}
 			log.Infof("handleCreateInput: Successfully decreased wallet balance for wallet=%s by %s in tx=%s block=%s", wallet.Address.String, txoutput.Value, txHash, b_id)
+		} else if in.UnlockingScript != "" {
+			// Script inputs carry no key, the output they spend tells whose
+			// balance decreases.
+			txoutput, err := j.dbTrans.GetTxOutputByTxIDAndIndex(ctx, dbchain.GetTxOutputByTxIDAndIndexParams{
+				TxID:  in.ID,
+				Index: in.Out,
+			}, tx)
+			if err != nil {
+				log.Errorf("handleCreateInput: Failed to get output for script input TxID=%s Index=%d in tx=%s block=%s: %v", in.ID, in.Out, txHash, b_id, err)
+				return err
+			}
+			if txoutput.PubKeyHash == "" {
+				continue
+			}
+
+			log.Debugf("handleCreateInput: Decreasing wallet balance for PubKeyHash=%s by value=%s for script input in tx=%s block=%s", txoutput.PubKeyHash, txoutput.Value, txHash, b_id)
+			err = j.dbWallet.DecreaseWalletBalanceByPubKeyHash(ctx, dbwallet.DecreaseWalletBalanceByPubKeyHashParams{
+				Balance:       txoutput.Value,
+				PublicKeyHash: txoutput.PubKeyHash,
+			}, tx)
+			if err != nil {
+				log.Errorf("handleCreateInput: Failed to decrease wallet balance for PubKeyHash=%s in tx=%s block=%s by %s: %v", txoutput.PubKeyHash, txHash, b_id, txoutput.Value, err)
+				return err
+			}
+			log.Infof("handleCreateInput: Successfully decreased wallet balance for PubKeyHash=%s by %s in tx=%s block=%s", txoutput.PubKeyHash, txoutput.Value, txHash, b_id)
 		}
 	}
 
@@ -170,17 +196,52 @@ func (j *jobBlockSync) handleCreateOutput(outs []dto.TxOutput, b_id, txHash stri
 	ctx := context.Background()
 	log.Infof("handleCreateOutput: Starting output creation for tx=%s block=%s (%d outputs)", txHash, b_id, len(outs))
 	for Index, out := range outs {
-		log.Debugf("handleCreateOutput: Processing output idx=%d value=%f pubKeyHash=%s for tx=%s block=%s", Index, out.Value, out.PubKeyHash, txHash, b_id)
-		wallet, err := j.dbWallet.GetWalletByPubKeyHash(ctx, out.PubKeyHash, tx)
+		value := utils.NewCoinAmountFromUnits(out.Value).String()
+		scriptType, pubKeyHash := outputAddressHash(out)
+		log.Debugf("handleCreateOutput: Processing output idx=%d value=%s type=%s pubKeyHash=%s for tx=%s block=%s", Index, value, scriptType, pubKeyHash, txHash, b_id)
+
+		args := dbchain.CreateTxOutputParams{
+			TxID:          txHash,
+			Value:         value,
+			PubKeyHash:    pubKeyHash,
+			Index:         int64(Index),
+			BID:           b_id,
+			ScriptType:    scriptType,
+			LockingScript: helpers.StringToNullString(out.LockingScript),
+		}
+
+		txout, err := j.dbTrans.CreateTxOutput(ctx, args, tx)
+		if err != nil {
+			log.Errorf("handleCreateOutput: Failed to create tx output for tx=%s index=%d block=%s: %v", txHash, Index, b_id, err)
+			return err
+		}
+
+		log.Infof("handleCreateOutput: Created TxOutput TxID=%s Value=%s PubKeyHash=%s ScriptType=%s Index=%d BID=%s: %v", txHash, txout.Value, txout.PubKeyHash, txout.ScriptType, txout.Index, b_id, txout)
+
+		// Non standard scripts pay no address, no wallet holds them.
+		if pubKeyHash == "" {
+			continue
+		}
+
+		wallet, err := j.dbWallet.GetWalletByPubKeyHash(ctx, pubKeyHash, tx)
 		if err != nil && errors.Is(err, sql.ErrNoRows) {
-			log.Infof("handleCreateOutput: No wallet found for PubKeyHash=%s in tx=%s output idx=%d block=%s, creating new wallet", out.PubKeyHash, txHash, Index, b_id)
+			log.Infof("handleCreateOutput: No wallet found for PubKeyHash=%s in tx=%s output idx=%d block=%s, creating new wallet", pubKeyHash, txHash, Index, b_id)
+
+			// Key hash wallets learn their address once they spend, script
+			// hash wallets never have a key.
+			address := ""
+			if scriptType == utils.ScriptTypeScriptHash {
+				hash, _ := hex.DecodeString(pubKeyHash)
+				address = string(utils.ScriptHashToAddress(hash))
+			}
+
 			newWallet, err := j.dbWallet.CreateWallet(
 				ctx,
 				// Set null
 				dbwallet.CreateWalletParams{
-					Address:       helpers.StringToNullString(""),
+					Address:       helpers.StringToNullString(address),
 					PublicKey:     helpers.StringToNullString(""),
-					PublicKeyHash: out.PubKeyHash,
+					PublicKeyHash: pubKeyHash,
 					Balance:       "0",
 					CreateAt: sql.NullTime{
 						Time:  time.Now(),
@@ -194,47 +255,31 @@ func (j *jobBlockSync) handleCreateOutput(outs []dto.TxOutput, b_id, txHash stri
 				tx,
 			)
 			if err != nil {
-				log.Errorf("handleCreateOutput: Failed to create wallet for PubKeyHash=%s in tx=%s output idx=%d block=%s: %v", out.PubKeyHash, txHash, Index, b_id, err)
+				log.Errorf("handleCreateOutput: Failed to create wallet for PubKeyHash=%s in tx=%s output idx=%d block=%s: %v", pubKeyHash, txHash, Index, b_id, err)
 				return err
 			}
 			wallet = &newWallet
-			log.Infof("handleCreateOutput: Created new wallet PublicKeyHash=%s for output idx=%d in tx=%s block=%s", out.PubKeyHash, Index, txHash, b_id)
+			log.Infof("handleCreateOutput: Created new wallet PublicKeyHash=%s for output idx=%d in tx=%s block=%s", pubKeyHash, Index, txHash, b_id)
 		} else if err != nil {
-			log.Errorf("handleCreateOutput: Failed to get wallet for PubKeyHash=%s in tx=%s output idx=%d block=%s: %v", out.PubKeyHash, txHash, Index, b_id, err)
-			return err
-		}
-
-		args := dbchain.CreateTxOutputParams{
-			TxID:       txHash,
-			Value:      fmt.Sprintf("%f", out.Value),
-			PubKeyHash: out.PubKeyHash,
-			Index:      int64(Index),
-			BID:        b_id,
-		}
-
-		txout, err := j.dbTrans.CreateTxOutput(ctx, args, tx)
-		if err != nil {
-			log.Errorf("handleCreateOutput: Failed to create tx output for tx=%s index=%d block=%s: %v", txHash, Index, b_id, err)
+			log.Errorf("handleCreateOutput: Failed to get wallet for PubKeyHash=%s in tx=%s output idx=%d block=%s: %v", pubKeyHash, txHash, Index, b_id, err)
 			return err
 		}
 
-		log.Infof("handleCreateOutput: Created TxOutput TxID=%s Value=%s PubKeyHash=%s Index=%d BID=%s: %v", txHash, txout.Value, txout.PubKeyHash, txout.Index, b_id, txout)
-
-		log.Debugf("handleCreateOutput: Increasing wallet balance for wallet=%s by value=%f for output idx=%d in tx=%s block=%s", wallet.Address.String, out.Value, Index, txHash, b_id)
+		log.Debugf("handleCreateOutput: Increasing wallet balance for wallet=%s by value=%s for output idx=%d in tx=%s block=%s", wallet.Address.String, value, Index, txHash, b_id)
 		err = j.dbWallet.IncreaseWalletBalanceByPubKeyHash(
 			ctx,
 			dbwallet.IncreaseWalletBalanceByPubKeyHashParams{
-				Balance:       fmt.Sprintf("%f", out.Value),
+				Balance:       value,
 				PublicKeyHash: wallet.PublicKeyHash,
 			},
 			tx,
 		)
 
 		if err != nil {
-			log.Errorf("handleCreateOutput: Failed to increase wallet balance for PubKeyHash=%s wallet=%s in tx=%s output idx=%d block=%s by %f: %v", out.PubKeyHash, wallet.Address.String, txHash, Index, b_id, out.Value, err)
+			log.Errorf("handleCreateOutput: Failed to increase wallet balance for PubKeyHash=%s wallet=%s in tx=%s output idx=%d block=%s by %s: %v", pubKeyHash, wallet.Address.String, txHash, Index, b_id, value, err)
 			return err
 		}
-		log.Infof("handleCreateOutput: Successfully increased wallet balance for wallet=%s by %f in tx=%s output idx=%d block=%s", wallet.Address.String, out.Value, txHash, Index, b_id)
+		log.Infof("handleCreateOutput: Successfully increased wallet balance for wallet=%s by %s in tx=%s output idx=%d block=%s", wallet.Address.String, value, txHash, Index, b_id)
 	}
 
 	log.Infof("handleCreateOutput: Completed output creation for tx=%s block=%s", txHash, b_id)
@@ -247,19 +292,21 @@ func (j *jobBlockSync) handleCreateTransactions(ctx context.Context, txs []*dto.
 		log.Debugf("handleCreateTransactions: Processing tx=%s in block=%s (isMiner=%v, inputs=%d, outputs=%d)", tx.ID, block.Hash, j.isTxMiner(tx), len(tx.Inputs), len(tx.Outputs))
 		fromHash := ""
 		toHash := ""
-		amount := utils.NewCoinAmountFromFloat(0.0)
-		fee := utils.NewCoinAmountFromFloat(0.0)
+		amount := utils.ZeroAmount()
+		fee := utils.ZeroAmount()
 
 		if !j.isTxMiner(tx) {
-			pubKeyBytes, err := hex.DecodeString(tx.Inputs[0].PubKey)
-			if err != nil {
-				log.Errorf("handleCreateTransactions: Failed to decode pubkey for first input in tx=%s block=%s: %v", tx.ID, block.Hash, err)
-				return err
+			if tx.Inputs[0].PubKey != "" {
+				pubKeyBytes, err := hex.DecodeString(tx.Inputs[0].PubKey)
+				if err != nil {
+					log.Errorf("handleCreateTransactions: Failed to decode pubkey for first input in tx=%s block=%s: %v", tx.ID, block.Hash, err)
+					return err
+				}
+				fromHash = hex.EncodeToString(utils.PublicKeyHash(pubKeyBytes))
+				log.Debugf("handleCreateTransactions: Set fromHash=%s for tx=%s block=%s", fromHash, tx.ID, block.Hash)
 			}
-			fromHash = hex.EncodeToString(utils.PublicKeyHash(pubKeyBytes))
-			log.Debugf("handleCreateTransactions: Set fromHash=%s for tx=%s block=%s", fromHash, tx.ID, block.Hash)
 
-			totalInput := utils.NewCoinAmountFromFloat(0.0)
+			totalInput := utils.ZeroAmount()
 
 			for _, in := range tx.Inputs {
 				utxo, err := j.dbUtxo.GetUTXOByTxIDAndOut(ctx, dbutxo.GetUTXOByTxIDAndOutParams{
@@ -272,29 +319,36 @@ func (j *jobBlockSync) handleCreateTransactions(ctx context.Context, txs []*dto.
 					return err
 				}
 
-				value, err := strconv.ParseFloat(utxo.Value, 64)
+				value, err := utils.NewCoinAmountFromString(utxo.Value)
 				if err != nil {
 					log.Errorf("handleCreateTransactions: Failed to parse UTXO value=%s for input TxID=%s Out=%d in tx=%s block=%s: %v", utxo.Value, in.ID, in.Out, tx.ID, block.Hash, err)
 					return err
 				}
 
-				totalInput = totalInput.Add(utils.NewCoinAmountFromFloat(value))
-				log.Debugf("handleCreateTransactions: Added input value=%f for TxID=%s Out=%d in tx=%s block=%s (totalInput=%f)", value, in.ID, in.Out, tx.ID, block.Hash, totalInput)
+				totalInput = totalInput.Add(value)
+
+				// Script inputs carry no key, the first one is from the
+				// address of the output it spends.
+				if fromHash == "" {
+					fromHash = utxo.PubKeyHash
+				}
+				log.Debugf("handleCreateTransactions: Added input value=%s for TxID=%s Out=%d in tx=%s block=%s (totalInput=%s)", value, in.ID, in.Out, tx.ID, block.Hash, totalInput)
 			}
 
-			totalOutput := utils.NewCoinAmountFromFloat(0.0)
+			totalOutput := utils.ZeroAmount()
 
 			for _, out := range tx.Outputs {
-				if out.PubKeyHash != fromHash {
-					toHash = out.PubKeyHash
-					amount = amount.Add(utils.NewCoinAmountFromFloat(out.Value))
+				_, pubKeyHash := outputAddressHash(out)
+				if pubKeyHash != fromHash {
+					toHash = pubKeyHash
+					amount = amount.Add(utils.NewCoinAmountFromUnits(out.Value))
 				}
-				totalOutput = totalOutput.Add(utils.NewCoinAmountFromFloat(out.Value))
-				log.Debugf("handleCreateTransactions: Processed output value=%f pubKeyHash=%s (toHash=%s amount=%f totalOutput=%f) in tx=%s block=%s", out.Value, out.PubKeyHash, toHash, amount, totalOutput, tx.ID, block.Hash)
+				totalOutput = totalOutput.Add(utils.NewCoinAmountFromUnits(out.Value))
+				log.Debugf("handleCreateTransactions: Processed output value=%d pubKeyHash=%s (toHash=%s amount=%s totalOutput=%s) in tx=%s block=%s", out.Value, pubKeyHash, toHash, amount, totalOutput, tx.ID, block.Hash)
 			}
 
 			fee = utils.SumFees(totalInput, totalOutput)
-			log.Infof("handleCreateTransactions: Calculated for tx=%s block=%s: from=%s to=%s amount=%f fee=%f totalInput=%f totalOutput=%f", tx.ID, block.Hash, fromHash, toHash, amount, fee, totalInput, totalOutput)
+			log.Infof("handleCreateTransactions: Calculated for tx=%s block=%s: from=%s to=%s amount=%s fee=%s totalInput=%s totalOutput=%s", tx.ID, block.Hash, fromHash, toHash, amount, fee, totalInput, totalOutput)
 		} else {
 			log.Infof("handleCreateTransactions: Skipping fee/amount calc for miner tx=%s in block=%s", tx.ID, block.Hash)
 		}
@@ -304,8 +358,8 @@ func (j *jobBlockSync) handleCreateTransactions(ctx context.Context, txs []*dto.
 			BID:      block.Hash,
 			Fromhash: helpers.StringToNullString(fromHash),
 			Tohash:   helpers.StringToNullString(toHash),
-			Amount:   helpers.FloatToNullString(amount.ToFloat()),
-			Fee:      helpers.FloatToNullString(fee.ToFloat()),
+			Amount:   helpers.StringToNullString(amount.String()),
+			Fee:      helpers.StringToNullString(fee.String()),
 			CreateAt: block.Timestamp,
 		}
 
@@ -314,7 +368,7 @@ func (j *jobBlockSync) handleCreateTransactions(ctx context.Context, txs []*dto.
 			log.Errorf("handleCreateTransactions: Failed to create transaction tx=%s for block=%s: %v", tx.ID, block.Hash, err)
 			return err
 		}
-		log.Infof("handleCreateTransactions: Created Transaction tx=%s BID=%s From=%s To=%s Amount=%f Fee=%f CreateAt=%d: %v", tx.ID, block.Hash, fromHash, toHash, amount, fee, block.Timestamp, transaction)
+		log.Infof("handleCreateTransactions: Created Transaction tx=%s BID=%s From=%s To=%s Amount=%s Fee=%s CreateAt=%d: %v", tx.ID, block.Hash, fromHash, toHash, amount, fee, block.Timestamp, transaction)
 
 		err = j.handleCreateInput(tx.Inputs, block.Hash, tx.ID, sqlTx)
 		if err != nil {
@@ -409,6 +463,24 @@ func (j *jobBlockSync) isGenesisBlock(block *chain.Block) bool {
 func (j *jobBlockSync) isTxMiner(tx *dto.Transaction) bool {
 	return len(tx.Inputs) == 1 && len(tx.Inputs[0].ID) == 0 && tx.Inputs[0].Out == -1
 }
+
+// outputAddressHash returns the script type of out and the hex encoded hash
+// its address encodes, the public key hash of legacy outputs. It is empty
+// for non standard scripts.
+func outputAddressHash(out dto.TxOutput) (string, string) {
+	if out.LockingScript == "" {
+		return utils.ScriptTypePubKeyHash, out.PubKeyHash
+	}
+
+	script, err := hex.DecodeString(out.LockingScript)
+	if err != nil {
+		return utils.ScriptTypeNonStandard, ""
+	}
+
+	scriptType, hash := utils.ClassifyScript(script)
+
+	return scriptType, hex.EncodeToString(hash)
+}
 func (j *jobBlockSync) handleReorganizationBlocks(tx *sql.Tx) error {
 	log.Info("🔁 [Reorg] Starting chain reorganization...")
 	ctx := context.Background()
@@ -52,6 +52,34 @@ func (j *jobSendTx) sendTransactionPending() error {
 	log.Info(res.Message)
 	log.Infof("List Transaction Sent: %s", strings.Join(res.ListTxs, "\n"))
 
+	return j.markReplaced(ctx, res.Replaced)
+}
+
+// markReplaced moves the pending transactions the node replaced by fee to
+// the replaced status. Nodes only do so with full RBF turned on, any
+// unconfirmed transaction can be replaced then.
+func (j *jobSendTx) markReplaced(ctx context.Context, replaced []transaction.RPCReplacedTx) error {
+	if len(replaced) == 0 {
+		return nil
+	}
+
+	txIDs := make([]string, 0, len(replaced))
+	for _, r := range replaced {
+		log.Infof("Transaction %s replaced by %s (full RBF)", r.TxID, r.ReplacedBy)
+		txIDs = append(txIDs, r.TxID)
+	}
+
+	_, err := j.dbTrans.UpdatePendingTxsStatus(ctx, dbPendingTx.UpdatePendingTxsStatusParams{
+		NewStatus: string(constants.TxStatusReplaced),
+		TxIds:     txIDs,
+		OldStatus: []string{
+			string(constants.TxStatusPending),
+		},
+	}, nil)
+	if err != nil {
+		return fmt.Errorf("mark replaced transactions: %w", err)
+	}
+
 	return nil
 }
 
@@ -0,0 +1,4 @@
+ALTER TABLE tx_outputs DROP COLUMN IF EXISTS locking_script;
+ALTER TABLE tx_outputs DROP COLUMN IF EXISTS script_type;
+
+ALTER TABLE tx_inputs DROP COLUMN IF EXISTS unlocking_script;
\ No newline at end of file
@@ -0,0 +1,4 @@
+ALTER TABLE tx_inputs ADD COLUMN IF NOT EXISTS unlocking_script TEXT;
+
+ALTER TABLE tx_outputs ADD COLUMN IF NOT EXISTS script_type VARCHAR(16) NOT NULL DEFAULT 'pubkeyhash';
+ALTER TABLE tx_outputs ADD COLUMN IF NOT EXISTS locking_script TEXT;
\ No newline at end of file